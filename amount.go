@@ -0,0 +1,153 @@
+package blnkgo
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ToPreciseAmount converts amount to minor units for the given precision
+// (e.g. 12.34 with precision 100 is 1234). The float is converted through its
+// shortest decimal representation so values such as 0.1 stay exact; anything
+// beyond the precision is rounded half away from zero.
+func ToPreciseAmount(amount float64, precision int64) *big.Int {
+	if precision <= 0 {
+		precision = 1
+	}
+	value, ok := new(big.Rat).SetString(strconv.FormatFloat(amount, 'f', -1, 64))
+	if !ok {
+		return new(big.Int)
+	}
+	value.Mul(value, new(big.Rat).SetInt64(precision))
+	return RoundRat(value, RoundHalfUp)
+}
+
+// RoundingMode decides how RoundRat rounds a value that is not whole.
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds to the nearest unit, half away from zero.
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds to the nearest unit, half to even (banker's rounding).
+	RoundHalfEven
+	// RoundDown truncates towards zero.
+	RoundDown
+	// RoundUp rounds away from zero.
+	RoundUp
+)
+
+// RoundRat rounds r to an integer with the given mode.
+func RoundRat(r *big.Rat, mode RoundingMode) *big.Int {
+	quo, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Sign() == 0 {
+		return quo
+	}
+	away := func() *big.Int {
+		if r.Sign() < 0 {
+			return quo.Sub(quo, big.NewInt(1))
+		}
+		return quo.Add(quo, big.NewInt(1))
+	}
+
+	switch mode {
+	case RoundDown:
+		return quo
+	case RoundUp:
+		return away()
+	}
+	twice := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2))
+	switch cmp := twice.Cmp(r.Denom()); {
+	case cmp > 0:
+		return away()
+	case cmp == 0 && (mode == RoundHalfUp || quo.Bit(0) == 1):
+		return away()
+	}
+	return quo
+}
+
+// ValueOrZero returns a copy of v, or zero if v is nil, for amounts that are
+// missing from a response.
+func ValueOrZero(v *big.Int) *big.Int {
+	if v == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(v)
+}
+
+// PrecisionDecimals returns the number of decimal places implied by precision,
+// e.g. 2 for 100. Precisions that are not powers of ten return -1.
+func PrecisionDecimals(precision int64) int {
+	if precision <= 1 {
+		return 0
+	}
+	decimals := 0
+	for precision > 1 {
+		if precision%10 != 0 {
+			return -1
+		}
+		precision /= 10
+		decimals++
+	}
+	return decimals
+}
+
+// FormatPreciseAmount formats an amount in minor units as a plain decimal
+// string, e.g. 1234 with precision 100 is "12.34".
+func FormatPreciseAmount(amount *big.Int, precision int64) string {
+	if amount == nil {
+		amount = new(big.Int)
+	}
+	if precision <= 1 {
+		return amount.String()
+	}
+	decimals := PrecisionDecimals(precision)
+	value := new(big.Rat).SetFrac(amount, big.NewInt(precision))
+	if decimals < 0 {
+		return strings.TrimRight(strings.TrimRight(value.FloatString(18), "0"), ".")
+	}
+	return value.FloatString(decimals)
+}
+
+// ParsePreciseAmount parses a decimal string such as "12.34" into minor units
+// for the given precision. It fails if the value has more decimals than the
+// precision can hold.
+func ParsePreciseAmount(amount string, precision int64) (*big.Int, bool) {
+	if precision <= 0 {
+		precision = 1
+	}
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok {
+		return nil, false
+	}
+	value.Mul(value, new(big.Rat).SetInt64(precision))
+	if !value.IsInt() {
+		return nil, false
+	}
+	return new(big.Int).Set(value.Num()), true
+}
+
+// RescalePreciseAmount converts an amount in minor units from one precision to
+// another, e.g. when a transaction recorded with precision 100 is applied to a
+// balance with precision 1000. Converting to a lower precision truncates.
+func RescalePreciseAmount(amount *big.Int, from, to int64) *big.Int {
+	if from <= 0 {
+		from = 1
+	}
+	if to <= 0 {
+		to = 1
+	}
+	if from == to {
+		return amount
+	}
+	scaled := new(big.Int).Mul(amount, big.NewInt(to))
+	return scaled.Quo(scaled, big.NewInt(from))
+}
+
+// PreciseValue returns the exact amount of the transaction in minor units,
+// preferring PreciseAmount over converting Amount with Precision.
+func (t ParentTransaction) PreciseValue() *big.Int {
+	if t.PreciseAmount != nil && t.PreciseAmount.Sign() != 0 {
+		return new(big.Int).Set(t.PreciseAmount)
+	}
+	return ToPreciseAmount(t.Amount, t.Precision)
+}
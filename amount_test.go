@@ -0,0 +1,88 @@
+package blnkgo_test
+
+import (
+	"math/big"
+	"testing"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToPreciseAmount(t *testing.T) {
+	tests := []struct {
+		amount    float64
+		precision int64
+		expected  int64
+	}{
+		{amount: 12.34, precision: 100, expected: 1234},
+		{amount: 0.1, precision: 100, expected: 10},
+		{amount: 1.005, precision: 100, expected: 101},
+		{amount: -1.005, precision: 100, expected: -101},
+		{amount: 1000, precision: 0, expected: 1000},
+		{amount: 0.29, precision: 100, expected: 29},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, big.NewInt(tt.expected), blnkgo.ToPreciseAmount(tt.amount, tt.precision), "%v with precision %d", tt.amount, tt.precision)
+	}
+}
+
+func TestFormatPreciseAmount(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	assert.Equal(t, "12.34", blnkgo.FormatPreciseAmount(big.NewInt(1234), 100))
+	assert.Equal(t, "-0.05", blnkgo.FormatPreciseAmount(big.NewInt(-5), 100))
+	assert.Equal(t, "1234", blnkgo.FormatPreciseAmount(big.NewInt(1234), 1))
+	assert.Equal(t, "0.00", blnkgo.FormatPreciseAmount(nil, 100))
+	assert.Equal(t, "1234567890123456789012345678.90", blnkgo.FormatPreciseAmount(huge, 100))
+}
+
+func TestParsePreciseAmount(t *testing.T) {
+	value, ok := blnkgo.ParsePreciseAmount("12.34", 100)
+	assert.True(t, ok)
+	assert.Equal(t, big.NewInt(1234), value)
+
+	_, ok = blnkgo.ParsePreciseAmount("12.345", 100)
+	assert.False(t, ok)
+
+	_, ok = blnkgo.ParsePreciseAmount("abc", 100)
+	assert.False(t, ok)
+}
+
+func TestParentTransaction_PreciseValue(t *testing.T) {
+	withPrecise := blnkgo.ParentTransaction{Amount: 10, Precision: 100, PreciseAmount: big.NewInt(1001)}
+	assert.Equal(t, big.NewInt(1001), withPrecise.PreciseValue())
+
+	withAmount := blnkgo.ParentTransaction{Amount: 10.5, Precision: 100}
+	assert.Equal(t, big.NewInt(1050), withAmount.PreciseValue())
+}
+
+func TestRoundRat(t *testing.T) {
+	tests := []struct {
+		value    *big.Rat
+		mode     blnkgo.RoundingMode
+		expected int64
+	}{
+		{big.NewRat(5, 2), blnkgo.RoundHalfUp, 3},
+		{big.NewRat(-5, 2), blnkgo.RoundHalfUp, -3},
+		{big.NewRat(5, 2), blnkgo.RoundHalfEven, 2},
+		{big.NewRat(7, 2), blnkgo.RoundHalfEven, 4},
+		{big.NewRat(-7, 2), blnkgo.RoundHalfEven, -4},
+		{big.NewRat(29, 10), blnkgo.RoundDown, 2},
+		{big.NewRat(21, 10), blnkgo.RoundUp, 3},
+		{big.NewRat(-21, 10), blnkgo.RoundUp, -3},
+		{big.NewRat(26, 10), blnkgo.RoundHalfEven, 3},
+		{big.NewRat(4, 1), blnkgo.RoundUp, 4},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, big.NewInt(tt.expected), blnkgo.RoundRat(tt.value, tt.mode), "%s mode %d", tt.value, tt.mode)
+	}
+}
+
+func TestValueOrZero(t *testing.T) {
+	assert.Equal(t, big.NewInt(0), blnkgo.ValueOrZero(nil))
+	v := big.NewInt(7)
+	copied := blnkgo.ValueOrZero(v)
+	copied.SetInt64(8)
+	assert.Equal(t, big.NewInt(7), v, "the value is copied")
+}
@@ -0,0 +1,165 @@
+package blnkgo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Blnk increments LedgerBalance.Version whenever a transaction changes a
+// balance, but not when its metadata changes. The operations below read the
+// balance right before writing and fail with a *VersionConflictError if it
+// moved on. Blnk has no conditional write endpoint, so the read and the write
+// are separate requests and a change landing between them is not detected.
+// The check narrows the race rather than closing it, which their names say.
+
+// VersionConflictError is returned when a balance is no longer at the version
+// an operation expected.
+type VersionConflictError struct {
+	BalanceID string
+	Expected  int64
+	Actual    int64
+	// MetaDataChanged is set when the version matched but the metadata did
+	// not, since metadata updates do not change the version.
+	MetaDataChanged bool
+}
+
+func (e *VersionConflictError) Error() string {
+	if e.MetaDataChanged {
+		return fmt.Sprintf("balance %s metadata changed since version %d was read", e.BalanceID, e.Expected)
+	}
+	return fmt.Sprintf("balance %s is at version %d, expected %d", e.BalanceID, e.Actual, e.Expected)
+}
+
+// IsVersionConflict reports whether err is or wraps a *VersionConflictError.
+func IsVersionConflict(err error) bool {
+	var conflict *VersionConflictError
+	return errors.As(err, &conflict)
+}
+
+// precheckBalanceVersion fetches the balance and fails if it is not at
+// version. If metaData is not nil the balance's metadata must also still equal
+// it. Nothing stops the balance changing after it returns.
+func precheckBalanceVersion(client ClientInterface, balanceID string, version int64, metaData map[string]interface{}) (*LedgerBalance, error) {
+	balance, _, err := NewLedgerBalanceService(client).Get(balanceID)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Version != version {
+		return nil, &VersionConflictError{BalanceID: balanceID, Expected: version, Actual: balance.Version}
+	}
+	if metaData != nil && !sameMetaData(balance.MetaData, metaData) {
+		return nil, &VersionConflictError{BalanceID: balanceID, Expected: version, Actual: balance.Version, MetaDataChanged: true}
+	}
+	return balance, nil
+}
+
+func sameMetaData(a, b map[string]interface{}) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	// maps marshal with sorted keys, and both sides went through JSON
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+// UpdateBalanceMetadataAfterVersionCheck updates the metadata of a balance if
+// it is still at version just before the update. A transaction landing
+// between the check and the update is not detected.
+func (s *MetadataService) UpdateBalanceMetadataAfterVersionCheck(balanceID string, version int64, body UpdateMetaDataRequest) (*Metadata, *http.Response, error) {
+	if balanceID == "" {
+		return nil, nil, fmt.Errorf("balance ID is required")
+	}
+	if _, err := precheckBalanceVersion(s.client, balanceID, version, nil); err != nil {
+		return nil, nil, err
+	}
+	return s.UpdateMetadata(balanceID, body)
+}
+
+// UpdateBalanceMetadataAfterSnapshotCheck updates the metadata of a balance if
+// both its version and its metadata still match the snapshot just before the
+// update. A change landing between the check and the update is not detected.
+func (s *MetadataService) UpdateBalanceMetadataAfterSnapshotCheck(snapshot *LedgerBalance, body UpdateMetaDataRequest) (*Metadata, *http.Response, error) {
+	if snapshot == nil || snapshot.BalanceID == "" {
+		return nil, nil, fmt.Errorf("balance snapshot is required")
+	}
+	metaData := snapshot.MetaData
+	if metaData == nil {
+		metaData = map[string]interface{}{}
+	}
+	if _, err := precheckBalanceVersion(s.client, snapshot.BalanceID, snapshot.Version, metaData); err != nil {
+		return nil, nil, err
+	}
+	return s.UpdateMetadata(snapshot.BalanceID, body)
+}
+
+// ModifyBalanceMetadataBestEffort runs a read-modify-write loop on the
+// metadata of a balance. modify gets the current balance and returns the keys
+// to set, with nil values stored as null. If the balance is seen to change
+// before the write, the loop starts over with a fresh read, up to attempts
+// times. A change made right between the last check and the write is still
+// overwritten.
+func (s *MetadataService) ModifyBalanceMetadataBestEffort(balanceID string, attempts int, modify func(balance *LedgerBalance) (map[string]interface{}, error)) (*Metadata, error) {
+	if balanceID == "" {
+		return nil, fmt.Errorf("balance ID is required")
+	}
+	var result *Metadata
+	err := RetryOnConflict(attempts, func() error {
+		balance, _, err := NewLedgerBalanceService(s.client).Get(balanceID)
+		if err != nil {
+			return err
+		}
+		changes, err := modify(balance)
+		if err != nil {
+			return err
+		}
+		result, _, err = s.UpdateBalanceMetadataAfterSnapshotCheck(balance, UpdateMetaDataRequest{MetaData: changes})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdateAfterVersionCheck updates a monitor if the balance it watches is
+// still at version just before the update, e.g. when the new threshold was
+// computed from that balance. A transaction landing between the check and the
+// update is not detected.
+func (s *BalanceMonitorService) UpdateAfterVersionCheck(monitorID string, version int64, data MonitorData) (*MonitorDataResp, *http.Response, error) {
+	if data.BalanceID == "" {
+		return nil, nil, fmt.Errorf("balance ID is required")
+	}
+	if _, err := precheckBalanceVersion(s.client, data.BalanceID, version, nil); err != nil {
+		return nil, nil, err
+	}
+	return s.Update(monitorID, data)
+}
+
+// conflictBackoff is the base wait between RetryOnConflict attempts.
+var conflictBackoff = 20 * time.Millisecond
+
+// RetryOnConflict calls fn until it returns an error that is not a version
+// conflict, waiting a little longer with some jitter after each conflict. It
+// returns the last conflict once attempts are used up. attempts below one
+// means one.
+func RetryOnConflict(attempts int, fn func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			wait := conflictBackoff * time.Duration(i)
+			time.Sleep(wait + rand.N(wait))
+		}
+		if err = fn(); !IsVersionConflict(err) {
+			return err
+		}
+	}
+	return err
+}
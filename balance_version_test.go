@@ -0,0 +1,122 @@
+package blnkgo_test
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func versionedBalance(t *testing.T) (*blnktest.Server, *blnkgo.Client, *blnkgo.LedgerBalance) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	balance, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: "ldg_1", Currency: "USD"})
+	require.NoError(t, err)
+	return server, client, balance
+}
+
+func fund(t *testing.T, client *blnkgo.Client, balanceID, reference string) {
+	_, _, err := client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: reference, PreciseAmount: big.NewInt(100), Precision: 100, Currency: "USD", Source: "@world", Destination: balanceID,
+	}})
+	require.NoError(t, err)
+}
+
+func TestMetadataService_UpdateBalanceMetadataAfterVersionCheck(t *testing.T) {
+	server, client, balance := versionedBalance(t)
+	fund(t, client, balance.BalanceID, "fund-1")
+
+	_, _, err := client.Metadata.UpdateBalanceMetadataAfterVersionCheck(balance.BalanceID, balance.Version, blnkgo.UpdateMetaDataRequest{MetaData: map[string]interface{}{"tier": "gold"}})
+	var conflict *blnkgo.VersionConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, balance.Version, conflict.Expected)
+	assert.Equal(t, balance.Version+1, conflict.Actual)
+	stored, _ := server.Balance(balance.BalanceID)
+	assert.Nil(t, stored.MetaData["tier"])
+
+	_, _, err = client.Metadata.UpdateBalanceMetadataAfterVersionCheck(balance.BalanceID, conflict.Actual, blnkgo.UpdateMetaDataRequest{MetaData: map[string]interface{}{"tier": "gold"}})
+	require.NoError(t, err)
+	stored, _ = server.Balance(balance.BalanceID)
+	assert.Equal(t, "gold", stored.MetaData["tier"])
+}
+
+func TestMetadataService_ModifyBalanceMetadataBestEffort(t *testing.T) {
+	server, client, balance := versionedBalance(t)
+	_, _, err := client.Metadata.UpdateMetadata(balance.BalanceID, blnkgo.UpdateMetaDataRequest{MetaData: map[string]interface{}{"counter": 1}})
+	require.NoError(t, err)
+
+	calls := 0
+	_, err = client.Metadata.ModifyBalanceMetadataBestEffort(balance.BalanceID, 3, func(b *blnkgo.LedgerBalance) (map[string]interface{}, error) {
+		calls++
+		if calls == 1 {
+			// another worker bumps the counter after our read
+			_, _, err := client.Metadata.UpdateMetadata(balance.BalanceID, blnkgo.UpdateMetaDataRequest{MetaData: map[string]interface{}{"counter": 5}})
+			require.NoError(t, err)
+		}
+		return map[string]interface{}{"counter": b.MetaData["counter"].(float64) + 1}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	stored, _ := server.Balance(balance.BalanceID)
+	assert.EqualValues(t, 6, stored.MetaData["counter"])
+
+	calls = 0
+	_, err = client.Metadata.ModifyBalanceMetadataBestEffort(balance.BalanceID, 2, func(b *blnkgo.LedgerBalance) (map[string]interface{}, error) {
+		calls++
+		fund(t, client, balance.BalanceID, fmt.Sprintf("busy-%d", calls))
+		return map[string]interface{}{"counter": 0}, nil
+	})
+	assert.True(t, blnkgo.IsVersionConflict(err))
+	assert.Equal(t, 2, calls)
+
+	nope := errors.New("nope")
+	_, err = client.Metadata.ModifyBalanceMetadataBestEffort(balance.BalanceID, 2, func(b *blnkgo.LedgerBalance) (map[string]interface{}, error) {
+		return nil, nope
+	})
+	assert.ErrorIs(t, err, nope)
+}
+
+func TestBalanceMonitorService_UpdateAfterVersionCheck(t *testing.T) {
+	_, client, balance := versionedBalance(t)
+	monitor, _, err := client.BalanceMonitor.Create(blnkgo.MonitorData{
+		BalanceID: balance.BalanceID,
+		Condition: blnkgo.MonitorCondition{Field: "balance", Operator: blnkgo.OperatorLessThan, Value: 10, Precision: 100},
+	})
+	require.NoError(t, err)
+	fund(t, client, balance.BalanceID, "fund-1")
+
+	update := monitor.MonitorData
+	update.Condition.Value = 20
+	_, _, err = client.BalanceMonitor.UpdateAfterVersionCheck(monitor.MonitorID, balance.Version, update)
+	assert.True(t, blnkgo.IsVersionConflict(err))
+
+	current, _, err := client.LedgerBalance.Get(balance.BalanceID)
+	require.NoError(t, err)
+	updated, _, err := client.BalanceMonitor.UpdateAfterVersionCheck(monitor.MonitorID, current.Version, update)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), updated.Condition.Value)
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	calls := 0
+	err := blnkgo.RetryOnConflict(3, func() error {
+		calls++
+		return &blnkgo.VersionConflictError{BalanceID: "bln_1", Expected: 1, Actual: 2}
+	})
+	assert.True(t, blnkgo.IsVersionConflict(err))
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	boom := errors.New("boom")
+	err = blnkgo.RetryOnConflict(3, func() error {
+		calls++
+		return boom
+	})
+	assert.ErrorIs(t, err, boom)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,937 @@
+// Package blnktest provides an in-memory fake of the Blnk API for testing code
+// built on blnk-go without a running Blnk server.
+//
+// The fake covers ledgers, balances (including historical balances),
+// transactions with inflight commits and voids, refunds, filters, identities
+// (create and get), metadata and balance monitors, which are stored but never
+// fire. Metadata updates are merged key by key like Blnk does, so a key
+// updated to null is kept with a null value.
+// Transactions are applied synchronously. Balances referenced by indicator
+// (e.g. "@world") are created on first use and may go negative; other
+// balances reject transactions that exceed their available balance unless
+// allow_overdraft is set. Split transactions (sources/destinations) are not
+// supported.
+package blnktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// Server is a fake Blnk API. Create it with NewServer and talk to it with
+// Client.
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	now          func() time.Time
+	sequence     int
+	ledgers      []*blnkgo.Ledger
+	balances     []*blnkgo.LedgerBalance
+	transactions []*blnkgo.Transaction
+	monitors     []*blnkgo.MonitorDataResp
+	identities   []*blnkgo.IdentityResponse
+	// inflight holds the amount of each inflight transaction that has not been
+	// committed or voided yet.
+	inflight   map[string]*big.Int
+	indicators map[string]string
+	history    map[string][]snapshot
+	failures   map[string][]int
+}
+
+type snapshot struct {
+	at                     time.Time
+	balance, credit, debit *big.Int
+}
+
+// NewServer starts a fake server that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		now:        func() time.Time { return time.Now().UTC() },
+		inflight:   make(map[string]*big.Int),
+		indicators: make(map[string]string),
+		history:    make(map[string][]snapshot),
+		failures:   make(map[string][]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /ledgers", s.createLedger)
+	mux.HandleFunc("GET /ledgers", s.listLedgers)
+	mux.HandleFunc("GET /ledgers/{id}", s.getLedger)
+	mux.HandleFunc("POST /ledgers/filter", s.filterLedgers)
+	mux.HandleFunc("POST /balances", s.createBalance)
+	mux.HandleFunc("GET /balances/{id}", s.getBalance)
+	mux.HandleFunc("GET /balances/{id}/at", s.getHistoricalBalance)
+	mux.HandleFunc("GET /balances/indicator/{indicator}/currency/{currency}", s.getBalanceByIndicator)
+	mux.HandleFunc("POST /balances/filter", s.filterBalances)
+	mux.HandleFunc("POST /transactions", s.createTransaction)
+	mux.HandleFunc("GET /transactions/{id}", s.getTransaction)
+	mux.HandleFunc("PUT /transactions/inflight/{id}", s.updateInflight)
+	mux.HandleFunc("POST /transactions/filter", s.filterTransactions)
+	mux.HandleFunc("POST /refund-transaction/{id}", s.refundTransaction)
+	mux.HandleFunc("POST /balance-monitors", s.createMonitor)
+	mux.HandleFunc("GET /balance-monitors", s.listMonitors)
+	mux.HandleFunc("GET /balance-monitors/{id}", s.getMonitor)
+	mux.HandleFunc("PUT /balance-monitors/{id}", s.updateMonitor)
+	mux.HandleFunc("POST /identities", s.createIdentity)
+	mux.HandleFunc("GET /identities/{id}", s.getIdentity)
+	// "/{id}/metadata" would conflict with "/refund-transaction/{id}"
+	mux.HandleFunc("POST /{path...}", s.updateMetadata)
+
+	s.Server = httptest.NewServer(s.withFailures(mux))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Client returns a client for the fake server.
+func (s *Server) Client(opts ...blnkgo.ClientOption) *blnkgo.Client {
+	baseURL, _ := url.Parse(s.URL)
+	return blnkgo.NewClient(baseURL, nil, opts...)
+}
+
+// SetClock replaces the clock used for created_at timestamps and historical
+// balances.
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// FailNext makes the next request matching method and path (e.g. "POST",
+// "/transactions") fail with status.
+func (s *Server) FailNext(method, path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := method + " " + path
+	s.failures[key] = append(s.failures[key], status)
+}
+
+func (s *Server) withFailures(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.Path
+		s.mu.Lock()
+		statuses := s.failures[key]
+		if len(statuses) > 0 {
+			s.failures[key] = statuses[1:]
+		}
+		s.mu.Unlock()
+		if len(statuses) > 0 {
+			writeError(w, statuses[0], "blnktest: injected failure")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Balance returns a copy of a balance.
+func (s *Server) Balance(id string) (blnkgo.LedgerBalance, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balance := s.balance(id)
+	if balance == nil {
+		return blnkgo.LedgerBalance{}, false
+	}
+	return *balance, true
+}
+
+// Transaction returns a copy of a transaction.
+func (s *Server) Transaction(id string) (blnkgo.Transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	transaction := s.transaction(id)
+	if transaction == nil {
+		return blnkgo.Transaction{}, false
+	}
+	return *transaction, true
+}
+
+// Transactions returns copies of every transaction in creation order.
+func (s *Server) Transactions() []blnkgo.Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	transactions := make([]blnkgo.Transaction, len(s.transactions))
+	for i, t := range s.transactions {
+		transactions[i] = *t
+	}
+	return transactions
+}
+
+// Ledgers returns copies of every ledger in creation order.
+func (s *Server) Ledgers() []blnkgo.Ledger {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ledgers := make([]blnkgo.Ledger, len(s.ledgers))
+	for i, l := range s.ledgers {
+		ledgers[i] = *l
+	}
+	return ledgers
+}
+
+func (s *Server) nextID(prefix string) string {
+	s.sequence++
+	return fmt.Sprintf("%s_%04d", prefix, s.sequence)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+func (s *Server) createLedger(w http.ResponseWriter, r *http.Request) {
+	var body blnkgo.CreateLedgerRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ledger := &blnkgo.Ledger{LedgerID: s.nextID("ldg"), Name: body.Name, CreatedAt: s.now(), MetaData: body.MetaData}
+	s.ledgers = append(s.ledgers, ledger)
+	writeJSON(w, http.StatusCreated, ledger)
+}
+
+func (s *Server) listLedgers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.ledgers)
+}
+
+func (s *Server) getLedger(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ledger := range s.ledgers {
+		if ledger.LedgerID == r.PathValue("id") {
+			writeJSON(w, http.StatusOK, ledger)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "ledger not found")
+}
+
+func (s *Server) filterLedgers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	serveFilter(w, r, s.ledgers)
+}
+
+func (s *Server) createBalance(w http.ResponseWriter, r *http.Request) {
+	var body blnkgo.CreateLedgerBalanceRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if body.LedgerID == "" || body.Currency == "" {
+		writeError(w, http.StatusBadRequest, "ledger_id and currency are required")
+		return
+	}
+	balance := s.newBalance(body.LedgerID, body.Currency, "")
+	balance.IdentityID = body.IdentityID
+	balance.MetaData = body.MetaData
+	writeJSON(w, http.StatusCreated, balance)
+}
+
+func (s *Server) newBalance(ledgerID, currency, indicator string) *blnkgo.LedgerBalance {
+	balance := &blnkgo.LedgerBalance{
+		BalanceID:             s.nextID("bln"),
+		LedgerID:              ledgerID,
+		Currency:              currency,
+		Indicator:             indicator,
+		Balance:               new(big.Int),
+		CreditBalance:         new(big.Int),
+		DebitBalance:          new(big.Int),
+		InflightBalance:       new(big.Int),
+		InflightCreditBalance: new(big.Int),
+		InflightDebitBalance:  new(big.Int),
+		CreatedAt:             s.now(),
+	}
+	s.balances = append(s.balances, balance)
+	return balance
+}
+
+func (s *Server) balance(id string) *blnkgo.LedgerBalance {
+	for _, balance := range s.balances {
+		if balance.BalanceID == id {
+			return balance
+		}
+	}
+	return nil
+}
+
+func (s *Server) getBalance(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balance := s.balance(r.PathValue("id"))
+	if balance == nil {
+		writeError(w, http.StatusNotFound, "balance not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, balance)
+}
+
+func (s *Server) getBalanceByIndicator(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.indicators[r.PathValue("indicator")+"|"+r.PathValue("currency")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "balance not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.balance(id))
+}
+
+func (s *Server) getHistoricalBalance(w http.ResponseWriter, r *http.Request) {
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("timestamp"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid timestamp")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balance := s.balance(r.PathValue("id"))
+	if balance == nil {
+		writeError(w, http.StatusNotFound, "balance not found")
+		return
+	}
+
+	details := blnkgo.BalanceDetails{BalanceID: balance.BalanceID, Currency: balance.Currency, Balance: new(big.Int), CreditBalance: new(big.Int), DebitBalance: new(big.Int)}
+	for _, snap := range s.history[balance.BalanceID] {
+		if snap.at.After(at) {
+			break
+		}
+		details.Balance, details.CreditBalance, details.DebitBalance = snap.balance, snap.credit, snap.debit
+	}
+	writeJSON(w, http.StatusOK, blnkgo.LedgerBalanceHistorical{
+		Balance:    details,
+		FromSource: r.URL.Query().Get("from_source") == "true",
+		Timestamp:  at,
+	})
+}
+
+func (s *Server) filterBalances(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	serveFilter(w, r, s.balances)
+}
+
+// resolve returns the balance a transaction refers to, creating balances for
+// indicators on first use.
+func (s *Server) resolve(ref, currency string) (*blnkgo.LedgerBalance, error) {
+	if strings.HasPrefix(ref, "@") {
+		key := ref + "|" + currency
+		if id, ok := s.indicators[key]; ok {
+			return s.balance(id), nil
+		}
+		balance := s.newBalance("general_ledger_id", currency, ref)
+		s.indicators[key] = balance.BalanceID
+		return balance, nil
+	}
+	balance := s.balance(ref)
+	if balance == nil {
+		return nil, fmt.Errorf("balance %s not found", ref)
+	}
+	return balance, nil
+}
+
+func (s *Server) record(balances ...*blnkgo.LedgerBalance) {
+	at := s.now()
+	for _, b := range balances {
+		s.history[b.BalanceID] = append(s.history[b.BalanceID], snapshot{
+			at:      at,
+			balance: new(big.Int).Set(b.Balance),
+			credit:  new(big.Int).Set(b.CreditBalance),
+			debit:   new(big.Int).Set(b.DebitBalance),
+		})
+	}
+}
+
+func settle(b *blnkgo.LedgerBalance) {
+	b.Balance = new(big.Int).Sub(b.CreditBalance, b.DebitBalance)
+	b.InflightBalance = new(big.Int).Sub(b.InflightCreditBalance, b.InflightDebitBalance)
+	b.Version++
+}
+
+// destinationAmount applies the transaction rate to amount.
+func destinationAmount(amount *big.Int, rate float64) *big.Int {
+	if rate == 0 || rate == 1 {
+		return new(big.Int).Set(amount)
+	}
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(rate, 'f', -1, 64))
+	scaled := new(big.Rat).Mul(new(big.Rat).SetInt(amount), r)
+	quo, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if new(big.Int).Mul(rem.Abs(rem), big.NewInt(2)).Cmp(scaled.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	return quo
+}
+
+func (s *Server) createTransaction(w http.ResponseWriter, r *http.Request) {
+	var body blnkgo.CreateTransactionRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(body.Sources) > 0 || len(body.Destinations) > 0 {
+		writeError(w, http.StatusBadRequest, "blnktest: split transactions are not supported")
+		return
+	}
+	if body.Reference == "" {
+		writeError(w, http.StatusBadRequest, "reference is required")
+		return
+	}
+	for _, t := range s.transactions {
+		if t.Reference == body.Reference {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("reference %s has already been used", body.Reference))
+			return
+		}
+	}
+
+	transaction := &blnkgo.Transaction{
+		ParentTransaction: body.ParentTransaction,
+		TransactionID:     s.nextID("txn"),
+		CreatedAt:         s.now(),
+		Inflight:          body.Inflight,
+		ScheduledFor:      body.ScheduledFor,
+	}
+	transaction.MetaData = copyMeta(body.MetaData)
+	if transaction.Precision <= 0 {
+		transaction.Precision = 1
+	}
+	if transaction.PreciseAmount == nil || transaction.PreciseAmount.Sign() == 0 {
+		transaction.PreciseAmount = blnkgo.ToPreciseAmount(body.Amount, transaction.Precision)
+	}
+	transaction.Amount, _ = new(big.Rat).SetFrac(transaction.PreciseAmount, big.NewInt(transaction.Precision)).Float64()
+	transaction.Hash = blnkgo.BlnkTransactionHash(blnkgo.ChainRecordFromTransaction(*transaction))
+	if transaction.PreciseAmount.Sign() <= 0 {
+		writeError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+
+	source, err := s.resolve(body.Source, body.Currency)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	destination, err := s.resolve(body.Destination, body.Currency)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	transaction.Source, transaction.Destination = source.BalanceID, destination.BalanceID
+	s.transactions = append(s.transactions, transaction)
+	// balances take the precision of the first transaction that moves them
+	for _, balance := range []*blnkgo.LedgerBalance{source, destination} {
+		if balance.Precision == 0 {
+			balance.Precision = int(transaction.Precision)
+		}
+	}
+
+	switch {
+	case body.ScheduledFor != nil && body.ScheduledFor.After(s.now()):
+		transaction.Status = blnkgo.PryTransactionStatusQueued
+	case source.Indicator == "" && !body.AllowOverdraft && available(source).Cmp(transaction.PreciseAmount) < 0:
+		transaction.Status = blnkgo.PryTransactionStatusRejected
+		if transaction.MetaData == nil {
+			transaction.MetaData = map[string]interface{}{}
+		}
+		transaction.MetaData["blnk_rejection_reason"] = "insufficient funds"
+	case body.Inflight:
+		transaction.Status = blnkgo.PryTransactionStatusInFlight
+		s.inflight[transaction.TransactionID] = new(big.Int).Set(transaction.PreciseAmount)
+		source.InflightDebitBalance.Add(source.InflightDebitBalance, transaction.PreciseAmount)
+		destination.InflightCreditBalance.Add(destination.InflightCreditBalance, destinationAmount(transaction.PreciseAmount, body.Rate))
+		settle(source)
+		settle(destination)
+	default:
+		transaction.Status = blnkgo.PryTransactionStatusApplied
+		s.apply(source, destination, transaction.PreciseAmount, body.Rate)
+	}
+	writeJSON(w, http.StatusCreated, transaction)
+}
+
+func copyMeta(metaData map[string]interface{}) map[string]interface{} {
+	if metaData == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(metaData))
+	for k, v := range metaData {
+		copied[k] = v
+	}
+	return copied
+}
+
+func available(b *blnkgo.LedgerBalance) *big.Int {
+	return new(big.Int).Sub(b.Balance, b.InflightDebitBalance)
+}
+
+func (s *Server) apply(source, destination *blnkgo.LedgerBalance, amount *big.Int, rate float64) {
+	source.DebitBalance.Add(source.DebitBalance, amount)
+	destination.CreditBalance.Add(destination.CreditBalance, destinationAmount(amount, rate))
+	settle(source)
+	settle(destination)
+	s.record(source, destination)
+}
+
+func (s *Server) transaction(id string) *blnkgo.Transaction {
+	for _, t := range s.transactions {
+		if t.TransactionID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *Server) getTransaction(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	transaction := s.transaction(r.PathValue("id"))
+	if transaction == nil {
+		writeError(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, transaction)
+}
+
+func (s *Server) updateInflight(w http.ResponseWriter, r *http.Request) {
+	var body blnkgo.UpdateStatus
+	if !decode(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent := s.transaction(r.PathValue("id"))
+	if parent == nil {
+		writeError(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+	// a queued inflight transaction can be voided before it runs
+	if parent.Status == blnkgo.PryTransactionStatusQueued && parent.Inflight && body.Status == blnkgo.InflightStatusVoid {
+		parent.Status = blnkgo.PryTransactionStatusVoid
+		writeJSON(w, http.StatusOK, parent)
+		return
+	}
+	remaining, ok := s.inflight[parent.TransactionID]
+	if !ok || remaining.Sign() == 0 {
+		writeError(w, http.StatusBadRequest, "transaction is not inflight")
+		return
+	}
+
+	amount := new(big.Int).Set(remaining)
+	if body.PreciseAmount != nil && body.PreciseAmount.Sign() > 0 {
+		amount.Set(body.PreciseAmount)
+	} else if body.Amount > 0 {
+		amount = blnkgo.ToPreciseAmount(body.Amount, parent.Precision)
+	}
+	if body.Status == blnkgo.InflightStatusVoid {
+		amount.Set(remaining)
+	}
+	if amount.Cmp(remaining) > 0 {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("amount %s exceeds the inflight amount %s", amount, remaining))
+		return
+	}
+
+	source, destination := s.balance(parent.Source), s.balance(parent.Destination)
+	remaining.Sub(remaining, amount)
+	source.InflightDebitBalance.Sub(source.InflightDebitBalance, amount)
+	destination.InflightCreditBalance.Sub(destination.InflightCreditBalance, destinationAmount(amount, parent.Rate))
+
+	child := &blnkgo.Transaction{
+		ParentTransaction:   parent.ParentTransaction,
+		TransactionID:       s.nextID("txn"),
+		ParentTransactionID: parent.TransactionID,
+		CreatedAt:           s.now(),
+	}
+	child.Reference = fmt.Sprintf("%s-%s-%d", parent.Reference, body.Status, s.sequence)
+	child.MetaData = copyMeta(parent.MetaData)
+	child.PreciseAmount = amount
+	child.Amount, _ = new(big.Rat).SetFrac(amount, big.NewInt(parent.Precision)).Float64()
+	child.Hash = blnkgo.BlnkTransactionHash(blnkgo.ChainRecordFromTransaction(*child))
+
+	switch body.Status {
+	case blnkgo.InflightStatusCommit:
+		child.Status = blnkgo.PryTransactionStatusApplied
+		s.apply(source, destination, amount, parent.Rate)
+	case blnkgo.InflightStatusVoid:
+		child.Status = blnkgo.PryTransactionStatusVoid
+		settle(source)
+		settle(destination)
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown status %s", body.Status))
+		return
+	}
+	if remaining.Sign() == 0 {
+		parent.Status = child.Status
+		if body.Status == blnkgo.InflightStatusCommit {
+			parent.Status = blnkgo.PryTransactionStatusCommit
+		}
+	}
+	s.transactions = append(s.transactions, child)
+	writeJSON(w, http.StatusOK, child)
+}
+
+func (s *Server) refundTransaction(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		PreciseAmount *big.Int               `json:"precise_amount"`
+		MetaData      map[string]interface{} `json:"meta_data"`
+	}
+	if r.ContentLength != 0 {
+		if !decode(w, r, &body) {
+			return
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent := s.transaction(r.PathValue("id"))
+	if parent == nil {
+		writeError(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+	amount := new(big.Int).Set(parent.PreciseAmount)
+	if body.PreciseAmount != nil && body.PreciseAmount.Sign() > 0 {
+		amount.Set(body.PreciseAmount)
+	}
+
+	refund := &blnkgo.Transaction{
+		ParentTransaction:   parent.ParentTransaction,
+		TransactionID:       s.nextID("txn"),
+		ParentTransactionID: parent.TransactionID,
+		CreatedAt:           s.now(),
+	}
+	refund.Source, refund.Destination = parent.Destination, parent.Source
+	refund.Reference = fmt.Sprintf("%s-refund-%d", parent.Reference, s.sequence)
+	refund.PreciseAmount = amount
+	refund.Amount, _ = new(big.Rat).SetFrac(amount, big.NewInt(parent.Precision)).Float64()
+	refund.MetaData = copyMeta(body.MetaData)
+	refund.Status = blnkgo.PryTransactionStatusApplied
+	refund.Hash = blnkgo.BlnkTransactionHash(blnkgo.ChainRecordFromTransaction(*refund))
+	s.apply(s.balance(refund.Source), s.balance(refund.Destination), amount, 0)
+	s.transactions = append(s.transactions, refund)
+	writeJSON(w, http.StatusCreated, refund)
+}
+
+func (s *Server) filterTransactions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	serveFilter(w, r, s.transactions)
+}
+
+func (s *Server) updateMetadata(w http.ResponseWriter, r *http.Request) {
+	id, ok := strings.CutSuffix(r.PathValue("path"), "/metadata")
+	if !ok || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	var body blnkgo.UpdateMetaDataRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var target *map[string]interface{}
+	switch {
+	case strings.HasPrefix(id, "ldg_"):
+		for _, ledger := range s.ledgers {
+			if ledger.LedgerID == id {
+				target = &ledger.MetaData
+			}
+		}
+	case strings.HasPrefix(id, "bln_"):
+		if balance := s.balance(id); balance != nil {
+			target = &balance.MetaData
+		}
+	case strings.HasPrefix(id, "txn_"):
+		if transaction := s.transaction(id); transaction != nil {
+			target = &transaction.MetaData
+		}
+	case strings.HasPrefix(id, "idt_"):
+		if identity := s.identity(id); identity != nil {
+			target = &identity.MetaData
+		}
+	}
+	if target == nil {
+		writeError(w, http.StatusNotFound, "entity not found")
+		return
+	}
+
+	if *target == nil {
+		*target = make(map[string]interface{})
+	}
+	// nulls are stored, not deleted
+	for k, v := range body.MetaData {
+		(*target)[k] = v
+	}
+	writeJSON(w, http.StatusOK, blnkgo.Metadata{MetaData: *target})
+}
+
+func (s *Server) createIdentity(w http.ResponseWriter, r *http.Request) {
+	var body blnkgo.Identity
+	if !decode(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	identity := &blnkgo.IdentityResponse{IdentityId: s.nextID("idt"), CreatedAt: s.now().Format(time.RFC3339), Identity: body}
+	identity.MetaData = copyMeta(body.MetaData)
+	s.identities = append(s.identities, identity)
+	writeJSON(w, http.StatusCreated, identity)
+}
+
+func (s *Server) getIdentity(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if identity := s.identity(r.PathValue("id")); identity != nil {
+		writeJSON(w, http.StatusOK, identity)
+		return
+	}
+	writeError(w, http.StatusNotFound, "identity not found")
+}
+
+func (s *Server) identity(id string) *blnkgo.IdentityResponse {
+	for _, identity := range s.identities {
+		if identity.IdentityId == id {
+			return identity
+		}
+	}
+	return nil
+}
+
+func (s *Server) createMonitor(w http.ResponseWriter, r *http.Request) {
+	var body blnkgo.MonitorData
+	if !decode(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.balance(body.BalanceID) == nil {
+		writeError(w, http.StatusBadRequest, "balance not found")
+		return
+	}
+	monitor := &blnkgo.MonitorDataResp{MonitorData: body, MonitorID: s.nextID("mon"), CreatedAt: s.now().Format(time.RFC3339)}
+	s.monitors = append(s.monitors, monitor)
+	writeJSON(w, http.StatusCreated, monitor)
+}
+
+func (s *Server) listMonitors(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.monitors)
+}
+
+func (s *Server) getMonitor(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, monitor := range s.monitors {
+		if monitor.MonitorID == r.PathValue("id") {
+			writeJSON(w, http.StatusOK, monitor)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "monitor not found")
+}
+
+func (s *Server) updateMonitor(w http.ResponseWriter, r *http.Request) {
+	var body blnkgo.MonitorData
+	if !decode(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, monitor := range s.monitors {
+		if monitor.MonitorID == r.PathValue("id") {
+			monitor.MonitorData = body
+			writeJSON(w, http.StatusOK, monitor)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "monitor not found")
+}
+
+// serveFilter applies FilterParams to records the way the Blnk filter
+// endpoints do. Fields are matched on the JSON representation of the records,
+// and meta_data.<key> reaches into metadata.
+func serveFilter[T any](w http.ResponseWriter, r *http.Request, records []*T) {
+	var params blnkgo.FilterParams
+	if !decode(w, r, &params) {
+		return
+	}
+
+	type row struct {
+		record *T
+		fields map[string]interface{}
+	}
+	var rows []row
+	for _, record := range records {
+		raw, _ := json.Marshal(record)
+		decoder := json.NewDecoder(strings.NewReader(string(raw)))
+		decoder.UseNumber()
+		var fields map[string]interface{}
+		_ = decoder.Decode(&fields)
+
+		matched := true
+		for _, filter := range params.Filters {
+			ok, err := matchFilter(fields, filter)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			rows = append(rows, row{record, fields})
+		}
+	}
+
+	if params.SortBy != "" {
+		sort.SliceStable(rows, func(i, j int) bool {
+			cmp := compareValues(lookup(rows[i].fields, params.SortBy), lookup(rows[j].fields, params.SortBy))
+			if strings.EqualFold(params.SortOrder, "desc") {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+	}
+
+	total := int64(len(rows))
+	if params.Offset > 0 {
+		if params.Offset >= len(rows) {
+			rows = nil
+		} else {
+			rows = rows[params.Offset:]
+		}
+	}
+	if params.Limit > 0 && len(rows) > params.Limit {
+		rows = rows[:params.Limit]
+	}
+
+	data := make([]*T, len(rows))
+	for i, row := range rows {
+		data[i] = row.record
+	}
+	if params.IncludeCount {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": data, "total_count": total})
+		return
+	}
+	writeJSON(w, http.StatusOK, data)
+}
+
+func lookup(fields map[string]interface{}, path string) interface{} {
+	var current interface{} = fields
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+func matchFilter(fields map[string]interface{}, filter blnkgo.Filter) (bool, error) {
+	value := lookup(fields, filter.Field)
+	switch filter.Operator {
+	case blnkgo.OpEqual:
+		return value != nil && compareValues(value, filter.Value) == 0, nil
+	case blnkgo.OpNotEqual:
+		return value == nil || compareValues(value, filter.Value) != 0, nil
+	case blnkgo.OpGreaterThan:
+		return value != nil && compareValues(value, filter.Value) > 0, nil
+	case blnkgo.OpGreaterThanOrEqual:
+		return value != nil && compareValues(value, filter.Value) >= 0, nil
+	case blnkgo.OpLessThan:
+		return value != nil && compareValues(value, filter.Value) < 0, nil
+	case blnkgo.OpLessThanOrEqual:
+		return value != nil && compareValues(value, filter.Value) <= 0, nil
+	case blnkgo.OpIn:
+		for _, v := range filter.Values {
+			if value != nil && compareValues(value, v) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case blnkgo.OpBetween:
+		if len(filter.Values) != 2 {
+			return false, fmt.Errorf("between needs two values")
+		}
+		return value != nil && compareValues(value, filter.Values[0]) >= 0 && compareValues(value, filter.Values[1]) <= 0, nil
+	case blnkgo.OpLike, blnkgo.OpILike:
+		pattern, text := fmt.Sprint(filter.Value), fmt.Sprint(value)
+		if filter.Operator == blnkgo.OpILike {
+			pattern, text = strings.ToLower(pattern), strings.ToLower(text)
+		}
+		return value != nil && likeMatch(pattern, text), nil
+	case blnkgo.OpIsNull:
+		return value == nil || value == "", nil
+	case blnkgo.OpIsNotNull:
+		return value != nil && value != "", nil
+	}
+	return false, fmt.Errorf("unsupported operator %s", filter.Operator)
+}
+
+// compareValues compares numbers numerically, RFC 3339 times chronologically
+// and everything else as strings.
+func compareValues(a, b interface{}) int {
+	sa, sb := fmt.Sprint(a), fmt.Sprint(b)
+	if ra, ok := new(big.Rat).SetString(sa); ok {
+		if rb, ok := new(big.Rat).SetString(sb); ok {
+			return ra.Cmp(rb)
+		}
+	}
+	if ta, err := time.Parse(time.RFC3339Nano, sa); err == nil {
+		if tb, err := time.Parse(time.RFC3339Nano, sb); err == nil {
+			return ta.Compare(tb)
+		}
+	}
+	return strings.Compare(sa, sb)
+}
+
+// likeMatch implements SQL LIKE with % wildcards.
+func likeMatch(pattern, text string) bool {
+	parts := strings.Split(pattern, "%")
+	if len(parts) == 1 {
+		return pattern == text
+	}
+	if !strings.HasPrefix(text, parts[0]) {
+		return false
+	}
+	text = text[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(text, part)
+		if i < 0 {
+			return false
+		}
+		text = text[i+len(part):]
+	}
+	return strings.HasSuffix(text, parts[len(parts)-1])
+}
@@ -0,0 +1,100 @@
+package blnktest_test
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Transactions(t *testing.T) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	server.SetClock(func() time.Time { return now })
+
+	ledger, _, err := client.Ledger.Create(blnkgo.CreateLedgerRequest{Name: "Wallets"})
+	require.NoError(t, err)
+	wallet, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: ledger.LedgerID, Currency: "USD"})
+	require.NoError(t, err)
+
+	deposit, _, err := client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: "deposit", Amount: 50, Precision: 100, Currency: "USD", Source: "@world", Destination: wallet.BalanceID,
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, blnkgo.PryTransactionStatusApplied, deposit.Status)
+	assert.NotEmpty(t, deposit.Hash)
+
+	_, _, err = client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: "deposit", Amount: 1, Precision: 100, Currency: "USD", Source: "@world", Destination: wallet.BalanceID,
+	}})
+	assert.Error(t, err, "duplicate reference")
+
+	overdraft, _, err := client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: "too-much", Amount: 60, Precision: 100, Currency: "USD", Source: wallet.BalanceID, Destination: "@merchant",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, blnkgo.PryTransactionStatusRejected, overdraft.Status)
+
+	now = start.Add(time.Hour)
+	hold, _, err := client.Transaction.Create(blnkgo.CreateTransactionRequest{
+		ParentTransaction: blnkgo.ParentTransaction{
+			Reference: "hold", Amount: 30, Precision: 100, Currency: "USD", Source: wallet.BalanceID, Destination: "@merchant",
+		},
+		Inflight: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, blnkgo.PryTransactionStatusInFlight, hold.Status)
+
+	commit, _, err := client.Transaction.Update(hold.TransactionID, blnkgo.UpdateStatus{Status: blnkgo.InflightStatusCommit, PreciseAmount: big.NewInt(1000)})
+	require.NoError(t, err)
+	assert.Equal(t, hold.TransactionID, commit.ParentTransactionID)
+	_, _, err = client.Transaction.Update(hold.TransactionID, blnkgo.UpdateStatus{Status: blnkgo.InflightStatusVoid})
+	require.NoError(t, err)
+
+	balance, _, err := client.LedgerBalance.Get(wallet.BalanceID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4000), balance.Balance.Int64())
+	assert.Equal(t, int64(0), balance.InflightDebitBalance.Int64())
+	parent, _ := server.Transaction(hold.TransactionID)
+	assert.Equal(t, blnkgo.PryTransactionStatusVoid, parent.Status)
+
+	historical, _, err := client.LedgerBalance.GetHistorical(wallet.BalanceID, start.Add(30*time.Minute), false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), historical.Balance.Balance.Int64())
+
+	merchant, _, err := client.LedgerBalance.GetByIndicator("@merchant", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), merchant.Balance.Int64())
+}
+
+func TestServer_FilterAndMetadata(t *testing.T) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	for i, kind := range []string{"savings", "current", "savings"} {
+		_, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{
+			LedgerID: "ldg_1", Currency: "USD", MetaData: map[string]interface{}{"kind": kind, "rank": i},
+		})
+		require.NoError(t, err)
+	}
+
+	balances, err := client.LedgerBalance.FilterAll(blnkgo.FilterParams{
+		Filters: []blnkgo.Filter{{Field: "meta_data.kind", Operator: blnkgo.OpEqual, Value: "savings"}},
+		SortBy:  "meta_data.rank", SortOrder: "desc",
+	})
+	require.NoError(t, err)
+	require.Len(t, balances, 2)
+	assert.Equal(t, "bln_0003", balances[0].BalanceID)
+
+	response, _, err := client.Metadata.UpdateMetadata("bln_0001", blnkgo.UpdateMetaDataRequest{MetaData: map[string]interface{}{"kind": nil, "frozen": true}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"rank": float64(0), "kind": nil, "frozen": true}, response.MetaData, "nulls are stored like Blnk does")
+
+	_, _, err = client.Metadata.UpdateMetadata("bln_missing", blnkgo.UpdateMetaDataRequest{MetaData: map[string]interface{}{"a": 1}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,380 @@
+package blnkgo
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores the responses of a CachingClient. Implementations must be safe
+// for concurrent use. A value set with a TTL must not be returned once the TTL
+// has passed.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+	Delete(key string)
+}
+
+// LRUCache is an in-memory Cache that evicts the least recently used entry
+// once it holds maxEntries.
+type LRUCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+	now        func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func NewLRUCache(maxEntries int) *LRUCache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &LRUCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.now().Add(ttl)
+	}
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value, entry.expires = value, expires
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Len returns the number of entries, expired ones included until they are
+// read or evicted.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRUCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*lruEntry).key)
+}
+
+// CacheConfig configures a CachingClient.
+type CacheConfig struct {
+	// TTL is how long a response is served from the cache. Defaults to five
+	// minutes.
+	TTL time.Duration
+	// MaxEntries bounds the default in-memory backend. Defaults to 1000.
+	MaxEntries int
+	// Backend stores the responses. Defaults to an LRUCache. A backend set
+	// here is taken to be shared, so the balance ID of every cached indicator
+	// balance is stored in it as well, for the other clients to invalidate.
+	Backend Cache
+	// Namespace prefixes every key, so several Blnk servers can share a
+	// backend.
+	Namespace string
+}
+
+// CachingClient is a ClientInterface that serves GET ledgers/{id},
+// balances/indicator/{indicator}/currency/{currency} and identities/{id} from
+// a cache. Concurrent misses for the same key share one request. Other
+// requests go to the wrapped client, and writes made through the
+// CachingClient invalidate the entries of the IDs in their path, such as a
+// metadata update of a ledger or a balance.
+//
+// Balances are cached to resolve indicators to balance IDs; their amounts are
+// as old as the entry. Use LedgerBalanceService.Get for current amounts.
+type CachingClient struct {
+	client    ClientInterface
+	cache     Cache
+	ttl       time.Duration
+	namespace string
+	// shared is set when the backend was passed in and may be shared with
+	// other clients, which then need the balance references stored in it
+	shared bool
+
+	mu       sync.Mutex
+	inflight map[string]*cacheCall
+	// refs maps the ID of every indicator balance cached by this client to
+	// its key. It is kept out of the backend so that evicting a reference
+	// cannot leave a balance that Invalidate no longer finds.
+	refs    map[string]cacheRef
+	sweepAt int
+}
+
+type cacheRef struct {
+	key     string
+	expires time.Time
+}
+
+type cacheCall struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+func NewCachingClient(client ClientInterface, config CacheConfig) *CachingClient {
+	if config.TTL <= 0 {
+		config.TTL = 5 * time.Minute
+	}
+	shared := config.Backend != nil
+	if !shared {
+		config.Backend = NewLRUCache(config.MaxEntries)
+	}
+	return &CachingClient{
+		client:    client,
+		cache:     config.Backend,
+		ttl:       config.TTL,
+		namespace: config.Namespace,
+		shared:    shared,
+		inflight:  make(map[string]*cacheCall),
+		refs:      make(map[string]cacheRef),
+	}
+}
+
+func (c *CachingClient) NewRequest(endpoint, method string, opt interface{}) (*http.Request, error) {
+	return c.client.NewRequest(endpoint, method, opt)
+}
+
+func (c *CachingClient) NewFileUploadRequest(endpoint string, fileParam string, file interface{}, fileName string, fields map[string]string) (*http.Request, error) {
+	return c.client.NewFileUploadRequest(endpoint, fileParam, file, fileName, fields)
+}
+
+// CallWithRetry serves cacheable requests from the cache and sends the others
+// to the wrapped client. Responses served from the cache have an empty body
+// and the X-Blnk-Cache header set to HIT.
+func (c *CachingClient) CallWithRetry(req *http.Request, resBody interface{}) (*http.Response, error) {
+	key, ok := c.cacheKey(req)
+	if !ok {
+		resp, err := c.client.CallWithRetry(req, resBody)
+		if req.Method != http.MethodGet && err == nil {
+			c.invalidatePath(req.URL.Path)
+		}
+		return resp, err
+	}
+
+	if data, ok := c.cache.Get(key); ok {
+		return cachedResponse(req, "HIT"), decodeCached(data, resBody)
+	}
+
+	c.mu.Lock()
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		if call.err != nil {
+			return nil, call.err
+		}
+		return cachedResponse(req, "SHARED"), decodeCached(call.data, resBody)
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	var raw json.RawMessage
+	resp, err := c.client.CallWithRetry(req, &raw)
+	call.data, call.err = raw, err
+	if err == nil {
+		c.cache.Set(key, raw, c.ttl)
+		if id := referencedBalance(raw); id != "" && strings.Contains(key, "balances/indicator/") {
+			c.setRef(id, key)
+		}
+	}
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(call.done)
+
+	if err != nil {
+		return resp, err
+	}
+	return resp, decodeCached(raw, resBody)
+}
+
+// cacheKey returns the key of a cacheable request: the path from the resource
+// name on, so the key does not depend on the base URL.
+func (c *CachingClient) cacheKey(req *http.Request) (string, bool) {
+	if req.Method != http.MethodGet || req.URL.RawQuery != "" {
+		return "", false
+	}
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	n := len(segments)
+	switch {
+	case n >= 2 && (segments[n-2] == "ledgers" || segments[n-2] == "identities"):
+		return c.namespace + strings.Join(segments[n-2:], "/"), true
+	case n >= 5 && segments[n-5] == "balances" && segments[n-4] == "indicator" && segments[n-2] == "currency":
+		return c.namespace + strings.Join(segments[n-5:], "/"), true
+	}
+	return "", false
+}
+
+// setRef records that the indicator balance id is cached under key. A
+// backend passed in CacheConfig gets the reference too, for the clients it is
+// shared with.
+func (c *CachingClient) setRef(id, key string) {
+	c.mu.Lock()
+	c.refs[id] = cacheRef{key: key, expires: time.Now().Add(c.ttl)}
+	if len(c.refs) >= c.sweepAt {
+		// entries expire in the backend without telling us, so the expired
+		// references are dropped whenever the index has doubled
+		now := time.Now()
+		for id, ref := range c.refs {
+			if !now.Before(ref.expires) {
+				delete(c.refs, id)
+			}
+		}
+		c.sweepAt = max(2*len(c.refs), 64)
+	}
+	c.mu.Unlock()
+	if c.shared {
+		c.cache.Set(c.namespace+"ref/"+id, []byte(key), c.ttl)
+	}
+}
+
+// Invalidate drops the cached ledgers, identities and indicator balances with
+// the given IDs.
+func (c *CachingClient) Invalidate(ids ...string) {
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		c.cache.Delete(c.namespace + "ledgers/" + id)
+		c.cache.Delete(c.namespace + "identities/" + id)
+		c.mu.Lock()
+		ref, ok := c.refs[id]
+		delete(c.refs, id)
+		c.mu.Unlock()
+		if ok {
+			c.cache.Delete(ref.key)
+		}
+		if !c.shared {
+			continue
+		}
+		if key, ok := c.cache.Get(c.namespace + "ref/" + id); ok {
+			c.cache.Delete(string(key))
+			c.cache.Delete(c.namespace + "ref/" + id)
+		}
+	}
+}
+
+// InvalidateIndicator drops a cached indicator balance, e.g. after creating
+// it so a cached miss is not served. Errors are never cached, so this is only
+// needed when a balance was recreated under the same indicator.
+func (c *CachingClient) InvalidateIndicator(indicator, currency string) {
+	c.cache.Delete(fmt.Sprintf("%sbalances/indicator/%s/currency/%s", c.namespace, indicator, currency))
+}
+
+// invalidatePath invalidates every segment of the path of a write after the
+// resource name, since those are the IDs it may have changed.
+func (c *CachingClient) invalidatePath(path string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	c.Invalidate(segments...)
+}
+
+// webhookEvent is the envelope of a Blnk webhook.
+type webhookEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		LedgerID   string `json:"ledger_id"`
+		BalanceID  string `json:"balance_id"`
+		IdentityID string `json:"identity_id"`
+	} `json:"data"`
+}
+
+// InvalidateFromWebhook drops the entries of the ledger, balance and identity
+// a Blnk webhook payload refers to, so changes made by other clients are seen
+// before the TTL expires.
+func (c *CachingClient) InvalidateFromWebhook(payload []byte) error {
+	var event webhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("failed to decode webhook: %w", err)
+	}
+	c.Invalidate(event.Data.LedgerID, event.Data.BalanceID, event.Data.IdentityID)
+	return nil
+}
+
+// WebhookMiddleware invalidates the cache from the webhooks handled by next.
+// The request body is left intact for next.
+func (c *CachingClient) WebhookMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		_ = c.InvalidateFromWebhook(payload)
+		r.Body = io.NopCloser(bytes.NewReader(payload))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func referencedBalance(data []byte) string {
+	var balance struct {
+		BalanceID string `json:"balance_id"`
+	}
+	_ = json.Unmarshal(data, &balance)
+	return balance.BalanceID
+}
+
+func decodeCached(data []byte, v interface{}) error {
+	if v == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+func cachedResponse(req *http.Request, status string) *http.Response {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"X-Blnk-Cache": []string{status}},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
@@ -0,0 +1,221 @@
+package blnkgo_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingServer serves a ledger, an indicator balance and an identity and
+// counts the GETs it receives per path.
+func countingServer(t *testing.T, delay time.Duration) (*blnkgo.Client, map[string]*int64) {
+	counts := map[string]*int64{
+		"/ledgers/ldg_1":                         new(int64),
+		"/balances/indicator/@fees/currency/USD": new(int64),
+		"/identities/idt_1":                      new(int64),
+	}
+	var mu sync.Mutex
+	name := "Fees"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ledgers/ldg_1", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(counts[r.URL.Path], 1)
+		time.Sleep(delay)
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, `{"ledger_id":"ldg_1","name":%q}`, name)
+	})
+	mux.HandleFunc("GET /balances/indicator/{indicator}/currency/{currency}", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(counts[r.URL.Path], 1)
+		fmt.Fprint(w, `{"balance_id":"bln_1","indicator":"@fees","currency":"USD","balance":100}`)
+	})
+	mux.HandleFunc("GET /identities/idt_1", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(counts[r.URL.Path], 1)
+		fmt.Fprint(w, `{"identity_id":"idt_1","first_name":"Ada"}`)
+	})
+	mux.HandleFunc("GET /ledgers/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+	})
+	mux.HandleFunc("POST /{id}/metadata", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		name = "Fee income"
+		fmt.Fprint(w, `{"metadata":{}}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	baseURL, _ := url.Parse(server.URL)
+	return blnkgo.NewClient(baseURL, nil), counts
+}
+
+func TestCachingClient_ServesFromCache(t *testing.T) {
+	client, counts := countingServer(t, 0)
+	cached := blnkgo.NewCachingClient(client, blnkgo.CacheConfig{TTL: time.Minute})
+	ledgers := blnkgo.NewLedgerService(cached)
+	balances := blnkgo.NewLedgerBalanceService(cached)
+	identities := blnkgo.NewIdentityService(cached)
+
+	for i := 0; i < 3; i++ {
+		ledger, _, err := ledgers.Get("ldg_1")
+		require.NoError(t, err)
+		assert.Equal(t, "Fees", ledger.Name)
+		balance, resp, err := balances.GetByIndicator("@fees", "USD")
+		require.NoError(t, err)
+		assert.Equal(t, "bln_1", balance.BalanceID)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		identity, _, err := identities.Get("idt_1")
+		require.NoError(t, err)
+		assert.Equal(t, "Ada", identity.FirstName)
+	}
+	for path, count := range counts {
+		assert.Equal(t, int64(1), *count, path)
+	}
+
+	_, resp, err := ledgers.Get("ldg_1")
+	require.NoError(t, err)
+	assert.Equal(t, "HIT", resp.Header.Get("X-Blnk-Cache"))
+
+	_, _, err = ledgers.Get("missing")
+	assert.Error(t, err)
+	_, _, err = ledgers.Get("missing")
+	assert.Error(t, err, "errors are not cached")
+}
+
+func TestCachingClient_InvalidatesOnWrites(t *testing.T) {
+	client, counts := countingServer(t, 0)
+	cached := blnkgo.NewCachingClient(client, blnkgo.CacheConfig{})
+	ledgers := blnkgo.NewLedgerService(cached)
+	balances := blnkgo.NewLedgerBalanceService(cached)
+
+	_, _, err := ledgers.Get("ldg_1")
+	require.NoError(t, err)
+	_, _, err = balances.GetByIndicator("@fees", "USD")
+	require.NoError(t, err)
+
+	_, _, err = blnkgo.NewMetadataService(cached).UpdateMetadata("ldg_1", blnkgo.UpdateMetaDataRequest{MetaData: map[string]interface{}{"x": 1}})
+	require.NoError(t, err)
+	ledger, _, err := ledgers.Get("ldg_1")
+	require.NoError(t, err)
+	assert.Equal(t, "Fee income", ledger.Name)
+	assert.Equal(t, int64(2), *counts["/ledgers/ldg_1"])
+
+	_, _, err = blnkgo.NewMetadataService(cached).UpdateMetadata("bln_1", blnkgo.UpdateMetaDataRequest{MetaData: map[string]interface{}{"x": 1}})
+	require.NoError(t, err)
+	_, _, err = balances.GetByIndicator("@fees", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), *counts["/balances/indicator/@fees/currency/USD"])
+}
+
+func TestCachingClient_InvalidatesEvictedReference(t *testing.T) {
+	client, counts := countingServer(t, 0)
+	cached := blnkgo.NewCachingClient(client, blnkgo.CacheConfig{MaxEntries: 2})
+	ledgers := blnkgo.NewLedgerService(cached)
+	balances := blnkgo.NewLedgerBalanceService(cached)
+
+	// the indicator balance is read twice, so it is the most recently used
+	// entry when the ledger fills the cache
+	for i := 0; i < 2; i++ {
+		_, _, err := balances.GetByIndicator("@fees", "USD")
+		require.NoError(t, err)
+	}
+	_, _, err := ledgers.Get("ldg_1")
+	require.NoError(t, err)
+
+	cached.Invalidate("bln_1")
+	_, _, err = balances.GetByIndicator("@fees", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), *counts["/balances/indicator/@fees/currency/USD"])
+	_, resp, err := ledgers.Get("ldg_1")
+	require.NoError(t, err)
+	assert.Equal(t, "HIT", resp.Header.Get("X-Blnk-Cache"))
+}
+
+func TestCachingClient_SharedBackend(t *testing.T) {
+	client, counts := countingServer(t, 0)
+	backend := blnkgo.NewLRUCache(10)
+	first := blnkgo.NewCachingClient(client, blnkgo.CacheConfig{Backend: backend})
+	second := blnkgo.NewCachingClient(client, blnkgo.CacheConfig{Backend: backend})
+
+	_, _, err := blnkgo.NewLedgerBalanceService(first).GetByIndicator("@fees", "USD")
+	require.NoError(t, err)
+	// the other client never cached the balance, but finds it in the backend
+	second.Invalidate("bln_1")
+	_, _, err = blnkgo.NewLedgerBalanceService(first).GetByIndicator("@fees", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), *counts["/balances/indicator/@fees/currency/USD"])
+}
+
+func TestCachingClient_Webhooks(t *testing.T) {
+	client, counts := countingServer(t, 0)
+	cached := blnkgo.NewCachingClient(client, blnkgo.CacheConfig{})
+	identities := blnkgo.NewIdentityService(cached)
+
+	_, _, err := identities.Get("idt_1")
+	require.NoError(t, err)
+
+	var received string
+	handler := cached.WebhookMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r.Body)
+		received = buf.String()
+	}))
+	payload := `{"event":"identity.updated","data":{"identity_id":"idt_1"}}`
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(payload)))
+	assert.Equal(t, payload, received)
+
+	_, _, err = identities.Get("idt_1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), *counts["/identities/idt_1"])
+
+	assert.Error(t, cached.InvalidateFromWebhook([]byte("not json")))
+}
+
+func TestCachingClient_CoalescesConcurrentMisses(t *testing.T) {
+	client, counts := countingServer(t, 50*time.Millisecond)
+	cached := blnkgo.NewCachingClient(client, blnkgo.CacheConfig{})
+	ledgers := blnkgo.NewLedgerService(cached)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ledger, _, err := ledgers.Get("ldg_1")
+			assert.NoError(t, err)
+			assert.Equal(t, "Fees", ledger.Name)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(1), *counts["/ledgers/ldg_1"])
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := blnkgo.NewLRUCache(2)
+	cache.Set("a", []byte("1"), 0)
+	cache.Set("b", []byte("2"), 0)
+	_, ok := cache.Get("a")
+	require.True(t, ok)
+	cache.Set("c", []byte("3"), 0)
+
+	_, ok = cache.Get("b")
+	assert.False(t, ok, "b was least recently used")
+	assert.Equal(t, 2, cache.Len())
+
+	cache.Set("d", []byte("4"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	_, ok = cache.Get("d")
+	assert.False(t, ok, "d expired")
+
+	cache.Delete("a")
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+}
@@ -0,0 +1,671 @@
+// Package cards maps card network events onto Blnk transactions.
+//
+// A card is a Blnk balance. An authorization places an inflight transaction
+// from the card to a settlement balance, and an incremental authorization
+// places another one. A reversal voids holds, and a settlement commits them,
+// partially if it is for less than was authorized. Settlements for more than
+// was authorized post the difference as a separate transaction. Every
+// transaction carries the same card metadata: card_authorization, card_event,
+// card_event_id, card_last4, card_mcc, card_merchant_id and card_merchant_name.
+//
+// Events are identified by the network's authorization ID and an event ID, and
+// an event that was already processed is ignored, so redelivered network
+// messages are safe to process again. An event that failed partway is
+// finished by processing it again: reversals and settlements record which
+// holds they void and commit before touching any, and every step is skipped
+// once Blnk shows it done.
+package cards
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// EventType is the kind of network event a transaction records.
+type EventType string
+
+const (
+	EventAuthorization EventType = "authorization"
+	EventIncrement     EventType = "increment"
+	EventReversal      EventType = "reversal"
+	EventSettlement    EventType = "settlement"
+)
+
+// Merchant is the card acceptor of an authorization. MCC is the ISO 18245
+// merchant category code.
+type Merchant struct {
+	ID      string
+	Name    string
+	MCC     string
+	Country string
+}
+
+// AuthorizationRequest is an authorization request (ISO 8583 0100). Amount is
+// in minor units of the card's currency.
+type AuthorizationRequest struct {
+	// ID is the network's authorization ID. It identifies the authorization in
+	// later events.
+	ID       string
+	CardID   string
+	Amount   *big.Int
+	Merchant Merchant
+	MetaData map[string]interface{}
+}
+
+// Status is where an authorization is in its lifecycle.
+type Status string
+
+const (
+	StatusPending          Status = "pending"
+	StatusPartiallySettled Status = "partially_settled"
+	StatusSettled          Status = "settled"
+	StatusReversed         Status = "reversed"
+	StatusDeclined         Status = "declined"
+)
+
+// Hold is an inflight transaction of an authorization. Remaining is the part
+// that has been neither committed nor voided.
+type Hold struct {
+	TransactionID string
+	Amount        *big.Int
+	Remaining     *big.Int
+	CreatedAt     time.Time
+}
+
+// Authorization is the state of an authorization, rebuilt from its
+// transactions. Amounts are in minor units.
+type Authorization struct {
+	ID        string
+	CardID    string
+	Currency  string
+	Precision int64
+	Holds     []Hold
+	// Settled is the amount committed or posted by settlements, and Released
+	// the amount of holds voided by reversals or final settlements.
+	Settled  *big.Int
+	Released *big.Int
+	// Events are the IDs of the events that have been processed.
+	Events []string
+
+	metaData map[string]interface{}
+	original string
+	// pending are the plans of reversals and settlements that have started
+	// but not finished, by event ID, and references the references of the
+	// authorization's transactions.
+	pending    map[string]plan
+	references map[string]bool
+}
+
+// plan is what a reversal or settlement does, worked out before it changes
+// anything so that a retry finishes the same work. Amounts are decimal
+// strings, since the plan is stored in metadata.
+type plan struct {
+	Steps []step `json:"steps,omitempty"`
+	// Hold is the amount a reversal holds again, and Post the amount a
+	// settlement posts above what was held.
+	Hold string `json:"hold,omitempty"`
+	Post string `json:"post,omitempty"`
+}
+
+// step commits Commit of a hold that had From remaining, then voids the rest
+// if Void is set.
+type step struct {
+	Hold   string `json:"hold"`
+	From   string `json:"from"`
+	Commit string `json:"commit,omitempty"`
+	Void   bool   `json:"void,omitempty"`
+}
+
+func (a *Authorization) hold(transactionID string) *Hold {
+	for i := range a.Holds {
+		if a.Holds[i].TransactionID == transactionID {
+			return &a.Holds[i]
+		}
+	}
+	return nil
+}
+
+// amountOf parses an amount of a plan, treating an empty one as zero.
+func amountOf(s string) *big.Int {
+	amount, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return amount
+}
+
+// Held returns the amount still held.
+func (a *Authorization) Held() *big.Int {
+	held := new(big.Int)
+	for _, hold := range a.Holds {
+		held.Add(held, hold.Remaining)
+	}
+	return held
+}
+
+// Status derives the status of the authorization from its amounts.
+func (a *Authorization) Status() Status {
+	held := a.Held().Sign() > 0
+	settled := a.Settled.Sign() > 0
+	switch {
+	case len(a.Holds) == 0 && !settled:
+		return StatusDeclined
+	case held && settled:
+		return StatusPartiallySettled
+	case held:
+		return StatusPending
+	case settled:
+		return StatusSettled
+	}
+	return StatusReversed
+}
+
+// ErrAuthorizationNotFound is returned by Load for authorizations without an
+// approved hold.
+var ErrAuthorizationNotFound = errors.New("authorization not found")
+
+// DeclineReason is why an authorization was declined.
+type DeclineReason string
+
+const (
+	DeclineInsufficientFunds DeclineReason = "insufficient_funds"
+	DeclineLimitExceeded     DeclineReason = "limit_exceeded"
+	DeclineCardInactive      DeclineReason = "card_inactive"
+	DeclineMerchantBlocked   DeclineReason = "merchant_blocked"
+)
+
+// DeclineError is returned when an authorization or increment is declined.
+type DeclineError struct {
+	AuthorizationID string
+	Reason          DeclineReason
+	Detail          string
+}
+
+func (e *DeclineError) Error() string {
+	return fmt.Sprintf("authorization %s declined (%s): %s", e.AuthorizationID, e.Reason, e.Detail)
+}
+
+// Processor processes card events for cards in one currency precision.
+type Processor struct {
+	// Settlement is the balance card spending moves to, e.g.
+	// "@card-settlement".
+	Settlement string
+	// Precision of the transactions. It defaults to 100.
+	Precision int64
+	Limits    Limits
+
+	balances     *blnkgo.LedgerBalanceService
+	transactions *blnkgo.TransactionService
+	metadata     *blnkgo.MetadataService
+	monitors     *blnkgo.BalanceMonitorService
+}
+
+func NewProcessor(client blnkgo.ClientInterface, settlement string) *Processor {
+	return &Processor{
+		Settlement:   settlement,
+		Precision:    100,
+		balances:     blnkgo.NewLedgerBalanceService(client),
+		transactions: blnkgo.NewTransactionService(client),
+		metadata:     blnkgo.NewMetadataService(client),
+		monitors:     blnkgo.NewBalanceMonitorService(client),
+	}
+}
+
+func (p *Processor) precision() int64 {
+	if p.Precision <= 0 {
+		return 100
+	}
+	return p.Precision
+}
+
+// Authorize places a hold for an authorization request, or returns a
+// *DeclineError. Authorizing an ID that was already authorized returns the
+// existing authorization.
+func (p *Processor) Authorize(req AuthorizationRequest) (*Authorization, error) {
+	if req.ID == "" || req.CardID == "" {
+		return nil, fmt.Errorf("authorization ID and card ID are required")
+	}
+	if req.Amount == nil || req.Amount.Sign() <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if existing, err := p.Load(req.ID); err == nil {
+		return existing, nil
+	} else if !errors.Is(err, ErrAuthorizationNotFound) {
+		return nil, err
+	}
+
+	card, _, err := p.balances.Get(req.CardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card %s: %w", req.CardID, err)
+	}
+	metaData := cardMetaData(card, req.Merchant)
+	for k, v := range req.MetaData {
+		metaData[k] = v
+	}
+	metaData["card_authorization"] = req.ID
+
+	auth := &Authorization{
+		ID:         req.ID,
+		CardID:     card.BalanceID,
+		Currency:   card.Currency,
+		Precision:  p.precision(),
+		Settled:    new(big.Int),
+		Released:   new(big.Int),
+		metaData:   metaData,
+		pending:    make(map[string]plan),
+		references: make(map[string]bool),
+	}
+	if err := p.check(auth, card, req.Merchant.MCC, req.Amount); err != nil {
+		return nil, err
+	}
+	if _, err := p.hold(auth, EventAuthorization, req.ID, req.Amount); err != nil {
+		return nil, err
+	}
+	return auth, p.saveEvents(auth, req.ID)
+}
+
+// Increment adds amount to an authorization (an incremental authorization),
+// or returns a *DeclineError.
+func (p *Processor) Increment(authorizationID, eventID string, amount *big.Int) (*Authorization, error) {
+	auth, done, err := p.event(authorizationID, eventID)
+	if err != nil || done {
+		return auth, err
+	}
+	if amount == nil || amount.Sign() <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if auth.references[reference(auth.ID, EventIncrement, eventID)] {
+		// held by an attempt that failed to record the event
+		return auth, p.saveEvents(auth, eventID)
+	}
+	if auth.Held().Sign() == 0 {
+		return nil, fmt.Errorf("authorization %s is %s", auth.ID, auth.Status())
+	}
+
+	card, _, err := p.balances.Get(auth.CardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card %s: %w", auth.CardID, err)
+	}
+	mcc, _ := auth.metaData["card_mcc"].(string)
+	if err := p.check(auth, card, mcc, amount); err != nil {
+		return nil, err
+	}
+	if _, err := p.hold(auth, EventIncrement, eventID, amount); err != nil {
+		return nil, err
+	}
+	return auth, p.saveEvents(auth, eventID)
+}
+
+// Reverse releases amount of the authorization, or all of what is held when
+// amount is nil. Blnk can only void the whole of an inflight transaction, so a
+// partial reversal voids holds, newest first, and places a new hold for the
+// part that stays authorized. Retrying a reversal that failed partway
+// finishes it, whatever amount the retry passes.
+func (p *Processor) Reverse(authorizationID, eventID string, amount *big.Int) (*Authorization, error) {
+	auth, done, err := p.event(authorizationID, eventID)
+	if err != nil || done {
+		return auth, err
+	}
+	plan, started := auth.pending[eventID]
+	if !started {
+		held := auth.Held()
+		if amount == nil {
+			amount = held
+		}
+		if amount.Sign() <= 0 || amount.Cmp(held) > 0 {
+			return nil, fmt.Errorf("cannot reverse %s of authorization %s holding %s", amount, auth.ID, held)
+		}
+		left := new(big.Int).Set(amount)
+		for i := len(auth.Holds) - 1; i >= 0 && left.Sign() > 0; i-- {
+			hold := auth.Holds[i]
+			if hold.Remaining.Sign() == 0 {
+				continue
+			}
+			plan.Steps = append(plan.Steps, step{Hold: hold.TransactionID, From: hold.Remaining.String(), Void: true})
+			if hold.Remaining.Cmp(left) <= 0 {
+				left.Sub(left, hold.Remaining)
+				continue
+			}
+			plan.Hold = new(big.Int).Sub(hold.Remaining, left).String()
+			left = new(big.Int)
+		}
+		if err := p.savePlan(auth, eventID, plan); err != nil {
+			return auth, err
+		}
+	}
+
+	if err := p.run(auth, plan.Steps); err != nil {
+		return auth, err
+	}
+	if plan.Hold != "" && !auth.references[reference(auth.ID, EventReversal, eventID)] {
+		if _, err := p.hold(auth, EventReversal, eventID, amountOf(plan.Hold)); err != nil {
+			return auth, err
+		}
+	}
+	return auth, p.saveEvents(auth, eventID)
+}
+
+// Settle commits amount of the authorization (a clearing or presentment),
+// oldest holds first. Any amount above what is held is posted as a separate
+// transaction, since the network has already settled it. A final settlement
+// releases whatever is left held; a non-final one leaves it for later
+// settlements, e.g. of a split shipment. Retrying a settlement that failed
+// partway finishes it, whatever amount the retry passes.
+func (p *Processor) Settle(authorizationID, eventID string, amount *big.Int, final bool) (*Authorization, error) {
+	auth, done, err := p.event(authorizationID, eventID)
+	if err != nil || done {
+		return auth, err
+	}
+	plan, started := auth.pending[eventID]
+	if !started {
+		if amount == nil || amount.Sign() <= 0 {
+			return nil, fmt.Errorf("amount must be positive")
+		}
+		left := new(big.Int).Set(amount)
+		for _, hold := range auth.Holds {
+			if hold.Remaining.Sign() == 0 || (left.Sign() == 0 && !final) {
+				continue
+			}
+			commit := new(big.Int).Set(hold.Remaining)
+			if left.Cmp(commit) < 0 {
+				commit.Set(left)
+			}
+			left.Sub(left, commit)
+			s := step{Hold: hold.TransactionID, From: hold.Remaining.String(), Void: final && commit.Cmp(hold.Remaining) < 0}
+			if commit.Sign() > 0 {
+				s.Commit = commit.String()
+			}
+			plan.Steps = append(plan.Steps, s)
+		}
+		if left.Sign() > 0 {
+			plan.Post = left.String()
+		}
+		if err := p.savePlan(auth, eventID, plan); err != nil {
+			return auth, err
+		}
+	}
+
+	if err := p.run(auth, plan.Steps); err != nil {
+		return auth, err
+	}
+	if plan.Post != "" && !auth.references[reference(auth.ID, EventSettlement, eventID)] {
+		post := amountOf(plan.Post)
+		request := p.request(auth, EventSettlement, eventID, post)
+		request.AllowOverdraft = true
+		if _, _, err := p.transactions.Create(request); err != nil {
+			return auth, fmt.Errorf("failed to post %s settled above the authorization %s: %w", post, auth.ID, err)
+		}
+		auth.references[request.Reference] = true
+		auth.Settled.Add(auth.Settled, post)
+	}
+	return auth, p.saveEvents(auth, eventID)
+}
+
+// savePlan records the plan of an event before any of it is carried out.
+func (p *Processor) savePlan(auth *Authorization, eventID string, plan plan) error {
+	auth.pending[eventID] = plan
+	_, _, err := p.metadata.UpdateMetadata(auth.original, blnkgo.UpdateMetaDataRequest{MetaData: map[string]interface{}{
+		"card_pending": auth.pending,
+	}})
+	if err != nil {
+		delete(auth.pending, eventID)
+		return fmt.Errorf("failed to record the plan of event %s of authorization %s: %w", eventID, auth.ID, err)
+	}
+	return nil
+}
+
+// run carries out the steps of a plan, checking each hold first: a commit is
+// done once less than From remains and a void once nothing does.
+func (p *Processor) run(auth *Authorization, steps []step) error {
+	for _, s := range steps {
+		hold := auth.hold(s.Hold)
+		if hold == nil {
+			return fmt.Errorf("authorization %s has no hold %s", auth.ID, s.Hold)
+		}
+		if commit := amountOf(s.Commit); commit.Sign() > 0 && hold.Remaining.Cmp(amountOf(s.From)) >= 0 {
+			_, _, err := p.transactions.Update(hold.TransactionID, blnkgo.UpdateStatus{Status: blnkgo.InflightStatusCommit, PreciseAmount: commit})
+			if err != nil {
+				return fmt.Errorf("failed to commit %s of %s: %w", commit, hold.TransactionID, err)
+			}
+			hold.Remaining = new(big.Int).Sub(hold.Remaining, commit)
+			auth.Settled.Add(auth.Settled, commit)
+		}
+		if s.Void && hold.Remaining.Sign() > 0 {
+			if err := p.void(auth, hold); err != nil {
+				return err
+			}
+			hold.Remaining = new(big.Int)
+		}
+	}
+	return nil
+}
+
+// event loads an authorization for an event and reports whether the event
+// was already processed.
+func (p *Processor) event(authorizationID, eventID string) (*Authorization, bool, error) {
+	if eventID == "" {
+		return nil, false, fmt.Errorf("event ID is required")
+	}
+	auth, err := p.Load(authorizationID)
+	if err != nil {
+		return nil, false, err
+	}
+	return auth, slices.Contains(auth.Events, eventID), nil
+}
+
+// hold places an inflight transaction for amount.
+func (p *Processor) hold(auth *Authorization, event EventType, eventID string, amount *big.Int) (*blnkgo.Transaction, error) {
+	request := p.request(auth, event, eventID, amount)
+	request.Inflight = true
+	transaction, _, err := p.transactions.Create(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hold %s for authorization %s: %w", amount, auth.ID, err)
+	}
+	if transaction.Status == blnkgo.PryTransactionStatusRejected {
+		return nil, &DeclineError{AuthorizationID: auth.ID, Reason: DeclineInsufficientFunds, Detail: fmt.Sprintf("%s is not available", amount)}
+	}
+	if auth.original == "" {
+		auth.original = transaction.TransactionID
+	}
+	auth.references[request.Reference] = true
+	auth.Holds = append(auth.Holds, Hold{
+		TransactionID: transaction.TransactionID,
+		Amount:        new(big.Int).Set(amount),
+		Remaining:     new(big.Int).Set(amount),
+		CreatedAt:     transaction.CreatedAt,
+	})
+	return transaction, nil
+}
+
+func (p *Processor) void(auth *Authorization, hold *Hold) error {
+	if _, _, err := p.transactions.Update(hold.TransactionID, blnkgo.UpdateStatus{Status: blnkgo.InflightStatusVoid}); err != nil {
+		return fmt.Errorf("failed to void %s: %w", hold.TransactionID, err)
+	}
+	auth.Released.Add(auth.Released, hold.Remaining)
+	return nil
+}
+
+func (p *Processor) request(auth *Authorization, event EventType, eventID string, amount *big.Int) blnkgo.CreateTransactionRequest {
+	metaData := make(map[string]interface{}, len(auth.metaData)+2)
+	for k, v := range auth.metaData {
+		metaData[k] = v
+	}
+	metaData["card_event"] = string(event)
+	metaData["card_event_id"] = eventID
+
+	merchant, _ := metaData["card_merchant_name"].(string)
+	value, _ := new(big.Rat).SetFrac(amount, big.NewInt(auth.Precision)).Float64()
+	return blnkgo.CreateTransactionRequest{
+		ParentTransaction: blnkgo.ParentTransaction{
+			Amount:        value,
+			PreciseAmount: new(big.Int).Set(amount),
+			Precision:     auth.Precision,
+			Currency:      auth.Currency,
+			Reference:     reference(auth.ID, event, eventID),
+			Description:   strings.TrimSpace(fmt.Sprintf("Card %s %s", event, merchant)),
+			Source:        auth.CardID,
+			Destination:   p.Settlement,
+			MetaData:      metaData,
+		},
+	}
+}
+
+func reference(authorizationID string, event EventType, eventID string) string {
+	if event == EventAuthorization {
+		return "card-" + authorizationID
+	}
+	return fmt.Sprintf("card-%s-%s-%s", authorizationID, event, eventID)
+}
+
+// saveEvents records eventID as processed on the authorization's first hold,
+// dropping its plan.
+func (p *Processor) saveEvents(auth *Authorization, eventID string) error {
+	auth.Events = append(auth.Events, eventID)
+	metaData := map[string]interface{}{"card_events": auth.Events}
+	if _, ok := auth.pending[eventID]; ok {
+		delete(auth.pending, eventID)
+		metaData["card_pending"] = auth.pending
+	}
+	_, _, err := p.metadata.UpdateMetadata(auth.original, blnkgo.UpdateMetaDataRequest{MetaData: metaData})
+	if err != nil {
+		return fmt.Errorf("failed to record event %s of authorization %s: %w", eventID, auth.ID, err)
+	}
+	return nil
+}
+
+// Load rebuilds an authorization from its transactions.
+func (p *Processor) Load(authorizationID string) (*Authorization, error) {
+	if authorizationID == "" {
+		return nil, fmt.Errorf("authorization ID is required")
+	}
+	tagged, err := p.transactions.FilterAll(blnkgo.FilterParams{
+		Filters: []blnkgo.Filter{{Field: "meta_data.card_authorization", Operator: blnkgo.OpEqual, Value: authorizationID}},
+		SortBy:  "created_at", SortOrder: "asc",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &Authorization{
+		ID:         authorizationID,
+		Settled:    new(big.Int),
+		Released:   new(big.Int),
+		pending:    make(map[string]plan),
+		references: make(map[string]bool),
+	}
+	holds := make(map[string]int)
+	var holdIDs []interface{}
+	for _, transaction := range tagged {
+		if transaction.ParentTransactionID != "" || transaction.Status == blnkgo.PryTransactionStatusRejected {
+			continue
+		}
+		auth.references[transaction.Reference] = true
+		amount := transaction.PreciseValue()
+		if auth.original == "" {
+			auth.original = transaction.TransactionID
+			auth.CardID, auth.Currency, auth.Precision = transaction.Source, transaction.Currency, transaction.Precision
+			auth.metaData = make(map[string]interface{})
+			for k, v := range transaction.MetaData {
+				if k != "card_event" && k != "card_event_id" && k != "card_events" && k != "card_pending" {
+					auth.metaData[k] = v
+				}
+			}
+			if events, ok := transaction.MetaData["card_events"].([]interface{}); ok {
+				for _, event := range events {
+					auth.Events = append(auth.Events, fmt.Sprint(event))
+				}
+			}
+			if pending, ok := transaction.MetaData["card_pending"].(map[string]interface{}); ok {
+				raw, err := json.Marshal(pending)
+				if err == nil {
+					err = json.Unmarshal(raw, &auth.pending)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to read the pending events of authorization %s: %w", authorizationID, err)
+				}
+			}
+		}
+		if !transaction.Inflight {
+			auth.Settled.Add(auth.Settled, amount)
+			continue
+		}
+		holds[transaction.TransactionID] = len(auth.Holds)
+		holdIDs = append(holdIDs, transaction.TransactionID)
+		auth.Holds = append(auth.Holds, Hold{
+			TransactionID: transaction.TransactionID,
+			Amount:        amount,
+			Remaining:     new(big.Int).Set(amount),
+			CreatedAt:     transaction.CreatedAt,
+		})
+	}
+	if auth.original == "" {
+		return nil, fmt.Errorf("%w: %s", ErrAuthorizationNotFound, authorizationID)
+	}
+	if auth.Precision <= 0 {
+		auth.Precision = p.precision()
+	}
+
+	children, err := p.children(holdIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		hold := &auth.Holds[holds[child.ParentTransactionID]]
+		amount := child.PreciseValue()
+		switch child.Status {
+		case blnkgo.PryTransactionStatusVoid:
+			auth.Released.Add(auth.Released, hold.Remaining)
+			hold.Remaining = new(big.Int)
+		case blnkgo.PryTransactionStatusApplied, blnkgo.PryTransactionStatusCommit:
+			auth.Settled.Add(auth.Settled, amount)
+			hold.Remaining = new(big.Int).Sub(hold.Remaining, amount)
+		}
+	}
+	return auth, nil
+}
+
+// children returns the commits and voids of holds, oldest first.
+func (p *Processor) children(holdIDs []interface{}) ([]blnkgo.Transaction, error) {
+	var children []blnkgo.Transaction
+	for i := 0; i < len(holdIDs); i += 100 {
+		batch, err := p.transactions.FilterAll(blnkgo.FilterParams{
+			Filters: []blnkgo.Filter{{Field: "parent_transaction", Operator: blnkgo.OpIn, Values: holdIDs[i:min(i+100, len(holdIDs))]}},
+			SortBy:  "created_at", SortOrder: "asc",
+		})
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, batch...)
+	}
+	return children, nil
+}
+
+// cardMetaData returns the card and merchant metadata of a transaction. The
+// last four digits come from the card balance's card_last4, or from its masked
+// card_number.
+func cardMetaData(card *blnkgo.LedgerBalance, merchant Merchant) map[string]interface{} {
+	last4, _ := card.MetaData["card_last4"].(string)
+	if number, ok := card.MetaData["card_number"].(string); ok && last4 == "" && len(number) >= 4 {
+		last4 = number[len(number)-4:]
+	}
+
+	metaData := map[string]interface{}{"card_last4": last4}
+	for key, value := range map[string]string{
+		"card_mcc":              merchant.MCC,
+		"card_merchant_id":      merchant.ID,
+		"card_merchant_name":    merchant.Name,
+		"card_merchant_country": merchant.Country,
+	} {
+		if value != "" {
+			metaData[key] = value
+		}
+	}
+	return metaData
+}
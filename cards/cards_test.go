@@ -0,0 +1,249 @@
+package cards_test
+
+import (
+	"math/big"
+	"net/http"
+	"testing"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
+	"github.com/blnkfinance/blnk-go/cards"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setup(t *testing.T) (*blnktest.Server, *blnkgo.Client, *cards.Processor, string) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	card, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{
+		LedgerID: "ldg_cards", Currency: "USD",
+		MetaData: map[string]interface{}{"card_state": "ACTIVE", "card_number": "411111XXXXXX1111"},
+	})
+	require.NoError(t, err)
+	_, _, err = client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: "top-up", PreciseAmount: big.NewInt(50000), Precision: 100, Currency: "USD", Source: "@funding", Destination: card.BalanceID,
+	}})
+	require.NoError(t, err)
+	return server, client, cards.NewProcessor(client, "@card-settlement"), card.BalanceID
+}
+
+var coffee = cards.Merchant{ID: "M-42", Name: "Corner Coffee", MCC: "5814", Country: "US"}
+
+func balance(t *testing.T, server *blnktest.Server, id string) blnkgo.LedgerBalance {
+	b, ok := server.Balance(id)
+	require.True(t, ok)
+	return b
+}
+
+func TestProcessor_AuthorizeAndSettle(t *testing.T) {
+	server, _, processor, cardID := setup(t)
+
+	auth, err := processor.Authorize(cards.AuthorizationRequest{ID: "A1", CardID: cardID, Amount: big.NewInt(1000), Merchant: coffee})
+	require.NoError(t, err)
+	assert.Equal(t, cards.StatusPending, auth.Status())
+	assert.Equal(t, int64(1000), balance(t, server, cardID).InflightDebitBalance.Int64())
+
+	hold, _ := server.Transaction(auth.Holds[0].TransactionID)
+	assert.Equal(t, "card-A1", hold.Reference)
+	assert.Equal(t, "1111", hold.MetaData["card_last4"])
+	assert.Equal(t, "5814", hold.MetaData["card_mcc"])
+	assert.Equal(t, "Corner Coffee", hold.MetaData["card_merchant_name"])
+	assert.Equal(t, "authorization", hold.MetaData["card_event"])
+
+	auth, err = processor.Increment("A1", "I1", big.NewInt(200))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1200), auth.Held().Int64())
+
+	// a tip takes the settlement above the authorization
+	auth, err = processor.Settle("A1", "S1", big.NewInt(1500), true)
+	require.NoError(t, err)
+	assert.Equal(t, cards.StatusSettled, auth.Status())
+	assert.Equal(t, int64(1500), auth.Settled.Int64())
+
+	card := balance(t, server, cardID)
+	assert.Equal(t, int64(48500), card.Balance.Int64())
+	assert.Equal(t, int64(0), card.InflightDebitBalance.Int64())
+
+	loaded, err := processor.Load("A1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), loaded.Settled.Int64())
+	assert.Equal(t, int64(0), loaded.Held().Int64())
+	assert.Equal(t, []string{"A1", "I1", "S1"}, loaded.Events)
+	assert.Len(t, loaded.Holds, 2)
+}
+
+func TestProcessor_PartialSettlement(t *testing.T) {
+	server, _, processor, cardID := setup(t)
+	_, err := processor.Authorize(cards.AuthorizationRequest{ID: "A2", CardID: cardID, Amount: big.NewInt(10000), Merchant: coffee})
+	require.NoError(t, err)
+
+	auth, err := processor.Settle("A2", "S1", big.NewInt(4000), false)
+	require.NoError(t, err)
+	assert.Equal(t, cards.StatusPartiallySettled, auth.Status())
+	assert.Equal(t, int64(6000), auth.Held().Int64())
+
+	auth, err = processor.Settle("A2", "S2", big.NewInt(3000), true)
+	require.NoError(t, err)
+	assert.Equal(t, cards.StatusSettled, auth.Status())
+	assert.Equal(t, int64(7000), auth.Settled.Int64())
+
+	card := balance(t, server, cardID)
+	assert.Equal(t, int64(43000), card.Balance.Int64())
+	assert.Equal(t, int64(0), card.InflightDebitBalance.Int64())
+
+	// redelivered settlement is ignored
+	again, err := processor.Settle("A2", "S2", big.NewInt(3000), true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7000), again.Settled.Int64())
+	assert.Equal(t, int64(43000), balance(t, server, cardID).Balance.Int64())
+}
+
+func TestProcessor_Reverse(t *testing.T) {
+	server, _, processor, cardID := setup(t)
+	_, err := processor.Authorize(cards.AuthorizationRequest{ID: "A3", CardID: cardID, Amount: big.NewInt(8000), Merchant: coffee})
+	require.NoError(t, err)
+
+	auth, err := processor.Reverse("A3", "R1", big.NewInt(3000))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), auth.Held().Int64())
+	assert.Equal(t, int64(5000), balance(t, server, cardID).InflightDebitBalance.Int64())
+
+	loaded, err := processor.Load("A3")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), loaded.Held().Int64())
+	require.Len(t, loaded.Holds, 2)
+	assert.Equal(t, int64(0), loaded.Holds[0].Remaining.Int64())
+
+	auth, err = processor.Reverse("A3", "R2", nil)
+	require.NoError(t, err)
+	assert.Equal(t, cards.StatusReversed, auth.Status())
+	card := balance(t, server, cardID)
+	assert.Equal(t, int64(50000), card.Balance.Int64())
+	assert.Equal(t, int64(0), card.InflightDebitBalance.Int64())
+
+	_, err = processor.Increment("A3", "I1", big.NewInt(100))
+	assert.Error(t, err)
+}
+
+func TestProcessor_RetryAfterPartialFailure(t *testing.T) {
+	server, _, processor, cardID := setup(t)
+	auth, err := processor.Authorize(cards.AuthorizationRequest{ID: "A5", CardID: cardID, Amount: big.NewInt(1000), Merchant: coffee})
+	require.NoError(t, err)
+	auth, err = processor.Increment("A5", "I1", big.NewInt(200))
+	require.NoError(t, err)
+
+	// the first hold is committed, then committing the second fails
+	server.FailNext(http.MethodPut, "/transactions/inflight/"+auth.Holds[1].TransactionID, http.StatusBadRequest)
+	_, err = processor.Settle("A5", "S1", big.NewInt(1500), true)
+	require.Error(t, err)
+
+	auth, err = processor.Settle("A5", "S1", big.NewInt(1500), true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), auth.Settled.Int64())
+	card := balance(t, server, cardID)
+	assert.Equal(t, int64(48500), card.Balance.Int64())
+	assert.Equal(t, int64(0), card.InflightDebitBalance.Int64())
+
+	loaded, err := processor.Load("A5")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), loaded.Settled.Int64())
+	assert.Equal(t, []string{"A5", "I1", "S1"}, loaded.Events)
+	original, _ := server.Transaction(loaded.Holds[0].TransactionID)
+	assert.Empty(t, original.MetaData["card_pending"])
+
+	// the hold is voided, then placing the hold that stays authorized fails
+	_, err = processor.Authorize(cards.AuthorizationRequest{ID: "A6", CardID: cardID, Amount: big.NewInt(8000), Merchant: coffee})
+	require.NoError(t, err)
+	server.FailNext(http.MethodPost, "/transactions", http.StatusBadRequest)
+	_, err = processor.Reverse("A6", "R1", big.NewInt(3000))
+	require.Error(t, err)
+	assert.Equal(t, int64(0), balance(t, server, cardID).InflightDebitBalance.Int64())
+
+	auth, err = processor.Reverse("A6", "R1", big.NewInt(3000))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), auth.Held().Int64())
+	assert.Equal(t, int64(5000), balance(t, server, cardID).InflightDebitBalance.Int64())
+
+	// once finished the event is ignored
+	auth, err = processor.Reverse("A6", "R1", big.NewInt(3000))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), auth.Held().Int64())
+}
+
+func TestProcessor_Declines(t *testing.T) {
+	_, client, processor, cardID := setup(t)
+	processor.Limits = cards.Limits{
+		PerAuthorization: big.NewInt(20000),
+		Daily:            big.NewInt(30000),
+		BlockedMCCs:      []string{"7995"},
+	}
+	var declined *cards.DeclineError
+
+	_, err := processor.Authorize(cards.AuthorizationRequest{ID: "D1", CardID: cardID, Amount: big.NewInt(100), Merchant: cards.Merchant{MCC: "7995"}})
+	require.ErrorAs(t, err, &declined)
+	assert.Equal(t, cards.DeclineMerchantBlocked, declined.Reason)
+
+	_, err = processor.Authorize(cards.AuthorizationRequest{ID: "D2", CardID: cardID, Amount: big.NewInt(25000)})
+	require.ErrorAs(t, err, &declined)
+	assert.Equal(t, cards.DeclineLimitExceeded, declined.Reason)
+
+	_, err = processor.Authorize(cards.AuthorizationRequest{ID: "D3", CardID: cardID, Amount: big.NewInt(20000)})
+	require.NoError(t, err)
+	_, err = processor.Increment("D3", "I1", big.NewInt(1))
+	require.ErrorAs(t, err, &declined)
+
+	_, err = processor.Authorize(cards.AuthorizationRequest{ID: "D4", CardID: cardID, Amount: big.NewInt(15000)})
+	require.ErrorAs(t, err, &declined)
+	assert.Contains(t, declined.Detail, "daily total")
+
+	_, err = processor.Reverse("D3", "R1", nil)
+	require.NoError(t, err)
+	_, err = processor.Authorize(cards.AuthorizationRequest{ID: "D4", CardID: cardID, Amount: big.NewInt(15000)})
+	require.NoError(t, err, "reversed amounts do not count towards the daily limit")
+
+	processor.Limits = cards.Limits{}
+	_, err = processor.Authorize(cards.AuthorizationRequest{ID: "D5", CardID: cardID, Amount: big.NewInt(40000)})
+	require.ErrorAs(t, err, &declined)
+	assert.Equal(t, cards.DeclineInsufficientFunds, declined.Reason)
+
+	_, _, err = client.Metadata.UpdateMetadata(cardID, blnkgo.UpdateMetaDataRequest{MetaData: map[string]interface{}{"card_state": "FROZEN"}})
+	require.NoError(t, err)
+	_, err = processor.Authorize(cards.AuthorizationRequest{ID: "D6", CardID: cardID, Amount: big.NewInt(100)})
+	require.ErrorAs(t, err, &declined)
+	assert.Equal(t, cards.DeclineCardInactive, declined.Reason)
+}
+
+func TestProcessor_MonitorLimits(t *testing.T) {
+	_, client, processor, cardID := setup(t)
+	processor.Limits.UseMonitors = true
+	_, _, err := client.BalanceMonitor.Create(blnkgo.MonitorData{
+		BalanceID: cardID,
+		Condition: blnkgo.MonitorCondition{Field: "inflight_debit_balance", Operator: blnkgo.OperatorGreaterThan, Value: 100, Precision: 100},
+	})
+	require.NoError(t, err)
+
+	_, err = processor.Authorize(cards.AuthorizationRequest{ID: "M1", CardID: cardID, Amount: big.NewInt(6000)})
+	require.NoError(t, err)
+	_, err = processor.Authorize(cards.AuthorizationRequest{ID: "M2", CardID: cardID, Amount: big.NewInt(5000)})
+	var declined *cards.DeclineError
+	require.ErrorAs(t, err, &declined)
+	assert.Equal(t, cards.DeclineLimitExceeded, declined.Reason)
+	_, err = processor.Authorize(cards.AuthorizationRequest{ID: "M3", CardID: cardID, Amount: big.NewInt(4000)})
+	assert.NoError(t, err)
+}
+
+func TestProcessor_AuthorizeTwice(t *testing.T) {
+	server, _, processor, cardID := setup(t)
+	request := cards.AuthorizationRequest{ID: "A9", CardID: cardID, Amount: big.NewInt(700), Merchant: coffee}
+
+	first, err := processor.Authorize(request)
+	require.NoError(t, err)
+	second, err := processor.Authorize(request)
+	require.NoError(t, err)
+
+	assert.Equal(t, first.Holds[0].TransactionID, second.Holds[0].TransactionID)
+	assert.Equal(t, int64(700), balance(t, server, cardID).InflightDebitBalance.Int64())
+
+	_, err = processor.Load("missing")
+	assert.ErrorIs(t, err, cards.ErrAuthorizationNotFound)
+}
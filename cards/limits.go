@@ -0,0 +1,151 @@
+package cards
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// Limits are checked before a hold is placed. Amounts are in minor units and
+// nil amounts are unlimited.
+type Limits struct {
+	// PerAuthorization caps the total of an authorization, increments
+	// included.
+	PerAuthorization *big.Int
+	// Daily caps what a card authorizes per UTC day, less what was reversed.
+	Daily       *big.Int
+	BlockedMCCs []string
+	// UseMonitors declines holds that would meet the condition of a balance
+	// monitor on the card, e.g. inflight_debit_balance > 500.
+	UseMonitors bool
+}
+
+// check declines a hold of amount on card when the card is not active or a
+// limit would be exceeded. A card is active unless its card_state metadata
+// says otherwise.
+func (p *Processor) check(auth *Authorization, card *blnkgo.LedgerBalance, mcc string, amount *big.Int) error {
+	decline := func(reason DeclineReason, format string, args ...interface{}) error {
+		return &DeclineError{AuthorizationID: auth.ID, Reason: reason, Detail: fmt.Sprintf(format, args...)}
+	}
+
+	if state, ok := card.MetaData["card_state"].(string); ok && !strings.EqualFold(state, "active") {
+		return decline(DeclineCardInactive, "card is %s", state)
+	}
+	if mcc != "" && slices.Contains(p.Limits.BlockedMCCs, mcc) {
+		return decline(DeclineMerchantBlocked, "merchant category %s is blocked", mcc)
+	}
+
+	if limit := p.Limits.PerAuthorization; limit != nil {
+		total := new(big.Int).Add(auth.Held(), auth.Settled)
+		if total.Add(total, amount).Cmp(limit) > 0 {
+			return decline(DeclineLimitExceeded, "authorization total %s is over the limit of %s", total, limit)
+		}
+	}
+
+	if limit := p.Limits.Daily; limit != nil {
+		spent, err := p.spentToday(card.BalanceID)
+		if err != nil {
+			return err
+		}
+		if spent.Add(spent, amount).Cmp(limit) > 0 {
+			return decline(DeclineLimitExceeded, "daily total %s is over the limit of %s", spent, limit)
+		}
+	}
+
+	if p.Limits.UseMonitors {
+		monitors, _, err := p.monitors.List()
+		if err != nil {
+			return fmt.Errorf("failed to list balance monitors: %w", err)
+		}
+		for _, monitor := range monitors {
+			if monitor.BalanceID == card.BalanceID && triggers(monitor.Condition, card, amount) {
+				return decline(DeclineLimitExceeded, "balance monitor %s: %s %s %d", monitor.MonitorID, monitor.Condition.Field, monitor.Condition.Operator, monitor.Condition.Value)
+			}
+		}
+	}
+	return nil
+}
+
+// triggers reports whether condition would hold on card after a hold of
+// amount. Balance and debit_balance are projected as if the hold settled.
+// Conditions on other fields never trigger.
+func triggers(condition blnkgo.MonitorCondition, card *blnkgo.LedgerBalance, amount *big.Int) bool {
+	var value *big.Int
+	switch condition.Field {
+	case "balance":
+		value = new(big.Int).Sub(blnkgo.ValueOrZero(card.Balance), amount)
+	case "debit_balance":
+		value = new(big.Int).Add(blnkgo.ValueOrZero(card.DebitBalance), amount)
+	case "inflight_balance":
+		value = new(big.Int).Sub(blnkgo.ValueOrZero(card.InflightBalance), amount)
+	case "inflight_debit_balance":
+		value = new(big.Int).Add(blnkgo.ValueOrZero(card.InflightDebitBalance), amount)
+	default:
+		return false
+	}
+
+	precision := condition.Precision
+	if precision <= 0 {
+		precision = 1
+	}
+	threshold := new(big.Int).Mul(big.NewInt(condition.Value), big.NewInt(precision))
+	cmp := value.Cmp(threshold)
+	switch condition.Operator {
+	case blnkgo.OperatorGreaterThan:
+		return cmp > 0
+	case blnkgo.OperatorGreaterThanOrEqual:
+		return cmp >= 0
+	case blnkgo.OperatorLessThan:
+		return cmp < 0
+	case blnkgo.OperatorLessThanOrEqual:
+		return cmp <= 0
+	case blnkgo.OperatorEqualTo:
+		return cmp == 0
+	case blnkgo.OperatorNotEqualTo:
+		return cmp != 0
+	}
+	return false
+}
+
+// spentToday returns what a card has authorized since the start of the UTC
+// day, less what was voided.
+func (p *Processor) spentToday(cardID string) (*big.Int, error) {
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	transactions, err := p.transactions.FilterAll(blnkgo.FilterParams{
+		Filters: []blnkgo.Filter{
+			{Field: "source", Operator: blnkgo.OpEqual, Value: cardID},
+			{Field: "meta_data.card_event", Operator: blnkgo.OpIsNotNull},
+			{Field: "created_at", Operator: blnkgo.OpGreaterThanOrEqual, Value: start.Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the spending of card %s: %w", cardID, err)
+	}
+
+	spent := new(big.Int)
+	var holdIDs []interface{}
+	for _, transaction := range transactions {
+		if transaction.ParentTransactionID != "" || transaction.Status == blnkgo.PryTransactionStatusRejected {
+			continue
+		}
+		spent.Add(spent, transaction.PreciseValue())
+		if transaction.Inflight {
+			holdIDs = append(holdIDs, transaction.TransactionID)
+		}
+	}
+	children, err := p.children(holdIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		if child.Status == blnkgo.PryTransactionStatusVoid {
+			spent.Sub(spent, child.PreciseValue())
+		}
+	}
+	return spent, nil
+}
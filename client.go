@@ -11,6 +11,8 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/google/go-querystring/query"
@@ -43,14 +45,17 @@ type service struct {
 }
 
 type Options struct {
-	RetryCount int
-	Timeout    time.Duration
-	Logger     Logger
+	RetryCount     int
+	RetryWait      time.Duration
+	Timeout        time.Duration
+	Logger         Logger
+	UploadProgress UploadProgressFunc
 }
 
 func DefaultOptions() Options {
 	return Options{
 		RetryCount: 1,
+		RetryWait:  time.Second * 2,
 		Timeout:    time.Second * 10,
 		Logger:     NewDefaultLogger(),
 	}
@@ -158,10 +163,19 @@ func (c *Client) CallWithRetry(req *http.Request, resBody interface{}) (*http.Re
 	var err error
 
 	for i := 0; i < retryCount; i++ {
+		//rewind the request body before retrying, when the request supports it
+		if i > 0 && req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			req.Body = body
+		}
+
 		resp, err = c.client.Do(req)
 		if err != nil {
 			c.options.Logger.Info(err.Error())
-			time.Sleep(time.Second * 2)
+			time.Sleep(c.options.RetryWait)
 			continue
 		}
 
@@ -169,7 +183,7 @@ func (c *Client) CallWithRetry(req *http.Request, resBody interface{}) (*http.Re
 		if resp.StatusCode >= 500 {
 			logString := fmt.Sprintf("Request failed with status code %v and Status %v", resp.StatusCode, resp.Status)
 			c.options.Logger.Error(logString)
-			time.Sleep(time.Second * 2)
+			time.Sleep(c.options.RetryWait)
 			continue
 		}
 
@@ -196,34 +210,74 @@ func (c *Client) DecodeResponse(resp *http.Response, v interface{}) error {
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(v)
-	if err != nil {
+	//an empty body (e.g. 204 No Content) leaves v untouched
+	if err != nil && !errors.Is(err, io.EOF) {
 		return err
 	}
 
 	return nil
 }
 
+// NewFileUploadRequest builds a multipart POST request for endpoint. The body is
+// streamed through an io.Pipe, so the file is never buffered in memory and the
+// request is sent with chunked transfer encoding. Nothing is opened and no
+// goroutine is started until the body is first read.
+//
+// file may be a path (string), a []byte or an io.Reader. Paths, byte slices and
+// io.ReadSeekers also get a GetBody func so the body can be replayed on retries;
+// plain readers can only be sent once. io.ReadSeekers are replayed from the
+// offset they were at when the request was built. Resumable uploads are not
+// supported by the Blnk upload endpoints, so a failed upload is always resent
+// from the start.
 func (c *Client) NewFileUploadRequest(endpoint string, fileParam string, file interface{}, fileName string, fields map[string]string) (*http.Request, error) {
-	// Prepare multipart form data
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	// Add file to the form
-	var fileReader io.Reader
+	var (
+		open       func() (io.ReadCloser, error)
+		size       int64 = -1
+		replayable       = true
+	)
 
 	switch v := file.(type) {
 	case string: // File path
-		openedFile, err := os.Open(v)
+		//the file is only opened once the body is read, so an unsent request holds nothing open
+		info, err := os.Stat(v)
 		if err != nil {
 			return nil, err
 		}
-		defer openedFile.Close()
-		fileReader = openedFile
+		size = info.Size()
+		open = func() (io.ReadCloser, error) {
+			return os.Open(v)
+		}
 		if fileName == "" {
 			fileName = filepath.Base(v)
 		}
+	case []byte:
+		size = int64(len(v))
+		open = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(v)), nil
+		}
+		if fileName == "" {
+			fileName = "upload"
+		}
+	case io.ReadSeeker: // Rewindable stream
+		//replays start where the reader was positioned when the request was built
+		start, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		open = func() (io.ReadCloser, error) {
+			if _, err := v.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return io.NopCloser(v), nil
+		}
+		if fileName == "" {
+			fileName = "upload"
+		}
 	case io.Reader: // Read stream
-		fileReader = v
+		replayable = false
+		open = func() (io.ReadCloser, error) {
+			return io.NopCloser(v), nil
+		}
 		// Default file name
 		if fileName == "" {
 			fileName = "upload"
@@ -232,37 +286,143 @@ func (c *Client) NewFileUploadRequest(endpoint string, fileParam string, file in
 		return nil, fmt.Errorf("unsupported file input type")
 	}
 
-	part, err := writer.CreateFormFile(fileParam, fileName)
+	//the boundary is fixed up front so every replayed body matches the Content-Type header
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	getBody := func() (io.ReadCloser, error) {
+		return &lazyBody{start: func() (io.ReadCloser, error) {
+			src, err := open()
+			if err != nil {
+				return nil, err
+			}
+			return c.streamMultipart(boundary, fileParam, fileName, src, size, fields), nil
+		}}, nil
+	}
+
+	body, _ := getBody()
+
+	// Create the HTTP request
+	req, err := http.NewRequest(http.MethodPost, c.BaseURL.ResolveReference(&url.URL{Path: endpoint}).String(), body)
 	if err != nil {
+		body.Close()
 		return nil, err
 	}
-	if _, err := io.Copy(part, fileReader); err != nil {
-		return nil, err
+	if replayable {
+		req.GetBody = getBody
+	}
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	if c.ApiKey != nil {
+		req.Header.Add("X-Blnk-Key", *c.ApiKey)
 	}
 
-	// Add additional form fields
-	for key, value := range fields {
-		if err := writer.WriteField(key, value); err != nil {
-			return nil, err
+	return req, nil
+}
+
+// streamMultipart writes the form fields and the file into a pipe from a separate
+// goroutine and returns the read side. src is closed once it has been consumed.
+func (c *Client) streamMultipart(boundary, fileParam, fileName string, src io.ReadCloser, size int64, fields map[string]string) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	go func() {
+		defer src.Close()
+
+		writer := multipart.NewWriter(pw)
+		if err := writer.SetBoundary(boundary); err != nil {
+			pw.CloseWithError(err)
+			return
 		}
-	}
 
-	if err := writer.Close(); err != nil {
-		fmt.Println("in error", err)
-		return nil, err
-	}
+		// Add additional form fields first so the server can read them before the file
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := writer.WriteField(key, fields[key]); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
 
-	// Create the HTTP request
-	req, err := http.NewRequest(http.MethodPost, c.BaseURL.ResolveReference(&url.URL{Path: endpoint}).String(), io.NopCloser(body))
+		part, err := writer.CreateFormFile(fileParam, fileName)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		var reader io.Reader = src
+		if c.options.UploadProgress != nil {
+			reader = &progressReader{reader: src, fileName: fileName, total: size, report: c.options.UploadProgress}
+		}
+		if _, err := io.Copy(part, reader); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		pw.CloseWithError(writer.Close())
+	}()
+
+	return pr
+}
 
+// lazyBody starts a body on the first Read, so a request that is built but
+// never sent starts no goroutine and opens no file. The transport may Close it
+// from another goroutine while a Read is blocked, e.g. when the request is
+// cancelled, so the body is only read outside the lock.
+type lazyBody struct {
+	start  func() (io.ReadCloser, error)
+	mu     sync.Mutex
+	body   io.ReadCloser
+	err    error
+	closed bool
+}
+
+func (l *lazyBody) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	if l.body == nil && l.err == nil {
+		if l.closed {
+			l.err = io.ErrClosedPipe
+		} else {
+			l.body, l.err = l.start()
+		}
+	}
+	body, err := l.body, l.err
+	l.mu.Unlock()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	//print out the file type
-	if c.ApiKey != nil {
-		req.Header.Add("X-Blnk-Key", *c.ApiKey)
+	return body.Read(p)
+}
+
+func (l *lazyBody) Close() error {
+	l.mu.Lock()
+	l.closed = true
+	body := l.body
+	l.mu.Unlock()
+	if body == nil {
+		return nil
 	}
+	return body.Close()
+}
 
-	return req, nil
+// UploadProgressFunc is called as file uploads are streamed. total is -1 when the
+// size of the upload is not known in advance.
+type UploadProgressFunc func(fileName string, written, total int64)
+
+type progressReader struct {
+	reader   io.Reader
+	fileName string
+	written  int64
+	total    int64
+	report   UploadProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	if n > 0 {
+		p.written += int64(n)
+		p.report(p.fileName, p.written, p.total)
+	}
+	return n, err
 }
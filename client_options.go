@@ -16,8 +16,24 @@ func WithRetry(count int) ClientOption {
 	}
 }
 
+// WithRetryWait sets how long CallWithRetry waits before retrying a failed
+// request. The default is two seconds.
+func WithRetryWait(wait time.Duration) ClientOption {
+	return func(c *Client) {
+		c.options.RetryWait = wait
+	}
+}
+
 func WithTimeout(timeout time.Duration) ClientOption {
 	return func(c *Client) {
 		c.options.Timeout = timeout
 	}
 }
+
+// WithUploadProgress registers a callback that reports how many bytes of a file
+// upload have been streamed so far.
+func WithUploadProgress(fn UploadProgressFunc) ClientOption {
+	return func(c *Client) {
+		c.options.UploadProgress = fn
+	}
+}
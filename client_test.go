@@ -0,0 +1,210 @@
+package blnkgo_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type uploadRecorder struct {
+	mu       sync.Mutex
+	attempts int
+	files    []string
+	sources  []string
+	failFor  int
+}
+
+func (u *uploadRecorder) handler(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.attempts++
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	content, _ := io.ReadAll(file)
+	u.files = append(u.files, string(content))
+	u.sources = append(u.sources, r.FormValue("source"))
+
+	if u.attempts <= u.failFor {
+		http.Error(w, "temporary failure", http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte(`{"upload_id":"upload-123","record_count":2,"source":"bank"}`))
+}
+
+func newUploadClient(t *testing.T, rec *uploadRecorder, opts ...blnkgo.ClientOption) *blnkgo.Client {
+	server := httptest.NewServer(http.HandlerFunc(rec.handler))
+	t.Cleanup(server.Close)
+	baseURL, _ := url.Parse(server.URL)
+	return blnkgo.NewClient(baseURL, nil, opts...)
+}
+
+func TestClient_NewFileUploadRequest_StreamsFilePath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recon.csv")
+	require.NoError(t, os.WriteFile(path, []byte("id,amount\n1,100\n2,200\n"), 0o600))
+
+	var progress []int64
+	rec := &uploadRecorder{}
+	client := newUploadClient(t, rec, blnkgo.WithUploadProgress(func(fileName string, written, total int64) {
+		assert.Equal(t, "recon.csv", fileName)
+		assert.Equal(t, int64(22), total)
+		progress = append(progress, written)
+	}))
+
+	resp, httpResp, err := client.Reconciliation.Upload("bank", path, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, httpResp.StatusCode)
+	assert.Equal(t, "upload-123", resp.UploadID)
+	assert.Equal(t, []string{"id,amount\n1,100\n2,200\n"}, rec.files)
+	assert.Equal(t, []string{"bank"}, rec.sources)
+	assert.NotEmpty(t, progress)
+	assert.Equal(t, int64(22), progress[len(progress)-1])
+}
+
+func TestClient_NewFileUploadRequest_RetryReopensFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recon.csv")
+	require.NoError(t, os.WriteFile(path, []byte("id,amount\n1,100\n"), 0o600))
+
+	rec := &uploadRecorder{failFor: 1}
+	client := newUploadClient(t, rec, blnkgo.WithRetry(2), blnkgo.WithTimeout(5*time.Second))
+
+	req, err := client.NewFileUploadRequest("reconciliation/upload", "file", path, "", map[string]string{"source": "bank"})
+	require.NoError(t, err)
+	assert.NotNil(t, req.GetBody)
+	assert.Equal(t, int64(-1), req.ContentLength)
+
+	var out blnkgo.ReconciliationUploadResp
+	_, err = client.CallWithRetry(req, &out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rec.attempts)
+	assert.Equal(t, []string{"id,amount\n1,100\n", "id,amount\n1,100\n"}, rec.files)
+}
+
+func TestClient_NewFileUploadRequest_Readers(t *testing.T) {
+	rec := &uploadRecorder{}
+	client := newUploadClient(t, rec)
+
+	seekable, err := client.NewFileUploadRequest("reconciliation/upload", "file", bytes.NewReader([]byte("a,b")), "", nil)
+	require.NoError(t, err)
+	assert.NotNil(t, seekable.GetBody)
+
+	stream, err := client.NewFileUploadRequest("reconciliation/upload", "file", io.MultiReader(strings.NewReader("a,b")), "", nil)
+	require.NoError(t, err)
+	assert.Nil(t, stream.GetBody)
+
+	content, err := io.ReadAll(stream.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `filename="upload"`)
+	assert.Contains(t, string(content), "a,b")
+
+	// a positioned reader is replayed from where it was, not from the start
+	positioned := strings.NewReader("header\na,b")
+	_, err = positioned.Seek(7, io.SeekStart)
+	require.NoError(t, err)
+	req, err := client.NewFileUploadRequest("reconciliation/upload", "file", positioned, "", nil)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		body, err := req.GetBody()
+		require.NoError(t, err)
+		content, err = io.ReadAll(body)
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "header")
+		assert.Contains(t, string(content), "a,b")
+	}
+}
+
+func TestClient_NewFileUploadRequest_UnsentBody(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recon.csv")
+	require.NoError(t, os.WriteFile(path, []byte("id,amount\n"), 0o600))
+	client := newUploadClient(t, &uploadRecorder{})
+
+	// nothing is opened until the body is read, so the file can go away
+	req, err := client.NewFileUploadRequest("reconciliation/upload", "file", path, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(path))
+	_, err = io.ReadAll(req.Body)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, os.WriteFile(path, []byte("id,amount\n"), 0o600))
+	req, err = client.NewFileUploadRequest("reconciliation/upload", "file", path, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, req.Body.Close())
+	_, err = req.Body.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+func TestClient_NewFileUploadRequest_ClosedWhileReading(t *testing.T) {
+	client := newUploadClient(t, &uploadRecorder{})
+
+	for i := 0; i < 5; i++ {
+		// the upload never ends, so the read blocks until the body is closed,
+		// as the transport does from its own goroutine when a request is
+		// cancelled
+		src, feed := io.Pipe()
+		req, err := client.NewFileUploadRequest("reconciliation/upload", "file", src, "", nil)
+		require.NoError(t, err)
+
+		read := make(chan error, 1)
+		go func() {
+			_, err := io.ReadAll(req.Body)
+			read <- err
+		}()
+		// the write returns once the body has started and taken it
+		_, err = feed.Write([]byte("id,amount\n"))
+		require.NoError(t, err)
+		require.NoError(t, req.Body.Close())
+
+		select {
+		case err := <-read:
+			assert.Error(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("read not unblocked by close")
+		}
+		feed.Close()
+	}
+
+	// a close racing the first read either stops the body or keeps it from
+	// starting
+	for i := 0; i < 20; i++ {
+		src, feed := io.Pipe()
+		feed.Close()
+		req, err := client.NewFileUploadRequest("reconciliation/upload", "file", src, "", nil)
+		require.NoError(t, err)
+		read := make(chan struct{})
+		go func() {
+			defer close(read)
+			_, _ = io.ReadAll(req.Body)
+		}()
+		_ = req.Body.Close()
+		<-read
+	}
+}
+
+func TestClient_NewFileUploadRequest_InvalidInput(t *testing.T) {
+	client := newUploadClient(t, &uploadRecorder{})
+
+	_, err := client.NewFileUploadRequest("reconciliation/upload", "file", 42, "", nil)
+	assert.Error(t, err)
+
+	_, err = client.NewFileUploadRequest("reconciliation/upload", "file", filepath.Join(t.TempDir(), "missing.csv"), "", nil)
+	assert.Error(t, err)
+}
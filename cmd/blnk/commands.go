@@ -0,0 +1,627 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// metaFlag collects repeated key=value flags into meta_data. Values are
+// strings.
+type metaFlag map[string]interface{}
+
+func (m metaFlag) String() string { return fmt.Sprint(map[string]interface{}(m)) }
+
+func (m metaFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("want key=value, got %q", s)
+	}
+	m[key] = value
+	return nil
+}
+
+// listFlag collects repeated flags.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func ledgersList(a *app, args []string) error {
+	fs := a.flags("ledgers list")
+	if _, err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	ledgers, _, err := blnkgo.NewLedgerService(client).List()
+	if err != nil {
+		return err
+	}
+	return a.print(ledgers, ledgerColumns)
+}
+
+func ledgersGet(a *app, args []string) error {
+	fs := a.flags("ledgers get <ledger-id>")
+	rest, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	ledger, _, err := blnkgo.NewLedgerService(client).Get(rest[0])
+	if err != nil {
+		return err
+	}
+	return a.print(ledger, ledgerColumns)
+}
+
+func ledgersCreate(a *app, args []string) error {
+	fs := a.flags("ledgers create --name <name> [--meta key=value]...")
+	name := fs.String("name", "", "ledger name")
+	meta := metaFlag{}
+	fs.Var(meta, "meta", "metadata as key=value, repeatable")
+	if _, err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("--name is required")
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	request := blnkgo.CreateLedgerRequest{Name: *name}
+	if len(meta) > 0 {
+		request.MetaData = meta
+	}
+	ledger, _, err := blnkgo.NewLedgerService(client).Create(request)
+	if err != nil {
+		return err
+	}
+	return a.print(ledger, ledgerColumns)
+}
+
+func ledgersFilter(a *app, args []string) error {
+	fs := a.flags("ledgers filter [flags] [expression]...")
+	var ff filterFlags
+	ff.register(fs)
+	exprs, err := a.parse(fs, args, -1)
+	if err != nil {
+		return err
+	}
+	params, err := ff.params(exprs)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	service := blnkgo.NewLedgerService(client)
+	var ledgers []blnkgo.Ledger
+	if ff.all {
+		ledgers, err = service.FilterAll(params)
+	} else {
+		ledgers, err = filterPage[blnkgo.Ledger](service.Filter, params)
+	}
+	if err != nil {
+		return err
+	}
+	return a.print(ledgers, ledgerColumns)
+}
+
+// filterPage fetches one page of filter results.
+func filterPage[T any](filter func(blnkgo.FilterParams) (*blnkgo.FilterResponse, *http.Response, error), params blnkgo.FilterParams) ([]T, error) {
+	response, _, err := filter(params)
+	if err != nil {
+		return nil, err
+	}
+	var records []T
+	if err := response.DecodeData(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func balancesGet(a *app, args []string) error {
+	fs := a.flags("balances get <balance-id>")
+	rest, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	balance, _, err := blnkgo.NewLedgerBalanceService(client).Get(rest[0])
+	if err != nil {
+		return err
+	}
+	return a.print(balance, balanceColumns)
+}
+
+func balancesByIndicator(a *app, args []string) error {
+	fs := a.flags("balances by-indicator <indicator> --currency <currency>")
+	currency := fs.String("currency", "", "balance currency")
+	rest, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	balance, _, err := blnkgo.NewLedgerBalanceService(client).GetByIndicator(rest[0], *currency)
+	if err != nil {
+		return err
+	}
+	return a.print(balance, balanceColumns)
+}
+
+func balancesHistory(a *app, args []string) error {
+	fs := a.flags("balances history <balance-id> --at <time>")
+	at := fs.String("at", "", "point in time, RFC 3339 or YYYY-MM-DD (end of day UTC)")
+	fromSource := fs.Bool("from-source", false, "rebuild the balance from its transactions")
+	rest, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	timestamp, err := parseTime(*at)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	historical, _, err := blnkgo.NewLedgerBalanceService(client).GetHistorical(rest[0], timestamp, *fromSource)
+	if err != nil {
+		return err
+	}
+	return a.print(historical, historyColumns)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("--at is required")
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, want RFC 3339 or YYYY-MM-DD", s)
+	}
+	return day.Add(24*time.Hour - time.Second), nil
+}
+
+func txCreate(a *app, args []string) error {
+	fs := a.flags("tx create --source <id> --destination <id> --amount <amount> --currency <code> --reference <ref>")
+	var request blnkgo.CreateTransactionRequest
+	fs.StringVar(&request.Source, "source", "", "source balance ID or @indicator")
+	fs.StringVar(&request.Destination, "destination", "", "destination balance ID or @indicator")
+	amount := fs.String("amount", "", "amount in major units, e.g. 12.50")
+	fs.Int64Var(&request.Precision, "precision", 100, "precision of the amount")
+	fs.StringVar(&request.Currency, "currency", "", "currency code")
+	fs.StringVar(&request.Reference, "reference", "", "unique reference")
+	fs.StringVar(&request.Description, "description", "", "description")
+	fs.BoolVar(&request.Inflight, "inflight", false, "hold the amount until committed or voided")
+	fs.BoolVar(&request.AllowOverdraft, "allow-overdraft", false, "let the source go negative")
+	fs.BoolVar(&request.SkipQueue, "skip-queue", false, "apply the transaction synchronously")
+	meta := metaFlag{}
+	fs.Var(meta, "meta", "metadata as key=value, repeatable")
+	if _, err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	switch {
+	case request.Source == "" || request.Destination == "":
+		return fmt.Errorf("--source and --destination are required")
+	case request.Currency == "":
+		return fmt.Errorf("--currency is required")
+	case request.Reference == "":
+		return fmt.Errorf("--reference is required")
+	}
+	precise, err := parseAmount(*amount, request.Precision)
+	if err != nil {
+		return err
+	}
+	request.PreciseAmount = precise
+	if len(meta) > 0 {
+		request.MetaData = meta
+	}
+
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	transaction, _, err := blnkgo.NewTransactionService(client).Create(request)
+	if err != nil {
+		return err
+	}
+	return a.print(transaction, transactionColumns)
+}
+
+func parseAmount(amount string, precision int64) (*big.Int, error) {
+	if amount == "" {
+		return nil, fmt.Errorf("--amount is required")
+	}
+	precise, ok := blnkgo.ParsePreciseAmount(amount, precision)
+	if !ok || precise.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid amount %q for precision %d", amount, precision)
+	}
+	return precise, nil
+}
+
+func txGet(a *app, args []string) error {
+	fs := a.flags("tx get <transaction-id>")
+	rest, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	transaction, _, err := blnkgo.NewTransactionService(client).Get(rest[0])
+	if err != nil {
+		return err
+	}
+	return a.print(transaction, transactionColumns)
+}
+
+func txCommit(a *app, args []string) error {
+	fs := a.flags("tx commit <transaction-id> [--amount <amount>]")
+	amount := fs.String("amount", "", "commit part of the held amount, in major units")
+	precision := fs.Int64("precision", 100, "precision of --amount")
+	rest, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	update := blnkgo.UpdateStatus{Status: blnkgo.InflightStatusCommit}
+	if *amount != "" {
+		if update.PreciseAmount, err = parseAmount(*amount, *precision); err != nil {
+			return err
+		}
+	}
+	return a.updateInflight(rest[0], update)
+}
+
+func txVoid(a *app, args []string) error {
+	fs := a.flags("tx void <transaction-id>")
+	rest, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	return a.updateInflight(rest[0], blnkgo.UpdateStatus{Status: blnkgo.InflightStatusVoid})
+}
+
+func (a *app) updateInflight(id string, update blnkgo.UpdateStatus) error {
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	transaction, _, err := blnkgo.NewTransactionService(client).Update(id, update)
+	if err != nil {
+		return err
+	}
+	return a.print(transaction, transactionColumns)
+}
+
+func txRefund(a *app, args []string) error {
+	fs := a.flags("tx refund <transaction-id>")
+	rest, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	transaction, _, err := blnkgo.NewTransactionService(client).Refund(rest[0])
+	if err != nil {
+		return err
+	}
+	return a.print(transaction, transactionColumns)
+}
+
+func txFilter(a *app, args []string) error {
+	fs := a.flags("tx filter [flags] [expression]...")
+	var ff filterFlags
+	ff.register(fs)
+	exprs, err := a.parse(fs, args, -1)
+	if err != nil {
+		return err
+	}
+	params, err := ff.params(exprs)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	service := blnkgo.NewTransactionService(client)
+	var transactions []blnkgo.Transaction
+	if ff.all {
+		transactions, err = service.FilterAll(params)
+	} else {
+		transactions, err = filterPage[blnkgo.Transaction](service.Filter, params)
+	}
+	if err != nil {
+		return err
+	}
+	return a.print(transactions, transactionColumns)
+}
+
+func monitorsList(a *app, args []string) error {
+	fs := a.flags("monitors list")
+	if _, err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	monitors, _, err := blnkgo.NewBalanceMonitorService(client).List()
+	if err != nil {
+		return err
+	}
+	return a.print(monitors, monitorColumns)
+}
+
+func monitorsGet(a *app, args []string) error {
+	fs := a.flags("monitors get <monitor-id>")
+	rest, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	monitor, _, err := blnkgo.NewBalanceMonitorService(client).Get(rest[0])
+	if err != nil {
+		return err
+	}
+	return a.print(monitor, monitorColumns)
+}
+
+func monitorsCreate(a *app, args []string) error {
+	fs := a.flags("monitors create --balance <id> --field <field> --op <op> --value <value>")
+	var data blnkgo.MonitorData
+	fs.StringVar(&data.BalanceID, "balance", "", "balance ID")
+	fs.StringVar(&data.Condition.Field, "field", "balance", "balance field to watch")
+	op := fs.String("op", "", "comparison: >, <, =, !=, >= or <=")
+	fs.Int64Var(&data.Condition.Value, "value", 0, "threshold in major units")
+	fs.Int64Var(&data.Condition.Precision, "precision", 100, "precision of the balance")
+	fs.StringVar(&data.Description, "description", "", "description")
+	fs.StringVar(&data.CallBackURL, "callback", "", "URL called when the condition is met")
+	if _, err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if data.BalanceID == "" {
+		return fmt.Errorf("--balance is required")
+	}
+	data.Condition.Operator = blnkgo.MonitorConditionOperators(*op)
+	switch data.Condition.Operator {
+	case blnkgo.OperatorGreaterThan, blnkgo.OperatorLessThan, blnkgo.OperatorEqualTo,
+		blnkgo.OperatorNotEqualTo, blnkgo.OperatorGreaterThanOrEqual, blnkgo.OperatorLessThanOrEqual:
+	default:
+		return fmt.Errorf("invalid --op %q, want >, <, =, !=, >= or <=", *op)
+	}
+
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	monitor, _, err := blnkgo.NewBalanceMonitorService(client).Create(data)
+	if err != nil {
+		return err
+	}
+	return a.print(monitor, monitorColumns)
+}
+
+func identitiesList(a *app, args []string) error {
+	fs := a.flags("identities list")
+	if _, err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	identities, _, err := blnkgo.NewIdentityService(client).List()
+	if err != nil {
+		return err
+	}
+	return a.print(identities, identityColumns)
+}
+
+func identitiesGet(a *app, args []string) error {
+	fs := a.flags("identities get <identity-id>")
+	rest, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	identity, _, err := blnkgo.NewIdentityService(client).Get(rest[0])
+	if err != nil {
+		return err
+	}
+	return a.print(identity, identityColumns)
+}
+
+func identitiesCreate(a *app, args []string) error {
+	fs := a.flags("identities create --file <identity.json|->")
+	file := fs.String("file", "", "JSON identity, - for stdin")
+	if _, err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("--file is required")
+	}
+	var r io.Reader = a.stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	var identity blnkgo.Identity
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&identity); err != nil {
+		return fmt.Errorf("failed to read identity: %w", err)
+	}
+
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	created, _, err := blnkgo.NewIdentityService(client).Create(identity)
+	if err != nil {
+		return err
+	}
+	return a.print(created, identityColumns)
+}
+
+func identitiesDelete(a *app, args []string) error {
+	fs := a.flags("identities delete <identity-id>")
+	rest, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	response, _, err := blnkgo.NewIdentityService(client).Delete(rest[0])
+	if err != nil {
+		return err
+	}
+	return a.print(response, messageColumns)
+}
+
+var searchColumns = map[blnkgo.ResourceType][]string{
+	blnkgo.Ledgers:      {"ledger_id", "name", "created_at"},
+	blnkgo.Balances:     {"balance_id", "ledger_id", "currency", "balance"},
+	blnkgo.Transactions: {"transaction_id", "reference", "status", "source", "destination", "precise_amount", "currency"},
+}
+
+func search(a *app, args []string) error {
+	fs := a.flags("search <ledgers|balances|transactions> [query]")
+	var params blnkgo.SearchParams
+	fs.StringVar(&params.QueryBy, "query-by", "", "fields to match the query against")
+	fs.StringVar(&params.FilterBy, "filter-by", "", "filter, e.g. status:APPLIED")
+	fs.StringVar(&params.SortBy, "sort-by", "", "sort, e.g. created_at:desc")
+	fs.IntVar(&params.Page, "page", 1, "page number")
+	fs.IntVar(&params.PerPage, "per-page", 20, "hits per page")
+	rest, err := a.parse(fs, args, -1)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 || len(rest) > 2 {
+		fs.Usage()
+		return errUsage
+	}
+	resource := blnkgo.ResourceType(rest[0])
+	columns, ok := searchColumns[resource]
+	if !ok {
+		return fmt.Errorf("cannot search %q, want ledgers, balances or transactions", rest[0])
+	}
+	params.Q = "*"
+	if len(rest) == 2 {
+		params.Q = rest[1]
+	}
+
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	response, _, err := blnkgo.NewSearchService(client).SearchDocument(params, resource)
+	if err != nil {
+		return err
+	}
+	if a.output == "json" {
+		return a.print(response, nil)
+	}
+	documents := make([]blnkgo.SearchDocument, len(response.Hits))
+	for i, hit := range response.Hits {
+		documents[i] = hit.Document
+	}
+	return a.print(documents, columns)
+}
+
+func reconUpload(a *app, args []string) error {
+	fs := a.flags("recon upload --source <name> <file>")
+	source := fs.String("source", "", "name of the external source, e.g. the bank")
+	rest, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *source == "" {
+		return fmt.Errorf("--source is required")
+	}
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	upload, _, err := blnkgo.NewReconciliationService(client).Upload(*source, rest[0], filepath.Base(rest[0]))
+	if err != nil {
+		return err
+	}
+	return a.print(upload, uploadColumns)
+}
+
+func reconRun(a *app, args []string) error {
+	fs := a.flags("recon run --upload <upload-id> --rule <rule-id>...")
+	var data blnkgo.RunReconData
+	var rules listFlag
+	fs.StringVar(&data.UploadID, "upload", "", "upload ID")
+	strategy := fs.String("strategy", string(blnkgo.ReconciliationStrategyOneToOne), "one_to_one, one_to_many or many_to_one")
+	grouping := fs.String("group-by", "", "field grouping records for one_to_many and many_to_one")
+	fs.Var(&rules, "rule", "matching rule ID, repeatable")
+	fs.BoolVar(&data.DryRun, "preview", false, "ask Blnk to match without recording the results")
+	if _, err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if data.UploadID == "" || len(rules) == 0 {
+		return fmt.Errorf("--upload and at least one --rule are required")
+	}
+	data.Strategy = blnkgo.ReconciliationStrategy(*strategy)
+	data.GroupingCriteria = blnkgo.CriteriaField(*grouping)
+	data.MatchingRuleIDs = rules
+
+	client, err := a.connect()
+	if err != nil {
+		return err
+	}
+	result, _, err := blnkgo.NewReconciliationService(client).Run(data)
+	if err != nil {
+		return err
+	}
+	return a.print(result, reconColumns)
+}
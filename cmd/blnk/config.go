@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// loadConfig resolves the client config. The config file is
+// ~/.blnk/config.yaml unless --config or BLNK_CONFIG name another; a missing
+// default file is skipped unless a profile was asked for. BLNK_* variables
+// override the profile and the --url and --api-key flags override both.
+func (a *app) loadConfig() (*blnkgo.Config, error) {
+	path := firstNonEmpty(a.configPath, os.Getenv("BLNK_CONFIG"))
+	explicit := path != "" || a.profile != "" || os.Getenv("BLNK_PROFILE") != ""
+	if path == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, ".blnk", "config.yaml")
+		}
+	}
+
+	var config *blnkgo.Config
+	var err error
+	if _, statErr := os.Stat(path); path != "" && (statErr == nil || explicit) {
+		config, err = blnkgo.LoadConfig(path, a.profile)
+	} else {
+		config, err = blnkgo.ConfigFromEnv()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.url != "" {
+		config.BaseURL = a.url
+	}
+	if a.apiKey != "" {
+		config.ApiKey = blnkgo.Secret(a.apiKey)
+	}
+	return config, nil
+}
+
+// connect builds the client, wrapped to print requests with --dry-run.
+func (a *app) connect() (blnkgo.ClientInterface, error) {
+	if a.client != nil {
+		return a.client, nil
+	}
+	config, err := a.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("no server configured, set --url, BLNK_URL or a profile")
+	}
+	client, err := blnkgo.NewClientWithConfig(config, blnkgo.WithLogger(cliLogger{a}))
+	if err != nil {
+		return nil, err
+	}
+	a.client = client
+	if a.dryRun {
+		a.client = &dryRunClient{ClientInterface: client, out: a.stdout}
+	}
+	return a.client, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// cliLogger prints the HTTP client's logs to stderr with -v. Errors reach the
+// user through the returned error either way.
+type cliLogger struct{ a *app }
+
+func (l cliLogger) Info(msg string) {
+	if l.a.verbose {
+		fmt.Fprintln(l.a.stderr, "blnk:", msg)
+	}
+}
+
+func (l cliLogger) Error(msg string) {
+	if l.a.verbose {
+		fmt.Fprintln(l.a.stderr, "blnk:", msg)
+	}
+}
+
+// dryRunClient prints requests instead of sending them. The API key is never
+// printed.
+type dryRunClient struct {
+	blnkgo.ClientInterface
+	out io.Writer
+}
+
+func (c *dryRunClient) CallWithRetry(req *http.Request, _ interface{}) (*http.Response, error) {
+	fmt.Fprintf(c.out, "%s %s\n", req.Method, req.URL)
+	if req.Body == nil {
+		return nil, errDryRun
+	}
+	if contentType := req.Header.Get("Content-Type"); strings.HasPrefix(contentType, "multipart/") {
+		fmt.Fprintf(c.out, "Content-Type: %s\n", contentType)
+		req.Body.Close()
+		return nil, errDryRun
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var indented bytes.Buffer
+	if json.Indent(&indented, body, "", "  ") == nil {
+		body = indented.Bytes()
+	}
+	fmt.Fprintf(c.out, "%s\n", bytes.TrimSpace(body))
+	return nil, errDryRun
+}
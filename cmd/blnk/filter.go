@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// symbolOperators are tried longest first so ">=" is not read as ">".
+var symbolOperators = []struct {
+	symbol   string
+	operator blnkgo.Operator
+}{
+	{"!=", blnkgo.OpNotEqual},
+	{">=", blnkgo.OpGreaterThanOrEqual},
+	{"<=", blnkgo.OpLessThanOrEqual},
+	{"=", blnkgo.OpEqual},
+	{">", blnkgo.OpGreaterThan},
+	{"<", blnkgo.OpLessThan},
+	{"~", blnkgo.OpILike},
+}
+
+var namedOperators = map[string]blnkgo.Operator{
+	"eq": blnkgo.OpEqual, "ne": blnkgo.OpNotEqual,
+	"gt": blnkgo.OpGreaterThan, "gte": blnkgo.OpGreaterThanOrEqual,
+	"lt": blnkgo.OpLessThan, "lte": blnkgo.OpLessThanOrEqual,
+	"in": blnkgo.OpIn, "between": blnkgo.OpBetween,
+	"like": blnkgo.OpLike, "ilike": blnkgo.OpILike,
+	"isnull": blnkgo.OpIsNull, "isnotnull": blnkgo.OpIsNotNull,
+}
+
+// parseFilter parses a filter expression. The short forms are field=value,
+// field!=value, field>value, field>=value, field<value, field<=value and
+// field~pattern (case-insensitive LIKE). Every operator is also available as
+// field:op=value, e.g. status:in=APPLIED,QUEUED, amount:between=100,500 or
+// meta_data.card_id:isnotnull.
+func parseFilter(expr string) (blnkgo.Filter, error) {
+	end := strings.IndexAny(expr, ":!=<>~")
+	if end <= 0 {
+		return blnkgo.Filter{}, fmt.Errorf("invalid filter %q, want field<op>value", expr)
+	}
+	field, rest := expr[:end], expr[end:]
+
+	if strings.HasPrefix(rest, ":") {
+		name, value, hasValue := strings.Cut(rest[1:], "=")
+		operator, ok := namedOperators[strings.ToLower(name)]
+		if !ok {
+			return blnkgo.Filter{}, fmt.Errorf("invalid filter %q, unknown operator %q", expr, name)
+		}
+		filter := blnkgo.Filter{Field: field, Operator: operator}
+		switch operator {
+		case blnkgo.OpIsNull, blnkgo.OpIsNotNull:
+			if hasValue {
+				return blnkgo.Filter{}, fmt.Errorf("invalid filter %q, %s takes no value", expr, name)
+			}
+		case blnkgo.OpIn, blnkgo.OpBetween:
+			for _, v := range strings.Split(value, ",") {
+				filter.Values = append(filter.Values, v)
+			}
+			if operator == blnkgo.OpBetween && len(filter.Values) != 2 {
+				return blnkgo.Filter{}, fmt.Errorf("invalid filter %q, between takes two values", expr)
+			}
+		default:
+			if !hasValue {
+				return blnkgo.Filter{}, fmt.Errorf("invalid filter %q, %s needs a value", expr, name)
+			}
+			filter.Value = value
+		}
+		return filter, nil
+	}
+
+	for _, op := range symbolOperators {
+		if strings.HasPrefix(rest, op.symbol) {
+			return blnkgo.Filter{Field: field, Operator: op.operator, Value: rest[len(op.symbol):]}, nil
+		}
+	}
+	return blnkgo.Filter{}, fmt.Errorf("invalid filter %q, want field<op>value", expr)
+}
+
+// filterFlags registers the paging and sorting flags of filter commands.
+type filterFlags struct {
+	limit     int
+	offset    int
+	sortBy    string
+	sortOrder string
+	all       bool
+}
+
+func (f *filterFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&f.limit, "limit", 20, "records per page")
+	fs.IntVar(&f.offset, "offset", 0, "records to skip")
+	fs.StringVar(&f.sortBy, "sort", "created_at", "field to sort by")
+	fs.StringVar(&f.sortOrder, "order", "desc", "sort order, asc or desc")
+	fs.BoolVar(&f.all, "all", false, "fetch every matching record, ignoring --limit and --offset")
+}
+
+func (f *filterFlags) params(exprs []string) (blnkgo.FilterParams, error) {
+	params := blnkgo.FilterParams{
+		Filters:   []blnkgo.Filter{},
+		Limit:     f.limit,
+		Offset:    f.offset,
+		SortBy:    f.sortBy,
+		SortOrder: f.sortOrder,
+	}
+	if f.all {
+		params.Limit, params.Offset = 0, 0
+	}
+	for _, expr := range exprs {
+		filter, err := parseFilter(expr)
+		if err != nil {
+			return params, err
+		}
+		params.Filters = append(params.Filters, filter)
+	}
+	return params, nil
+}
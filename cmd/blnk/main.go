@@ -0,0 +1,240 @@
+// Command blnk is a command-line client for the Blnk API built on the Go SDK.
+//
+// Commands map to the SDK services:
+//
+//	blnk ledgers list|get|create|filter
+//	blnk balances get|by-indicator|history
+//	blnk tx create|get|commit|void|refund|filter
+//	blnk monitors list|get|create
+//	blnk identities list|get|create|delete
+//	blnk search <ledgers|balances|transactions> [query]
+//	blnk recon upload|run
+//
+// The server and API key come from --url and --api-key, the BLNK_*
+// environment variables read by blnkgo.ConfigFromEnv or a profile in
+// ~/.blnk/config.yaml, in that order. Output is a table by default; -o json and -o csv are also
+// supported. With --dry-run, requests are printed instead of sent.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+func main() {
+	a := &app{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	os.Exit(a.run(os.Args[1:]))
+}
+
+// app holds the streams and global flags of one invocation, so tests can run
+// commands in-process.
+type app struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	url        string
+	apiKey     string
+	profile    string
+	configPath string
+	output     string
+	dryRun     bool
+	verbose    bool
+
+	client blnkgo.ClientInterface
+}
+
+type command func(a *app, args []string) error
+
+var commands = map[string]map[string]command{
+	"ledgers": {
+		"list":   ledgersList,
+		"get":    ledgersGet,
+		"create": ledgersCreate,
+		"filter": ledgersFilter,
+	},
+	"balances": {
+		"get":          balancesGet,
+		"by-indicator": balancesByIndicator,
+		"history":      balancesHistory,
+	},
+	"tx": {
+		"create": txCreate,
+		"get":    txGet,
+		"commit": txCommit,
+		"void":   txVoid,
+		"refund": txRefund,
+		"filter": txFilter,
+	},
+	"monitors": {
+		"list":   monitorsList,
+		"get":    monitorsGet,
+		"create": monitorsCreate,
+	},
+	"identities": {
+		"list":   identitiesList,
+		"get":    identitiesGet,
+		"create": identitiesCreate,
+		"delete": identitiesDelete,
+	},
+	"recon": {
+		"upload": reconUpload,
+		"run":    reconRun,
+	},
+}
+
+// errDryRun is returned by the dry-run client instead of sending a request.
+var errDryRun = errors.New("dry run")
+
+// errUsage is returned when the arguments are wrong and usage was printed.
+var errUsage = errors.New("usage")
+
+func (a *app) run(args []string) int {
+	fs := a.flags("blnk")
+	fs.Usage = func() { a.usage() }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	args = fs.Args()
+	if len(args) == 0 || args[0] == "help" {
+		a.usage()
+		return 2
+	}
+
+	var err error
+	if args[0] == "search" {
+		err = search(a, args[1:])
+	} else {
+		group, ok := commands[args[0]]
+		if !ok {
+			fmt.Fprintf(a.stderr, "blnk: unknown command %q\n", args[0])
+			a.usage()
+			return 2
+		}
+		if len(args) < 2 {
+			a.groupUsage(args[0])
+			return 2
+		}
+		cmd, ok := group[args[1]]
+		if !ok {
+			fmt.Fprintf(a.stderr, "blnk: unknown command %q\n", args[0]+" "+args[1])
+			a.groupUsage(args[0])
+			return 2
+		}
+		err = cmd(a, args[2:])
+	}
+
+	switch {
+	case err == nil, errors.Is(err, errDryRun):
+		return 0
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		return 2
+	}
+	fmt.Fprintf(a.stderr, "blnk: %v\n", err)
+	return 1
+}
+
+// flags returns a flag set with the global flags registered, so they can be
+// given before or after the command. name is the usage line of the command.
+func (a *app) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "usage: blnk %s\n", name)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&a.url, "url", a.url, "Blnk server URL")
+	fs.StringVar(&a.apiKey, "api-key", a.apiKey, "Blnk API key")
+	fs.StringVar(&a.profile, "profile", a.profile, "profile in the config file")
+	fs.StringVar(&a.configPath, "config", a.configPath, "config file (default ~/.blnk/config.yaml)")
+	if a.output == "" {
+		a.output = "table"
+	}
+	fs.StringVar(&a.output, "o", a.output, "output format: table, json or csv")
+	fs.BoolVar(&a.dryRun, "dry-run", a.dryRun, "print requests instead of sending them")
+	fs.BoolVar(&a.verbose, "v", a.verbose, "log retries and errors of the HTTP client")
+	return fs
+}
+
+// parse parses the flags of a command and checks that it got want positional
+// arguments. A negative want accepts any number.
+func (a *app) parse(fs *flag.FlagSet, args []string, want int) ([]string, error) {
+	if err := fs.Parse(interleave(fs, args)); err != nil {
+		return nil, errUsage
+	}
+	rest := fs.Args()
+	if want >= 0 && len(rest) != want {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return rest, nil
+}
+
+// interleave moves flags after positional arguments in front of them, since
+// the flag package stops at the first positional argument.
+func interleave(fs *flag.FlagSet, args []string) []string {
+	var flags, positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			continue
+		}
+		flags = append(flags, arg)
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		if f := fs.Lookup(name); f != nil && !isBoolFlag(f) && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+	return append(flags, positional...)
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func (a *app) usage() {
+	fmt.Fprintln(a.stderr, "usage: blnk [flags] <command> <action> [arguments]")
+	fmt.Fprintln(a.stderr, "\ncommands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(a.stderr, "  %-11s %s\n", name, strings.Join(actions(name), ", "))
+	}
+	fmt.Fprintf(a.stderr, "  %-11s <ledgers|balances|transactions> [query]\n", "search")
+	fmt.Fprintln(a.stderr, "\nflags:")
+	a.flags("blnk").PrintDefaults()
+}
+
+func (a *app) groupUsage(group string) {
+	fmt.Fprintf(a.stderr, "usage: blnk %s <%s> [arguments]\n", group, strings.Join(actions(group), "|"))
+	fmt.Fprintf(a.stderr, "run 'blnk %s <action> -h' for the flags of an action\n", group)
+}
+
+func actions(group string) []string {
+	var names []string
+	for name := range commands[group] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
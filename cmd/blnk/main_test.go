@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type result struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+func runCLI(t *testing.T, env map[string]string, args ...string) result {
+	t.Helper()
+	// keep a config file in the real home directory out of the tests
+	t.Setenv("HOME", t.TempDir())
+	for _, key := range []string{"BLNK_URL", "BLNK_API_KEY", "BLNK_CONFIG", "BLNK_PROFILE"} {
+		t.Setenv(key, env[key])
+	}
+	var stdout, stderr bytes.Buffer
+	a := &app{
+		stdin:  strings.NewReader(""),
+		stdout: &stdout,
+		stderr: &stderr,
+	}
+	code := a.run(args)
+	return result{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+func TestLedgersAndTransactions(t *testing.T) {
+	server := blnktest.NewServer(t)
+	env := map[string]string{"BLNK_URL": server.URL}
+
+	res := runCLI(t, env, "ledgers", "create", "--name", "Wallets", "--meta", "team=ops", "-o", "json")
+	require.Equal(t, 0, res.code, res.stderr)
+	var ledger blnkgo.Ledger
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &ledger))
+	assert.Equal(t, "ops", ledger.MetaData["team"])
+
+	res = runCLI(t, env, "ledgers", "list")
+	require.Equal(t, 0, res.code, res.stderr)
+	assert.Contains(t, res.stdout, "LEDGER_ID")
+	assert.Contains(t, res.stdout, "Wallets")
+
+	balance, _, err := server.Client().LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: ledger.LedgerID, Currency: "USD"})
+	require.NoError(t, err)
+
+	res = runCLI(t, env, "tx", "create", "--source", "@world", "--destination", balance.BalanceID,
+		"--amount", "12.50", "--currency", "USD", "--reference", "ref-1", "--inflight", "-o", "json")
+	require.Equal(t, 0, res.code, res.stderr)
+	var hold blnkgo.Transaction
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &hold))
+	assert.Equal(t, int64(1250), hold.PreciseAmount.Int64())
+
+	res = runCLI(t, env, "tx", "commit", hold.TransactionID, "--amount", "10")
+	require.Equal(t, 0, res.code, res.stderr)
+	stored, _ := server.Balance(balance.BalanceID)
+	assert.Equal(t, int64(1000), stored.Balance.Int64())
+
+	res = runCLI(t, env, "-o", "csv", "tx", "filter", "reference~ref-1%", "status:in=APPLIED,INFLIGHT", "--order", "asc")
+	require.Equal(t, 0, res.code, res.stderr)
+	lines := strings.Split(strings.TrimSpace(res.stdout), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, strings.Join(transactionColumns, ","), lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], hold.TransactionID+",ref-1,"), lines[1])
+
+	res = runCLI(t, env, "balances", "get", balance.BalanceID, "-o", "csv")
+	require.Equal(t, 0, res.code, res.stderr)
+	assert.Contains(t, res.stdout, ",USD,1000,")
+
+	res = runCLI(t, env, "tx", "get", "txn_missing")
+	assert.Equal(t, 1, res.code)
+	assert.Contains(t, res.stderr, "404")
+}
+
+func TestDryRun(t *testing.T) {
+	env := map[string]string{"BLNK_URL": "http://blnk.invalid:5001", "BLNK_API_KEY": "secret"}
+
+	res := runCLI(t, env, "--dry-run", "tx", "void", "txn_123")
+	require.Equal(t, 0, res.code, res.stderr)
+	assert.Equal(t, "PUT http://blnk.invalid:5001/transactions/inflight/txn_123\n{\n  \"status\": \"void\",\n  \"amount\": 0,\n  \"precise_amount\": null\n}\n", res.stdout)
+
+	res = runCLI(t, env, "ledgers", "filter", "--dry-run", "meta_data.team=ops", "--limit", "5")
+	require.Equal(t, 0, res.code, res.stderr)
+	assert.Contains(t, res.stdout, "POST http://blnk.invalid:5001/ledgers/filter")
+	assert.Contains(t, res.stdout, `"operator": "eq"`)
+	assert.Contains(t, res.stdout, `"limit": 5`)
+	assert.NotContains(t, res.stdout, "secret")
+}
+
+func TestProfiles(t *testing.T) {
+	server := blnktest.NewServer(t)
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(
+		"default_profile: prod\nprofiles:\n  prod:\n    url: http://prod.invalid\n  local:\n    url: "+server.URL+"\n    timeout: 5s\n"), 0o600))
+	env := map[string]string{"BLNK_CONFIG": config}
+
+	res := runCLI(t, env, "--dry-run", "ledgers", "get", "ldg_1")
+	require.Equal(t, 0, res.code, res.stderr)
+	assert.Equal(t, "GET http://prod.invalid/ledgers/ldg_1\n", res.stdout)
+
+	res = runCLI(t, env, "--profile", "local", "ledgers", "list")
+	assert.Equal(t, 0, res.code, res.stderr)
+
+	env["BLNK_URL"] = "http://env.invalid"
+	res = runCLI(t, env, "--dry-run", "ledgers", "list")
+	assert.Equal(t, "GET http://env.invalid/ledgers\n", res.stdout)
+
+	res = runCLI(t, env, "--profile", "staging", "ledgers", "list")
+	assert.Equal(t, 1, res.code)
+	assert.Contains(t, res.stderr, `profile "staging" not found`)
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := map[string]blnkgo.Filter{
+		"status=APPLIED":           {Field: "status", Operator: blnkgo.OpEqual, Value: "APPLIED"},
+		"amount>=100":              {Field: "amount", Operator: blnkgo.OpGreaterThanOrEqual, Value: "100"},
+		"currency!=USD":            {Field: "currency", Operator: blnkgo.OpNotEqual, Value: "USD"},
+		"reference~inv-%":          {Field: "reference", Operator: blnkgo.OpILike, Value: "inv-%"},
+		"amount:between=100,500":   {Field: "amount", Operator: blnkgo.OpBetween, Values: []interface{}{"100", "500"}},
+		"meta_data.card:isnotnull": {Field: "meta_data.card", Operator: blnkgo.OpIsNotNull},
+	}
+	for expr, want := range tests {
+		got, err := parseFilter(expr)
+		require.NoError(t, err, expr)
+		assert.Equal(t, want, got, expr)
+	}
+
+	for _, expr := range []string{"status", "=x", "amount:near=1", "amount:between=1", "x:isnull=1"} {
+		_, err := parseFilter(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	res := runCLI(t, nil)
+	assert.Equal(t, 2, res.code)
+	assert.Contains(t, res.stderr, "ledgers")
+
+	res = runCLI(t, map[string]string{"BLNK_URL": "http://x.invalid"}, "ledgers", "get")
+	assert.Equal(t, 2, res.code)
+	assert.Contains(t, res.stderr, "usage: blnk ledgers get <ledger-id>")
+
+	res = runCLI(t, nil, "tx", "rewind")
+	assert.Equal(t, 2, res.code)
+	assert.Contains(t, res.stderr, "commit|create|filter|get|refund|void")
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	ledgerColumns      = []string{"ledger_id", "name", "created_at"}
+	balanceColumns     = []string{"balance_id", "ledger_id", "indicator", "currency", "balance", "credit_balance", "debit_balance", "inflight_balance"}
+	historyColumns     = []string{"balance.balance_id", "balance.currency", "balance.balance", "balance.credit_balance", "balance.debit_balance", "timestamp"}
+	transactionColumns = []string{"transaction_id", "reference", "status", "source", "destination", "precise_amount", "currency", "created_at"}
+	monitorColumns     = []string{"monitor_id", "balance_id", "condition.field", "condition.operator", "condition.value", "description"}
+	identityColumns    = []string{"identity_id", "identity_type", "first_name", "last_name", "organization_name", "email_address", "category"}
+	uploadColumns      = []string{"upload_id", "source", "record_count"}
+	reconColumns       = []string{"rule_id", "name", "created_at"}
+	messageColumns     = []string{"message"}
+)
+
+// print writes v, a record or a slice of records, in the selected output
+// format. Table and CSV output show columns, which are JSON field names with
+// dots for nested fields; JSON output shows everything.
+func (a *app) print(v interface{}, columns []string) error {
+	if a.output == "json" {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(a.stdout, "%s\n", data)
+		return err
+	}
+
+	rows, err := records(v)
+	if err != nil {
+		return err
+	}
+	switch a.output {
+	case "table":
+		w := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(cells(row, columns), "\t"))
+		}
+		return w.Flush()
+	case "csv":
+		w := csv.NewWriter(a.stdout)
+		if err := w.Write(columns); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := w.Write(cells(row, columns)); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+	}
+	return fmt.Errorf("unknown output format %q, use table, json or csv", a.output)
+}
+
+// records turns v into generic JSON objects. Numbers are kept as written so
+// large amounts print exactly.
+func records(v interface{}) ([]map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	switch generic := generic.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{generic}, nil
+	case []interface{}:
+		rows := make([]map[string]interface{}, 0, len(generic))
+		for _, item := range generic {
+			if row, ok := item.(map[string]interface{}); ok {
+				rows = append(rows, row)
+			}
+		}
+		return rows, nil
+	}
+	return nil, nil
+}
+
+func cells(row map[string]interface{}, columns []string) []string {
+	out := make([]string, len(columns))
+	for i, column := range columns {
+		out[i] = cell(lookup(row, column))
+	}
+	return out
+}
+
+func lookup(row map[string]interface{}, path string) interface{} {
+	var value interface{} = row
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+func cell(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,183 @@
+// Package coa manages a chart of accounts on top of Blnk ledgers.
+//
+// The chart is declared in YAML. Every account becomes a ledger tagged with
+// its code, type and parent in meta_data, and accounts that hold money get a
+// system balance per currency. Syncer reconciles the chart with the ledgers
+// and balances that already exist and creates what is missing, so it can run
+// on every deploy. The Accounts it returns look balances up by account code,
+// so transaction code never hardcodes balance IDs.
+//
+//	name: acme
+//	currencies: [USD]
+//	accounts:
+//	  - code: "1000"
+//	    name: Assets
+//	    type: asset
+//	    children:
+//	      - code: "1100"
+//	        name: Cash at bank
+//	      - code: "1200"
+//	        name: Customer wallets
+//	        currencies: [USD, EUR]
+//	  - code: "4000"
+//	    name: Revenue
+//	    type: revenue
+//	    children:
+//	      - code: "4100"
+//	        name: Fees
+package coa
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AccountType is the accounting class of an account.
+type AccountType string
+
+const (
+	Asset     AccountType = "asset"
+	Liability AccountType = "liability"
+	Equity    AccountType = "equity"
+	Revenue   AccountType = "revenue"
+	Expense   AccountType = "expense"
+)
+
+// Valid reports whether t is one of the five account types.
+func (t AccountType) Valid() bool {
+	switch t {
+	case Asset, Liability, Equity, Revenue, Expense:
+		return true
+	}
+	return false
+}
+
+// DebitNormal reports whether accounts of type t increase with debits.
+func (t AccountType) DebitNormal() bool {
+	return t == Asset || t == Expense
+}
+
+// Account is an account of the chart. Type is inherited from the parent when
+// empty. Currencies are those the account holds system balances in; leaf
+// accounts default to the chart's currencies and parent accounts to none.
+type Account struct {
+	Code       string                 `yaml:"code"`
+	Name       string                 `yaml:"name"`
+	Type       AccountType            `yaml:"type"`
+	Currencies []string               `yaml:"currencies"`
+	MetaData   map[string]interface{} `yaml:"meta_data"`
+	Children   []*Account             `yaml:"children"`
+
+	Parent *Account `yaml:"-"`
+}
+
+// Chart is a chart of accounts. Name identifies the chart in meta_data, so
+// several charts can share a Blnk instance.
+type Chart struct {
+	Name       string     `yaml:"name"`
+	Currencies []string   `yaml:"currencies"`
+	Accounts   []*Account `yaml:"accounts"`
+
+	byCode map[string]*Account
+}
+
+// Parse reads and validates a chart.
+func Parse(r io.Reader) (*Chart, error) {
+	var chart Chart
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&chart); err != nil {
+		return nil, fmt.Errorf("failed to parse chart of accounts: %w", err)
+	}
+	if err := chart.init(); err != nil {
+		return nil, err
+	}
+	return &chart, nil
+}
+
+// ParseFile reads and validates the chart in path.
+func ParseFile(path string) (*Chart, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// init links parents, inherits types and currencies and validates the chart.
+func (c *Chart) init() error {
+	if c.Name == "" {
+		return fmt.Errorf("chart name is required")
+	}
+	for i, currency := range c.Currencies {
+		c.Currencies[i] = strings.ToUpper(currency)
+	}
+	c.byCode = make(map[string]*Account)
+
+	var walk func(accounts []*Account, parent *Account) error
+	walk = func(accounts []*Account, parent *Account) error {
+		for _, account := range accounts {
+			if account.Code == "" || account.Name == "" {
+				return fmt.Errorf("account %q needs a code and a name", account.Code+account.Name)
+			}
+			if _, ok := c.byCode[account.Code]; ok {
+				return fmt.Errorf("account code %s is used twice", account.Code)
+			}
+			c.byCode[account.Code] = account
+			account.Parent = parent
+
+			if account.Type == "" && parent != nil {
+				account.Type = parent.Type
+			}
+			if !account.Type.Valid() {
+				return fmt.Errorf("account %s has invalid type %q", account.Code, account.Type)
+			}
+			if parent != nil && account.Type != parent.Type {
+				return fmt.Errorf("account %s is %s but its parent %s is %s", account.Code, account.Type, parent.Code, parent.Type)
+			}
+
+			if account.Currencies == nil && len(account.Children) == 0 {
+				account.Currencies = append([]string(nil), c.Currencies...)
+			}
+			for i, currency := range account.Currencies {
+				account.Currencies[i] = strings.ToUpper(currency)
+			}
+			if err := walk(account.Children, account); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(c.Accounts, nil)
+}
+
+// Account returns the account with code.
+func (c *Chart) Account(code string) (*Account, bool) {
+	account, ok := c.byCode[code]
+	return account, ok
+}
+
+// Walk calls fn for every account, parents before their children.
+func (c *Chart) Walk(fn func(*Account)) {
+	var walk func([]*Account)
+	walk = func(accounts []*Account) {
+		for _, account := range accounts {
+			fn(account)
+			walk(account.Children)
+		}
+	}
+	walk(c.Accounts)
+}
+
+// Path returns the codes from the root account down to a.
+func (a *Account) Path() []string {
+	if a.Parent == nil {
+		return []string{a.Code}
+	}
+	return append(a.Parent.Path(), a.Code)
+}
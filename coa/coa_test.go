@@ -0,0 +1,148 @@
+package coa_test
+
+import (
+	"strings"
+	"testing"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
+	"github.com/blnkfinance/blnk-go/coa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const chartYAML = `
+name: acme
+currencies: [usd]
+accounts:
+  - code: "1000"
+    name: Assets
+    type: asset
+    children:
+      - code: "1100"
+        name: Cash at bank
+      - code: "1200"
+        name: Customer wallets
+        currencies: [USD, EUR]
+  - code: "2000"
+    name: Liabilities
+    type: liability
+    children:
+      - code: "2100"
+        name: Customer funds
+        meta_data:
+          regulated: true
+  - code: "4000"
+    name: Revenue
+    type: revenue
+    children:
+      - code: "4100"
+        name: Fees
+`
+
+func TestParse(t *testing.T) {
+	chart, err := coa.Parse(strings.NewReader(chartYAML))
+	require.NoError(t, err)
+
+	wallets, ok := chart.Account("1200")
+	require.True(t, ok)
+	assert.Equal(t, coa.Asset, wallets.Type)
+	assert.Equal(t, []string{"USD", "EUR"}, wallets.Currencies)
+	assert.Equal(t, []string{"1000", "1200"}, wallets.Path())
+
+	cash, _ := chart.Account("1100")
+	assert.Equal(t, []string{"USD"}, cash.Currencies)
+	assets, _ := chart.Account("1000")
+	assert.Empty(t, assets.Currencies, "parent accounts hold no balances by default")
+	assert.True(t, coa.Asset.DebitNormal())
+	assert.False(t, coa.Revenue.DebitNormal())
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"duplicate code": "name: x\naccounts:\n  - {code: '1', name: A, type: asset}\n  - {code: '1', name: B, type: asset}\n",
+		"invalid type":   "name: x\naccounts:\n  - {code: '1', name: A, type: cash}\n",
+		"type mismatch":  "name: x\naccounts:\n  - code: '1'\n    name: A\n    type: asset\n    children:\n      - {code: '2', name: B, type: expense}\n",
+		"missing name":   "accounts:\n  - {code: '1', name: A, type: asset}\n",
+		"unknown field":  "name: x\naccounts:\n  - {code: '1', name: A, type: asset, colour: red}\n",
+	}
+	for name, yaml := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := coa.Parse(strings.NewReader(yaml))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSyncer_Sync(t *testing.T) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	chart, err := coa.Parse(strings.NewReader(chartYAML))
+	require.NoError(t, err)
+	syncer := coa.NewSyncer(client, chart)
+
+	syncer.DryRun = true
+	_, plan, err := syncer.Sync()
+	require.NoError(t, err)
+	assert.Len(t, plan.Changes, 7+5)
+	assert.Empty(t, server.Ledgers())
+	_, err = syncer.Resolve()
+	assert.Error(t, err)
+
+	syncer.DryRun = false
+	accounts, report, err := syncer.Sync()
+	require.NoError(t, err)
+	assert.Len(t, report.Changes, 12)
+	assert.Len(t, server.Ledgers(), 7)
+
+	walletsEUR, err := accounts.BalanceID("1200", "eur")
+	require.NoError(t, err)
+	balance, ok := server.Balance(walletsEUR)
+	require.True(t, ok)
+	assert.Equal(t, "EUR", balance.Currency)
+	ledgerID, err := accounts.LedgerID("1200")
+	require.NoError(t, err)
+	assert.Equal(t, ledgerID, balance.LedgerID)
+	ledger, _, err := client.Ledger.Get(ledgerID)
+	require.NoError(t, err)
+	assert.Equal(t, "1200 Customer wallets", ledger.Name)
+	assert.Equal(t, "1000", ledger.MetaData["coa_parent"])
+	assert.Equal(t, "asset", ledger.MetaData["coa_type"])
+
+	_, err = accounts.BalanceID("1000", "USD")
+	assert.Error(t, err)
+	_, err = accounts.BalanceID("9999", "USD")
+	assert.Error(t, err)
+	assert.Len(t, accounts.ByType(coa.Liability), 2)
+
+	_, report, err = syncer.Sync()
+	require.NoError(t, err)
+	assert.True(t, report.InSync(), "%+v", report.Changes)
+
+	resolved, err := syncer.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, walletsEUR, resolved.MustBalanceID("1200", "EUR"))
+}
+
+func TestSyncer_Drift(t *testing.T) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	chart, err := coa.Parse(strings.NewReader(chartYAML))
+	require.NoError(t, err)
+	_, _, err = coa.NewSyncer(client, chart).Sync()
+	require.NoError(t, err)
+	_, _, err = client.Ledger.Create(blnkgo.CreateLedgerRequest{Name: "3000 Old", MetaData: map[string]interface{}{"coa_chart": "acme", "coa_code": "3000"}})
+	require.NoError(t, err)
+
+	renamed, err := coa.Parse(strings.NewReader(strings.Replace(chartYAML, "name: Fees", "name: Fee income", 1)))
+	require.NoError(t, err)
+	_, report, err := coa.NewSyncer(client, renamed).Sync()
+
+	require.NoError(t, err)
+	require.Len(t, report.Changes, 2)
+	assert.Equal(t, coa.LedgerUpdated, report.Changes[0].Kind)
+	assert.Equal(t, "4100", report.Changes[0].Code)
+	assert.Equal(t, "coa_name", report.Changes[0].Detail)
+	assert.Equal(t, coa.LedgerOrphaned, report.Changes[1].Kind)
+	assert.Equal(t, "3000", report.Changes[1].Code)
+}
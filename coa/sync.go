@@ -0,0 +1,302 @@
+package coa
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// ChangeKind is what a sync did, or would do in a dry run.
+type ChangeKind string
+
+const (
+	LedgerCreated  ChangeKind = "ledger_created"
+	LedgerUpdated  ChangeKind = "ledger_updated"
+	BalanceCreated ChangeKind = "balance_created"
+	// LedgerOrphaned is a ledger tagged with the chart whose code is no longer
+	// in it. Orphaned ledgers are reported but left alone.
+	LedgerOrphaned ChangeKind = "ledger_orphaned"
+)
+
+// Change is one difference between the chart and Blnk. ID is the ledger or
+// balance concerned, and is empty for creations in a dry run.
+type Change struct {
+	Kind     ChangeKind
+	Code     string
+	Currency string
+	ID       string
+	Detail   string
+}
+
+// Report lists the changes of a sync.
+type Report struct {
+	Changes []Change
+}
+
+// InSync reports whether Blnk already matched the chart.
+func (r *Report) InSync() bool {
+	return len(r.Changes) == 0
+}
+
+// Syncer reconciles a chart with Blnk.
+type Syncer struct {
+	Chart *Chart
+	// DryRun reports the changes without making them.
+	DryRun bool
+
+	ledgers  *blnkgo.LedgerService
+	balances *blnkgo.LedgerBalanceService
+	metadata *blnkgo.MetadataService
+}
+
+func NewSyncer(client blnkgo.ClientInterface, chart *Chart) *Syncer {
+	return &Syncer{
+		Chart:    chart,
+		ledgers:  blnkgo.NewLedgerService(client),
+		balances: blnkgo.NewLedgerBalanceService(client),
+		metadata: blnkgo.NewMetadataService(client),
+	}
+}
+
+func ledgerMetaData(chart *Chart, account *Account) map[string]interface{} {
+	metaData := make(map[string]interface{}, len(account.MetaData)+5)
+	for k, v := range account.MetaData {
+		metaData[k] = v
+	}
+	parent := ""
+	if account.Parent != nil {
+		parent = account.Parent.Code
+	}
+	metaData["coa_chart"] = chart.Name
+	metaData["coa_code"] = account.Code
+	metaData["coa_name"] = account.Name
+	metaData["coa_type"] = string(account.Type)
+	metaData["coa_parent"] = parent
+	return metaData
+}
+
+// Sync creates the ledgers and system balances missing from Blnk and updates
+// the metadata of ledgers whose account changed. Ledgers and balances are
+// matched by their coa_chart and coa_code metadata, so running Sync again
+// changes nothing.
+func (s *Syncer) Sync() (*Accounts, *Report, error) {
+	if s.Chart == nil || s.Chart.byCode == nil {
+		return nil, nil, fmt.Errorf("a parsed chart is required")
+	}
+	chart := s.Chart
+	accounts := &Accounts{chart: chart, ledgers: make(map[string]string), balances: make(map[string]string)}
+	report := &Report{}
+
+	byChart := []blnkgo.Filter{{Field: "meta_data.coa_chart", Operator: blnkgo.OpEqual, Value: chart.Name}}
+	ledgers, err := s.ledgers.FilterAll(blnkgo.FilterParams{Filters: byChart, SortBy: "created_at", SortOrder: "asc"})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list ledgers: %w", err)
+	}
+	existing := make(map[string]blnkgo.Ledger)
+	for _, ledger := range ledgers {
+		code, _ := ledger.MetaData["coa_code"].(string)
+		if _, ok := existing[code]; !ok {
+			existing[code] = ledger
+		}
+	}
+
+	var syncErr error
+	chart.Walk(func(account *Account) {
+		if syncErr != nil {
+			return
+		}
+		want := ledgerMetaData(chart, account)
+		ledger, ok := existing[account.Code]
+		if !ok {
+			change := Change{Kind: LedgerCreated, Code: account.Code, Detail: account.Code + " " + account.Name}
+			if !s.DryRun {
+				created, _, err := s.ledgers.Create(blnkgo.CreateLedgerRequest{Name: account.Code + " " + account.Name, MetaData: want})
+				if err != nil {
+					syncErr = fmt.Errorf("failed to create ledger for account %s: %w", account.Code, err)
+					return
+				}
+				change.ID = created.LedgerID
+				accounts.ledgers[account.Code] = created.LedgerID
+			}
+			report.Changes = append(report.Changes, change)
+			return
+		}
+
+		accounts.ledgers[account.Code] = ledger.LedgerID
+		if drift := metaDataDrift(ledger.MetaData, want); len(drift) > 0 {
+			if !s.DryRun {
+				if _, _, err := s.metadata.UpdateMetadata(ledger.LedgerID, blnkgo.UpdateMetaDataRequest{MetaData: want}); err != nil {
+					syncErr = fmt.Errorf("failed to update ledger %s of account %s: %w", ledger.LedgerID, account.Code, err)
+					return
+				}
+			}
+			report.Changes = append(report.Changes, Change{Kind: LedgerUpdated, Code: account.Code, ID: ledger.LedgerID, Detail: strings.Join(drift, ", ")})
+		}
+	})
+	if syncErr != nil {
+		return nil, report, syncErr
+	}
+
+	var orphans []string
+	for code := range existing {
+		if _, ok := chart.Account(code); !ok {
+			orphans = append(orphans, code)
+		}
+	}
+	sort.Strings(orphans)
+	for _, code := range orphans {
+		report.Changes = append(report.Changes, Change{Kind: LedgerOrphaned, Code: code, ID: existing[code].LedgerID, Detail: existing[code].Name})
+	}
+
+	balances, err := s.balances.FilterAll(blnkgo.FilterParams{Filters: byChart, SortBy: "created_at", SortOrder: "asc"})
+	if err != nil {
+		return nil, report, fmt.Errorf("failed to list balances: %w", err)
+	}
+	for _, balance := range balances {
+		code, _ := balance.MetaData["coa_code"].(string)
+		key := balanceKey(code, balance.Currency)
+		if _, ok := accounts.balances[key]; !ok && balance.LedgerID == accounts.ledgers[code] {
+			accounts.balances[key] = balance.BalanceID
+		}
+	}
+
+	chart.Walk(func(account *Account) {
+		if syncErr != nil {
+			return
+		}
+		for _, currency := range account.Currencies {
+			if _, ok := accounts.balances[balanceKey(account.Code, currency)]; ok {
+				continue
+			}
+			change := Change{Kind: BalanceCreated, Code: account.Code, Currency: currency}
+			if !s.DryRun {
+				created, _, err := s.balances.Create(blnkgo.CreateLedgerBalanceRequest{
+					LedgerID: accounts.ledgers[account.Code],
+					Currency: currency,
+					MetaData: map[string]interface{}{
+						"coa_chart":  chart.Name,
+						"coa_code":   account.Code,
+						"coa_system": true,
+					},
+				})
+				if err != nil {
+					syncErr = fmt.Errorf("failed to create %s balance for account %s: %w", currency, account.Code, err)
+					return
+				}
+				change.ID = created.BalanceID
+				accounts.balances[balanceKey(account.Code, currency)] = created.BalanceID
+			}
+			report.Changes = append(report.Changes, change)
+		}
+	})
+	if syncErr != nil {
+		return nil, report, syncErr
+	}
+	return accounts, report, nil
+}
+
+// Resolve looks the chart's ledgers and balances up without changing
+// anything, and fails if any is missing.
+func (s *Syncer) Resolve() (*Accounts, error) {
+	dryRun := s.DryRun
+	s.DryRun = true
+	defer func() { s.DryRun = dryRun }()
+
+	accounts, report, err := s.Sync()
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, change := range report.Changes {
+		switch change.Kind {
+		case LedgerCreated:
+			missing = append(missing, "ledger "+change.Code)
+		case BalanceCreated:
+			missing = append(missing, "balance "+change.Code+" "+change.Currency)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("chart %s is not synced, missing %s", s.Chart.Name, strings.Join(missing, ", "))
+	}
+	return accounts, nil
+}
+
+// metaDataDrift returns the keys of want that differ in have.
+func metaDataDrift(have, want map[string]interface{}) []string {
+	var drift []string
+	for k, v := range want {
+		if fmt.Sprint(have[k]) != fmt.Sprint(v) {
+			drift = append(drift, k)
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+func balanceKey(code, currency string) string {
+	return code + "|" + strings.ToUpper(currency)
+}
+
+// Accounts resolves account codes to the ledgers and balances of a synced
+// chart.
+type Accounts struct {
+	chart    *Chart
+	ledgers  map[string]string
+	balances map[string]string
+}
+
+// Account returns the account with code.
+func (a *Accounts) Account(code string) (*Account, error) {
+	account, ok := a.chart.Account(code)
+	if !ok {
+		return nil, fmt.Errorf("account %s is not in chart %s", code, a.chart.Name)
+	}
+	return account, nil
+}
+
+// LedgerID returns the ledger of an account.
+func (a *Accounts) LedgerID(code string) (string, error) {
+	if _, err := a.Account(code); err != nil {
+		return "", err
+	}
+	id, ok := a.ledgers[code]
+	if !ok {
+		return "", fmt.Errorf("account %s has no ledger", code)
+	}
+	return id, nil
+}
+
+// BalanceID returns the system balance of an account in currency.
+func (a *Accounts) BalanceID(code, currency string) (string, error) {
+	if _, err := a.Account(code); err != nil {
+		return "", err
+	}
+	id, ok := a.balances[balanceKey(code, currency)]
+	if !ok {
+		return "", fmt.Errorf("account %s has no %s balance", code, strings.ToUpper(currency))
+	}
+	return id, nil
+}
+
+// MustBalanceID is like BalanceID but panics if the balance does not exist.
+// It is meant for codes that are constants in the program.
+func (a *Accounts) MustBalanceID(code, currency string) string {
+	id, err := a.BalanceID(code, currency)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// ByType returns the accounts of type t, parents before their children.
+func (a *Accounts) ByType(t AccountType) []*Account {
+	var accounts []*Account
+	a.chart.Walk(func(account *Account) {
+		if account.Type == t {
+			accounts = append(accounts, account)
+		}
+	})
+	return accounts
+}
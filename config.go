@@ -0,0 +1,271 @@
+package blnkgo
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Secret is a string that is redacted when printed or marshalled, so configs
+// can be logged safely. Convert it with string(s) to use the value.
+type Secret string
+
+func (s Secret) String() string {
+	switch {
+	case s == "":
+		return ""
+	case len(s) <= 8:
+		return "****"
+	}
+	return "****" + string(s[len(s)-4:])
+}
+
+func (s Secret) GoString() string {
+	return strconv.Quote(s.String())
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Config holds the settings of a client. Zero values keep the client defaults.
+type Config struct {
+	BaseURL    string        `yaml:"url" toml:"url"`
+	ApiKey     Secret        `yaml:"api_key" toml:"api_key"`
+	Timeout    time.Duration `yaml:"timeout" toml:"timeout"`
+	RetryCount int           `yaml:"retry_count" toml:"retry_count"`
+	RetryWait  time.Duration `yaml:"retry_wait" toml:"retry_wait"`
+	LogLevel   LogLevel      `yaml:"log_level" toml:"log_level"`
+}
+
+// ConfigFile is a config file with named profiles, one per environment. In
+// YAML:
+//
+//	default_profile: dev
+//	profiles:
+//	  dev:
+//	    url: http://localhost:5001
+//	  prod:
+//	    url: https://blnk.example.com
+//	    api_key: sk_live_...
+//	    timeout: 30s
+//	    retry_count: 3
+//	    retry_wait: 500ms
+//	    log_level: error
+//
+// TOML files use the same keys, with [profiles.prod] tables.
+type ConfigFile struct {
+	DefaultProfile string            `yaml:"default_profile" toml:"default_profile"`
+	Profiles       map[string]Config `yaml:"profiles" toml:"profiles"`
+}
+
+// String prints the config with the API key redacted.
+func (c Config) String() string {
+	return fmt.Sprintf("Config{BaseURL: %s, ApiKey: %s, Timeout: %s, RetryCount: %d, RetryWait: %s, LogLevel: %s}",
+		c.BaseURL, c.ApiKey, c.Timeout, c.RetryCount, c.RetryWait, c.LogLevel)
+}
+
+// Validate checks the config can build a client.
+func (c *Config) Validate() error {
+	if c.BaseURL == "" {
+		return fmt.Errorf("base url is required")
+	}
+	u, err := url.Parse(c.BaseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid base url %q", c.BaseURL)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if c.RetryCount < 0 {
+		return fmt.Errorf("retry count must not be negative")
+	}
+	if c.RetryWait < 0 {
+		return fmt.Errorf("retry wait must not be negative")
+	}
+	if c.LogLevel != "" && !c.LogLevel.Valid() {
+		return fmt.Errorf("invalid log level %q, want info, error or off", c.LogLevel)
+	}
+	return nil
+}
+
+// ConfigFromEnv reads a config from the BLNK_URL, BLNK_API_KEY, BLNK_TIMEOUT,
+// BLNK_RETRY_COUNT, BLNK_RETRY_WAIT and BLNK_LOG_LEVEL environment variables.
+func ConfigFromEnv() (*Config, error) {
+	config := &Config{}
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyEnv overrides the fields whose BLNK_* variable is set.
+func (c *Config) applyEnv() error {
+	if v := os.Getenv("BLNK_URL"); v != "" {
+		c.BaseURL = v
+	}
+	if v := os.Getenv("BLNK_API_KEY"); v != "" {
+		c.ApiKey = Secret(v)
+	}
+	if v := os.Getenv("BLNK_LOG_LEVEL"); v != "" {
+		c.LogLevel = LogLevel(strings.ToLower(v))
+	}
+	for name, field := range map[string]*time.Duration{"BLNK_TIMEOUT": &c.Timeout, "BLNK_RETRY_WAIT": &c.RetryWait} {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", name, v, err)
+			}
+			*field = d
+		}
+	}
+	if v := os.Getenv("BLNK_RETRY_COUNT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid BLNK_RETRY_COUNT %q: %w", v, err)
+		}
+		c.RetryCount = n
+	}
+	return nil
+}
+
+// ParseConfigFile reads a YAML (.yaml, .yml) or TOML (.toml) config file.
+func ParseConfigFile(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var file ConfigFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(data), &file)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown field %s", meta.Undecoded()[0])
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config format %q, use .yaml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+// Profile returns the named profile. An empty name selects BLNK_PROFILE, then
+// the default profile, then the only profile of the file.
+func (f *ConfigFile) Profile(name string) (*Config, error) {
+	if name == "" {
+		name = os.Getenv("BLNK_PROFILE")
+	}
+	if name == "" {
+		name = f.DefaultProfile
+	}
+	if name == "" && len(f.Profiles) == 1 {
+		for only := range f.Profiles {
+			name = only
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no profile selected, set default_profile or BLNK_PROFILE")
+	}
+	config, ok := f.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(f.Profiles))
+		for n := range f.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("profile %q not found, have %s", name, strings.Join(names, ", "))
+	}
+	return &config, nil
+}
+
+// LoadConfig reads a profile from the config file at path, or from BLNK_CONFIG
+// when path is empty, and applies the BLNK_* variables on top so secrets can
+// stay out of the file. See ConfigFile.Profile for how profile is resolved.
+func LoadConfig(path, profile string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv("BLNK_CONFIG")
+	}
+	if path == "" {
+		return nil, fmt.Errorf("config path is required, pass one or set BLNK_CONFIG")
+	}
+	file, err := ParseConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := file.Profile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// NewClientWithConfig builds a client from config. Unlike NewClient it returns
+// an error for an invalid config. opts are applied after the config.
+func NewClientWithConfig(config *Config, opts ...ClientOption) (*Client, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config is required")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	baseURL, _ := url.Parse(config.BaseURL)
+
+	var options []ClientOption
+	if config.Timeout > 0 {
+		options = append(options, WithTimeout(config.Timeout))
+	}
+	if config.RetryCount > 0 {
+		options = append(options, WithRetry(config.RetryCount))
+	}
+	if config.RetryWait > 0 {
+		options = append(options, WithRetryWait(config.RetryWait))
+	}
+	if config.LogLevel != "" {
+		options = append(options, WithLogger(NewLevelLogger(config.LogLevel)))
+	}
+	var apiKey *string
+	if config.ApiKey != "" {
+		key := string(config.ApiKey)
+		apiKey = &key
+	}
+	return NewClient(baseURL, apiKey, append(options, opts...)...), nil
+}
+
+// NewClientFromEnv builds a client from the BLNK_* environment variables.
+func NewClientFromEnv(opts ...ClientOption) (*Client, error) {
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithConfig(config, opts...)
+}
+
+// NewClientFromConfig builds a client from a profile of the config file at
+// path, selected by BLNK_PROFILE or the file's default_profile.
+func NewClientFromConfig(path string, opts ...ClientOption) (*Client, error) {
+	config, err := LoadConfig(path, "")
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithConfig(config, opts...)
+}
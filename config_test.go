@@ -0,0 +1,137 @@
+package blnkgo_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig_YAML(t *testing.T) {
+	path := writeConfig(t, "blnk.yaml", `
+default_profile: dev
+profiles:
+  dev:
+    url: http://localhost:5001
+  prod:
+    url: https://blnk.example.com
+    api_key: sk_live_0123456789
+    timeout: 30s
+    retry_count: 3
+    retry_wait: 500ms
+    log_level: error
+`)
+
+	config, err := blnkgo.LoadConfig(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:5001", config.BaseURL)
+
+	config, err = blnkgo.LoadConfig(path, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, blnkgo.Config{
+		BaseURL:    "https://blnk.example.com",
+		ApiKey:     "sk_live_0123456789",
+		Timeout:    30 * time.Second,
+		RetryCount: 3,
+		RetryWait:  500 * time.Millisecond,
+		LogLevel:   blnkgo.LogLevelError,
+	}, *config)
+
+	t.Setenv("BLNK_PROFILE", "prod")
+	t.Setenv("BLNK_API_KEY", "sk_from_env_abcdef")
+	config, err = blnkgo.LoadConfig(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, blnkgo.Secret("sk_from_env_abcdef"), config.ApiKey)
+	assert.Equal(t, 3, config.RetryCount)
+
+	_, err = blnkgo.LoadConfig(path, "staging")
+	assert.ErrorContains(t, err, `profile "staging" not found, have dev, prod`)
+}
+
+func TestLoadConfig_TOML(t *testing.T) {
+	path := writeConfig(t, "blnk.toml", `
+default_profile = "staging"
+
+[profiles.staging]
+url = "https://staging.blnk.example.com"
+api_key = "sk_test_0123456789"
+timeout = "5s"
+log_level = "off"
+`)
+	client, err := blnkgo.NewClientFromConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "https://staging.blnk.example.com/", client.BaseURL.String())
+	require.NotNil(t, client.ApiKey)
+	assert.Equal(t, "sk_test_0123456789", *client.ApiKey)
+
+	_, err = blnkgo.LoadConfig(writeConfig(t, "bad.toml", "[profiles.x]\nurl = \"http://x\"\ncolour = \"red\"\n"), "x")
+	assert.ErrorContains(t, err, "colour")
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+	t.Setenv("BLNK_API_KEY", "")
+	t.Setenv("BLNK_URL", "")
+	_, err := blnkgo.NewClientFromEnv()
+	assert.ErrorContains(t, err, "base url is required")
+
+	t.Setenv("BLNK_URL", "localhost:5001")
+	_, err = blnkgo.NewClientFromEnv()
+	assert.ErrorContains(t, err, "invalid base url")
+
+	t.Setenv("BLNK_URL", "http://localhost:5001")
+	t.Setenv("BLNK_TIMEOUT", "soon")
+	_, err = blnkgo.NewClientFromEnv()
+	assert.ErrorContains(t, err, "BLNK_TIMEOUT")
+
+	t.Setenv("BLNK_TIMEOUT", "2s")
+	t.Setenv("BLNK_LOG_LEVEL", "verbose")
+	_, err = blnkgo.NewClientFromEnv()
+	assert.ErrorContains(t, err, "log level")
+
+	t.Setenv("BLNK_LOG_LEVEL", "INFO")
+	client, err := blnkgo.NewClientFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, client.ApiKey)
+
+	_, err = blnkgo.NewClientFromConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+	_, err = blnkgo.NewClientFromConfig(writeConfig(t, "blnk.ini", "url=x"))
+	assert.ErrorContains(t, err, "unsupported config format")
+}
+
+func TestConfig_RedactsApiKey(t *testing.T) {
+	config := blnkgo.Config{BaseURL: "https://blnk.example.com", ApiKey: "sk_live_0123456789"}
+
+	for _, printed := range []string{
+		config.String(),
+		fmt.Sprintf("%v", config),
+		fmt.Sprintf("%+v", &config),
+		fmt.Sprintf("%#v", config),
+	} {
+		assert.NotContains(t, printed, "sk_live", printed)
+		assert.Contains(t, printed, "****6789", printed)
+	}
+
+	data, err := json.Marshal(config)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "sk_live")
+	data, err = yaml.Marshal(config)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "sk_live")
+
+	assert.Equal(t, "****", blnkgo.Secret("short").String())
+}
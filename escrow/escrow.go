@@ -0,0 +1,412 @@
+// Package escrow holds funds from a buyer until they are released to a seller
+// or refunded.
+//
+// Each escrow is a Blnk balance. Funding places an inflight transaction from
+// the buyer to the escrow balance, so the money is held without being spent.
+// Releasing commits part or all of the hold and pays the committed amount to
+// the seller; refunding voids what is left of the hold. A disputed escrow
+// accepts no releases or refunds until it is resolved.
+//
+// The escrow's state is stored in the escrow balance's meta_data, so an escrow
+// can be loaded again by its ID, and every transaction is tagged with
+// escrow_id and escrow_action.
+package escrow
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// Escrow is the state of one escrow. Amounts are in minor units of Currency.
+type Escrow struct {
+	// ID is the ID of the escrow balance.
+	ID        string
+	Reference string
+	Buyer     string
+	Seller    string
+	Currency  string
+	Precision int64
+	State     State
+	// Amount is the amount held by funding.
+	Amount *big.Int
+	// Committed is the part of Amount committed into the escrow balance and
+	// Released the part of it paid to the seller.
+	Committed         *big.Int
+	Released          *big.Int
+	HoldTransactionID string
+	// DisputedFrom is the state a disputed escrow returns to when resolved.
+	DisputedFrom  State
+	DisputeReason string
+	// Events are the latest events, oldest first. Only Manager.MaxEvents of
+	// them are kept.
+	Events []Event
+
+	fundAttempts int
+	payouts      int
+}
+
+// Remaining returns the amount still held.
+func (e *Escrow) Remaining() *big.Int {
+	return new(big.Int).Sub(blnkgo.ValueOrZero(e.Amount), blnkgo.ValueOrZero(e.Committed))
+}
+
+// OpenRequest describes a new escrow. Buyer and Seller are balance IDs or
+// indicators; Precision defaults to 100.
+type OpenRequest struct {
+	Reference string
+	Buyer     string
+	Seller    string
+	Currency  string
+	Precision int64
+	MetaData  map[string]interface{}
+}
+
+// Manager runs escrows in a ledger.
+type Manager struct {
+	LedgerID string
+	// OnEvent, when set, is called with every event after it is recorded.
+	OnEvent func(Event)
+	// Now returns the time events are stamped with. It defaults to time.Now.
+	Now func() time.Time
+	// MaxEvents is the number of events an escrow keeps in its meta_data,
+	// dropping the oldest. It defaults to 50.
+	MaxEvents int
+
+	balances     *blnkgo.LedgerBalanceService
+	transactions *blnkgo.TransactionService
+	metadata     *blnkgo.MetadataService
+}
+
+func NewManager(client blnkgo.ClientInterface, ledgerID string) *Manager {
+	return &Manager{
+		LedgerID:     ledgerID,
+		Now:          time.Now,
+		MaxEvents:    50,
+		balances:     blnkgo.NewLedgerBalanceService(client),
+		transactions: blnkgo.NewTransactionService(client),
+		metadata:     blnkgo.NewMetadataService(client),
+	}
+}
+
+// Open creates the escrow balance.
+func (m *Manager) Open(req OpenRequest) (*Escrow, error) {
+	if req.Reference == "" || req.Buyer == "" || req.Seller == "" || req.Currency == "" {
+		return nil, fmt.Errorf("reference, buyer, seller and currency are required")
+	}
+	if req.Precision <= 0 {
+		req.Precision = 100
+	}
+
+	e := &Escrow{
+		Reference: req.Reference,
+		Buyer:     req.Buyer,
+		Seller:    req.Seller,
+		Currency:  req.Currency,
+		Precision: req.Precision,
+		State:     StateOpen,
+		Amount:    new(big.Int),
+		Committed: new(big.Int),
+		Released:  new(big.Int),
+	}
+	metaData := make(map[string]interface{}, len(req.MetaData)+12)
+	for k, v := range req.MetaData {
+		metaData[k] = v
+	}
+	for k, v := range stateMetaData(e) {
+		metaData[k] = v
+	}
+
+	balance, _, err := m.balances.Create(blnkgo.CreateLedgerBalanceRequest{
+		LedgerID: m.LedgerID,
+		Currency: req.Currency,
+		MetaData: metaData,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create escrow balance: %w", err)
+	}
+	e.ID = balance.BalanceID
+
+	if err := m.record(e, Event{Type: EventOpened, To: StateOpen}); err != nil {
+		return e, err
+	}
+	return e, nil
+}
+
+// Load reads an escrow back from its balance.
+func (m *Manager) Load(id string) (*Escrow, error) {
+	if id == "" {
+		return nil, fmt.Errorf("escrow ID is required")
+	}
+	balance, _, err := m.balances.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	e, err := fromMetaData(balance.BalanceID, balance.MetaData)
+	if err != nil {
+		return nil, err
+	}
+	e.Currency = balance.Currency
+	return e, nil
+}
+
+// Fund holds amount from the buyer in the escrow.
+func (m *Manager) Fund(e *Escrow, amount *big.Int) error {
+	if err := check(e, ActionFund); err != nil {
+		return err
+	}
+	if amount == nil || amount.Sign() <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+
+	// a rejected transaction keeps its reference, so each attempt gets its own
+	reference := fmt.Sprintf("%s-fund-%d", e.Reference, e.fundAttempts+1)
+	request := m.request(e, ActionFund, reference, amount, e.Buyer, e.ID)
+	request.Inflight = true
+	transaction, _, err := m.transactions.Create(request)
+	if err != nil {
+		return fmt.Errorf("failed to fund escrow %s: %w", e.ID, err)
+	}
+	e.fundAttempts++
+	if transaction.Status == blnkgo.PryTransactionStatusRejected {
+		if err := m.save(e); err != nil {
+			return err
+		}
+		return fmt.Errorf("funding escrow %s was rejected", e.ID)
+	}
+
+	e.Amount = new(big.Int).Set(amount)
+	e.HoldTransactionID = transaction.TransactionID
+	return m.transition(e, Event{Type: EventFunded, To: StateFunded, Amount: amount, TransactionID: transaction.TransactionID})
+}
+
+// Release pays amount of the hold to the seller, or everything that is left
+// when amount is nil. Releasing the last of the hold releases the escrow.
+//
+// A release commits the amount into the escrow balance and then pays the
+// seller from it. If paying the seller fails, the committed amount is paid out
+// by the next call to Release.
+func (m *Manager) Release(e *Escrow, amount *big.Int) error {
+	if err := check(e, ActionRelease); err != nil {
+		return err
+	}
+	remaining := e.Remaining()
+	if amount == nil {
+		amount = remaining
+	}
+	if amount.Sign() < 0 || amount.Cmp(remaining) > 0 {
+		return fmt.Errorf("cannot release %s from escrow %s holding %s", amount, e.ID, remaining)
+	}
+
+	if amount.Sign() > 0 {
+		_, _, err := m.transactions.Update(e.HoldTransactionID, blnkgo.UpdateStatus{
+			Status:        blnkgo.InflightStatusCommit,
+			PreciseAmount: new(big.Int).Set(amount),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to commit %s of escrow %s: %w", amount, e.ID, err)
+		}
+		e.Committed = new(big.Int).Add(e.Committed, amount)
+		if err := m.save(e); err != nil {
+			return err
+		}
+	}
+
+	payout := new(big.Int).Sub(e.Committed, e.Released)
+	if payout.Sign() == 0 {
+		return fmt.Errorf("nothing to release from escrow %s", e.ID)
+	}
+	reference := fmt.Sprintf("%s-release-%d", e.Reference, e.payouts+1)
+	transaction, _, err := m.transactions.Create(m.request(e, ActionRelease, reference, payout, e.ID, e.Seller))
+	if err != nil {
+		return fmt.Errorf("failed to pay %s from escrow %s: %w", payout, e.ID, err)
+	}
+	e.payouts++
+	e.Released = new(big.Int).Set(e.Committed)
+
+	to := StatePartiallyReleased
+	if e.Remaining().Sign() == 0 {
+		to = StateReleased
+	}
+	return m.transition(e, Event{Type: EventReleased, To: to, Amount: payout, TransactionID: transaction.TransactionID})
+}
+
+// Refund returns what is left of the hold to the buyer.
+func (m *Manager) Refund(e *Escrow) error {
+	if err := check(e, ActionRefund); err != nil {
+		return err
+	}
+	remaining := e.Remaining()
+	if remaining.Sign() == 0 {
+		return fmt.Errorf("nothing to refund from escrow %s", e.ID)
+	}
+
+	transaction, _, err := m.transactions.Update(e.HoldTransactionID, blnkgo.UpdateStatus{Status: blnkgo.InflightStatusVoid})
+	if err != nil {
+		return fmt.Errorf("failed to void the hold of escrow %s: %w", e.ID, err)
+	}
+	return m.transition(e, Event{Type: EventRefunded, To: StateRefunded, Amount: remaining, TransactionID: transaction.TransactionID})
+}
+
+// Dispute freezes the escrow until Resolve is called.
+func (m *Manager) Dispute(e *Escrow, reason string) error {
+	if err := check(e, ActionDispute); err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	e.DisputedFrom, e.DisputeReason = e.State, reason
+	return m.transition(e, Event{Type: EventDisputed, To: StateDisputed, Reason: reason})
+}
+
+// Resolve ends a dispute and returns the escrow to the state it was in before.
+func (m *Manager) Resolve(e *Escrow, reason string) error {
+	if err := check(e, ActionResolve); err != nil {
+		return err
+	}
+	to := e.DisputedFrom
+	e.DisputedFrom, e.DisputeReason = "", ""
+	return m.transition(e, Event{Type: EventResolved, To: to, Reason: reason})
+}
+
+func check(e *Escrow, action Action) error {
+	if e == nil || e.ID == "" {
+		return fmt.Errorf("escrow is required")
+	}
+	if !CanTransition(e.State, action) {
+		return &TransitionError{EscrowID: e.ID, Action: action, From: e.State}
+	}
+	return nil
+}
+
+func (m *Manager) transition(e *Escrow, event Event) error {
+	event.From = e.State
+	e.State = event.To
+	return m.record(e, event)
+}
+
+// record appends event to the escrow, saves it and notifies OnEvent.
+func (m *Manager) record(e *Escrow, event Event) error {
+	event.EscrowID = e.ID
+	event.At = m.Now().UTC()
+	e.Events = append(e.Events, event)
+	limit := m.MaxEvents
+	if limit <= 0 {
+		limit = 50
+	}
+	if len(e.Events) > limit {
+		e.Events = slices.Clone(e.Events[len(e.Events)-limit:])
+	}
+	if err := m.save(e); err != nil {
+		return err
+	}
+	if m.OnEvent != nil {
+		m.OnEvent(event)
+	}
+	return nil
+}
+
+func (m *Manager) save(e *Escrow) error {
+	_, _, err := m.metadata.UpdateMetadata(e.ID, blnkgo.UpdateMetaDataRequest{MetaData: stateMetaData(e)})
+	if err != nil {
+		return fmt.Errorf("failed to save escrow %s: %w", e.ID, err)
+	}
+	return nil
+}
+
+func (m *Manager) request(e *Escrow, action Action, reference string, amount *big.Int, source, destination string) blnkgo.CreateTransactionRequest {
+	value, _ := new(big.Rat).SetFrac(amount, big.NewInt(e.Precision)).Float64()
+	return blnkgo.CreateTransactionRequest{
+		ParentTransaction: blnkgo.ParentTransaction{
+			Amount:        value,
+			PreciseAmount: new(big.Int).Set(amount),
+			Precision:     e.Precision,
+			Currency:      e.Currency,
+			Reference:     reference,
+			Description:   fmt.Sprintf("Escrow %s %s", e.Reference, action),
+			Source:        source,
+			Destination:   destination,
+			MetaData: map[string]interface{}{
+				"escrow_id":        e.ID,
+				"escrow_action":    string(action),
+				"escrow_reference": e.Reference,
+			},
+		},
+	}
+}
+
+// stateMetaData returns the meta_data the escrow is stored in. Amounts are
+// strings so they survive JSON numbers.
+func stateMetaData(e *Escrow) map[string]interface{} {
+	return map[string]interface{}{
+		"escrow_reference":        e.Reference,
+		"escrow_buyer":            e.Buyer,
+		"escrow_seller":           e.Seller,
+		"escrow_precision":        strconv.FormatInt(e.Precision, 10),
+		"escrow_state":            string(e.State),
+		"escrow_amount":           blnkgo.ValueOrZero(e.Amount).String(),
+		"escrow_committed":        blnkgo.ValueOrZero(e.Committed).String(),
+		"escrow_released":         blnkgo.ValueOrZero(e.Released).String(),
+		"escrow_fund_attempts":    strconv.Itoa(e.fundAttempts),
+		"escrow_payouts":          strconv.Itoa(e.payouts),
+		"escrow_hold_transaction": e.HoldTransactionID,
+		"escrow_disputed_from":    string(e.DisputedFrom),
+		"escrow_dispute_reason":   e.DisputeReason,
+		"escrow_events":           e.Events,
+	}
+}
+
+func fromMetaData(id string, metaData map[string]interface{}) (*Escrow, error) {
+	str := func(key string) string {
+		s, _ := metaData[key].(string)
+		return s
+	}
+	if str("escrow_state") == "" {
+		return nil, fmt.Errorf("balance %s is not an escrow", id)
+	}
+
+	e := &Escrow{
+		ID:                id,
+		Reference:         str("escrow_reference"),
+		Buyer:             str("escrow_buyer"),
+		Seller:            str("escrow_seller"),
+		State:             State(str("escrow_state")),
+		HoldTransactionID: str("escrow_hold_transaction"),
+		DisputedFrom:      State(str("escrow_disputed_from")),
+		DisputeReason:     str("escrow_dispute_reason"),
+	}
+	var err error
+	if e.Precision, err = strconv.ParseInt(str("escrow_precision"), 10, 64); err != nil {
+		return nil, fmt.Errorf("escrow %s has an invalid precision: %w", id, err)
+	}
+	if e.fundAttempts, err = strconv.Atoi(str("escrow_fund_attempts")); err != nil {
+		return nil, fmt.Errorf("escrow %s has an invalid funding attempt count: %w", id, err)
+	}
+	if e.payouts, err = strconv.Atoi(str("escrow_payouts")); err != nil {
+		return nil, fmt.Errorf("escrow %s has an invalid payout count: %w", id, err)
+	}
+	for key, amount := range map[string]**big.Int{"escrow_amount": &e.Amount, "escrow_committed": &e.Committed, "escrow_released": &e.Released} {
+		value, ok := new(big.Int).SetString(str(key), 10)
+		if !ok {
+			return nil, fmt.Errorf("escrow %s has an invalid %s", id, key)
+		}
+		*amount = value
+	}
+
+	if events, ok := metaData["escrow_events"]; ok && events != nil {
+		raw, err := json.Marshal(events)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &e.Events); err != nil {
+			return nil, fmt.Errorf("escrow %s has invalid events: %w", id, err)
+		}
+	}
+	return e, nil
+}
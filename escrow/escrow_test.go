@@ -0,0 +1,221 @@
+package escrow_test
+
+import (
+	"math/big"
+	"net/http"
+	"testing"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
+	"github.com/blnkfinance/blnk-go/escrow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setup(t *testing.T) (*blnktest.Server, *escrow.Manager, *escrow.Escrow) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	ledger, _, err := client.Ledger.Create(blnkgo.CreateLedgerRequest{Name: "Escrow"})
+	require.NoError(t, err)
+	buyer, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: ledger.LedgerID, Currency: "USD"})
+	require.NoError(t, err)
+	seller, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: ledger.LedgerID, Currency: "USD"})
+	require.NoError(t, err)
+	_, _, err = client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: "deposit", Amount: 100, Precision: 100, Currency: "USD", Source: "@bank", Destination: buyer.BalanceID,
+	}})
+	require.NoError(t, err)
+
+	manager := escrow.NewManager(client, ledger.LedgerID)
+	e, err := manager.Open(escrow.OpenRequest{Reference: "order-1", Buyer: buyer.BalanceID, Seller: seller.BalanceID, Currency: "USD"})
+	require.NoError(t, err)
+	return server, manager, e
+}
+
+func balanceOf(t *testing.T, server *blnktest.Server, id string) int64 {
+	balance, ok := server.Balance(id)
+	require.True(t, ok)
+	return balance.Balance.Int64()
+}
+
+func TestManager_FundAndRelease(t *testing.T) {
+	server, manager, e := setup(t)
+	var events []escrow.Event
+	manager.OnEvent = func(event escrow.Event) { events = append(events, event) }
+
+	require.NoError(t, manager.Fund(e, big.NewInt(6000)))
+	buyer, _ := server.Balance(e.Buyer)
+	assert.Equal(t, int64(6000), buyer.InflightDebitBalance.Int64())
+	assert.Equal(t, int64(10000), buyer.Balance.Int64())
+
+	require.NoError(t, manager.Release(e, big.NewInt(2500)))
+	assert.Equal(t, escrow.StatePartiallyReleased, e.State)
+	assert.Equal(t, int64(2500), balanceOf(t, server, e.Seller))
+	assert.Equal(t, int64(7500), balanceOf(t, server, e.Buyer))
+	assert.Equal(t, int64(0), balanceOf(t, server, e.ID))
+
+	require.NoError(t, manager.Release(e, nil))
+	assert.Equal(t, escrow.StateReleased, e.State)
+	assert.Equal(t, int64(6000), balanceOf(t, server, e.Seller))
+	assert.Equal(t, int64(4000), balanceOf(t, server, e.Buyer))
+
+	require.Len(t, events, 3)
+	assert.Equal(t, escrow.EventFunded, events[0].Type)
+	assert.Equal(t, escrow.StateOpen, events[0].From)
+	assert.Equal(t, escrow.EventReleased, events[2].Type)
+	assert.Equal(t, int64(3500), events[2].Amount.Int64())
+	assert.Equal(t, e.ID, events[2].EscrowID)
+
+	var tagged int
+	for _, transaction := range server.Transactions() {
+		if transaction.MetaData["escrow_id"] == e.ID {
+			tagged++
+		}
+	}
+	assert.Equal(t, 5, tagged, "fund, two commits and two payouts")
+
+	err := manager.Refund(e)
+	var transitionErr *escrow.TransitionError
+	require.ErrorAs(t, err, &transitionErr)
+	assert.Equal(t, escrow.ActionRefund, transitionErr.Action)
+	assert.Equal(t, escrow.StateReleased, transitionErr.From)
+}
+
+func TestManager_Refund(t *testing.T) {
+	server, manager, e := setup(t)
+	require.NoError(t, manager.Fund(e, big.NewInt(6000)))
+	require.NoError(t, manager.Release(e, big.NewInt(1000)))
+
+	require.NoError(t, manager.Refund(e))
+
+	assert.Equal(t, escrow.StateRefunded, e.State)
+	assert.Equal(t, int64(9000), balanceOf(t, server, e.Buyer))
+	assert.Equal(t, int64(1000), balanceOf(t, server, e.Seller))
+	buyer, _ := server.Balance(e.Buyer)
+	assert.Equal(t, int64(0), buyer.InflightDebitBalance.Int64())
+	last := e.Events[len(e.Events)-1]
+	assert.Equal(t, escrow.EventRefunded, last.Type)
+	assert.Equal(t, int64(5000), last.Amount.Int64())
+}
+
+func TestManager_Dispute(t *testing.T) {
+	_, manager, e := setup(t)
+	require.NoError(t, manager.Fund(e, big.NewInt(6000)))
+	require.NoError(t, manager.Dispute(e, "item not received"))
+
+	var transitionErr *escrow.TransitionError
+	assert.ErrorAs(t, manager.Release(e, nil), &transitionErr)
+	assert.ErrorAs(t, manager.Refund(e), &transitionErr)
+	assert.ErrorAs(t, manager.Dispute(e, "again"), &transitionErr)
+
+	loaded, err := manager.Load(e.ID)
+	require.NoError(t, err)
+	assert.Equal(t, escrow.StateDisputed, loaded.State)
+	assert.Equal(t, "item not received", loaded.DisputeReason)
+
+	require.NoError(t, manager.Resolve(loaded, "tracking confirmed"))
+	assert.Equal(t, escrow.StateFunded, loaded.State)
+	require.NoError(t, manager.Release(loaded, nil))
+	assert.Equal(t, escrow.StateReleased, loaded.State)
+}
+
+func TestManager_Load(t *testing.T) {
+	_, manager, e := setup(t)
+	require.NoError(t, manager.Fund(e, big.NewInt(6000)))
+	require.NoError(t, manager.Release(e, big.NewInt(2000)))
+
+	loaded, err := manager.Load(e.ID)
+
+	require.NoError(t, err)
+	assert.Equal(t, e.Reference, loaded.Reference)
+	assert.Equal(t, "USD", loaded.Currency)
+	assert.Equal(t, int64(100), loaded.Precision)
+	assert.Equal(t, escrow.StatePartiallyReleased, loaded.State)
+	assert.Equal(t, int64(4000), loaded.Remaining().Int64())
+	assert.Equal(t, e.HoldTransactionID, loaded.HoldTransactionID)
+	require.Len(t, loaded.Events, 3)
+	assert.Equal(t, escrow.EventOpened, loaded.Events[0].Type)
+	assert.Equal(t, int64(2000), loaded.Events[2].Amount.Int64())
+
+	require.NoError(t, manager.Release(loaded, nil))
+	assert.Equal(t, escrow.StateReleased, loaded.State)
+}
+
+func TestManager_EventAmountsStayExact(t *testing.T) {
+	server, manager, e := setup(t)
+	amount, _ := new(big.Int).SetString("123456789012345678901", 10)
+	_, _, err := server.Client().Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: "large-deposit", PreciseAmount: amount, Precision: 100, Currency: "USD", Source: "@bank", Destination: e.Buyer,
+	}})
+	require.NoError(t, err)
+	require.NoError(t, manager.Fund(e, amount))
+
+	balance, _ := server.Balance(e.ID)
+	events := balance.MetaData["escrow_events"].([]interface{})
+	assert.Equal(t, amount.String(), events[1].(map[string]interface{})["amount"])
+
+	loaded, err := manager.Load(e.ID)
+	require.NoError(t, err)
+	assert.Equal(t, amount, loaded.Events[1].Amount)
+	assert.Nil(t, loaded.Events[0].Amount)
+}
+
+func TestManager_MaxEvents(t *testing.T) {
+	_, manager, e := setup(t)
+	manager.MaxEvents = 3
+	require.NoError(t, manager.Fund(e, big.NewInt(1000)))
+	for i := 0; i < 2; i++ {
+		require.NoError(t, manager.Dispute(e, "late"))
+		require.NoError(t, manager.Resolve(e, "arrived"))
+	}
+
+	loaded, err := manager.Load(e.ID)
+	require.NoError(t, err)
+	require.Len(t, loaded.Events, 3)
+	assert.Equal(t, []escrow.EventType{escrow.EventResolved, escrow.EventDisputed, escrow.EventResolved},
+		[]escrow.EventType{loaded.Events[0].Type, loaded.Events[1].Type, loaded.Events[2].Type})
+}
+
+func TestManager_ReleaseRetriesPayout(t *testing.T) {
+	server, manager, e := setup(t)
+	require.NoError(t, manager.Fund(e, big.NewInt(6000)))
+	server.FailNext(http.MethodPost, "/transactions", http.StatusBadRequest)
+
+	require.Error(t, manager.Release(e, big.NewInt(2000)))
+	assert.Equal(t, escrow.StateFunded, e.State)
+	assert.Equal(t, int64(2000), balanceOf(t, server, e.ID))
+
+	require.NoError(t, manager.Release(e, big.NewInt(1000)))
+	assert.Equal(t, int64(3000), balanceOf(t, server, e.Seller))
+	assert.Equal(t, int64(0), balanceOf(t, server, e.ID))
+	assert.Equal(t, int64(3000), e.Remaining().Int64())
+}
+
+func TestManager_InvalidOperations(t *testing.T) {
+	_, manager, e := setup(t)
+
+	var transitionErr *escrow.TransitionError
+	assert.ErrorAs(t, manager.Release(e, nil), &transitionErr)
+	assert.Error(t, manager.Fund(e, big.NewInt(0)))
+	assert.EqualError(t, manager.Fund(e, big.NewInt(1_000_000)), "funding escrow "+e.ID+" was rejected")
+	assert.Equal(t, escrow.StateOpen, e.State)
+
+	require.NoError(t, manager.Fund(e, big.NewInt(6000)))
+	assert.ErrorAs(t, manager.Fund(e, big.NewInt(1)), &transitionErr)
+	assert.Error(t, manager.Release(e, big.NewInt(6001)))
+
+	_, err := manager.Open(escrow.OpenRequest{Reference: "x"})
+	assert.Error(t, err)
+	_, err = manager.Load(e.Buyer)
+	assert.EqualError(t, err, "balance "+e.Buyer+" is not an escrow")
+}
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, escrow.CanTransition(escrow.StateOpen, escrow.ActionFund))
+	assert.True(t, escrow.CanTransition(escrow.StatePartiallyReleased, escrow.ActionRefund))
+	assert.False(t, escrow.CanTransition(escrow.StateOpen, escrow.ActionRelease))
+	assert.False(t, escrow.CanTransition(escrow.StateRefunded, escrow.ActionDispute))
+	assert.False(t, escrow.CanTransition(escrow.StateFunded, escrow.ActionResolve))
+	assert.True(t, escrow.StateReleased.Terminal())
+	assert.False(t, escrow.StateDisputed.Terminal())
+}
@@ -0,0 +1,134 @@
+package escrow
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// State is where an escrow is in its lifecycle.
+type State string
+
+const (
+	StateOpen              State = "open"
+	StateFunded            State = "funded"
+	StatePartiallyReleased State = "partially_released"
+	StateReleased          State = "released"
+	StateRefunded          State = "refunded"
+	StateDisputed          State = "disputed"
+)
+
+// Terminal reports whether no further action is possible in s.
+func (s State) Terminal() bool {
+	return s == StateReleased || s == StateRefunded
+}
+
+// Action is an operation on an escrow.
+type Action string
+
+const (
+	ActionFund    Action = "fund"
+	ActionRelease Action = "release"
+	ActionRefund  Action = "refund"
+	ActionDispute Action = "dispute"
+	ActionResolve Action = "resolve"
+)
+
+// transitions lists the states each action is allowed from.
+var transitions = map[Action][]State{
+	ActionFund:    {StateOpen},
+	ActionRelease: {StateFunded, StatePartiallyReleased},
+	ActionRefund:  {StateFunded, StatePartiallyReleased},
+	ActionDispute: {StateFunded, StatePartiallyReleased},
+	ActionResolve: {StateDisputed},
+}
+
+// CanTransition reports whether action is allowed in state from.
+func CanTransition(from State, action Action) bool {
+	for _, state := range transitions[action] {
+		if state == from {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError is returned when an action is not allowed in the escrow's
+// current state.
+type TransitionError struct {
+	EscrowID string
+	Action   Action
+	From     State
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("escrow %s cannot %s while %s", e.EscrowID, e.Action, e.From)
+}
+
+// EventType identifies what happened to an escrow.
+type EventType string
+
+const (
+	EventOpened   EventType = "escrow.opened"
+	EventFunded   EventType = "escrow.funded"
+	EventReleased EventType = "escrow.released"
+	EventRefunded EventType = "escrow.refunded"
+	EventDisputed EventType = "escrow.disputed"
+	EventResolved EventType = "escrow.resolved"
+)
+
+// Event records a state change. Amount is in minor units of the escrow's
+// currency and TransactionID is the Blnk transaction that moved it, if any.
+// Amount marshals to a decimal string, so it stays exact when read back
+// through meta_data.
+type Event struct {
+	Type          EventType `json:"type"`
+	EscrowID      string    `json:"escrow_id"`
+	From          State     `json:"from,omitempty"`
+	To            State     `json:"to"`
+	Amount        *big.Int  `json:"-"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+// eventJSON is Event without its methods, so they do not recurse.
+type eventJSON Event
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	aux := struct {
+		eventJSON
+		Amount string `json:"amount,omitempty"`
+	}{eventJSON: eventJSON(e)}
+	if e.Amount != nil {
+		aux.Amount = e.Amount.String()
+	}
+	return json.Marshal(aux)
+}
+
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		*eventJSON
+		Amount json.RawMessage `json:"amount,omitempty"`
+	}
+	aux.eventJSON = (*eventJSON)(e)
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	e.Amount = nil
+	if len(aux.Amount) == 0 || string(aux.Amount) == "null" {
+		return nil
+	}
+	text := string(aux.Amount)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	amount, ok := new(big.Int).SetString(text, 10)
+	if !ok {
+		return fmt.Errorf("invalid escrow event amount %s", aux.Amount)
+	}
+	e.Amount = amount
+	return nil
+}
@@ -0,0 +1,121 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// DefaultColumns are the columns exported when Config.Columns is empty.
+var DefaultColumns = []string{
+	"transaction_id", "reference", "status", "source", "destination",
+	"amount", "currency", "precision", "description", "created_at", "effective_date",
+}
+
+// columns renders the named columns of a transaction. Times are RFC 3339 in
+// UTC and empty when unset.
+var columns = map[string]func(t *blnkgo.Transaction) string{
+	"transaction_id":     func(t *blnkgo.Transaction) string { return t.TransactionID },
+	"parent_transaction": func(t *blnkgo.Transaction) string { return t.ParentTransactionID },
+	"reference":          func(t *blnkgo.Transaction) string { return t.Reference },
+	"status":             func(t *blnkgo.Transaction) string { return string(t.Status) },
+	"source":             func(t *blnkgo.Transaction) string { return t.Source },
+	"destination":        func(t *blnkgo.Transaction) string { return t.Destination },
+	"amount":             func(t *blnkgo.Transaction) string { return blnkgo.FormatPreciseAmount(preciseAmount(t), t.Precision) },
+	"precise_amount":     func(t *blnkgo.Transaction) string { return preciseAmount(t).String() },
+	"currency":           func(t *blnkgo.Transaction) string { return t.Currency },
+	"precision":          func(t *blnkgo.Transaction) string { return strconv.FormatInt(t.Precision, 10) },
+	"rate":               func(t *blnkgo.Transaction) string { return formatFloat(t.Rate) },
+	"description":        func(t *blnkgo.Transaction) string { return t.Description },
+	"hash":               func(t *blnkgo.Transaction) string { return t.Hash },
+	"inflight":           func(t *blnkgo.Transaction) string { return strconv.FormatBool(t.Inflight) },
+	"skip_queue":         func(t *blnkgo.Transaction) string { return strconv.FormatBool(t.SkipQueue) },
+	"created_at":         func(t *blnkgo.Transaction) string { return formatTime(&t.CreatedAt) },
+	"effective_date":     func(t *blnkgo.Transaction) string { return formatTime(t.EffectiveDate) },
+	"scheduled_for":      func(t *blnkgo.Transaction) string { return formatTime(t.ScheduledFor) },
+	"sources":            func(t *blnkgo.Transaction) string { return formatJSON(t.Sources) },
+	"destinations":       func(t *blnkgo.Transaction) string { return formatJSON(t.Destinations) },
+	"meta_data":          func(t *blnkgo.Transaction) string { return formatJSON(t.MetaData) },
+}
+
+// Columns returns the names of the columns that can be exported besides
+// meta_data.<key> columns.
+func Columns() []string {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// columnFunc resolves a column name. meta_data.<key> columns flatten
+// metadata: dots reach into nested objects, strings are written as they are
+// and other values as JSON.
+func columnFunc(name string) (func(t *blnkgo.Transaction) string, error) {
+	if fn, ok := columns[name]; ok {
+		return fn, nil
+	}
+	path, ok := strings.CutPrefix(name, "meta_data.")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("unknown column %q", name)
+	}
+	keys := strings.Split(path, ".")
+	return func(t *blnkgo.Transaction) string {
+		var value interface{} = t.MetaData
+		for _, key := range keys {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return ""
+			}
+			value = object[key]
+		}
+		switch value := value.(type) {
+		case nil:
+			return ""
+		case string:
+			return value
+		}
+		return formatJSON(value)
+	}, nil
+}
+
+// preciseAmount returns the amount in minor units, computing it from the
+// float amount for transactions that do not carry one.
+func preciseAmount(t *blnkgo.Transaction) *big.Int {
+	if t.PreciseAmount != nil {
+		return t.PreciseAmount
+	}
+	return blnkgo.ToPreciseAmount(t.Amount, t.Precision)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func formatFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return ""
+	}
+	return string(data)
+}
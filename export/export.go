@@ -0,0 +1,467 @@
+// Package export streams Blnk transactions to CSV, NDJSON or Parquet, for
+// loading into a data warehouse.
+//
+// An Exporter pages through every transaction matching FilterParams or a
+// SearchQuery in created_at order and writes each page as it arrives, so
+// memory use does not grow with the number of transactions. After every batch
+// handed to the writer it reports a Cursor, which can be stored and passed
+// back to resume an interrupted export:
+//
+//	exporter, _ := export.NewExporter(client, export.Config{
+//		Format:     export.CSV,
+//		Columns:    []string{"transaction_id", "amount", "currency", "meta_data.customer_id"},
+//		Checkpoint: func(c export.Cursor) error { return save(c) },
+//	})
+//	cursor, err := exporter.Filter(file, params, saved)
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// Format is an output format.
+type Format string
+
+const (
+	CSV Format = "csv"
+	// NDJSON writes one JSON object per line, with every value a string so
+	// amounts stay exact.
+	NDJSON Format = "ndjson"
+	// Parquet writes a Parquet file of uncompressed UTF-8 string columns.
+	Parquet Format = "parquet"
+)
+
+// Config configures an Exporter.
+type Config struct {
+	Format Format
+	// Columns are the columns to export, in order: names from Columns() or
+	// meta_data.<key> to flatten a metadata key into its own column, with
+	// dots reaching into nested objects. Defaults to DefaultColumns.
+	Columns []string
+	// PageSize is the number of transactions fetched per request. Defaults to
+	// 100.
+	PageSize int
+	// RowGroupSize is the number of rows per Parquet row group, the unit
+	// Parquet output is written and checkpointed in. Every row group has
+	// exactly this many rows except the last. Defaults to 10000.
+	RowGroupSize int
+	// Checkpoint, if set, is called with the cursor every time rows have been
+	// handed to the output. An error stops the export.
+	Checkpoint func(Cursor) error
+}
+
+// Cursor is the position of an export: the number of transactions written, the
+// ID and created_at of the last one, and every transaction written with that
+// created_at. Transactions created at the same time have no stable order, so
+// an export continues after CreatedAt, skipping TransactionIDs, rather than
+// at Offset. It marshals to JSON for storage.
+type Cursor struct {
+	Offset            int       `json:"offset"`
+	LastTransactionID string    `json:"last_transaction_id,omitempty"`
+	CreatedAt         time.Time `json:"created_at,omitzero"`
+	TransactionIDs    []string  `json:"transaction_ids,omitempty"`
+}
+
+// next is the cursor after transaction t has been written.
+func (c Cursor) next(t *blnkgo.Transaction) Cursor {
+	next := Cursor{Offset: c.Offset + 1, LastTransactionID: t.TransactionID, CreatedAt: t.CreatedAt}
+	if t.CreatedAt.Equal(c.CreatedAt) {
+		// clipped so cursors handed to Checkpoint never share an array
+		next.TransactionIDs = append(slices.Clip(c.TransactionIDs), t.TransactionID)
+	} else {
+		next.TransactionIDs = []string{t.TransactionID}
+	}
+	return next
+}
+
+// written reports whether t was written before c.
+func (c Cursor) written(t *blnkgo.Transaction) bool {
+	return t.CreatedAt.Equal(c.CreatedAt) && slices.Contains(c.TransactionIDs, t.TransactionID)
+}
+
+// SourceChangedError is returned when resuming a Search with a custom sort
+// from a cursor whose last transaction is no longer at the cursor's offset,
+// e.g. because the query changed, so resuming would skip or repeat
+// transactions.
+type SourceChangedError struct {
+	Cursor Cursor
+	Found  string
+}
+
+func (e *SourceChangedError) Error() string {
+	return fmt.Sprintf("cannot resume export at offset %d: expected transaction %s there, found %q", e.Cursor.Offset, e.Cursor.LastTransactionID, e.Found)
+}
+
+// Exporter writes transactions in one format.
+type Exporter struct {
+	transactions *blnkgo.TransactionService
+	search       *blnkgo.SearchService
+	config       Config
+	columns      []func(t *blnkgo.Transaction) string
+}
+
+func NewExporter(client blnkgo.ClientInterface, config Config) (*Exporter, error) {
+	switch config.Format {
+	case CSV, NDJSON, Parquet:
+	default:
+		return nil, fmt.Errorf("unknown format %q, want csv, ndjson or parquet", config.Format)
+	}
+	if len(config.Columns) == 0 {
+		config.Columns = DefaultColumns
+	}
+	if config.PageSize <= 0 {
+		config.PageSize = 100
+	}
+	if config.RowGroupSize <= 0 {
+		config.RowGroupSize = 10000
+	}
+	e := &Exporter{
+		transactions: blnkgo.NewTransactionService(client),
+		search:       blnkgo.NewSearchService(client),
+		config:       config,
+	}
+	seen := make(map[string]bool)
+	for _, name := range config.Columns {
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		seen[name] = true
+		fn, err := columnFunc(name)
+		if err != nil {
+			return nil, err
+		}
+		e.columns = append(e.columns, fn)
+	}
+	return e, nil
+}
+
+// page fetches the transactions created at or after from.CreatedAt, or all of
+// them when it is zero, skipping the first skip. last reports that there are
+// none after them.
+type page func(from Cursor, skip int) (records []blnkgo.Transaction, last bool, err error)
+
+// Filter exports every transaction matching params, ordered by created_at
+// ascending whatever params.SortBy says, so that transactions recorded during
+// the export come last. Pass the cursor of an interrupted export as from to
+// continue it, or nil to start over.
+//
+// The returned cursor covers every row written, also when an error stopped
+// the export. CSV and NDJSON output of a resumed export has no header and can
+// be appended to the earlier output. Parquet output is always a complete file,
+// so a resumed export writes a new file with the remaining transactions.
+func (e *Exporter) Filter(w io.Writer, params blnkgo.FilterParams, from *Cursor) (Cursor, error) {
+	params.SortBy, params.SortOrder = "created_at", "asc"
+	params.Limit = e.config.PageSize
+	params.IncludeCount = false
+	if params.Filters == nil {
+		params.Filters = []blnkgo.Filter{}
+	}
+	if err := checkResumable(from); err != nil {
+		return *from, err
+	}
+	return e.run(w, func(from Cursor, skip int) ([]blnkgo.Transaction, bool, error) {
+		page := params
+		page.Offset = skip
+		if !from.CreatedAt.IsZero() {
+			page.Filters = append(slices.Clip(params.Filters), blnkgo.Filter{
+				Field: "created_at", Operator: blnkgo.OpGreaterThanOrEqual, Value: from.CreatedAt.Format(time.RFC3339Nano),
+			})
+		}
+		response, _, err := e.transactions.Filter(page)
+		if err != nil {
+			return nil, false, err
+		}
+		var records []blnkgo.Transaction
+		if err := response.DecodeData(&records); err != nil {
+			return nil, false, err
+		}
+		return records, len(records) < page.Limit, nil
+	}, from)
+}
+
+// Search exports every transaction matching a search query, sorted by
+// created_at ascending unless query.Sort is set. Without a custom sort it
+// resumes like Filter. With one it pages by offset, which only resumes
+// correctly if no transactions were recorded in between and the sort has no
+// ties.
+func (e *Exporter) Search(w io.Writer, query blnkgo.SearchQuery, from *Cursor) (Cursor, error) {
+	query.Resource = blnkgo.Transactions
+	sorted := len(query.Sort) > 0
+	if !sorted {
+		query.Sort = []blnkgo.SearchSort{blnkgo.SortAsc("created_at")}
+	}
+	query.PerPage = e.config.PageSize
+	params, err := query.Params()
+	if err != nil {
+		return Cursor{}, err
+	}
+	fetch := func(from Cursor, skip int) ([]blnkgo.Transaction, bool, error) {
+		page := params
+		offset := skip
+		switch {
+		case sorted:
+			offset += from.Offset
+		case !from.CreatedAt.IsZero():
+			after := blnkgo.And(query.Filter, blnkgo.SearchField("created_at").Gte(from.CreatedAt))
+			var err error
+			if page.FilterBy, err = after.Build(query.Resource); err != nil {
+				return nil, false, err
+			}
+		}
+		page.Page = offset/page.PerPage + 1
+		results, _, err := e.search.SearchTransactions(page)
+		if err != nil {
+			return nil, false, err
+		}
+		within := offset % page.PerPage
+		if within > len(results.Hits) {
+			within = len(results.Hits)
+		}
+		records := make([]blnkgo.Transaction, 0, len(results.Hits)-within)
+		for _, hit := range results.Hits[within:] {
+			records = append(records, documentTransaction(hit.Document))
+		}
+		return records, len(results.Hits) < page.PerPage || page.Page*page.PerPage >= results.Found, nil
+	}
+	if !sorted {
+		if err := checkResumable(from); err != nil {
+			return *from, err
+		}
+		return e.run(w, fetch, from)
+	}
+	if from != nil && from.Offset > 0 && from.LastTransactionID != "" {
+		records, _, err := fetch(Cursor{}, from.Offset-1)
+		if err != nil {
+			return *from, err
+		}
+		found := ""
+		if len(records) > 0 {
+			found = records[0].TransactionID
+		}
+		if found != from.LastTransactionID {
+			return *from, &SourceChangedError{Cursor: *from, Found: found}
+		}
+	}
+	return e.run(w, fetch, from)
+}
+
+// checkResumable rejects a cursor that has rows but no created_at to continue
+// after, which would start the export over.
+func checkResumable(from *Cursor) error {
+	if from != nil && from.Offset > 0 && from.CreatedAt.IsZero() {
+		return fmt.Errorf("cannot resume export at offset %d: the cursor has no created_at", from.Offset)
+	}
+	return nil
+}
+
+func (e *Exporter) run(w io.Writer, fetch page, from *Cursor) (Cursor, error) {
+	var cursor Cursor
+	if from != nil {
+		cursor = *from
+	}
+
+	out, err := e.newWriter(w, cursor.Offset == 0)
+	if err != nil {
+		return cursor, err
+	}
+	// position is how far the export got; cursor only moves up to it once
+	// the rows are out of the writer's buffer.
+	position := cursor
+	saved := false
+	save := func() error {
+		saved = true
+		return e.checkpoint(cursor)
+	}
+	finish := func(err error) (Cursor, error) {
+		if closeErr := out.close(); closeErr != nil {
+			if err == nil {
+				err = closeErr
+			}
+			return cursor, err
+		}
+		if err == nil && (!saved || cursor.Offset != position.Offset) {
+			cursor = position
+			err = save()
+		}
+		cursor = position
+		return cursor, err
+	}
+
+	row := make([]string, len(e.columns))
+	// window is the position pages are fetched from. It stays put while the
+	// export is still within the same created_at, paging on with skip, which
+	// happens when more transactions share a created_at than fit on a page.
+	window, skip := position, 0
+	for {
+		records, last, err := fetch(window, skip)
+		if err != nil {
+			return finish(err)
+		}
+		fresh := 0
+		for i := range records {
+			if position.written(&records[i]) {
+				continue
+			}
+			fresh++
+			for j, column := range e.columns {
+				row[j] = column(&records[i])
+			}
+			flushed, err := out.write(row)
+			if err != nil {
+				return cursor, err
+			}
+			position = position.next(&records[i])
+			if flushed {
+				cursor = position
+				if err := save(); err != nil {
+					return finish(err)
+				}
+			}
+		}
+		if last || len(records) == 0 {
+			return finish(nil)
+		}
+		if position.CreatedAt.Equal(window.CreatedAt) {
+			skip += len(records)
+		} else {
+			window, skip = position, 0
+		}
+		if fresh == 0 {
+			continue
+		}
+		flushed, err := out.flush()
+		if err != nil {
+			return cursor, err
+		}
+		if flushed {
+			cursor = position
+			if err := save(); err != nil {
+				return finish(err)
+			}
+		}
+	}
+}
+
+func (e *Exporter) checkpoint(cursor Cursor) error {
+	if e.config.Checkpoint == nil {
+		return nil
+	}
+	return e.config.Checkpoint(cursor)
+}
+
+// rowWriter writes rows in one format. write reports whether the row completed
+// a chunk that was passed on to the underlying writer along with every row
+// before it. flush is called after each page and reports the same for the
+// rows buffered so far.
+type rowWriter interface {
+	write(row []string) (bool, error)
+	flush() (bool, error)
+	close() error
+}
+
+func (e *Exporter) newWriter(w io.Writer, header bool) (rowWriter, error) {
+	switch e.config.Format {
+	case NDJSON:
+		return &ndjsonWriter{w: w, columns: e.config.Columns}, nil
+	case Parquet:
+		return newParquetWriter(w, e.config.Columns, e.config.RowGroupSize), nil
+	}
+	out := &csvWriter{w: csv.NewWriter(w)}
+	if header {
+		if err := out.w.Write(e.config.Columns); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) write(row []string) (bool, error) {
+	return false, c.w.Write(row)
+}
+
+func (c *csvWriter) flush() (bool, error) {
+	c.w.Flush()
+	return true, c.w.Error()
+}
+
+func (c *csvWriter) close() error {
+	_, err := c.flush()
+	return err
+}
+
+type ndjsonWriter struct {
+	w       io.Writer
+	columns []string
+	buf     []byte
+}
+
+func (n *ndjsonWriter) write(row []string) (bool, error) {
+	n.buf = append(n.buf[:0], '{')
+	for i, value := range row {
+		if i > 0 {
+			n.buf = append(n.buf, ',')
+		}
+		for _, s := range []string{n.columns[i], value} {
+			quoted, err := json.Marshal(s)
+			if err != nil {
+				return false, err
+			}
+			n.buf = append(n.buf, quoted...)
+			n.buf = append(n.buf, ':')
+		}
+		n.buf = n.buf[:len(n.buf)-1]
+	}
+	n.buf = append(n.buf, '}', '\n')
+	_, err := n.w.Write(n.buf)
+	return false, err
+}
+
+func (n *ndjsonWriter) flush() (bool, error) { return true, nil }
+
+func (n *ndjsonWriter) close() error { return nil }
+
+// documentTransaction converts a search hit into a Transaction.
+func documentTransaction(d blnkgo.TransactionDocument) blnkgo.Transaction {
+	t := blnkgo.Transaction{
+		TransactionID:       d.TransactionID,
+		ParentTransactionID: d.ParentTransaction,
+		Hash:                d.Hash,
+		Inflight:            d.Inflight,
+		CreatedAt:           d.CreatedAt.Time,
+	}
+	t.Amount = d.Amount
+	t.Reference = d.Reference
+	t.Precision = int64(d.Precision)
+	t.Description = d.Description
+	t.Currency = d.Currency
+	t.Rate = d.Rate
+	t.Source = d.Source
+	t.Destination = d.Destination
+	t.SkipQueue = d.SkipQueue
+	t.Status = blnkgo.PryTransactionStatus(d.Status)
+	if amount, ok := new(big.Int).SetString(d.PreciseAmount, 10); ok {
+		t.PreciseAmount = amount
+	}
+	if metaData, ok := d.MetaData.(map[string]interface{}); ok {
+		t.MetaData = metaData
+	}
+	if d.EffectiveDate != nil {
+		t.EffectiveDate = &d.EffectiveDate.Time
+	}
+	if d.ScheduledFor != nil {
+		t.ScheduledFor = &d.ScheduledFor.Time
+	}
+	return t
+}
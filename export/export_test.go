@@ -0,0 +1,254 @@
+package export_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
+	"github.com/blnkfinance/blnk-go/export"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seed(t *testing.T, n int) (*blnktest.Server, *blnkgo.Client) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	for i := 0; i < n; i++ {
+		_, _, err := client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+			Reference:     fmt.Sprintf("ref-%d", i),
+			PreciseAmount: new(big.Int).SetInt64(int64(1000000000000000001 + i)),
+			Precision:     100,
+			Currency:      "USD",
+			Source:        "@world",
+			Destination:   "@wallet",
+			Description:   "payout, \"weekly\"",
+			MetaData:      map[string]interface{}{"customer": map[string]interface{}{"id": fmt.Sprintf("cus_%d", i)}, "batch": i},
+		}})
+		require.NoError(t, err)
+	}
+	return server, client
+}
+
+func TestExporter_CSV(t *testing.T) {
+	_, client := seed(t, 5)
+	var checkpoints []export.Cursor
+	exporter, err := export.NewExporter(client, export.Config{
+		Format:     export.CSV,
+		Columns:    []string{"reference", "amount", "description", "meta_data.customer.id", "meta_data.batch", "meta_data.missing"},
+		PageSize:   2,
+		Checkpoint: func(c export.Cursor) error { checkpoints = append(checkpoints, c); return nil },
+	})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	cursor, err := exporter.Filter(&out, blnkgo.FilterParams{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 5, cursor.Offset)
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 6)
+	assert.Equal(t, []string{"reference", "amount", "description", "meta_data.customer.id", "meta_data.batch", "meta_data.missing"}, rows[0])
+	assert.Equal(t, []string{"ref-0", "10000000000000000.01", "payout, \"weekly\"", "cus_0", "0", ""}, rows[1])
+	// each page starts at the created_at of the last row written, so it
+	// repeats that row, which is skipped
+	assert.Equal(t, []int{2, 3, 4, 5}, offsets(checkpoints))
+	assert.Equal(t, cursor, checkpoints[len(checkpoints)-1])
+}
+
+func TestExporter_ResumeWithinCreatedAt(t *testing.T) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	server.SetClock(func() time.Time { return now })
+	for i := 0; i < 5; i++ {
+		_, _, err := client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+			Reference: fmt.Sprintf("ref-%d", i), PreciseAmount: big.NewInt(100), Precision: 100, Currency: "USD", Source: "@world", Destination: "@wallet",
+		}})
+		require.NoError(t, err)
+	}
+	now = now.Add(time.Second)
+	_, _, err := client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: "ref-5", PreciseAmount: big.NewInt(100), Precision: 100, Currency: "USD", Source: "@world", Destination: "@wallet",
+	}})
+	require.NoError(t, err)
+
+	interrupted := errors.New("interrupted")
+	config := export.Config{
+		Format:     export.CSV,
+		Columns:    []string{"reference"},
+		PageSize:   2,
+		Checkpoint: func(c export.Cursor) error { return interrupted },
+	}
+	exporter, err := export.NewExporter(client, config)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	cursor, err := exporter.Filter(&out, blnkgo.FilterParams{}, nil)
+	require.ErrorIs(t, err, interrupted)
+	assert.Equal(t, now.Add(-time.Second), cursor.CreatedAt)
+	assert.Len(t, cursor.TransactionIDs, 2)
+
+	// more transactions share the created_at than fit on a page
+	config.Checkpoint = nil
+	exporter, err = export.NewExporter(client, config)
+	require.NoError(t, err)
+	cursor, err = exporter.Filter(&out, blnkgo.FilterParams{}, &cursor)
+	require.NoError(t, err)
+	assert.Equal(t, 6, cursor.Offset)
+	assert.Equal(t, now, cursor.CreatedAt)
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"reference"}, {"ref-0"}, {"ref-1"}, {"ref-2"}, {"ref-3"}, {"ref-4"}, {"ref-5"}}, rows)
+}
+
+func TestExporter_Resume(t *testing.T) {
+	_, client := seed(t, 5)
+	interrupted := errors.New("interrupted")
+	config := export.Config{
+		Format:     export.NDJSON,
+		Columns:    []string{"reference", "precise_amount"},
+		PageSize:   2,
+		Checkpoint: func(export.Cursor) error { return interrupted },
+	}
+	exporter, err := export.NewExporter(client, config)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	cursor, err := exporter.Filter(&out, blnkgo.FilterParams{}, nil)
+	require.ErrorIs(t, err, interrupted)
+	require.Equal(t, 2, cursor.Offset)
+
+	config.Checkpoint = nil
+	exporter, err = export.NewExporter(client, config)
+	require.NoError(t, err)
+	stored, err := json.Marshal(cursor)
+	require.NoError(t, err)
+	var resumed export.Cursor
+	require.NoError(t, json.Unmarshal(stored, &resumed))
+
+	cursor, err = exporter.Filter(&out, blnkgo.FilterParams{}, &resumed)
+	require.NoError(t, err)
+	assert.Equal(t, 5, cursor.Offset)
+
+	var references []string
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var record map[string]string
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		references = append(references, record["reference"])
+	}
+	assert.Equal(t, []string{"ref-0", "ref-1", "ref-2", "ref-3", "ref-4"}, references)
+
+	_, err = exporter.Filter(&out, blnkgo.FilterParams{}, &export.Cursor{Offset: 2, LastTransactionID: "txn_other"})
+	assert.ErrorContains(t, err, "no created_at")
+}
+
+func TestExporter_Parquet(t *testing.T) {
+	_, client := seed(t, 7)
+	var out bytes.Buffer
+	var checkpoints []export.Cursor
+	var written []int
+	columns := []string{"transaction_id", "amount", "description", "meta_data.customer.id"}
+	exporter, err := export.NewExporter(client, export.Config{
+		Format:       export.Parquet,
+		Columns:      columns,
+		PageSize:     2,
+		RowGroupSize: 3,
+		Checkpoint: func(c export.Cursor) error {
+			checkpoints = append(checkpoints, c)
+			written = append(written, out.Len())
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = exporter.Filter(&out, blnkgo.FilterParams{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 6, 7}, offsets(checkpoints), "checkpoints follow row groups")
+	require.Len(t, written, 3)
+	assert.Less(t, 4, written[0], "a checkpointed row group has been written out")
+	assert.Less(t, written[0], written[1])
+
+	// read the file back
+	data := out.Bytes()
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	fields := file.Schema().Fields()
+	require.Len(t, fields, len(columns))
+	for i, field := range fields {
+		assert.Equal(t, columns[i], field.Name())
+		assert.True(t, field.Required(), field.Name())
+		assert.Equal(t, parquet.ByteArray, field.Type().Kind(), field.Name())
+		require.NotNil(t, field.Type().LogicalType(), field.Name())
+		assert.Equal(t, "STRING", field.Type().LogicalType().String(), field.Name())
+	}
+
+	assert.Equal(t, int64(7), file.NumRows())
+	var groupSizes []int64
+	var rows [][]string
+	for _, group := range file.RowGroups() {
+		groupSizes = append(groupSizes, group.NumRows())
+		reader := group.Rows()
+		buffer := make([]parquet.Row, 10)
+		n, err := reader.ReadRows(buffer)
+		if !errors.Is(err, io.EOF) {
+			require.NoError(t, err)
+		}
+		require.NoError(t, reader.Close())
+		for _, row := range buffer[:n] {
+			values := make([]string, len(row))
+			for _, value := range row {
+				values[value.Column()] = string(value.ByteArray())
+			}
+			rows = append(rows, values)
+		}
+	}
+	assert.Equal(t, []int64{3, 3, 1}, groupSizes)
+
+	transactions, err := client.Transaction.FilterAll(blnkgo.FilterParams{SortBy: "created_at", SortOrder: "asc"})
+	require.NoError(t, err)
+	require.Len(t, rows, len(transactions))
+	for i, transaction := range transactions {
+		assert.Equal(t, []string{
+			transaction.TransactionID,
+			fmt.Sprintf("10000000000000000.%02d", i+1),
+			`payout, "weekly"`,
+			fmt.Sprintf("cus_%d", i),
+		}, rows[i])
+	}
+}
+
+func TestNewExporter(t *testing.T) {
+	client := blnktest.NewServer(t).Client()
+	for _, config := range []export.Config{
+		{Format: "xlsx"},
+		{Format: export.CSV, Columns: []string{"nope"}},
+		{Format: export.CSV, Columns: []string{"meta_data."}},
+		{Format: export.CSV, Columns: []string{"amount", "amount"}},
+	} {
+		_, err := export.NewExporter(client, config)
+		assert.Error(t, err, "%+v", config)
+	}
+	assert.True(t, strings.Contains(strings.Join(export.Columns(), ","), "precise_amount"))
+}
+
+func offsets(cursors []export.Cursor) []int {
+	var out []int
+	for _, c := range cursors {
+		out = append(out, c.Offset)
+	}
+	return out
+}
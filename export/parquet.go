@@ -0,0 +1,89 @@
+package export
+
+import (
+	"io"
+	"reflect"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetWriter writes a Parquet file in which every column is a required
+// UTF-8 string, uncompressed, cutting a row group every rowGroupSize rows.
+// Only the current row group is held in memory.
+type parquetWriter struct {
+	w            *parquet.Writer
+	rowGroupSize int
+	rows         int
+	row          parquet.Row
+}
+
+func newParquetWriter(w io.Writer, columns []string, rowGroupSize int) *parquetWriter {
+	// without a write buffer a flushed row group is in w when write returns,
+	// so the checkpoint that follows it is safe
+	return &parquetWriter{
+		w: parquet.NewWriter(w,
+			parquet.NewSchema("schema", newColumnGroup(columns)),
+			parquet.WriteBufferSize(0),
+		),
+		rowGroupSize: rowGroupSize,
+		row:          make(parquet.Row, len(columns)),
+	}
+}
+
+// write buffers row and writes the buffered rows as a row group once there
+// are rowGroupSize of them, reporting whether it did.
+func (p *parquetWriter) write(row []string) (bool, error) {
+	for i, value := range row {
+		p.row[i] = parquet.ByteArrayValue([]byte(value)).Level(0, 0, i)
+	}
+	if _, err := p.w.WriteRows([]parquet.Row{p.row}); err != nil {
+		return false, err
+	}
+	p.rows++
+	if p.rows < p.rowGroupSize {
+		return false, nil
+	}
+	p.rows = 0
+	return true, p.w.Flush()
+}
+
+// flush does nothing: row groups are only cut by write, so that all but the
+// last have exactly rowGroupSize rows.
+func (p *parquetWriter) flush() (bool, error) {
+	return false, nil
+}
+
+// close writes the remaining rows and the footer.
+func (p *parquetWriter) close() error {
+	return p.w.Close()
+}
+
+// columnGroup is the root of the schema. parquet.Group sorts its fields by
+// name, which would reorder Config.Columns.
+type columnGroup struct {
+	parquet.Group
+	fields []parquet.Field
+}
+
+func newColumnGroup(columns []string) *columnGroup {
+	g := &columnGroup{Group: parquet.Group{}}
+	for _, name := range columns {
+		node := parquet.String()
+		g.Group[name] = node
+		g.fields = append(g.fields, &column{Node: node, name: name})
+	}
+	return g
+}
+
+func (g *columnGroup) Fields() []parquet.Field { return g.fields }
+
+type column struct {
+	parquet.Node
+	name string
+}
+
+func (c *column) Name() string { return c.name }
+
+func (c *column) Value(base reflect.Value) reflect.Value {
+	return base.MapIndex(reflect.ValueOf(c.name))
+}
@@ -3,6 +3,8 @@ package blnkgo
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"slices"
 )
 
 type Operator string
@@ -41,14 +43,25 @@ type Filter struct {
 type FilterResponse struct {
 	Data       interface{} `json:"data"`
 	TotalCount *int64      `json:"total_count,omitempty"`
+
+	// raw keeps the undecoded data so DecodeData does not lose precision on
+	// large amounts that were turned into float64 when decoding into Data
+	raw json.RawMessage
 }
 
 func (f *FilterResponse) UnmarshalJSON(data []byte) error {
-	type alias FilterResponse
-	var aux alias
-	if err := json.Unmarshal(data, &aux); err == nil && aux.Data != nil {
-		f.Data = aux.Data
+	var aux struct {
+		Data       json.RawMessage `json:"data"`
+		TotalCount *int64          `json:"total_count,omitempty"`
+	}
+	if err := json.Unmarshal(data, &aux); err == nil && len(aux.Data) > 0 && string(aux.Data) != "null" {
+		var decoded interface{}
+		if err := json.Unmarshal(aux.Data, &decoded); err != nil {
+			return fmt.Errorf("failed to decode FilterResponse: %w", err)
+		}
+		f.Data = decoded
 		f.TotalCount = aux.TotalCount
+		f.raw = aux.Data
 		return nil
 	}
 
@@ -59,5 +72,109 @@ func (f *FilterResponse) UnmarshalJSON(data []byte) error {
 
 	f.Data = slice
 	f.TotalCount = nil
+	f.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// DecodeData decodes the records in Data into v, which should be a pointer to a
+// slice such as *[]Transaction.
+func (f *FilterResponse) DecodeData(v interface{}) error {
+	raw := f.raw
+	if raw == nil {
+		var err error
+		raw, err = json.Marshal(f.Data)
+		if err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("failed to decode filter data: %w", err)
+	}
 	return nil
 }
+
+// filterPageSize is the page size used when paging through filter results and
+// the caller did not set a Limit.
+const filterPageSize = 100
+
+// filterSortBy is the field filterEach sorts by when the caller did not choose
+// one.
+const filterSortBy = "created_at"
+
+// filterAll pages through every record matching params using offset pagination
+// and decodes them into T.
+func filterAll[T any](filter func(FilterParams) (*FilterResponse, *http.Response, error), params FilterParams) ([]T, error) {
+	var all []T
+	err := filterEach(filter, params, func(page []T) error {
+		all = append(all, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// filterRecord is a record filterEach can tell apart from the others.
+type filterRecord interface {
+	filterID() string
+}
+
+func (t *Transaction) filterID() string   { return t.TransactionID }
+func (b *LedgerBalance) filterID() string { return b.BalanceID }
+func (l *Ledger) filterID() string        { return l.LedgerID }
+
+// filterEach pages through every record matching params and calls fn with each
+// page, so callers that aggregate results do not need to hold them all.
+//
+// Offset pages only line up when every request returns the records in the same
+// order, so when the caller did not set SortBy the records are sorted by
+// created_at, oldest first. Records created while paging then land on the last
+// pages instead of shifting the earlier ones. Records sharing a created_at can
+// still move between pages, so a record that implements filterRecord and was
+// already passed to fn is dropped from later pages.
+func filterEach[T any](filter func(FilterParams) (*FilterResponse, *http.Response, error), params FilterParams, fn func(page []T) error) error {
+	if params.Limit <= 0 {
+		params.Limit = filterPageSize
+	}
+	if params.SortBy == "" {
+		params.SortBy = filterSortBy
+		params.SortOrder = "asc"
+	}
+	if params.Filters == nil {
+		params.Filters = []Filter{}
+	}
+
+	seen := make(map[string]bool)
+	for {
+		filterResponse, _, err := filter(params)
+		if err != nil {
+			return err
+		}
+
+		var page []T
+		if err := filterResponse.DecodeData(&page); err != nil {
+			return err
+		}
+		fetched := len(page)
+		page = slices.DeleteFunc(page, func(record T) bool {
+			r, ok := any(&record).(filterRecord)
+			if !ok {
+				return false
+			}
+			if seen[r.filterID()] {
+				return true
+			}
+			seen[r.filterID()] = true
+			return false
+		})
+		if err := fn(page); err != nil {
+			return err
+		}
+
+		if fetched < params.Limit {
+			return nil
+		}
+		params.Offset += params.Limit
+	}
+}
@@ -1,10 +1,16 @@
 package blnkgo_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	blnkgo "github.com/blnkfinance/blnk-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFilterParams_EmptyFilters(t *testing.T) {
@@ -236,3 +242,50 @@ func TestFilterResponse_UnmarshalJSON_InvalidJSON(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to decode FilterResponse")
 }
+
+func TestFilterResponse_DecodeData_KeepsPrecision(t *testing.T) {
+	jsonData := `{"data": [{"balance_id": "bln_123", "balance": 123456789012345678901234567890}]}`
+
+	var resp blnkgo.FilterResponse
+	err := json.Unmarshal([]byte(jsonData), &resp)
+	assert.NoError(t, err)
+
+	var balances []blnkgo.LedgerBalance
+	err = resp.DecodeData(&balances)
+	assert.NoError(t, err)
+	assert.Len(t, balances, 1)
+	assert.Equal(t, "123456789012345678901234567890", balances[0].Balance.String())
+}
+
+func TestFilterResponse_DecodeData_FromTypedData(t *testing.T) {
+	resp := blnkgo.FilterResponse{
+		Data: []blnkgo.Ledger{{LedgerID: "ldg_123", Name: "Savings"}},
+	}
+
+	var ledgers []blnkgo.Ledger
+	err := resp.DecodeData(&ledgers)
+	assert.NoError(t, err)
+	assert.Equal(t, "ldg_123", ledgers[0].LedgerID)
+}
+
+func TestTransactionService_FilterAll_DropsRepeatedRecords(t *testing.T) {
+	// txn_2 shares a created_at with txn_1 and moves to the second page
+	pages := map[int]string{
+		0: `[{"transaction_id":"txn_1"},{"transaction_id":"txn_2"}]`,
+		2: `[{"transaction_id":"txn_2"},{"transaction_id":"txn_3"}]`,
+		4: `[]`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params blnkgo.FilterParams
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		fmt.Fprint(w, pages[params.Offset])
+	}))
+	t.Cleanup(server.Close)
+	baseURL, _ := url.Parse(server.URL)
+
+	transactions, err := blnkgo.NewClient(baseURL, nil).Transaction.FilterAll(blnkgo.FilterParams{Limit: 2})
+
+	require.NoError(t, err)
+	require.Len(t, transactions, 3)
+	assert.Equal(t, "txn_3", transactions[2].TransactionID)
+}
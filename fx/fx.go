@@ -0,0 +1,287 @@
+// Package fx builds cross-currency transfers between Blnk balances. Amounts are
+// converted exactly from a quoted rate and rounded once, with a configurable
+// rounding mode, and the quote is recorded in the transactions' meta_data.
+//
+// Two routes are supported. RouteRate posts a single transaction with Rate set
+// and lets Blnk credit the destination. RouteLiquidity posts two transactions
+// through per-currency FX liquidity balances: the source pays the liquidity
+// balance of its currency, and the liquidity balance of the destination
+// currency pays the destination, so both amounts are exactly the quoted ones.
+package fx
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// RoundingMode decides how a converted amount is rounded to minor units.
+type RoundingMode = blnkgo.RoundingMode
+
+const (
+	RoundHalfUp   = blnkgo.RoundHalfUp
+	RoundHalfEven = blnkgo.RoundHalfEven
+	RoundDown     = blnkgo.RoundDown
+	RoundUp       = blnkgo.RoundUp
+)
+
+// Route is the way a transfer moves money between currencies.
+type Route string
+
+const (
+	RouteRate      Route = "rate"
+	RouteLiquidity Route = "liquidity"
+)
+
+// DefaultPrecision is used for currencies missing from Converter.Precisions.
+const DefaultPrecision int64 = 100
+
+// TransferRequest describes a transfer from a balance in one currency to a
+// balance in another. Set exactly one of SourceAmount (what the source pays)
+// and DestinationAmount (what the destination receives), in minor units of
+// the respective currency.
+type TransferRequest struct {
+	Source            string
+	Destination       string
+	SourceAmount      *big.Int
+	DestinationAmount *big.Int
+	// SourceCurrency and DestinationCurrency are looked up from the balances
+	// when empty.
+	SourceCurrency      string
+	DestinationCurrency string
+	Reference           string
+	Description         string
+	MetaData            map[string]interface{}
+	// Route defaults to RouteLiquidity when the converter has liquidity
+	// balances for both currencies, and RouteRate otherwise.
+	Route Route
+}
+
+// Quote is a priced transfer. Rate converts the source currency to the
+// destination currency.
+type Quote struct {
+	Rate                 Rate
+	SourceCurrency       string
+	DestinationCurrency  string
+	SourceAmount         *big.Int
+	DestinationAmount    *big.Int
+	SourcePrecision      int64
+	DestinationPrecision int64
+}
+
+// Transfer is a quote and the transactions that carry it out.
+type Transfer struct {
+	Quote    Quote
+	Route    Route
+	Requests []blnkgo.CreateTransactionRequest
+}
+
+// Converter prices and builds cross-currency transfers.
+type Converter struct {
+	Rates    RateProvider
+	Rounding RoundingMode
+	// Precisions maps currencies to their Blnk precision, e.g. "JPY": 1.
+	Precisions map[string]int64
+	// Liquidity maps currencies to the balance IDs used by RouteLiquidity.
+	Liquidity map[string]string
+	// MaxRateAge rejects timestamped rates older than this when set.
+	MaxRateAge time.Duration
+
+	balances     *blnkgo.LedgerBalanceService
+	transactions *blnkgo.TransactionService
+}
+
+func NewConverter(client blnkgo.ClientInterface, rates RateProvider) *Converter {
+	return &Converter{
+		Rates:        rates,
+		Precisions:   make(map[string]int64),
+		Liquidity:    make(map[string]string),
+		balances:     blnkgo.NewLedgerBalanceService(client),
+		transactions: blnkgo.NewTransactionService(client),
+	}
+}
+
+func (c *Converter) precision(currency string) int64 {
+	if p, ok := c.Precisions[currency]; ok && p > 0 {
+		return p
+	}
+	return DefaultPrecision
+}
+
+func (c *Converter) currency(balanceID, currency string) (string, error) {
+	if currency != "" {
+		return strings.ToUpper(currency), nil
+	}
+	balance, _, err := c.balances.Get(balanceID)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the currency of %s: %w", balanceID, err)
+	}
+	return strings.ToUpper(balance.Currency), nil
+}
+
+// Quote prices a transfer without creating anything.
+func (c *Converter) Quote(req TransferRequest) (*Quote, error) {
+	if req.Source == "" || req.Destination == "" {
+		return nil, fmt.Errorf("source and destination are required")
+	}
+	if (req.SourceAmount == nil) == (req.DestinationAmount == nil) {
+		return nil, fmt.Errorf("exactly one of SourceAmount and DestinationAmount must be set")
+	}
+	for _, amount := range []*big.Int{req.SourceAmount, req.DestinationAmount} {
+		if amount != nil && amount.Sign() <= 0 {
+			return nil, fmt.Errorf("amount must be positive")
+		}
+	}
+
+	sourceCurrency, err := c.currency(req.Source, req.SourceCurrency)
+	if err != nil {
+		return nil, err
+	}
+	destinationCurrency, err := c.currency(req.Destination, req.DestinationCurrency)
+	if err != nil {
+		return nil, err
+	}
+	if sourceCurrency == destinationCurrency {
+		return nil, fmt.Errorf("source and destination are both in %s", sourceCurrency)
+	}
+
+	rate, err := c.Rates.Rate(sourceCurrency, destinationCurrency)
+	if err != nil {
+		return nil, err
+	}
+	if rate.Value == nil || rate.Value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid rate for %s/%s", sourceCurrency, destinationCurrency)
+	}
+	if c.MaxRateAge > 0 && !rate.Timestamp.IsZero() && time.Since(rate.Timestamp) > c.MaxRateAge {
+		return nil, fmt.Errorf("rate for %s/%s from %s is older than %s", sourceCurrency, destinationCurrency, rate.Timestamp.Format(time.RFC3339), c.MaxRateAge)
+	}
+
+	quote := &Quote{
+		Rate:                 rate,
+		SourceCurrency:       sourceCurrency,
+		DestinationCurrency:  destinationCurrency,
+		SourcePrecision:      c.precision(sourceCurrency),
+		DestinationPrecision: c.precision(destinationCurrency),
+	}
+	// destination minor units = source minor units * rate * destination precision / source precision
+	scale := new(big.Rat).Mul(rate.Value, big.NewRat(quote.DestinationPrecision, quote.SourcePrecision))
+	if req.SourceAmount != nil {
+		quote.SourceAmount = new(big.Int).Set(req.SourceAmount)
+		quote.DestinationAmount = blnkgo.RoundRat(new(big.Rat).Mul(new(big.Rat).SetInt(req.SourceAmount), scale), c.Rounding)
+	} else {
+		quote.DestinationAmount = new(big.Int).Set(req.DestinationAmount)
+		quote.SourceAmount = blnkgo.RoundRat(new(big.Rat).Quo(new(big.Rat).SetInt(req.DestinationAmount), scale), c.Rounding)
+	}
+	if quote.SourceAmount.Sign() <= 0 || quote.DestinationAmount.Sign() <= 0 {
+		return nil, fmt.Errorf("amount is too small to convert from %s to %s", sourceCurrency, destinationCurrency)
+	}
+	return quote, nil
+}
+
+// Build quotes a transfer and returns the transactions that carry it out,
+// without creating them.
+func (c *Converter) Build(req TransferRequest) (*Transfer, error) {
+	if req.Reference == "" {
+		return nil, fmt.Errorf("reference is required")
+	}
+	quote, err := c.Quote(req)
+	if err != nil {
+		return nil, err
+	}
+
+	route := req.Route
+	if route == "" {
+		route = RouteRate
+		if c.Liquidity[quote.SourceCurrency] != "" && c.Liquidity[quote.DestinationCurrency] != "" {
+			route = RouteLiquidity
+		}
+	}
+
+	transfer := &Transfer{Quote: *quote, Route: route}
+	switch route {
+	case RouteRate:
+		// Blnk credits the destination with the amount times Rate in the
+		// transaction's precision, so the effective rate folds in the
+		// difference between the two precisions.
+		effective, _ := new(big.Rat).SetFrac(quote.DestinationAmount, quote.SourceAmount).Float64()
+		request := c.leg(req, quote, route, "", quote.SourceCurrency, quote.SourcePrecision, quote.SourceAmount, req.Source, req.Destination)
+		request.Rate = effective
+		transfer.Requests = []blnkgo.CreateTransactionRequest{request}
+	case RouteLiquidity:
+		sourcePool, destinationPool := c.Liquidity[quote.SourceCurrency], c.Liquidity[quote.DestinationCurrency]
+		if sourcePool == "" || destinationPool == "" {
+			return nil, fmt.Errorf("no liquidity balance for %s or %s", quote.SourceCurrency, quote.DestinationCurrency)
+		}
+		transfer.Requests = []blnkgo.CreateTransactionRequest{
+			c.leg(req, quote, route, "sell", quote.SourceCurrency, quote.SourcePrecision, quote.SourceAmount, req.Source, sourcePool),
+			c.leg(req, quote, route, "buy", quote.DestinationCurrency, quote.DestinationPrecision, quote.DestinationAmount, destinationPool, req.Destination),
+		}
+	default:
+		return nil, fmt.Errorf("unknown route %q", route)
+	}
+	return transfer, nil
+}
+
+func (c *Converter) leg(req TransferRequest, quote *Quote, route Route, leg, currency string, precision int64, amount *big.Int, source, destination string) blnkgo.CreateTransactionRequest {
+	reference := req.Reference
+	if leg != "" {
+		reference = fmt.Sprintf("%s-%s", req.Reference, leg)
+	}
+
+	metaData := make(map[string]interface{}, len(req.MetaData)+10)
+	for k, v := range req.MetaData {
+		metaData[k] = v
+	}
+	metaData["fx_rate"] = quote.Rate.String()
+	metaData["fx_rate_source"] = quote.Rate.Source
+	metaData["fx_pair"] = quote.SourceCurrency + "/" + quote.DestinationCurrency
+	metaData["fx_route"] = string(route)
+	metaData["fx_source_amount"] = blnkgo.FormatPreciseAmount(quote.SourceAmount, quote.SourcePrecision)
+	metaData["fx_destination_amount"] = blnkgo.FormatPreciseAmount(quote.DestinationAmount, quote.DestinationPrecision)
+	if !quote.Rate.Timestamp.IsZero() {
+		metaData["fx_rate_timestamp"] = quote.Rate.Timestamp.UTC().Format(time.RFC3339)
+	}
+	if leg != "" {
+		metaData["fx_leg"] = leg
+		metaData["fx_reference"] = req.Reference
+	}
+
+	value, _ := new(big.Rat).SetFrac(amount, big.NewInt(precision)).Float64()
+	return blnkgo.CreateTransactionRequest{
+		ParentTransaction: blnkgo.ParentTransaction{
+			Amount:        value,
+			PreciseAmount: new(big.Int).Set(amount),
+			Precision:     precision,
+			Currency:      currency,
+			Reference:     reference,
+			Description:   req.Description,
+			Source:        source,
+			Destination:   destination,
+			MetaData:      metaData,
+		},
+	}
+}
+
+// Execute builds a transfer and creates its transactions in order. If a leg
+// fails, the transactions created so far are returned with the error; their
+// references are deterministic, so retrying with the same request cannot post
+// a leg twice.
+func (c *Converter) Execute(req TransferRequest) (*Transfer, []blnkgo.Transaction, error) {
+	transfer, err := c.Build(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var created []blnkgo.Transaction
+	for _, request := range transfer.Requests {
+		transaction, _, err := c.transactions.Create(request)
+		if err != nil {
+			return transfer, created, fmt.Errorf("failed to create %s: %w", request.Reference, err)
+		}
+		created = append(created, *transaction)
+	}
+	return transfer, created, nil
+}
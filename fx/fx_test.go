@@ -0,0 +1,227 @@
+package fx_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/fx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fxServer struct {
+	created []blnkgo.CreateTransactionRequest
+	failOn  string
+}
+
+func newFXServer(t *testing.T, fs *fxServer) blnkgo.ClientInterface {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/balances/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/balances/")
+		currency := map[string]string{"bln_eur": "EUR", "bln_usd": "USD", "bln_jpy": "JPY"}[id]
+		if currency == "" {
+			http.Error(w, `{"error":"balance not found"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"balance_id":%q,"currency":%q}`, id, currency)
+	})
+	mux.HandleFunc("/transactions", func(w http.ResponseWriter, r *http.Request) {
+		var body blnkgo.CreateTransactionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body.Reference == fs.failOn {
+			http.Error(w, `{"error":"reference already used"}`, http.StatusBadRequest)
+			return
+		}
+		fs.created = append(fs.created, body)
+		fmt.Fprintf(w, `{"transaction_id":"txn_%d","reference":%q}`, len(fs.created), body.Reference)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	baseURL, _ := url.Parse(server.URL)
+	return blnkgo.NewClient(baseURL, nil)
+}
+
+func TestStaticProvider(t *testing.T) {
+	provider := fx.NewStaticProvider("treasury")
+	require.NoError(t, provider.Set("eur", "usd", "1.25"))
+	assert.Error(t, provider.Set("EUR", "GBP", "-1"))
+
+	rate, err := provider.Rate("EUR", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "1.25", rate.String())
+	assert.Equal(t, "treasury", rate.Source)
+
+	inverse, err := provider.Rate("USD", "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, "0.8", inverse.String())
+
+	same, err := provider.Rate("USD", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "1", same.String())
+
+	_, err = provider.Rate("USD", "NGN")
+	var notFound *fx.RateNotFoundError
+	assert.True(t, errors.As(err, &notFound))
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "rates.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"source":"ecb","timestamp":"2024-03-01T16:00:00Z","rates":{"EUR/USD":"1.0842"}}`), 0o600))
+
+	provider, err := fx.NewFileProvider(jsonPath)
+	require.NoError(t, err)
+	rate, err := provider.Rate("EUR", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0842", rate.String())
+	assert.Equal(t, "ecb", rate.Source)
+	assert.Equal(t, time.Date(2024, time.March, 1, 16, 0, 0, 0, time.UTC), rate.Timestamp)
+
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"source":"ecb","rates":{"EUR/USD":"1.1"}}`), 0o600))
+	require.NoError(t, os.Chtimes(jsonPath, time.Now(), time.Now().Add(time.Minute)))
+	rate, err = provider.Rate("EUR", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1", rate.String())
+
+	csvPath := filepath.Join(dir, "rates.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("base,quote,rate\nUSD,JPY,150.5\n"), 0o600))
+	csvProvider, err := fx.NewFileProvider(csvPath)
+	require.NoError(t, err)
+	rate, err = csvProvider.Rate("USD", "JPY")
+	require.NoError(t, err)
+	assert.Equal(t, "150.5", rate.String())
+	assert.Equal(t, "rates.csv", rate.Source)
+
+	require.NoError(t, os.WriteFile(csvPath, []byte("USD,JPY\n"), 0o600))
+	_, err = fx.NewFileProvider(csvPath)
+	assert.Error(t, err)
+}
+
+func TestConverter_Quote(t *testing.T) {
+	rates := &fx.FakeProvider{Rates: map[string]string{"USD/JPY": "150.123", "EUR/USD": "1.0842"}}
+	converter := fx.NewConverter(newFXServer(t, &fxServer{}), rates)
+	converter.Precisions["JPY"] = 1
+
+	quote, err := converter.Quote(fx.TransferRequest{Source: "bln_usd", Destination: "bln_jpy", SourceAmount: big.NewInt(1005)})
+	require.NoError(t, err)
+	assert.Equal(t, "USD", quote.SourceCurrency)
+	assert.Equal(t, "JPY", quote.DestinationCurrency)
+	assert.Equal(t, big.NewInt(1509), quote.DestinationAmount) // 10.05 * 150.123 = 1508.74
+	assert.Equal(t, []string{"USD/JPY"}, rates.Calls)
+
+	converter.Rounding = fx.RoundDown
+	quote, err = converter.Quote(fx.TransferRequest{Source: "bln_usd", Destination: "bln_eur", DestinationAmount: big.NewInt(10000)})
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(10842), quote.SourceAmount) // 100 EUR costs 108.42 USD
+	assert.Equal(t, "0.922339051835", quote.Rate.String())
+}
+
+func TestConverter_Quote_Errors(t *testing.T) {
+	rates := &fx.FakeProvider{Rates: map[string]string{"EUR/USD": "1.1"}, Timestamp: time.Now().Add(-time.Hour)}
+	converter := fx.NewConverter(newFXServer(t, &fxServer{}), rates)
+
+	_, err := converter.Quote(fx.TransferRequest{Source: "bln_eur", Destination: "bln_usd"})
+	assert.Error(t, err)
+	_, err = converter.Quote(fx.TransferRequest{Source: "bln_eur", Destination: "bln_usd", SourceAmount: big.NewInt(1), DestinationAmount: big.NewInt(1)})
+	assert.Error(t, err)
+	_, err = converter.Quote(fx.TransferRequest{Source: "bln_eur", Destination: "bln_eur", SourceAmount: big.NewInt(100)})
+	assert.EqualError(t, err, "source and destination are both in EUR")
+	_, err = converter.Quote(fx.TransferRequest{Source: "bln_missing", Destination: "bln_usd", SourceAmount: big.NewInt(100)})
+	assert.Error(t, err)
+
+	converter.MaxRateAge = time.Minute
+	_, err = converter.Quote(fx.TransferRequest{Source: "bln_eur", Destination: "bln_usd", SourceAmount: big.NewInt(100)})
+	assert.ErrorContains(t, err, "is older than")
+
+	rates.Err = errors.New("provider down")
+	_, err = converter.Quote(fx.TransferRequest{Source: "bln_eur", Destination: "bln_usd", SourceAmount: big.NewInt(100)})
+	assert.EqualError(t, err, "provider down")
+}
+
+func TestConverter_Execute_RateRoute(t *testing.T) {
+	fs := &fxServer{}
+	rates := &fx.FakeProvider{Rates: map[string]string{"EUR/USD": "1.0842"}, Source: "ecb"}
+	converter := fx.NewConverter(newFXServer(t, fs), rates)
+
+	transfer, created, err := converter.Execute(fx.TransferRequest{
+		Source: "bln_eur", Destination: "bln_usd", SourceAmount: big.NewInt(10000),
+		Reference: "fx-1", MetaData: map[string]interface{}{"customer": "c1"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, fx.RouteRate, transfer.Route)
+	require.Len(t, created, 1)
+	require.Len(t, fs.created, 1)
+	request := fs.created[0]
+	assert.Equal(t, "EUR", request.Currency)
+	assert.Equal(t, big.NewInt(10000), request.PreciseAmount)
+	assert.Equal(t, 1.0842, request.Rate)
+	assert.Equal(t, "1.0842", request.MetaData["fx_rate"])
+	assert.Equal(t, "ecb", request.MetaData["fx_rate_source"])
+	assert.Equal(t, "108.42", request.MetaData["fx_destination_amount"])
+	assert.Equal(t, "c1", request.MetaData["customer"])
+}
+
+func TestConverter_Execute_LiquidityRoute(t *testing.T) {
+	fs := &fxServer{}
+	converter := fx.NewConverter(newFXServer(t, fs), &fx.FakeProvider{Rates: map[string]string{"USD/JPY": "150.5"}})
+	converter.Precisions["JPY"] = 1
+	converter.Liquidity["USD"] = "bln_fx_usd"
+	converter.Liquidity["JPY"] = "bln_fx_jpy"
+
+	transfer, created, err := converter.Execute(fx.TransferRequest{
+		Source: "bln_usd", Destination: "bln_jpy", SourceAmount: big.NewInt(2000), Reference: "fx-2",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, fx.RouteLiquidity, transfer.Route)
+	assert.Len(t, created, 2)
+	require.Len(t, fs.created, 2)
+
+	sell, buy := fs.created[0], fs.created[1]
+	assert.Equal(t, "fx-2-sell", sell.Reference)
+	assert.Equal(t, "bln_usd", sell.Source)
+	assert.Equal(t, "bln_fx_usd", sell.Destination)
+	assert.Equal(t, big.NewInt(2000), sell.PreciseAmount)
+	assert.Equal(t, int64(100), sell.Precision)
+	assert.Equal(t, "fx-2-buy", buy.Reference)
+	assert.Equal(t, "bln_fx_jpy", buy.Source)
+	assert.Equal(t, "bln_jpy", buy.Destination)
+	assert.Equal(t, "JPY", buy.Currency)
+	assert.Equal(t, big.NewInt(3010), buy.PreciseAmount)
+	assert.Equal(t, int64(1), buy.Precision)
+	assert.Zero(t, buy.Rate)
+	assert.Equal(t, "fx-2", buy.MetaData["fx_reference"])
+	assert.Equal(t, "buy", buy.MetaData["fx_leg"])
+}
+
+func TestConverter_Execute_PartialFailure(t *testing.T) {
+	fs := &fxServer{failOn: "fx-3-buy"}
+	converter := fx.NewConverter(newFXServer(t, fs), &fx.FakeProvider{Rates: map[string]string{"EUR/USD": "1.1"}})
+	converter.Liquidity["EUR"] = "bln_fx_eur"
+	converter.Liquidity["USD"] = "bln_fx_usd"
+
+	transfer, created, err := converter.Execute(fx.TransferRequest{
+		Source: "bln_eur", Destination: "bln_usd", SourceAmount: big.NewInt(100), Reference: "fx-3",
+	})
+
+	assert.ErrorContains(t, err, "failed to create fx-3-buy")
+	require.NotNil(t, transfer)
+	require.Len(t, created, 1)
+	assert.Equal(t, "fx-3-sell", created[0].Reference)
+
+	_, err = converter.Build(fx.TransferRequest{Source: "bln_eur", Destination: "bln_usd", SourceAmount: big.NewInt(100)})
+	assert.EqualError(t, err, "reference is required")
+}
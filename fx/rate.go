@@ -0,0 +1,272 @@
+package fx
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate is the price of one unit of Base in Quote, e.g. EUR/USD 1.0842 means one
+// euro buys 1.0842 dollars. Value is exact.
+type Rate struct {
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Value     *big.Rat  `json:"-"`
+	Source    string    `json:"source"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// String returns the rate as a decimal with up to 12 decimal places.
+func (r Rate) String() string {
+	if r.Value == nil {
+		return ""
+	}
+	return strings.TrimRight(strings.TrimRight(r.Value.FloatString(12), "0"), ".")
+}
+
+// Inverse returns the Quote/Base rate.
+func (r Rate) Inverse() Rate {
+	inverse := r
+	inverse.Base, inverse.Quote = r.Quote, r.Base
+	inverse.Value = new(big.Rat).Inv(r.Value)
+	return inverse
+}
+
+// RateProvider quotes exchange rates.
+type RateProvider interface {
+	// Rate returns the price of one unit of base in quote.
+	Rate(base, quote string) (Rate, error)
+}
+
+// RateNotFoundError is returned by providers that have no rate for a pair.
+type RateNotFoundError struct {
+	Base, Quote string
+}
+
+func (e *RateNotFoundError) Error() string {
+	return fmt.Sprintf("no rate for %s/%s", e.Base, e.Quote)
+}
+
+// ParseRate parses a decimal rate such as "1.0842" exactly.
+func ParseRate(value string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok || rate.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid rate %q", value)
+	}
+	return rate, nil
+}
+
+func pairKey(base, quote string) string {
+	return strings.ToUpper(base) + "/" + strings.ToUpper(quote)
+}
+
+// StaticProvider quotes rates from an in-memory table. Inverse pairs are
+// derived, so setting EUR/USD also quotes USD/EUR.
+type StaticProvider struct {
+	mu        sync.RWMutex
+	source    string
+	timestamp time.Time
+	rates     map[string]*big.Rat
+}
+
+// NewStaticProvider returns an empty table; source is recorded on every rate it
+// quotes.
+func NewStaticProvider(source string) *StaticProvider {
+	return &StaticProvider{source: source, rates: make(map[string]*big.Rat)}
+}
+
+// Set sets the rate of a pair from a decimal string such as "1.0842".
+func (p *StaticProvider) Set(base, quote, rate string) error {
+	value, err := ParseRate(rate)
+	if err != nil {
+		return fmt.Errorf("%s: %w", pairKey(base, quote), err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rates[pairKey(base, quote)] = value
+	return nil
+}
+
+// SetTimestamp sets the time rates are quoted as of.
+func (p *StaticProvider) SetTimestamp(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.timestamp = t
+}
+
+func (p *StaticProvider) Rate(base, quote string) (Rate, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	rate := Rate{Base: strings.ToUpper(base), Quote: strings.ToUpper(quote), Source: p.source, Timestamp: p.timestamp}
+	switch {
+	case rate.Base == rate.Quote:
+		rate.Value = big.NewRat(1, 1)
+	case p.rates[pairKey(base, quote)] != nil:
+		rate.Value = new(big.Rat).Set(p.rates[pairKey(base, quote)])
+	case p.rates[pairKey(quote, base)] != nil:
+		rate.Value = new(big.Rat).Inv(p.rates[pairKey(quote, base)])
+	default:
+		return Rate{}, &RateNotFoundError{Base: rate.Base, Quote: rate.Quote}
+	}
+	return rate, nil
+}
+
+// rateFile is the JSON layout read by FileProvider.
+type rateFile struct {
+	Source    string            `json:"source"`
+	Timestamp time.Time         `json:"timestamp"`
+	Rates     map[string]string `json:"rates"`
+}
+
+// FileProvider quotes rates from a file, reloading it when it changes. JSON
+// files look like
+//
+//	{"source": "ecb", "timestamp": "2024-03-01T16:00:00Z", "rates": {"EUR/USD": "1.0842"}}
+//
+// and CSV files have the columns base, quote and rate, with an optional header.
+// Rates from CSV files are timestamped with the file's modification time and
+// sourced from the file name.
+type FileProvider struct {
+	path string
+
+	mu       sync.Mutex
+	modTime  time.Time
+	provider *StaticProvider
+}
+
+// NewFileProvider loads the rates in path.
+func NewFileProvider(path string) (*FileProvider, error) {
+	p := &FileProvider{path: path}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *FileProvider) Rate(base, quote string) (Rate, error) {
+	if err := p.reload(); err != nil {
+		return Rate{}, err
+	}
+	p.mu.Lock()
+	provider := p.provider
+	p.mu.Unlock()
+	return provider.Rate(base, quote)
+}
+
+func (p *FileProvider) reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	if p.provider != nil && info.ModTime().Equal(p.modTime) {
+		return nil
+	}
+
+	f, err := os.Open(p.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var provider *StaticProvider
+	if strings.EqualFold(filepath.Ext(p.path), ".csv") {
+		provider, err = readRateCSV(f, filepath.Base(p.path), info.ModTime())
+	} else {
+		provider, err = readRateJSON(f)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load rates from %s: %w", p.path, err)
+	}
+	p.provider, p.modTime = provider, info.ModTime()
+	return nil
+}
+
+func readRateJSON(r io.Reader) (*StaticProvider, error) {
+	var file rateFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+	provider := NewStaticProvider(file.Source)
+	provider.SetTimestamp(file.Timestamp)
+	for pair, rate := range file.Rates {
+		currencies := strings.Split(pair, "/")
+		if len(currencies) != 2 {
+			return nil, fmt.Errorf("invalid pair %q, expected BASE/QUOTE", pair)
+		}
+		if err := provider.Set(currencies[0], currencies[1], rate); err != nil {
+			return nil, err
+		}
+	}
+	return provider, nil
+}
+
+func readRateCSV(r io.Reader, source string, timestamp time.Time) (*StaticProvider, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	provider := NewStaticProvider(source)
+	provider.SetTimestamp(timestamp)
+	for i, row := range rows {
+		if len(row) != 3 {
+			return nil, fmt.Errorf("line %d: expected base,quote,rate", i+1)
+		}
+		if i == 0 && strings.EqualFold(row[2], "rate") {
+			continue
+		}
+		if err := provider.Set(row[0], row[1], row[2]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	return provider, nil
+}
+
+// FakeProvider is a RateProvider for tests. It quotes from Rates (keyed
+// "BASE/QUOTE", inverses derived), fails with Err when set and records every
+// pair it is asked for in Calls.
+type FakeProvider struct {
+	Rates     map[string]string
+	Source    string
+	Timestamp time.Time
+	Err       error
+
+	mu    sync.Mutex
+	Calls []string
+}
+
+func (p *FakeProvider) Rate(base, quote string) (Rate, error) {
+	p.mu.Lock()
+	p.Calls = append(p.Calls, pairKey(base, quote))
+	p.mu.Unlock()
+	if p.Err != nil {
+		return Rate{}, p.Err
+	}
+
+	source := p.Source
+	if source == "" {
+		source = "fake"
+	}
+	provider := NewStaticProvider(source)
+	provider.SetTimestamp(p.Timestamp)
+	for pair, rate := range p.Rates {
+		currencies := strings.Split(pair, "/")
+		if len(currencies) != 2 {
+			return Rate{}, fmt.Errorf("invalid pair %q, expected BASE/QUOTE", pair)
+		}
+		if err := provider.Set(currencies[0], currencies[1], rate); err != nil {
+			return Rate{}, err
+		}
+	}
+	return provider.Rate(base, quote)
+}
@@ -1,8 +1,10 @@
 package blnkgo
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 )
 
@@ -26,6 +28,47 @@ type Identity struct {
 	PostCode         string                 `json:"post_code"`
 	City             string                 `json:"city"`
 	MetaData         map[string]interface{} `json:"meta_data,omitempty"`
+
+	// DOBToken holds the token the server returns in place of dob once it is
+	// tokenized, in which case DOB is nil. It is never sent.
+	DOBToken string `json:"-"`
+}
+
+// UnmarshalJSON decodes an identity whose dob may be a token rather than a
+// date. A dob that is not an RFC 3339 time is only accepted as a token when
+// meta_data.tokenized_fields marks dob as tokenized.
+func (i *Identity) UnmarshalJSON(data []byte) error {
+	type plain Identity
+	aux := struct {
+		*plain
+		DOB json.RawMessage `json:"dob"`
+	}{plain: (*plain)(i)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	i.DOB, i.DOBToken = nil, ""
+	if len(aux.DOB) == 0 || string(aux.DOB) == "null" {
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(aux.DOB, &value); err != nil {
+		return fmt.Errorf("dob: %w", err)
+	}
+	if value == "" {
+		return nil
+	}
+	dob, err := time.Parse(time.RFC3339Nano, value)
+	if err == nil {
+		i.DOB = &dob
+		return nil
+	}
+	// only a dob the identity marks as tokenized is taken as a token, so a
+	// malformed date is not silently dropped
+	if !slices.Contains(tokenizedFields(i.MetaData), "dob") {
+		return fmt.Errorf("dob: %w", err)
+	}
+	i.DOBToken = value
+	return nil
 }
 
 type IdentityResponse struct {
@@ -34,6 +77,23 @@ type IdentityResponse struct {
 	Identity
 }
 
+// UnmarshalJSON decodes the response fields next to the embedded Identity,
+// whose own UnmarshalJSON would otherwise take over the whole object.
+func (r *IdentityResponse) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.Identity); err != nil {
+		return err
+	}
+	var ids struct {
+		IdentityId string `json:"identity_id"`
+		CreatedAt  string `json:"created_at"`
+	}
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return err
+	}
+	r.IdentityId, r.CreatedAt = ids.IdentityId, ids.CreatedAt
+	return nil
+}
+
 func (s *IdentityService) Create(identity Identity) (*IdentityResponse, *http.Response, error) {
 	//validate the identity
 	if err := ValidateCreateIdentity(identity); err != nil {
@@ -92,6 +152,73 @@ func (s *IdentityService) Update(identityId string, identity *Identity) (*Identi
 	return identityResponse, resp, nil
 }
 
+// IdentityPatch holds the identity fields to change in a partial update. Only
+// non-nil fields are sent, so fields left unset keep their current value.
+type IdentityPatch struct {
+	IdentityType     *IdentityType          `json:"identity_type,omitempty"`
+	FirstName        *string                `json:"first_name,omitempty"`
+	LastName         *string                `json:"last_name,omitempty"`
+	OtherNames       *string                `json:"other_names,omitempty"`
+	Gender           *string                `json:"gender,omitempty"`
+	DOB              *time.Time             `json:"dob,omitempty"`
+	EmailAddress     *string                `json:"email_address,omitempty"`
+	PhoneNumber      *string                `json:"phone_number,omitempty"`
+	Nationality      *string                `json:"nationality,omitempty"`
+	OrganizationName *string                `json:"organization_name,omitempty"`
+	Category         *string                `json:"category,omitempty"`
+	Street           *string                `json:"street,omitempty"`
+	Country          *string                `json:"country,omitempty"`
+	State            *string                `json:"state,omitempty"`
+	PostCode         *string                `json:"post_code,omitempty"`
+	City             *string                `json:"city,omitempty"`
+	MetaData         map[string]interface{} `json:"meta_data,omitempty"`
+}
+
+// Ptr returns a pointer to v, which is handy when filling an IdentityPatch.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Patch updates only the fields set in patch, unlike Update which replaces the
+// whole identity.
+func (s *IdentityService) Patch(identityId string, patch IdentityPatch) (*IdentityResponse, *http.Response, error) {
+	if identityId == "" {
+		return nil, nil, fmt.Errorf("identityId is required")
+	}
+	u := fmt.Sprintf("identities/%s", identityId)
+	req, err := s.client.NewRequest(u, http.MethodPatch, patch)
+	if err != nil {
+		return nil, nil, err
+	}
+	identityResponse := new(IdentityResponse)
+	resp, err := s.client.CallWithRetry(req, identityResponse)
+	if err != nil {
+		return nil, resp, err
+	}
+	return identityResponse, resp, nil
+}
+
+type DeleteIdentityResponse struct {
+	Message string `json:"message"`
+}
+
+func (s *IdentityService) Delete(identityId string) (*DeleteIdentityResponse, *http.Response, error) {
+	if identityId == "" {
+		return nil, nil, fmt.Errorf("identityId is required")
+	}
+	u := fmt.Sprintf("identities/%s", identityId)
+	req, err := s.client.NewRequest(u, http.MethodDelete, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	deleteResponse := new(DeleteIdentityResponse)
+	resp, err := s.client.CallWithRetry(req, deleteResponse)
+	if err != nil {
+		return nil, resp, err
+	}
+	return deleteResponse, resp, nil
+}
+
 func NewIdentityService(client ClientInterface) *IdentityService {
 	return &IdentityService{client: client}
 }
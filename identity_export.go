@@ -0,0 +1,84 @@
+package blnkgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// SubjectDataExport gathers everything Blnk holds about an identity, for
+// answering data-subject access requests.
+type SubjectDataExport struct {
+	ExportedAt   time.Time         `json:"exported_at"`
+	Identity     *IdentityResponse `json:"identity"`
+	Balances     []LedgerBalance   `json:"balances"`
+	Transactions []Transaction     `json:"transactions"`
+}
+
+// ExportSubjectData fetches the identity, every balance linked to it and every
+// transaction in which one of those balances is the source or destination.
+func (s *IdentityService) ExportSubjectData(identityID string) (*SubjectDataExport, error) {
+	if identityID == "" {
+		return nil, fmt.Errorf("identityID is required")
+	}
+
+	identity, _, err := s.Get(identityID)
+	if err != nil {
+		return nil, fmt.Errorf("fetching identity: %w", err)
+	}
+
+	balances, err := NewLedgerBalanceService(s.client).FilterAll(FilterParams{
+		Filters: []Filter{{Field: "identity_id", Operator: OpEqual, Value: identityID}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching balances: %w", err)
+	}
+
+	export := &SubjectDataExport{
+		ExportedAt:   time.Now().UTC(),
+		Identity:     identity,
+		Balances:     balances,
+		Transactions: []Transaction{},
+	}
+	if len(balances) == 0 {
+		return export, nil
+	}
+
+	balanceIDs := make([]interface{}, len(balances))
+	for i, balance := range balances {
+		balanceIDs[i] = balance.BalanceID
+	}
+
+	transactions := NewTransactionService(s.client)
+	seen := make(map[string]bool)
+	for _, field := range []string{"source", "destination"} {
+		found, err := transactions.FilterAll(FilterParams{
+			Filters: []Filter{{Field: field, Operator: OpIn, Values: balanceIDs}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fetching transactions: %w", err)
+		}
+		for _, transaction := range found {
+			if seen[transaction.TransactionID] {
+				continue
+			}
+			seen[transaction.TransactionID] = true
+			export.Transactions = append(export.Transactions, transaction)
+		}
+	}
+
+	sort.SliceStable(export.Transactions, func(i, j int) bool {
+		return export.Transactions[i].CreatedAt.Before(export.Transactions[j].CreatedAt)
+	})
+
+	return export, nil
+}
+
+// Encode writes the export as an indented JSON document.
+func (e *SubjectDataExport) Encode(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(e)
+}
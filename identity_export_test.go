@@ -0,0 +1,113 @@
+package blnkgo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func filterRequest(path string) *http.Request {
+	return &http.Request{Method: http.MethodPost, URL: &url.URL{Path: path}}
+}
+
+func TestIdentityService_ExportSubjectData(t *testing.T) {
+	mockClient, svc := setupIdentityService()
+	identityID := "idt_123"
+
+	identityReq := &http.Request{Method: http.MethodGet}
+	mockClient.On("NewRequest", "identities/idt_123", http.MethodGet, nil).Return(identityReq, nil)
+	mockClient.On("CallWithRetry", identityReq, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		resp := args.Get(1).(*blnkgo.IdentityResponse)
+		resp.IdentityId = identityID
+		resp.FirstName = "Jane"
+	})
+
+	balancesReq := filterRequest("balances/filter")
+	mockClient.On("NewRequest", "balances/filter", http.MethodPost, blnkgo.FilterParams{
+		Filters:   []blnkgo.Filter{{Field: "identity_id", Operator: blnkgo.OpEqual, Value: identityID}},
+		Limit:     100,
+		SortBy:    "created_at",
+		SortOrder: "asc",
+	}).Return(balancesReq, nil)
+	mockClient.On("CallWithRetry", balancesReq, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		resp := args.Get(1).(*blnkgo.FilterResponse)
+		resp.Data = []blnkgo.LedgerBalance{{BalanceID: "bln_1", Balance: big.NewInt(500), IdentityID: identityID}}
+	})
+
+	day := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	sourceReq := filterRequest("transactions/filter?source")
+	mockClient.On("NewRequest", "transactions/filter", http.MethodPost, blnkgo.FilterParams{
+		Filters:   []blnkgo.Filter{{Field: "source", Operator: blnkgo.OpIn, Values: []interface{}{"bln_1"}}},
+		Limit:     100,
+		SortBy:    "created_at",
+		SortOrder: "asc",
+	}).Return(sourceReq, nil)
+	mockClient.On("CallWithRetry", sourceReq, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		resp := args.Get(1).(*blnkgo.FilterResponse)
+		resp.Data = []blnkgo.Transaction{{TransactionID: "txn_2", CreatedAt: day.Add(time.Hour)}}
+	})
+
+	destinationReq := filterRequest("transactions/filter?destination")
+	mockClient.On("NewRequest", "transactions/filter", http.MethodPost, blnkgo.FilterParams{
+		Filters:   []blnkgo.Filter{{Field: "destination", Operator: blnkgo.OpIn, Values: []interface{}{"bln_1"}}},
+		Limit:     100,
+		SortBy:    "created_at",
+		SortOrder: "asc",
+	}).Return(destinationReq, nil)
+	mockClient.On("CallWithRetry", destinationReq, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		resp := args.Get(1).(*blnkgo.FilterResponse)
+		resp.Data = []blnkgo.Transaction{
+			{TransactionID: "txn_1", CreatedAt: day},
+			{TransactionID: "txn_2", CreatedAt: day.Add(time.Hour)},
+		}
+	})
+
+	export, err := svc.ExportSubjectData(identityID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, identityID, export.Identity.IdentityId)
+	assert.Len(t, export.Balances, 1)
+	assert.Len(t, export.Transactions, 2)
+	assert.Equal(t, "txn_1", export.Transactions[0].TransactionID)
+	assert.Equal(t, "txn_2", export.Transactions[1].TransactionID)
+
+	var buf bytes.Buffer
+	assert.NoError(t, export.Encode(&buf))
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Contains(t, decoded, "identity")
+	assert.Contains(t, decoded, "balances")
+	assert.Contains(t, decoded, "transactions")
+	mockClient.AssertExpectations(t)
+}
+
+func TestIdentityService_ExportSubjectData_IdentityNotFound(t *testing.T) {
+	mockClient, svc := setupIdentityService()
+
+	mockClient.On("NewRequest", "identities/idt_404", http.MethodGet, nil).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusNotFound}, errors.New("not found"))
+
+	export, err := svc.ExportSubjectData("idt_404")
+
+	assert.Error(t, err)
+	assert.Nil(t, export)
+	assert.Contains(t, err.Error(), "not found")
+}
+
+func TestIdentityService_ExportSubjectData_EmptyID(t *testing.T) {
+	_, svc := setupIdentityService()
+
+	export, err := svc.ExportSubjectData("")
+
+	assert.Error(t, err)
+	assert.Nil(t, export)
+}
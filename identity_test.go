@@ -1,6 +1,7 @@
 package blnkgo_test
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -313,3 +314,69 @@ func TestIdentityService_Create_EmptyPayload(t *testing.T) {
 	assert.Nil(t, resp)
 	assert.Nil(t, httpResp)
 }
+
+func TestIdentityService_Patch_SendsOnlySetFields(t *testing.T) {
+	mockClient, svc := setupIdentityService()
+	identityId := "12345"
+
+	patch := blnkgo.IdentityPatch{PhoneNumber: blnkgo.Ptr("+2348012345678")}
+	body, err := json.Marshal(patch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"phone_number":"+2348012345678"}`, string(body))
+
+	expectedResponse := &blnkgo.IdentityResponse{
+		IdentityId: identityId,
+		Identity: blnkgo.Identity{
+			EmailAddress: "jane.doe@example.com",
+			PhoneNumber:  "+2348012345678",
+		},
+	}
+
+	mockClient.On("NewRequest", fmt.Sprintf("identities/%s", identityId), http.MethodPatch, patch).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		resp := args.Get(1).(*blnkgo.IdentityResponse)
+		*resp = *expectedResponse
+	}).Return(&http.Response{StatusCode: http.StatusOK}, nil)
+
+	resp, httpResp, err := svc.Patch(identityId, patch)
+	assert.NoError(t, err)
+	assert.NotNil(t, httpResp)
+	assert.Equal(t, expectedResponse, resp)
+	mockClient.AssertExpectations(t)
+}
+
+func TestIdentityService_Patch_EmptyID(t *testing.T) {
+	_, svc := setupIdentityService()
+
+	resp, httpResp, err := svc.Patch("", blnkgo.IdentityPatch{})
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+	assert.Nil(t, httpResp)
+}
+
+func TestIdentityService_Delete(t *testing.T) {
+	mockClient, svc := setupIdentityService()
+	identityId := "12345"
+
+	mockClient.On("NewRequest", fmt.Sprintf("identities/%s", identityId), http.MethodDelete, nil).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusNoContent}, nil)
+
+	resp, httpResp, err := svc.Delete(identityId)
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.Equal(t, http.StatusNoContent, httpResp.StatusCode)
+	mockClient.AssertExpectations(t)
+}
+
+func TestIdentityService_Delete_NotFound(t *testing.T) {
+	mockClient, svc := setupIdentityService()
+	identityId := "12345"
+
+	mockClient.On("NewRequest", fmt.Sprintf("identities/%s", identityId), http.MethodDelete, nil).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusNotFound}, errors.New("not found"))
+
+	resp, httpResp, err := svc.Delete(identityId)
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+	assert.Equal(t, http.StatusNotFound, httpResp.StatusCode)
+}
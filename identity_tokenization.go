@@ -0,0 +1,246 @@
+package blnkgo
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// TokenizeRequest lists the identity fields to tokenize or detokenize, using the
+// field names the Blnk server expects (e.g. "EmailAddress").
+type TokenizeRequest struct {
+	Fields []string `json:"fields"`
+}
+
+type TokenizeResponse struct {
+	Message string `json:"message"`
+}
+
+type DetokenizeResponse struct {
+	Fields map[string]string `json:"fields"`
+}
+
+type TokenizedFieldsResponse struct {
+	TokenizedFields []string `json:"tokenized_fields"`
+}
+
+// tokenizableFields maps the json tag of every string field on Identity that
+// can hold a token, plus dob, to the struct field name used by the server.
+// Fields that classify the identity rather than describe a person are left
+// out.
+var tokenizableFields = func() map[string]string {
+	excluded := map[string]bool{"identity_type": true, "category": true}
+	fields := make(map[string]string)
+	t := reflect.TypeOf(Identity{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type.Kind() != reflect.String && field.Name != "DOB" {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" || excluded[tag] {
+			continue
+		}
+		fields[tag] = field.Name
+	}
+	return fields
+}()
+
+// TokenizableIdentityFields returns the json names of the identity fields that
+// can be tokenized, sorted alphabetically.
+func TokenizableIdentityFields() []string {
+	fields := make([]string, 0, len(tokenizableFields))
+	for tag := range tokenizableFields {
+		fields = append(fields, tag)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// resolveTokenFields checks fields against the Identity struct and returns the
+// server field names. Both json names ("email_address") and struct field names
+// ("EmailAddress") are accepted.
+func resolveTokenFields(fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("at least one field is required")
+	}
+	resolved := make([]string, 0, len(fields))
+	for _, field := range fields {
+		name, ok := tokenizableFields[field]
+		if !ok {
+			for _, goName := range tokenizableFields {
+				if goName == field {
+					name, ok = goName, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid: %q is not a tokenizable identity field", field)
+		}
+		resolved = append(resolved, name)
+	}
+	return resolved, nil
+}
+
+// jsonFieldName converts a server field name back to its json name.
+func jsonFieldName(goName string) string {
+	for tag, name := range tokenizableFields {
+		if name == goName {
+			return tag
+		}
+	}
+	return goName
+}
+
+func (s *IdentityService) Tokenize(identityID string, fields ...string) (*TokenizeResponse, *http.Response, error) {
+	if identityID == "" {
+		return nil, nil, fmt.Errorf("identityID is required")
+	}
+	resolved, err := resolveTokenFields(fields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u := fmt.Sprintf("identities/%s/tokenize", identityID)
+	req, err := s.client.NewRequest(u, http.MethodPost, TokenizeRequest{Fields: resolved})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokenizeResponse := new(TokenizeResponse)
+	resp, err := s.client.CallWithRetry(req, tokenizeResponse)
+	if err != nil {
+		return nil, resp, err
+	}
+	return tokenizeResponse, resp, nil
+}
+
+// TokenizeAll tokenizes every field returned by TokenizableIdentityFields.
+func (s *IdentityService) TokenizeAll(identityID string) (*TokenizeResponse, *http.Response, error) {
+	return s.Tokenize(identityID, TokenizableIdentityFields()...)
+}
+
+// Detokenize returns the original values of the given fields, keyed by their
+// json names.
+func (s *IdentityService) Detokenize(identityID string, fields ...string) (map[string]string, *http.Response, error) {
+	if identityID == "" {
+		return nil, nil, fmt.Errorf("identityID is required")
+	}
+	resolved, err := resolveTokenFields(fields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u := fmt.Sprintf("identities/%s/detokenize", identityID)
+	req, err := s.client.NewRequest(u, http.MethodPost, TokenizeRequest{Fields: resolved})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	detokenizeResponse := new(DetokenizeResponse)
+	resp, err := s.client.CallWithRetry(req, detokenizeResponse)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	values := make(map[string]string, len(detokenizeResponse.Fields))
+	for name, value := range detokenizeResponse.Fields {
+		values[jsonFieldName(name)] = value
+	}
+	return values, resp, nil
+}
+
+// GetTokenizedFields returns the json names of the fields currently stored as
+// tokens for the identity.
+func (s *IdentityService) GetTokenizedFields(identityID string) ([]string, *http.Response, error) {
+	if identityID == "" {
+		return nil, nil, fmt.Errorf("identityID is required")
+	}
+
+	u := fmt.Sprintf("identities/%s/tokenized-fields", identityID)
+	req, err := s.client.NewRequest(u, http.MethodGet, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fieldsResponse := new(TokenizedFieldsResponse)
+	resp, err := s.client.CallWithRetry(req, fieldsResponse)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	fields := make([]string, 0, len(fieldsResponse.TokenizedFields))
+	for _, name := range fieldsResponse.TokenizedFields {
+		fields = append(fields, jsonFieldName(name))
+	}
+	return fields, resp, nil
+}
+
+// TokenizedFields returns the json names of the fields the server marked as
+// tokenized in the identity's meta_data.
+func (r *IdentityResponse) TokenizedFields() []string {
+	if r == nil {
+		return nil
+	}
+	return tokenizedFields(r.MetaData)
+}
+
+// tokenizedFields reads the tokenized_fields marker from an identity's
+// meta_data.
+func tokenizedFields(metaData map[string]interface{}) []string {
+	if metaData == nil {
+		return nil
+	}
+
+	var fields []string
+	switch v := metaData["tokenized_fields"].(type) {
+	case map[string]interface{}:
+		for name, flag := range v {
+			if tokenized, ok := flag.(bool); ok && tokenized {
+				fields = append(fields, jsonFieldName(name))
+			}
+		}
+	case map[string]bool:
+		for name, tokenized := range v {
+			if tokenized {
+				fields = append(fields, jsonFieldName(name))
+			}
+		}
+	case []interface{}:
+		for _, name := range v {
+			if s, ok := name.(string); ok {
+				fields = append(fields, jsonFieldName(s))
+			}
+		}
+	case []string:
+		for _, name := range v {
+			fields = append(fields, jsonFieldName(name))
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// IsTokenized reports whether the given field (json or struct name) holds a
+// token rather than the real value.
+func (r *IdentityResponse) IsTokenized(field string) bool {
+	resolved, err := resolveTokenFields([]string{field})
+	if err != nil {
+		return false
+	}
+	for _, name := range r.TokenizedFields() {
+		if name == jsonFieldName(resolved[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTokenizedValues reports whether any field of the identity holds a token, so
+// callers know not to display the values as-is.
+func (r *IdentityResponse) HasTokenizedValues() bool {
+	return len(r.TokenizedFields()) > 0
+}
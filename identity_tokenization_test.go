@@ -0,0 +1,172 @@
+package blnkgo_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIdentityService_Tokenize_Success(t *testing.T) {
+	mockClient, svc := setupIdentityService()
+
+	body := blnkgo.TokenizeRequest{Fields: []string{"EmailAddress", "PhoneNumber"}}
+	mockClient.On("NewRequest", "identities/idt_123/tokenize", http.MethodPost, body).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		resp := args.Get(1).(*blnkgo.TokenizeResponse)
+		resp.Message = "fields tokenized successfully"
+	})
+
+	resp, httpResp, err := svc.Tokenize("idt_123", "email_address", "PhoneNumber")
+
+	assert.NoError(t, err)
+	assert.NotNil(t, httpResp)
+	assert.Equal(t, "fields tokenized successfully", resp.Message)
+	mockClient.AssertExpectations(t)
+}
+
+func TestIdentityService_Tokenize_InvalidField(t *testing.T) {
+	mockClient, svc := setupIdentityService()
+
+	tests := []struct {
+		name   string
+		id     string
+		fields []string
+	}{
+		{name: "empty identity id", id: "", fields: []string{"email_address"}},
+		{name: "no fields", id: "idt_123"},
+		{name: "unknown field", id: "idt_123", fields: []string{"password"}},
+		{name: "non tokenizable field", id: "idt_123", fields: []string{"identity_type"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, httpResp, err := svc.Tokenize(tt.id, tt.fields...)
+			assert.Error(t, err)
+			assert.Nil(t, resp)
+			assert.Nil(t, httpResp)
+		})
+	}
+	mockClient.AssertNotCalled(t, "NewRequest", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestIdentityService_TokenizeAll(t *testing.T) {
+	mockClient, svc := setupIdentityService()
+
+	mockClient.On("NewRequest", "identities/idt_123/tokenize", http.MethodPost, mock.MatchedBy(func(body blnkgo.TokenizeRequest) bool {
+		return len(body.Fields) == len(blnkgo.TokenizableIdentityFields())
+	})).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil)
+
+	_, _, err := svc.TokenizeAll("idt_123")
+
+	assert.NoError(t, err)
+	assert.Contains(t, blnkgo.TokenizableIdentityFields(), "email_address")
+	assert.NotContains(t, blnkgo.TokenizableIdentityFields(), "category")
+	mockClient.AssertExpectations(t)
+}
+
+func TestIdentityService_Detokenize_Success(t *testing.T) {
+	mockClient, svc := setupIdentityService()
+
+	body := blnkgo.TokenizeRequest{Fields: []string{"EmailAddress"}}
+	mockClient.On("NewRequest", "identities/idt_123/detokenize", http.MethodPost, body).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		resp := args.Get(1).(*blnkgo.DetokenizeResponse)
+		resp.Fields = map[string]string{"EmailAddress": "john.doe@example.com"}
+	})
+
+	values, _, err := svc.Detokenize("idt_123", "email_address")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"email_address": "john.doe@example.com"}, values)
+	mockClient.AssertExpectations(t)
+}
+
+func TestIdentityService_GetTokenizedFields(t *testing.T) {
+	mockClient, svc := setupIdentityService()
+
+	mockClient.On("NewRequest", "identities/idt_123/tokenized-fields", http.MethodGet, nil).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		resp := args.Get(1).(*blnkgo.TokenizedFieldsResponse)
+		resp.TokenizedFields = []string{"FirstName", "PhoneNumber"}
+	})
+
+	fields, _, err := svc.GetTokenizedFields("idt_123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first_name", "phone_number"}, fields)
+	mockClient.AssertExpectations(t)
+}
+
+func TestIdentityService_GetTokenizedFields_ServerError(t *testing.T) {
+	mockClient, svc := setupIdentityService()
+
+	mockClient.On("NewRequest", "identities/idt_123/tokenized-fields", http.MethodGet, nil).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusInternalServerError}, errors.New("server error"))
+
+	fields, httpResp, err := svc.GetTokenizedFields("idt_123")
+
+	assert.Error(t, err)
+	assert.Nil(t, fields)
+	assert.Equal(t, http.StatusInternalServerError, httpResp.StatusCode)
+}
+
+func TestIdentityResponse_TokenizedFields(t *testing.T) {
+	identity := &blnkgo.IdentityResponse{
+		Identity: blnkgo.Identity{
+			EmailAddress: "tkn_8f2c",
+			MetaData: map[string]interface{}{
+				"tokenized_fields": map[string]interface{}{
+					"EmailAddress": true,
+					"LastName":     false,
+				},
+			},
+		},
+	}
+
+	assert.True(t, identity.HasTokenizedValues())
+	assert.Equal(t, []string{"email_address"}, identity.TokenizedFields())
+	assert.True(t, identity.IsTokenized("email_address"))
+	assert.True(t, identity.IsTokenized("EmailAddress"))
+	assert.False(t, identity.IsTokenized("last_name"))
+
+	plain := &blnkgo.IdentityResponse{}
+	assert.False(t, plain.HasTokenizedValues())
+}
+
+func TestIdentity_TokenizedDOB(t *testing.T) {
+	assert.Contains(t, blnkgo.TokenizableIdentityFields(), "dob")
+
+	var tokenized blnkgo.IdentityResponse
+	err := json.Unmarshal([]byte(`{"identity_id":"idt_123","created_at":"2024-01-02T03:04:05Z","first_name":"John","dob":"tkn_4b1e","meta_data":{"tokenized_fields":{"DOB":true}}}`), &tokenized)
+	assert.NoError(t, err)
+	assert.Equal(t, "idt_123", tokenized.IdentityId)
+	assert.Equal(t, "2024-01-02T03:04:05Z", tokenized.CreatedAt)
+	assert.Equal(t, "John", tokenized.FirstName)
+	assert.Nil(t, tokenized.DOB)
+	assert.Equal(t, "tkn_4b1e", tokenized.DOBToken)
+	assert.True(t, tokenized.IsTokenized("dob"))
+
+	var plain blnkgo.IdentityResponse
+	err = json.Unmarshal([]byte(`{"identity_id":"idt_124","dob":"1990-05-01T00:00:00Z"}`), &plain)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC), *plain.DOB)
+	assert.Empty(t, plain.DOBToken)
+}
+
+func TestIdentity_MalformedDOB(t *testing.T) {
+	var identity blnkgo.IdentityResponse
+	err := json.Unmarshal([]byte(`{"identity_id":"idt_125","dob":"1990-05-01"}`), &identity)
+	assert.ErrorContains(t, err, "dob")
+
+	// the same value is a token once dob is marked as tokenized
+	err = json.Unmarshal([]byte(`{"identity_id":"idt_125","dob":"1990-05-01","meta_data":{"tokenized_fields":["dob"]}}`), &identity)
+	assert.NoError(t, err)
+	assert.Equal(t, "1990-05-01", identity.DOBToken)
+}
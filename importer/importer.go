@@ -0,0 +1,436 @@
+// Package importer loads transactions into Blnk from CSV or NDJSON files,
+// e.g. when migrating from another ledger.
+//
+// Each record is mapped to a CreateTransactionRequest through a column
+// mapping and checked with the SDK's transaction validators. Validate reports
+// every invalid record without sending anything. Import sends the valid
+// records in file order, in batches, and checkpoints after each batch. Before a
+// batch is sent,
+// the references already recorded in Blnk are looked up and skipped, so an
+// import that crashed or is run twice does not create duplicates:
+//
+//	imp, _ := importer.NewImporter(client, importer.Config{
+//		Format:  importer.CSV,
+//		Mapping: map[string]string{"reference": "txn_ref", "amount": "value", "effective_date": "booked_on"},
+//	})
+//	report, err := imp.Validate(file)
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// Format is an input format.
+type Format string
+
+const (
+	// CSV files have a header row naming the columns.
+	CSV Format = "csv"
+	// NDJSON files have one JSON object per line.
+	NDJSON Format = "ndjson"
+)
+
+// Config configures an Importer.
+type Config struct {
+	Format Format
+	// Mapping maps field names, from Fields() or meta_data.<key>, to the
+	// columns or JSON keys holding them. Fields that are not mapped are read
+	// from the column of the same name.
+	Mapping map[string]string
+	// Precision is used for records without a precision column. Defaults to
+	// 100.
+	Precision int64
+	// BatchSize is the number of records sent between checkpoints. Defaults
+	// to 100.
+	BatchSize int
+	// Workers is the number of transactions created concurrently within a
+	// batch. Defaults to 1, which creates them in file order. More workers
+	// are faster but give up the order within a batch, so balances can be
+	// moved in a different order than the file, e.g. a debit before the
+	// credit that funds it.
+	Workers int
+	// Checkpoint, if set, is called after every batch. An error stops the
+	// import.
+	Checkpoint func(Checkpoint) error
+}
+
+// Checkpoint is the position of an import: the number of records, valid or
+// not, that have been dealt with. It marshals to JSON for storage.
+type Checkpoint struct {
+	Records int `json:"records"`
+}
+
+// RowError is a record that could not be imported. Line is the line of the
+// file the record starts on, counting the CSV header as line 1.
+type RowError struct {
+	Line      int
+	Reference string
+	Err       error
+}
+
+func (e *RowError) Error() string {
+	if e.Reference != "" {
+		return fmt.Sprintf("line %d (%s): %v", e.Line, e.Reference, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Report summarizes a validation or an import.
+type Report struct {
+	// Records is the number of records read, not counting those skipped
+	// because of a checkpoint.
+	Records int
+	Valid   int
+	// Created is the number of transactions created, and Existing the number
+	// skipped because their reference was already recorded.
+	Created  int
+	Existing int
+	Errors   []RowError
+}
+
+// Importer reads transactions in one format.
+type Importer struct {
+	transactions *blnkgo.TransactionService
+	config       Config
+}
+
+func NewImporter(client blnkgo.ClientInterface, config Config) (*Importer, error) {
+	if config.Format != CSV && config.Format != NDJSON {
+		return nil, fmt.Errorf("unknown format %q, want csv or ndjson", config.Format)
+	}
+	for field := range config.Mapping {
+		if _, ok := fields[field]; !ok && !strings.HasPrefix(field, "meta_data.") {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+	}
+	if config.Precision <= 0 {
+		config.Precision = 100
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	return &Importer{transactions: blnkgo.NewTransactionService(client), config: config}, nil
+}
+
+// record is a record read from the file, keyed by field name.
+type record struct {
+	line   int
+	fields map[string]interface{}
+	err    error
+	// skipped is set on the records before the checkpoint being resumed from
+	skipped bool
+}
+
+// row is a valid record ready to be sent.
+type row struct {
+	line    int
+	request blnkgo.CreateTransactionRequest
+}
+
+// Validate reads every record and reports the invalid ones, including
+// references used more than once in the file. Nothing is sent to Blnk.
+func (i *Importer) Validate(r io.Reader) (*Report, error) {
+	report := &Report{}
+	seen := make(map[string]int)
+	err := i.read(r, 0, func(rec record) error {
+		report.Records++
+		if _, err := i.parse(rec, seen); err != nil {
+			report.Errors = append(report.Errors, *err)
+			return nil
+		}
+		report.Valid++
+		return nil
+	})
+	return report, err
+}
+
+// Import sends every valid record to Blnk, resuming after from if it is not
+// nil. Invalid records, records reusing a reference from earlier in the file
+// and records Blnk rejects with a 4xx status are reported and skipped.
+//
+// Any other failure to create a transaction, such as a network error or a
+// server error that outlasts the client's retries, stops the import before
+// the batch is checkpointed, so nothing is lost: resume from the last
+// checkpoint and the transactions of the batch already created are skipped.
+// The report is returned also when an error stops the import.
+func (i *Importer) Import(r io.Reader, from *Checkpoint) (*Report, error) {
+	report := &Report{}
+	var checkpoint Checkpoint
+	if from != nil {
+		checkpoint = *from
+	}
+	var batch []row
+	records := checkpoint.Records
+	seen := make(map[string]int)
+
+	flush := func() error {
+		if err := i.submit(batch, report); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		checkpoint.Records = records
+		if i.config.Checkpoint != nil {
+			return i.config.Checkpoint(checkpoint)
+		}
+		return nil
+	}
+
+	err := i.read(r, checkpoint.Records, func(rec record) error {
+		if rec.skipped {
+			// the references before the checkpoint are still taken, so a
+			// record reusing one after it is reported as on the first run
+			i.parse(rec, seen)
+			return nil
+		}
+		records++
+		report.Records++
+		request, rowErr := i.parse(rec, seen)
+		if rowErr != nil {
+			report.Errors = append(report.Errors, *rowErr)
+		} else {
+			report.Valid++
+			batch = append(batch, row{line: rec.line, request: request})
+		}
+		if len(batch) >= i.config.BatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	if records > checkpoint.Records {
+		return report, flush()
+	}
+	return report, nil
+}
+
+// parse builds the request of a record. seen holds the line of every
+// reference parsed so far, so a reference used twice in a file is an error.
+func (i *Importer) parse(rec record, seen map[string]int) (blnkgo.CreateTransactionRequest, *RowError) {
+	if rec.err != nil {
+		return blnkgo.CreateTransactionRequest{}, &RowError{Line: rec.line, Err: rec.err}
+	}
+	request, err := buildRequest(rec.fields, i.config.Precision)
+	if err != nil {
+		return request, &RowError{Line: rec.line, Reference: request.Reference, Err: err}
+	}
+	if first, ok := seen[request.Reference]; ok {
+		return request, &RowError{Line: rec.line, Reference: request.Reference, Err: fmt.Errorf("reference already used on line %d", first)}
+	}
+	seen[request.Reference] = rec.line
+	return request, nil
+}
+
+// submit creates the transactions of a batch whose references are not
+// recorded yet. Rejected transactions are added to the report; any other
+// error stops the batch and is returned.
+func (i *Importer) submit(batch []row, report *Report) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	references := make([]interface{}, len(batch))
+	for n, row := range batch {
+		references[n] = row.request.Reference
+	}
+	existing, err := i.transactions.FilterAll(blnkgo.FilterParams{
+		Filters: []blnkgo.Filter{{Field: "reference", Operator: blnkgo.OpIn, Values: references}},
+		Limit:   len(batch),
+	})
+	if err != nil {
+		return fmt.Errorf("looking up existing references: %w", err)
+	}
+	recorded := make(map[string]bool, len(existing))
+	for _, transaction := range existing {
+		recorded[transaction.Reference] = true
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		jobs = make(chan row)
+		// stopped is the first error that is not a rejection
+		stopped error
+	)
+	isStopped := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return stopped != nil
+	}
+	for w := 0; w < i.config.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range jobs {
+				if isStopped() {
+					continue
+				}
+				_, resp, err := i.transactions.Create(row.request)
+				mu.Lock()
+				switch {
+				case err == nil:
+					report.Created++
+				case rejected(resp):
+					report.Errors = append(report.Errors, RowError{Line: row.line, Reference: row.request.Reference, Err: err})
+				case stopped == nil:
+					stopped = fmt.Errorf("creating transaction on line %d: %w", row.line, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, row := range batch {
+		if recorded[row.request.Reference] {
+			report.Existing++
+			continue
+		}
+		if isStopped() {
+			break
+		}
+		jobs <- row
+	}
+	close(jobs)
+	wg.Wait()
+	return stopped
+}
+
+// rejected reports whether Blnk refused a request because of its content, so
+// sending it again would fail the same way.
+func rejected(resp *http.Response) bool {
+	if resp == nil || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests {
+		return false
+	}
+	return resp.StatusCode >= 400 && resp.StatusCode < 500
+}
+
+// read calls fn with every record, marking the first skip ones as skipped.
+func (i *Importer) read(r io.Reader, skip int, fn func(record) error) error {
+	if i.config.Format == NDJSON {
+		return i.readNDJSON(r, skip, fn)
+	}
+	return i.readCSV(r, skip, fn)
+}
+
+// column returns the column or key holding field.
+func (i *Importer) column(field string) string {
+	if column, ok := i.config.Mapping[field]; ok {
+		return column
+	}
+	return field
+}
+
+// wanted returns the fields to read, keyed by their column.
+func (i *Importer) wanted() map[string]string {
+	wanted := make(map[string]string, len(fields)+len(i.config.Mapping))
+	for field := range fields {
+		wanted[i.column(field)] = field
+	}
+	for field, column := range i.config.Mapping {
+		wanted[column] = field
+	}
+	return wanted
+}
+
+func (i *Importer) readCSV(r io.Reader, skip int, fn func(record) error) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("reading header: %w", err)
+	}
+	wanted := i.wanted()
+	columns := make(map[int]string)
+	for n, name := range header {
+		if field, ok := wanted[strings.TrimSpace(name)]; ok {
+			columns[n] = field
+		} else if strings.HasPrefix(name, "meta_data.") {
+			columns[n] = name
+		}
+	}
+	for field, column := range i.config.Mapping {
+		found := false
+		for _, name := range columns {
+			found = found || name == field
+		}
+		if !found {
+			return fmt.Errorf("column %q mapped to %s is not in the header", column, field)
+		}
+	}
+
+	for n := 0; ; n++ {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return err
+		}
+		rec := record{err: err, skipped: n < skip}
+		if parseErr != nil {
+			rec.line = parseErr.StartLine
+		} else {
+			rec.line, _ = reader.FieldPos(0)
+			rec.fields = make(map[string]interface{}, len(columns))
+			for index, field := range columns {
+				rec.fields[field] = values[index]
+			}
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// maxLine bounds the length of an NDJSON line.
+const maxLine = 1 << 20
+
+func (i *Importer) readNDJSON(r io.Reader, skip int, fn func(record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLine)
+	wanted := i.wanted()
+	n := 0
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		n++
+		rec := record{line: line, skipped: n <= skip}
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			rec.err = fmt.Errorf("invalid JSON: %w", err)
+		} else {
+			rec.fields = make(map[string]interface{}, len(object))
+			for key, value := range object {
+				if field, ok := wanted[key]; ok {
+					rec.fields[field] = value
+				} else if strings.HasPrefix(key, "meta_data.") {
+					rec.fields[key] = value
+				}
+			}
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
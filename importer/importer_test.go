@@ -0,0 +1,263 @@
+package importer_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
+	"github.com/blnkfinance/blnk-go/importer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ledgerCSV = `txn_ref,value,currency,source,destination,booked_on,customer
+r-1,10.50,USD,@world,@wallet,2024-01-31,cus_1
+r-2,0.01,USD,@world,@wallet,2024-02-01T10:00:00Z,cus_2
+r-3,3,USD,@world,@wallet,,cus_3
+r-4,7.25,USD,@world,@wallet,2024-02-03,cus_4
+r-5,1,USD,@world,@wallet,2024-02-04,cus_5
+`
+
+var ledgerMapping = map[string]string{
+	"reference":          "txn_ref",
+	"amount":             "value",
+	"effective_date":     "booked_on",
+	"meta_data.customer": "customer",
+}
+
+func TestImporter_Validate(t *testing.T) {
+	imp, err := importer.NewImporter(blnktest.NewServer(t).Client(), importer.Config{Format: importer.CSV, Mapping: ledgerMapping})
+	require.NoError(t, err)
+
+	report, err := imp.Validate(strings.NewReader(`txn_ref,value,currency,source,destination,booked_on,customer
+r-1,10.50,USD,@world,@wallet,2024-01-31,cus_1
+r-2,10.505,USD,@world,@wallet,,cus_2
+r-3,5,,@world,@wallet,,cus_3
+r-1,5,USD,@world,@wallet,,cus_4
+r-5,5,USD,@world,@wallet,last tuesday,cus_5
+,5,USD,@world,@wallet,,cus_6
+r-7,5,USD,@world
+r-8,5,USD,@world,@wallet,,cus_8
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 8, report.Records)
+	assert.Equal(t, 2, report.Valid)
+
+	lines := make([]int, len(report.Errors))
+	for n, rowErr := range report.Errors {
+		lines[n] = rowErr.Line
+	}
+	assert.Equal(t, []int{3, 4, 5, 6, 7, 8}, lines)
+	assert.Contains(t, report.Errors[0].Error(), "line 3 (r-2): amount")
+	assert.Contains(t, report.Errors[1].Error(), "currency is required")
+	assert.Contains(t, report.Errors[2].Error(), "already used on line 2")
+	assert.Contains(t, report.Errors[4].Error(), "reference is required")
+	assert.Contains(t, report.Errors[5].Error(), "wrong number of fields")
+}
+
+func TestImporter_ImportCSV(t *testing.T) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	var checkpoints []importer.Checkpoint
+	imp, err := importer.NewImporter(client, importer.Config{
+		Format:     importer.CSV,
+		Mapping:    ledgerMapping,
+		BatchSize:  2,
+		Checkpoint: func(c importer.Checkpoint) error { checkpoints = append(checkpoints, c); return nil },
+	})
+	require.NoError(t, err)
+
+	report, err := imp.Import(strings.NewReader(ledgerCSV), nil)
+	require.NoError(t, err)
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, 5, report.Created)
+	assert.Equal(t, []importer.Checkpoint{{Records: 2}, {Records: 4}, {Records: 5}}, checkpoints)
+
+	transactions, err := client.Transaction.FilterAll(blnkgo.FilterParams{
+		Filters: []blnkgo.Filter{{Field: "reference", Operator: blnkgo.OpEqual, Value: "r-1"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, big.NewInt(1050), transactions[0].PreciseAmount)
+	assert.Equal(t, "cus_1", transactions[0].MetaData["customer"])
+	require.NotNil(t, transactions[0].EffectiveDate)
+	assert.True(t, transactions[0].EffectiveDate.Equal(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)))
+
+	all, err := client.Transaction.FilterAll(blnkgo.FilterParams{})
+	require.NoError(t, err)
+	var references []string
+	for _, transaction := range all {
+		references = append(references, transaction.Reference)
+	}
+	assert.Equal(t, []string{"r-1", "r-2", "r-3", "r-4", "r-5"}, references, "created in file order")
+
+	// importing the same file again creates nothing
+	report, err = imp.Import(strings.NewReader(ledgerCSV), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 5, report.Existing)
+}
+
+func TestImporter_Resume(t *testing.T) {
+	client := blnktest.NewServer(t).Client()
+	interrupted := errors.New("interrupted")
+	config := importer.Config{
+		Format:     importer.CSV,
+		Mapping:    ledgerMapping,
+		BatchSize:  2,
+		Checkpoint: func(importer.Checkpoint) error { return interrupted },
+	}
+	imp, err := importer.NewImporter(client, config)
+	require.NoError(t, err)
+	report, err := imp.Import(strings.NewReader(ledgerCSV), nil)
+	require.ErrorIs(t, err, interrupted)
+	assert.Equal(t, 2, report.Created)
+
+	// the first transaction of the next batch was created before the crash
+	// was noticed
+	_, _, err = client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: "r-3", Amount: 3, Precision: 100, Currency: "USD", Source: "@world", Destination: "@wallet",
+	}})
+	require.NoError(t, err)
+
+	stored, err := json.Marshal(importer.Checkpoint{Records: 2})
+	require.NoError(t, err)
+	var from importer.Checkpoint
+	require.NoError(t, json.Unmarshal(stored, &from))
+
+	config.Checkpoint = nil
+	imp, err = importer.NewImporter(client, config)
+	require.NoError(t, err)
+	report, err = imp.Import(strings.NewReader(ledgerCSV), &from)
+	require.NoError(t, err)
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, 3, report.Records)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 1, report.Existing)
+
+	transactions, err := client.Transaction.FilterAll(blnkgo.FilterParams{Filters: []blnkgo.Filter{}})
+	require.NoError(t, err)
+	assert.Len(t, transactions, 5)
+}
+
+func TestImporter_Failures(t *testing.T) {
+	server := blnktest.NewServer(t)
+	client := server.Client(blnkgo.WithRetryWait(time.Millisecond))
+	var checkpoints []importer.Checkpoint
+	config := importer.Config{
+		Format:     importer.CSV,
+		Mapping:    ledgerMapping,
+		BatchSize:  2,
+		Checkpoint: func(c importer.Checkpoint) error { checkpoints = append(checkpoints, c); return nil },
+	}
+	imp, err := importer.NewImporter(client, config)
+	require.NoError(t, err)
+
+	// a rejected transaction is reported and the import goes on
+	server.FailNext(http.MethodPost, "/transactions", http.StatusUnprocessableEntity)
+	report, err := imp.Import(strings.NewReader(ledgerCSV), nil)
+	require.NoError(t, err)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, "r-1", report.Errors[0].Reference)
+	assert.Equal(t, 4, report.Created)
+
+	server = blnktest.NewServer(t)
+	client = server.Client(blnkgo.WithRetryWait(time.Millisecond))
+	imp, err = importer.NewImporter(client, config)
+	require.NoError(t, err)
+	checkpoints = nil
+	_, _, err = client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: "r-1", Amount: 10.5, Precision: 100, Currency: "USD", Source: "@world", Destination: "@wallet",
+	}})
+	require.NoError(t, err)
+	// r-1 exists, so the only transaction sent in the first batch is r-2
+	server.FailNext(http.MethodPost, "/transactions", http.StatusServiceUnavailable)
+	report, err = imp.Import(strings.NewReader(ledgerCSV), nil)
+	require.ErrorContains(t, err, "line 3")
+	assert.Empty(t, report.Errors, "transport errors are not row errors")
+	assert.Empty(t, checkpoints, "the failed batch is not checkpointed")
+
+	report, err = imp.Import(strings.NewReader(ledgerCSV), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Created)
+	assert.Equal(t, 1, report.Existing)
+	assert.Equal(t, []importer.Checkpoint{{Records: 2}, {Records: 4}, {Records: 5}}, checkpoints)
+}
+
+func TestImporter_ImportNDJSON(t *testing.T) {
+	client := blnktest.NewServer(t).Client()
+	imp, err := importer.NewImporter(client, importer.Config{Format: importer.NDJSON, Mapping: map[string]string{"reference": "id"}})
+	require.NoError(t, err)
+
+	report, err := imp.Import(strings.NewReader(`{"id":"n-1","amount":12.5,"currency":"USD","source":"@world","destination":"@wallet","meta_data":{"batch":7}}
+
+{"id":"n-2","precise_amount":"100000000000000000001","precision":1000,"currency":"USD","source":"@world","destination":"@wallet","skip_queue":true}
+{"id":"n-3",
+{"id":"n-4","amount":"1","currency":"USD","source":"@world","destination":"@wallet","effective_date":"2024-02-03 04:05:06"}
+`), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Records)
+	assert.Equal(t, 3, report.Created)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, 4, report.Errors[0].Line)
+
+	transactions, err := client.Transaction.FilterAll(blnkgo.FilterParams{
+		Filters: []blnkgo.Filter{{Field: "reference", Operator: blnkgo.OpEqual, Value: "n-2"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, "100000000000000000001", transactions[0].PreciseAmount.String())
+}
+
+func TestNewImporter(t *testing.T) {
+	client := blnktest.NewServer(t).Client()
+	_, err := importer.NewImporter(client, importer.Config{Format: "xlsx"})
+	assert.Error(t, err)
+	_, err = importer.NewImporter(client, importer.Config{Format: importer.CSV, Mapping: map[string]string{"amout": "value"}})
+	assert.Error(t, err)
+
+	imp, err := importer.NewImporter(client, importer.Config{Format: importer.CSV, Mapping: map[string]string{"reference": "txn_ref"}})
+	require.NoError(t, err)
+	_, err = imp.Validate(strings.NewReader("reference,amount\nr-1,1\n"))
+	assert.ErrorContains(t, err, `column "txn_ref"`)
+	assert.Contains(t, importer.Fields(), "effective_date")
+}
+
+func TestImporter_ResumeKeepsReferences(t *testing.T) {
+	client := blnktest.NewServer(t).Client()
+	imp, err := importer.NewImporter(client, importer.Config{Format: importer.CSV, Mapping: ledgerMapping})
+	require.NoError(t, err)
+
+	// r-1 is reused after the checkpoint
+	report, err := imp.Import(strings.NewReader(ledgerCSV+"r-1,2,USD,@world,@wallet,,cus_6\n"), &importer.Checkpoint{Records: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Records)
+	assert.Equal(t, 3, report.Created)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, 7, report.Errors[0].Line)
+	assert.Contains(t, report.Errors[0].Error(), "already used on line 2")
+}
+
+func TestImporter_ValidatesPreciseDistributions(t *testing.T) {
+	imp, err := importer.NewImporter(blnktest.NewServer(t).Client(), importer.Config{Format: importer.NDJSON})
+	require.NoError(t, err)
+
+	report, err := imp.Validate(strings.NewReader(`{"reference":"d-1","amount":"10.50","currency":"USD","source":"@world","destinations":[{"identifier":"@a","distribution":"50%"},{"identifier":"@b","distribution":"5.25"}]}
+{"reference":"d-2","amount":"10.50","currency":"USD","source":"@world","destinations":[{"identifier":"@a","distribution":"50%"},{"identifier":"@b","distribution":"6"}]}
+{"reference":"d-3","amount":"10.50","currency":"USD","source":"@world","destinations":[{"identifier":"@a","distribution":"10"},{"identifier":"@b","distribution":"left"}]}
+{"reference":"d-4","amount":"10.50","currency":"USD","source":"@world","destinations":[{"identifier":"@a","distribution":"11"},{"identifier":"@b","distribution":"left"}]}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Valid)
+	require.Len(t, report.Errors, 2)
+	assert.Equal(t, "d-2", report.Errors[0].Reference)
+	assert.Contains(t, report.Errors[0].Error(), "must be equal to the amount")
+	assert.Equal(t, "d-4", report.Errors[1].Reference)
+	assert.Contains(t, report.Errors[1].Error(), "exceeds the amount")
+}
@@ -0,0 +1,199 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// fields are the CreateTransactionRequest fields a column can be mapped to,
+// besides meta_data.<key>.
+var fields = map[string]func(t *blnkgo.CreateTransactionRequest, value interface{}) error{
+	"reference":   setString(func(t *blnkgo.CreateTransactionRequest) *string { return &t.Reference }),
+	"currency":    setString(func(t *blnkgo.CreateTransactionRequest) *string { return &t.Currency }),
+	"source":      setString(func(t *blnkgo.CreateTransactionRequest) *string { return &t.Source }),
+	"destination": setString(func(t *blnkgo.CreateTransactionRequest) *string { return &t.Destination }),
+	"description": setString(func(t *blnkgo.CreateTransactionRequest) *string { return &t.Description }),
+	// amount is handled after precision is known, see buildRequest
+	"amount":          func(*blnkgo.CreateTransactionRequest, interface{}) error { return nil },
+	"precision":       setPrecision,
+	"precise_amount":  setPreciseAmount,
+	"rate":            setRate,
+	"effective_date":  setEffectiveDate,
+	"skip_queue":      setBool(func(t *blnkgo.CreateTransactionRequest) *bool { return &t.SkipQueue }),
+	"allow_overdraft": setBool(func(t *blnkgo.CreateTransactionRequest) *bool { return &t.AllowOverdraft }),
+	"meta_data":       setJSON(func(t *blnkgo.CreateTransactionRequest) interface{} { return &t.MetaData }),
+	"sources":         setJSON(func(t *blnkgo.CreateTransactionRequest) interface{} { return &t.Sources }),
+	"destinations":    setJSON(func(t *blnkgo.CreateTransactionRequest) interface{} { return &t.Destinations }),
+}
+
+// Fields returns the names of the fields columns can be mapped to besides
+// meta_data.<key>.
+func Fields() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildRequest turns a record, keyed by field name, into a transaction
+// request. Empty values leave fields unset.
+func buildRequest(record map[string]interface{}, defaultPrecision int64) (blnkgo.CreateTransactionRequest, error) {
+	var t blnkgo.CreateTransactionRequest
+	t.Precision = defaultPrecision
+	// sorted so errors do not depend on map order
+	names := make([]string, 0, len(record))
+	for name := range record {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := record[name]
+		if isEmpty(value) {
+			continue
+		}
+		if key, ok := strings.CutPrefix(name, "meta_data."); ok {
+			if t.MetaData == nil {
+				t.MetaData = make(map[string]interface{})
+			}
+			t.MetaData[key] = value
+			continue
+		}
+		if err := fields[name](&t, value); err != nil {
+			return t, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if amount := record["amount"]; !isEmpty(amount) {
+		if t.PreciseAmount != nil {
+			return t, fmt.Errorf("amount and precise_amount cannot both be set")
+		}
+		precise, ok := blnkgo.ParsePreciseAmount(text(amount), t.Precision)
+		if !ok {
+			return t, fmt.Errorf("amount: %q is not a decimal amount with at most the decimals of precision %d", text(amount), t.Precision)
+		}
+		t.PreciseAmount = precise
+	}
+	if t.Reference == "" {
+		return t, fmt.Errorf("reference is required")
+	}
+	if t.Currency == "" {
+		return t, fmt.Errorf("currency is required")
+	}
+	if t.PreciseAmount == nil || t.PreciseAmount.Sign() <= 0 {
+		return t, fmt.Errorf("a positive amount or precise_amount is required")
+	}
+	return t, blnkgo.ValidateCreateTransacation(t)
+}
+
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	s, ok := value.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+// text returns the text of a CSV value or a decoded JSON scalar.
+func text(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case json.Number:
+		return value.String()
+	}
+	return fmt.Sprint(value)
+}
+
+func setString(field func(t *blnkgo.CreateTransactionRequest) *string) func(*blnkgo.CreateTransactionRequest, interface{}) error {
+	return func(t *blnkgo.CreateTransactionRequest, value interface{}) error {
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("want a string, got %v", value)
+		}
+		*field(t) = s
+		return nil
+	}
+}
+
+func setBool(field func(t *blnkgo.CreateTransactionRequest) *bool) func(*blnkgo.CreateTransactionRequest, interface{}) error {
+	return func(t *blnkgo.CreateTransactionRequest, value interface{}) error {
+		if b, ok := value.(bool); ok {
+			*field(t) = b
+			return nil
+		}
+		b, err := strconv.ParseBool(text(value))
+		if err != nil {
+			return fmt.Errorf("want true or false, got %q", text(value))
+		}
+		*field(t) = b
+		return nil
+	}
+}
+
+// setJSON decodes objects and arrays, given as JSON text in CSV files.
+func setJSON(field func(t *blnkgo.CreateTransactionRequest) interface{}) func(*blnkgo.CreateTransactionRequest, interface{}) error {
+	return func(t *blnkgo.CreateTransactionRequest, value interface{}) error {
+		var data []byte
+		if s, ok := value.(string); ok {
+			data = []byte(s)
+		} else {
+			var err error
+			if data, err = json.Marshal(value); err != nil {
+				return err
+			}
+		}
+		if err := json.Unmarshal(data, field(t)); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+		return nil
+	}
+}
+
+func setPrecision(t *blnkgo.CreateTransactionRequest, value interface{}) error {
+	precision, err := strconv.ParseInt(text(value), 10, 64)
+	if err != nil || precision < 1 {
+		return fmt.Errorf("want a positive integer, got %q", text(value))
+	}
+	t.Precision = precision
+	return nil
+}
+
+func setPreciseAmount(t *blnkgo.CreateTransactionRequest, value interface{}) error {
+	amount, ok := new(big.Int).SetString(text(value), 10)
+	if !ok {
+		return fmt.Errorf("want an integer, got %q", text(value))
+	}
+	t.PreciseAmount = amount
+	return nil
+}
+
+func setRate(t *blnkgo.CreateTransactionRequest, value interface{}) error {
+	rate, err := strconv.ParseFloat(text(value), 64)
+	if err != nil || rate <= 0 {
+		return fmt.Errorf("want a positive number, got %q", text(value))
+	}
+	t.Rate = rate
+	return nil
+}
+
+// setEffectiveDate backdates a transaction. Dates without a time are taken
+// as midnight UTC.
+func setEffectiveDate(t *blnkgo.CreateTransactionRequest, value interface{}) error {
+	s := text(value)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+		if date, err := time.Parse(layout, s); err == nil {
+			t.EffectiveDate = &date
+			return nil
+		}
+	}
+	return fmt.Errorf("want an RFC 3339 time or YYYY-MM-DD, got %q", s)
+}
@@ -0,0 +1,375 @@
+// Package interest accrues interest on Blnk balances from their historical
+// balances and posts it as transactions.
+//
+// Interest accrues daily on the balance at the end of each day. It is posted
+// once per period (a day or a calendar month) from an interest-expense balance,
+// with a reference derived from the balance and the period, so running an
+// accrual job twice never posts a period twice. Only periods that lie entirely
+// within the requested range are accrued, so a period's amount does not depend
+// on when the job ran.
+//
+// With Simple interest each day accrues on the balance alone. With Compound
+// interest it also accrues on the interest accrued earlier in the period and
+// not yet posted. Posted interest becomes part of the balance either way;
+// set Engine.Destination to pay interest into a different balance.
+package interest
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// Method is how interest accrues within a posting period.
+type Method int
+
+const (
+	Simple Method = iota
+	Compound
+)
+
+func (m Method) String() string {
+	if m == Compound {
+		return "compound"
+	}
+	return "simple"
+}
+
+// Period is how often accrued interest is posted.
+type Period int
+
+const (
+	Daily Period = iota
+	Monthly
+)
+
+// maxAccrualDays guards against accidentally accruing over a huge range.
+const maxAccrualDays = 3660
+
+// BalanceSource returns the balance of a balance at a point in time, in minor
+// units.
+type BalanceSource interface {
+	BalanceAt(balanceID string, at time.Time) (*big.Int, error)
+}
+
+// HistoricalSource reads balances with LedgerBalanceService.GetHistorical.
+type HistoricalSource struct {
+	// FromSource recomputes balances from transactions instead of snapshots.
+	FromSource bool
+
+	balances *blnkgo.LedgerBalanceService
+}
+
+func NewHistoricalSource(client blnkgo.ClientInterface) *HistoricalSource {
+	return &HistoricalSource{balances: blnkgo.NewLedgerBalanceService(client)}
+}
+
+func (s *HistoricalSource) BalanceAt(balanceID string, at time.Time) (*big.Int, error) {
+	historical, _, err := s.balances.GetHistorical(balanceID, at, s.FromSource)
+	if err != nil {
+		return nil, err
+	}
+	if historical.Balance.Balance == nil {
+		return new(big.Int), nil
+	}
+	return historical.Balance.Balance, nil
+}
+
+// TimeSeriesSource reads balances from a time series fetched earlier with
+// LedgerBalanceService.BalanceTimeSeries. The balance at a time is that of the
+// latest point at or before it.
+type TimeSeriesSource struct {
+	Series *blnkgo.TimeSeries
+}
+
+func (s TimeSeriesSource) BalanceAt(balanceID string, at time.Time) (*big.Int, error) {
+	if s.Series == nil || s.Series.BalanceID != balanceID {
+		return nil, fmt.Errorf("no time series for balance %s", balanceID)
+	}
+	var balance *big.Int
+	for _, point := range s.Series.Points {
+		if point.Timestamp.After(at) {
+			break
+		}
+		balance = point.Balance
+	}
+	if balance == nil {
+		return nil, fmt.Errorf("time series of %s starts after %s", balanceID, at.Format(time.RFC3339))
+	}
+	return balance, nil
+}
+
+// Accrual is the interest for one posting period. Exact is the unrounded
+// interest in minor units and Amount is Exact rounded half up.
+type Accrual struct {
+	BalanceID string
+	Start     time.Time
+	End       time.Time
+	Days      int
+	Exact     *big.Rat
+	Amount    *big.Int
+	Reference string
+}
+
+// Engine accrues and posts interest.
+type Engine struct {
+	Tiers    []Tier
+	TierMode TierMode
+	Method   Method
+	DayCount DayCount
+	Period   Period
+	// Location decides where days start. It defaults to UTC.
+	Location *time.Location
+	// ExpenseBalance pays the interest. Postings may overdraw it.
+	ExpenseBalance string
+	// Destination receives the interest instead of the accruing balance when
+	// set.
+	Destination string
+	// Currency and Precision of the postings. Accruals are in the minor units
+	// of their balance, so both are looked up from the balance when unset;
+	// Precision falls back to 100 for balances without one. Set Precision only
+	// to override the balance's own.
+	Currency  string
+	Precision int64
+	// ReferencePrefix starts every posting reference. It defaults to
+	// "interest".
+	ReferencePrefix string
+	Balances        BalanceSource
+
+	balances     *blnkgo.LedgerBalanceService
+	transactions *blnkgo.TransactionService
+}
+
+func NewEngine(client blnkgo.ClientInterface, expenseBalance string, tiers []Tier) *Engine {
+	return &Engine{
+		Tiers:          tiers,
+		ExpenseBalance: expenseBalance,
+		Balances:       NewHistoricalSource(client),
+		balances:       blnkgo.NewLedgerBalanceService(client),
+		transactions:   blnkgo.NewTransactionService(client),
+	}
+}
+
+func (e *Engine) location() *time.Location {
+	if e.Location == nil {
+		return time.UTC
+	}
+	return e.Location
+}
+
+func (e *Engine) date(t time.Time) time.Time {
+	y, m, d := t.In(e.location()).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, e.location())
+}
+
+// periodEnd returns the end of the posting period starting at start.
+func (e *Engine) periodEnd(start time.Time) time.Time {
+	if e.Period == Monthly {
+		return time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, e.location())
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// periodStart returns the start of the posting period containing day.
+func (e *Engine) periodStart(day time.Time) time.Time {
+	if e.Period == Monthly {
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, e.location())
+	}
+	return day
+}
+
+// Reference returns the posting reference of the period starting at start.
+func (e *Engine) Reference(balanceID string, start time.Time) string {
+	prefix := e.ReferencePrefix
+	if prefix == "" {
+		prefix = "interest"
+	}
+	layout := "20060102"
+	if e.Period == Monthly {
+		layout = "200601"
+	}
+	return fmt.Sprintf("%s-%s-%s", prefix, balanceID, start.In(e.location()).Format(layout))
+}
+
+// Accrue computes the interest of every posting period within the dates
+// [from, to) that has ended, without posting it.
+func (e *Engine) Accrue(balanceID string, from, to time.Time) ([]Accrual, error) {
+	if balanceID == "" {
+		return nil, fmt.Errorf("balanceID is required")
+	}
+	if err := validateTiers(e.Tiers); err != nil {
+		return nil, err
+	}
+	from, to = e.date(from), e.date(to)
+	if !from.Before(to) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+	if to.Sub(from) > maxAccrualDays*24*time.Hour {
+		return nil, fmt.Errorf("cannot accrue more than %d days at once", maxAccrualDays)
+	}
+	// days that have not ended yet have no closing balance
+	if today := e.date(time.Now()); to.After(today) {
+		to = today
+	}
+
+	start := e.periodStart(from)
+	if start.Before(from) {
+		start = e.periodEnd(start)
+	}
+
+	var accruals []Accrual
+	for end := e.periodEnd(start); !end.After(to); start, end = end, e.periodEnd(end) {
+		accrual := Accrual{BalanceID: balanceID, Start: start, End: end, Exact: new(big.Rat), Reference: e.Reference(balanceID, start)}
+		for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+			next := day.AddDate(0, 0, 1)
+			balance, err := e.Balances.BalanceAt(balanceID, next)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the balance of %s at %s: %w", balanceID, next.Format(time.RFC3339), err)
+			}
+
+			base := new(big.Rat).SetInt(balance)
+			if e.Method == Compound {
+				base.Add(base, accrual.Exact)
+			}
+			daily := AnnualInterest(base, e.Tiers, e.TierMode)
+			accrual.Exact.Add(accrual.Exact, daily.Mul(daily, e.DayCount.YearFraction(day, next)))
+			accrual.Days++
+		}
+		accrual.Amount = blnkgo.RoundRat(accrual.Exact, blnkgo.RoundHalfUp)
+		accruals = append(accruals, accrual)
+	}
+	return accruals, nil
+}
+
+// Post creates a transaction for every accrual with a non-zero amount whose
+// reference has not been used yet, and returns the transactions it created.
+func (e *Engine) Post(accruals []Accrual) ([]blnkgo.Transaction, error) {
+	if e.ExpenseBalance == "" {
+		return nil, fmt.Errorf("expense balance is required")
+	}
+
+	var pending []Accrual
+	for _, accrual := range accruals {
+		if accrual.Amount != nil && accrual.Amount.Sign() > 0 {
+			pending = append(pending, accrual)
+		}
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	posted, err := e.posted(pending)
+	if err != nil {
+		return nil, err
+	}
+
+	units := make(map[string]postingUnit)
+
+	var created []blnkgo.Transaction
+	for _, accrual := range pending {
+		if posted[accrual.Reference] {
+			continue
+		}
+		unit, ok := units[accrual.BalanceID]
+		if !ok {
+			if unit, err = e.postingUnit(accrual.BalanceID); err != nil {
+				return created, err
+			}
+			units[accrual.BalanceID] = unit
+		}
+		currency, precision := unit.currency, unit.precision
+		destination := e.Destination
+		if destination == "" {
+			destination = accrual.BalanceID
+		}
+
+		value, _ := new(big.Rat).SetFrac(accrual.Amount, big.NewInt(precision)).Float64()
+		transaction, _, err := e.transactions.Create(blnkgo.CreateTransactionRequest{
+			ParentTransaction: blnkgo.ParentTransaction{
+				Amount:        value,
+				PreciseAmount: new(big.Int).Set(accrual.Amount),
+				Precision:     precision,
+				Currency:      currency,
+				Reference:     accrual.Reference,
+				Description:   fmt.Sprintf("Interest %s to %s", accrual.Start.Format("2006-01-02"), accrual.End.AddDate(0, 0, -1).Format("2006-01-02")),
+				Source:        e.ExpenseBalance,
+				Destination:   destination,
+				MetaData: map[string]interface{}{
+					"interest_balance":      accrual.BalanceID,
+					"interest_period_start": accrual.Start.Format("2006-01-02"),
+					"interest_period_end":   accrual.End.Format("2006-01-02"),
+					"interest_days":         accrual.Days,
+					"interest_exact":        accrual.Exact.FloatString(6),
+					"interest_method":       e.Method.String(),
+					"interest_day_count":    e.DayCount.String(),
+				},
+			},
+			AllowOverdraft: true,
+		})
+		if err != nil {
+			return created, fmt.Errorf("failed to post %s: %w", accrual.Reference, err)
+		}
+		created = append(created, *transaction)
+	}
+	return created, nil
+}
+
+// Run accrues the interest of a balance over [from, to) and posts it.
+func (e *Engine) Run(balanceID string, from, to time.Time) ([]Accrual, []blnkgo.Transaction, error) {
+	accruals, err := e.Accrue(balanceID, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	created, err := e.Post(accruals)
+	return accruals, created, err
+}
+
+// postingUnit is the currency and precision interest on a balance is posted in.
+type postingUnit struct {
+	currency  string
+	precision int64
+}
+
+func (e *Engine) postingUnit(balanceID string) (postingUnit, error) {
+	unit := postingUnit{currency: e.Currency, precision: e.Precision}
+	if unit.currency != "" && unit.precision > 0 {
+		return unit, nil
+	}
+	balance, _, err := e.balances.Get(balanceID)
+	if err != nil {
+		return unit, fmt.Errorf("failed to look up the currency of %s: %w", balanceID, err)
+	}
+	if unit.currency == "" {
+		unit.currency = balance.Currency
+	}
+	if unit.precision <= 0 {
+		unit.precision = int64(balance.Precision)
+	}
+	if unit.precision <= 0 {
+		unit.precision = 100
+	}
+	return unit, nil
+}
+
+// posted returns the references of accruals that already have a transaction.
+func (e *Engine) posted(accruals []Accrual) (map[string]bool, error) {
+	posted := make(map[string]bool)
+	for i := 0; i < len(accruals); i += 100 {
+		batch := accruals[i:min(i+100, len(accruals))]
+		references := make([]interface{}, len(batch))
+		for j, accrual := range batch {
+			references[j] = accrual.Reference
+		}
+		existing, err := e.transactions.FilterAll(blnkgo.FilterParams{
+			Filters: []blnkgo.Filter{{Field: "reference", Operator: blnkgo.OpIn, Values: references}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up posted interest: %w", err)
+		}
+		for _, transaction := range existing {
+			posted[transaction.Reference] = true
+		}
+	}
+	return posted, nil
+}
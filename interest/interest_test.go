@@ -0,0 +1,207 @@
+package interest_test
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
+	"github.com/blnkfinance/blnk-go/interest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func flatRate(t *testing.T, rate string) []interest.Tier {
+	t.Helper()
+	tiers, err := interest.FlatRate(rate)
+	require.NoError(t, err)
+	return tiers
+}
+
+func TestDayCount_YearFraction(t *testing.T) {
+	tests := []struct {
+		dayCount   interest.DayCount
+		start, end time.Time
+		expected   *big.Rat
+	}{
+		{interest.Actual365, date(2024, 1, 1), date(2024, 1, 2), big.NewRat(1, 365)},
+		{interest.Actual365, date(2024, 1, 1), date(2025, 1, 1), big.NewRat(366, 365)},
+		{interest.Thirty360, date(2024, 1, 1), date(2024, 2, 1), big.NewRat(30, 360)},
+		{interest.Thirty360, date(2024, 1, 30), date(2024, 1, 31), big.NewRat(0, 360)},
+		{interest.Thirty360, date(2024, 1, 31), date(2024, 2, 1), big.NewRat(1, 360)},
+		{interest.Thirty360, date(2023, 2, 28), date(2023, 3, 1), big.NewRat(3, 360)},
+		{interest.Thirty360, date(2024, 1, 1), date(2025, 1, 1), big.NewRat(1, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dayCount.String()+" "+tt.start.Format("2006-01-02"), func(t *testing.T) {
+			assert.Equal(t, tt.expected.String(), tt.dayCount.YearFraction(tt.start, tt.end).String())
+		})
+	}
+}
+
+func TestAnnualInterest(t *testing.T) {
+	tiers := []interest.Tier{
+		{From: big.NewInt(0), Rate: big.NewRat(1, 100)},
+		{From: big.NewInt(100000), Rate: big.NewRat(2, 100)},
+		{From: big.NewInt(500000), Rate: big.NewRat(3, 100)},
+	}
+
+	banded := interest.AnnualInterest(big.NewRat(600000, 1), tiers, interest.Banded)
+	assert.Equal(t, "12000/1", banded.String(), "1000 + 8000 + 3000")
+
+	whole := interest.AnnualInterest(big.NewRat(600000, 1), tiers, interest.WholeBalance)
+	assert.Equal(t, "18000/1", whole.String())
+
+	assert.Equal(t, "500/1", interest.AnnualInterest(big.NewRat(50000, 1), tiers, interest.Banded).String())
+	assert.Equal(t, int64(0), interest.AnnualInterest(big.NewRat(-100, 1), tiers, interest.Banded).Num().Int64())
+
+	rate, err := interest.ParseRate("3.5%")
+	require.NoError(t, err)
+	assert.Equal(t, "7/200", rate.String())
+	_, err = interest.ParseRate("-1")
+	assert.Error(t, err)
+
+	tiers, err = interest.FlatRate("5%")
+	require.NoError(t, err)
+	require.Len(t, tiers, 1)
+	assert.Equal(t, "1/20", tiers[0].Rate.String())
+	assert.Equal(t, 0, tiers[0].From.Sign())
+	_, err = interest.FlatRate("five percent")
+	assert.Error(t, err)
+}
+
+func setup(t *testing.T) (*blnktest.Server, *blnkgo.Client, string) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	server.SetClock(func() time.Time { return date(2024, 1, 1).Add(10 * time.Hour) })
+
+	savings, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: "ldg_savings", Currency: "USD"})
+	require.NoError(t, err)
+	_, _, err = client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: "deposit", PreciseAmount: big.NewInt(1000000), Precision: 100, Currency: "USD", Source: "@world", Destination: savings.BalanceID,
+	}})
+	require.NoError(t, err)
+	server.SetClock(func() time.Time { return date(2024, 3, 1).Add(10 * time.Hour) })
+	return server, client, savings.BalanceID
+}
+
+func TestEngine_Simple(t *testing.T) {
+	_, client, balanceID := setup(t)
+	engine := interest.NewEngine(client, "@interest-expense", flatRate(t, "3.65%"))
+
+	accruals, err := engine.Accrue(balanceID, date(2023, 12, 30), date(2024, 1, 4))
+
+	require.NoError(t, err)
+	require.Len(t, accruals, 5)
+	assert.Equal(t, int64(0), accruals[0].Amount.Int64(), "no balance before the deposit")
+	assert.Equal(t, int64(100), accruals[2].Amount.Int64(), "10,000.00 at 3.65% is 1.00 a day")
+	assert.Equal(t, "interest-"+balanceID+"-20240102", accruals[3].Reference)
+}
+
+func TestEngine_MonthlyCompound(t *testing.T) {
+	_, client, balanceID := setup(t)
+	engine := interest.NewEngine(client, "@interest-expense", flatRate(t, "3.65%"))
+	engine.Period = interest.Monthly
+
+	simple, err := engine.Accrue(balanceID, date(2024, 1, 1), date(2024, 2, 1))
+	require.NoError(t, err)
+	require.Len(t, simple, 1)
+	assert.Equal(t, int64(3100), simple[0].Amount.Int64())
+	assert.Equal(t, 31, simple[0].Days)
+
+	engine.Method = interest.Compound
+	compound, err := engine.Accrue(balanceID, date(2024, 1, 1), date(2024, 2, 1))
+	require.NoError(t, err)
+	assert.Equal(t, 1, compound[0].Exact.Cmp(simple[0].Exact))
+	assert.Equal(t, "interest-"+balanceID+"-202401", compound[0].Reference)
+
+	partial, err := engine.Accrue(balanceID, date(2024, 1, 15), date(2024, 2, 20))
+	require.NoError(t, err)
+	assert.Empty(t, partial, "January and February are incomplete")
+
+	engine.DayCount = interest.Thirty360
+	engine.Method = interest.Simple
+	thirty, err := engine.Accrue(balanceID, date(2024, 1, 1), date(2024, 2, 1))
+	require.NoError(t, err)
+	assert.Equal(t, "9125/3", thirty[0].Exact.String())
+	assert.Equal(t, int64(3042), thirty[0].Amount.Int64())
+}
+
+func TestEngine_RunIsIdempotent(t *testing.T) {
+	server, client, balanceID := setup(t)
+	engine := interest.NewEngine(client, "@interest-expense", flatRate(t, "3.65%"))
+	engine.Period = interest.Monthly
+
+	_, created, err := engine.Run(balanceID, date(2024, 1, 1), date(2024, 3, 1))
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+	assert.Equal(t, "interest-"+balanceID+"-202401", created[0].Reference)
+	assert.Equal(t, "USD", created[0].Currency)
+	assert.Equal(t, balanceID, created[0].MetaData["interest_balance"])
+
+	_, created, err = engine.Run(balanceID, date(2024, 1, 1), date(2024, 3, 1))
+	require.NoError(t, err)
+	assert.Empty(t, created)
+
+	balance, _ := server.Balance(balanceID)
+	assert.Equal(t, int64(1000000+3100+2900), balance.Balance.Int64())
+}
+
+func TestEngine_PostsAtBalancePrecision(t *testing.T) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	server.SetClock(func() time.Time { return date(2024, 1, 1).Add(10 * time.Hour) })
+	wallet, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: "ldg_savings", Currency: "BTC"})
+	require.NoError(t, err)
+	// 1 BTC in satoshis
+	_, _, err = client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: "deposit", PreciseAmount: big.NewInt(100000000), Precision: 100000000, Currency: "BTC", Source: "@world", Destination: wallet.BalanceID,
+	}})
+	require.NoError(t, err)
+	server.SetClock(func() time.Time { return date(2024, 3, 1).Add(10 * time.Hour) })
+
+	engine := interest.NewEngine(client, "@interest-expense", flatRate(t, "3.65%"))
+	_, created, err := engine.Run(wallet.BalanceID, date(2024, 1, 2), date(2024, 1, 3))
+	require.NoError(t, err)
+	require.Len(t, created, 1)
+	assert.Equal(t, int64(100000000), created[0].Precision)
+	assert.Equal(t, big.NewInt(10000), created[0].PreciseAmount)
+	assert.InDelta(t, 0.0001, created[0].Amount, 1e-12)
+
+	balance, _ := server.Balance(wallet.BalanceID)
+	assert.Equal(t, int64(100010000), balance.Balance.Int64())
+
+	// an explicit precision overrides the balance's
+	engine.Precision = 100
+	engine.ReferencePrefix = "override"
+	_, created, err = engine.Run(wallet.BalanceID, date(2024, 1, 2), date(2024, 1, 3))
+	require.NoError(t, err)
+	require.Len(t, created, 1)
+	assert.Equal(t, int64(100), created[0].Precision)
+}
+
+func TestEngine_TimeSeriesSource(t *testing.T) {
+	series := &blnkgo.TimeSeries{BalanceID: "bln_1", Points: []blnkgo.BalancePoint{
+		{Timestamp: date(2024, 1, 1), Balance: big.NewInt(0)},
+		{Timestamp: date(2024, 1, 2), Balance: big.NewInt(730000)},
+		{Timestamp: date(2024, 1, 3), Balance: big.NewInt(1460000)},
+	}}
+	engine := interest.NewEngine(nil, "@interest-expense", flatRate(t, "0.05"))
+	engine.Balances = interest.TimeSeriesSource{Series: series}
+
+	accruals, err := engine.Accrue("bln_1", date(2024, 1, 1), date(2024, 1, 4))
+
+	require.NoError(t, err)
+	require.Len(t, accruals, 3)
+	assert.Equal(t, int64(100), accruals[0].Amount.Int64())
+	assert.Equal(t, int64(200), accruals[1].Amount.Int64())
+	assert.Equal(t, int64(200), accruals[2].Amount.Int64())
+
+	_, err = engine.Accrue("bln_2", date(2024, 1, 1), date(2024, 1, 4))
+	assert.Error(t, err)
+}
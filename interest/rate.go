@@ -0,0 +1,151 @@
+package interest
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DayCount is the convention that turns a number of days into a fraction of a
+// year.
+type DayCount int
+
+const (
+	// Actual365 counts actual calendar days over a 365-day year (ACT/365 Fixed).
+	Actual365 DayCount = iota
+	// Thirty360 counts every month as 30 days over a 360-day year (30/360 US).
+	Thirty360
+)
+
+func (d DayCount) String() string {
+	switch d {
+	case Actual365:
+		return "ACT/365"
+	case Thirty360:
+		return "30/360"
+	}
+	return fmt.Sprintf("DayCount(%d)", int(d))
+}
+
+// YearFraction returns the fraction of a year between the dates of start and
+// end. Times of day are ignored.
+func (d DayCount) YearFraction(start, end time.Time) *big.Rat {
+	y1, m1, d1 := start.Date()
+	y2, m2, d2 := end.Date()
+
+	if d == Thirty360 {
+		if d1 == 31 {
+			d1 = 30
+		}
+		if d2 == 31 && d1 == 30 {
+			d2 = 30
+		}
+		days := 360*(y2-y1) + 30*(int(m2)-int(m1)) + (d2 - d1)
+		return big.NewRat(int64(days), 360)
+	}
+
+	// counting in UTC keeps days 24 hours long across DST changes
+	days := time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC).Sub(time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour)
+	return big.NewRat(int64(days), 365)
+}
+
+// ParseRate parses an annual rate written as a fraction ("0.035") or a
+// percentage ("3.5%").
+func ParseRate(value string) (*big.Rat, error) {
+	value = strings.TrimSpace(value)
+	percent := strings.HasSuffix(value, "%")
+	rate, ok := new(big.Rat).SetString(strings.TrimSuffix(value, "%"))
+	if !ok || rate.Sign() < 0 {
+		return nil, fmt.Errorf("invalid rate %q", value)
+	}
+	if percent {
+		rate.Quo(rate, big.NewRat(100, 1))
+	}
+	return rate, nil
+}
+
+// Tier is an annual rate that applies from a balance, in minor units, upwards.
+type Tier struct {
+	From *big.Int
+	Rate *big.Rat
+}
+
+// TierMode decides how tiers combine.
+type TierMode int
+
+const (
+	// Banded applies each tier's rate to the part of the balance within the
+	// tier, like income tax brackets.
+	Banded TierMode = iota
+	// WholeBalance applies the rate of the highest tier reached to the whole
+	// balance.
+	WholeBalance
+)
+
+// FlatRate returns a single tier that applies rate, parsed with ParseRate, to
+// every balance.
+func FlatRate(rate string) ([]Tier, error) {
+	value, err := ParseRate(rate)
+	if err != nil {
+		return nil, err
+	}
+	return []Tier{{From: new(big.Int), Rate: value}}, nil
+}
+
+func validateTiers(tiers []Tier) error {
+	if len(tiers) == 0 {
+		return fmt.Errorf("at least one tier is required")
+	}
+	for i, tier := range tiers {
+		if tier.From == nil || tier.Rate == nil {
+			return fmt.Errorf("tier %d needs From and Rate", i)
+		}
+		if tier.From.Sign() < 0 || tier.Rate.Sign() < 0 {
+			return fmt.Errorf("tier %d has a negative From or Rate", i)
+		}
+	}
+	return nil
+}
+
+// AnnualInterest returns a year of interest on balance at the tiered rates.
+// Balances that are zero or negative earn nothing.
+func AnnualInterest(balance *big.Rat, tiers []Tier, mode TierMode) *big.Rat {
+	interest := new(big.Rat)
+	if balance.Sign() <= 0 {
+		return interest
+	}
+
+	sorted := append([]Tier(nil), tiers...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].From.Cmp(sorted[j].From) < 0 })
+
+	if mode == WholeBalance {
+		var rate *big.Rat
+		for _, tier := range sorted {
+			if new(big.Rat).SetInt(tier.From).Cmp(balance) <= 0 {
+				rate = tier.Rate
+			}
+		}
+		if rate != nil {
+			interest.Mul(balance, rate)
+		}
+		return interest
+	}
+
+	for i, tier := range sorted {
+		lower := new(big.Rat).SetInt(tier.From)
+		if lower.Cmp(balance) >= 0 {
+			break
+		}
+		upper := balance
+		if i+1 < len(sorted) {
+			if next := new(big.Rat).SetInt(sorted[i+1].From); next.Cmp(balance) < 0 {
+				upper = next
+			}
+		}
+		band := new(big.Rat).Sub(upper, lower)
+		interest.Add(interest, band.Mul(band, tier.Rate))
+	}
+	return interest
+}
@@ -82,6 +82,12 @@ func (s *LedgerService) Filter(body FilterParams) (*FilterResponse, *http.Respon
 	return &filterResponse, resp, nil
 }
 
+// FilterAll pages through every ledger matching params. params.Limit is used as
+// the page size.
+func (s *LedgerService) FilterAll(params FilterParams) ([]Ledger, error) {
+	return filterAll[Ledger](s.Filter, params)
+}
+
 func NewLedgerService(c ClientInterface) *LedgerService {
 	return &LedgerService{client: c}
 }
@@ -105,6 +105,12 @@ func (s *LedgerBalanceService) Filter(params FilterParams) (*FilterResponse, *ht
 	return &filterResponse, resp, nil
 }
 
+// FilterAll pages through every balance matching params. params.Limit is used as
+// the page size.
+func (s *LedgerBalanceService) FilterAll(params FilterParams) ([]LedgerBalance, error) {
+	return filterAll[LedgerBalance](s.Filter, params)
+}
+
 func NewLedgerBalanceService(c ClientInterface) *LedgerBalanceService {
 	return &LedgerBalanceService{client: c}
 }
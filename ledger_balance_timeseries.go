@@ -0,0 +1,262 @@
+package blnkgo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// maxTimeSeriesPoints guards against accidentally requesting a huge number of
+// historical balances, e.g. a one second interval over a year.
+const maxTimeSeriesPoints = 10000
+
+// BalancePoint is the state of a balance at a point in time.
+type BalancePoint struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Balance       *big.Int  `json:"balance"`
+	CreditBalance *big.Int  `json:"credit_balance"`
+	DebitBalance  *big.Int  `json:"debit_balance"`
+	Currency      string    `json:"currency"`
+	FromSource    bool      `json:"from_source"`
+}
+
+// TimeSeries holds the historical balances of a balance at regular intervals.
+type TimeSeries struct {
+	BalanceID string         `json:"balance_id"`
+	From      time.Time      `json:"from"`
+	To        time.Time      `json:"to"`
+	Interval  time.Duration  `json:"interval"`
+	Points    []BalancePoint `json:"points"`
+}
+
+// PeriodDelta summarizes the movement of a balance between two points.
+type PeriodDelta struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Opening *big.Int  `json:"opening"`
+	Closing *big.Int  `json:"closing"`
+	Credits *big.Int  `json:"credits"`
+	Debits  *big.Int  `json:"debits"`
+	Net     *big.Int  `json:"net"`
+}
+
+// HistoricalBalanceCache stores historical balances between calls so repeated
+// time series over overlapping ranges only fetch the missing points.
+type HistoricalBalanceCache interface {
+	Get(key string) (*LedgerBalanceHistorical, bool)
+	Set(key string, value *LedgerBalanceHistorical)
+}
+
+// MemoryHistoricalCache is an in-memory HistoricalBalanceCache safe for
+// concurrent use.
+type MemoryHistoricalCache struct {
+	entries sync.Map
+}
+
+func NewMemoryHistoricalCache() *MemoryHistoricalCache {
+	return &MemoryHistoricalCache{}
+}
+
+func (c *MemoryHistoricalCache) Get(key string) (*LedgerBalanceHistorical, bool) {
+	value, ok := c.entries.Load(key)
+	if !ok {
+		return nil, false
+	}
+	return value.(*LedgerBalanceHistorical), true
+}
+
+func (c *MemoryHistoricalCache) Set(key string, value *LedgerBalanceHistorical) {
+	c.entries.Store(key, value)
+}
+
+// Clear removes every cached balance, e.g. after backdated transactions have
+// changed the history of a balance.
+func (c *MemoryHistoricalCache) Clear() {
+	c.entries.Range(func(key, _ interface{}) bool {
+		c.entries.Delete(key)
+		return true
+	})
+}
+
+type timeSeriesOptions struct {
+	fromSource  bool
+	concurrency int
+	cache       HistoricalBalanceCache
+}
+
+type TimeSeriesOption func(*timeSeriesOptions)
+
+// WithFromSource recomputes every point from the balance's transactions instead
+// of using snapshots.
+func WithFromSource() TimeSeriesOption {
+	return func(o *timeSeriesOptions) {
+		o.fromSource = true
+	}
+}
+
+// WithConcurrency sets how many historical balances are fetched in parallel.
+// The default is 4.
+func WithConcurrency(n int) TimeSeriesOption {
+	return func(o *timeSeriesOptions) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+// WithHistoryCache reuses historical balances across calls. Only points in the
+// past are cached.
+func WithHistoryCache(cache HistoricalBalanceCache) TimeSeriesOption {
+	return func(o *timeSeriesOptions) {
+		o.cache = cache
+	}
+}
+
+// BalanceTimeSeries fetches the historical balance at from, every interval after
+// it and at to, using GetHistorical with bounded parallelism.
+func (s *LedgerBalanceService) BalanceTimeSeries(balanceID string, from, to time.Time, interval time.Duration, opts ...TimeSeriesOption) (*TimeSeries, error) {
+	if balanceID == "" {
+		return nil, fmt.Errorf("invalid: balanceID is required")
+	}
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return nil, fmt.Errorf("invalid: from must be before to")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid: interval must be positive")
+	}
+
+	options := timeSeriesOptions{concurrency: 4}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var timestamps []time.Time
+	for ts := from; ts.Before(to); ts = ts.Add(interval) {
+		timestamps = append(timestamps, ts)
+		if len(timestamps) > maxTimeSeriesPoints {
+			return nil, fmt.Errorf("invalid: time series would have more than %d points", maxTimeSeriesPoints)
+		}
+	}
+	timestamps = append(timestamps, to)
+
+	points := make([]BalancePoint, len(timestamps))
+	errs := make([]error, len(timestamps))
+	sem := make(chan struct{}, options.concurrency)
+	var wg sync.WaitGroup
+	now := time.Now()
+
+	for i, ts := range timestamps {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, ts time.Time) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			key := fmt.Sprintf("%s|%s|%t", balanceID, ts.UTC().Format(time.RFC3339Nano), options.fromSource)
+			historical, ok := (*LedgerBalanceHistorical)(nil), false
+			if options.cache != nil {
+				historical, ok = options.cache.Get(key)
+			}
+			if !ok {
+				var err error
+				historical, _, err = s.GetHistorical(balanceID, ts, options.fromSource)
+				if err != nil {
+					errs[i] = fmt.Errorf("fetching balance at %s: %w", ts.Format(time.RFC3339), err)
+					return
+				}
+				if options.cache != nil && ts.Before(now) {
+					options.cache.Set(key, historical)
+				}
+			}
+
+			points[i] = BalancePoint{
+				Timestamp:     ts,
+				Balance:       ValueOrZero(historical.Balance.Balance),
+				CreditBalance: ValueOrZero(historical.Balance.CreditBalance),
+				DebitBalance:  ValueOrZero(historical.Balance.DebitBalance),
+				Currency:      historical.Balance.Currency,
+				FromSource:    historical.FromSource,
+			}
+		}(i, ts)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &TimeSeries{
+		BalanceID: balanceID,
+		From:      from,
+		To:        to,
+		Interval:  interval,
+		Points:    points,
+	}, nil
+}
+
+// Deltas returns the movement between each pair of consecutive points.
+func (ts *TimeSeries) Deltas() []PeriodDelta {
+	if len(ts.Points) < 2 {
+		return nil
+	}
+	deltas := make([]PeriodDelta, 0, len(ts.Points)-1)
+	for i := 1; i < len(ts.Points); i++ {
+		deltas = append(deltas, newPeriodDelta(ts.Points[i-1], ts.Points[i]))
+	}
+	return deltas
+}
+
+// Summary returns the movement between the first and the last point.
+func (ts *TimeSeries) Summary() PeriodDelta {
+	if len(ts.Points) == 0 {
+		zero := new(big.Int)
+		return PeriodDelta{Start: ts.From, End: ts.To, Opening: zero, Closing: zero, Credits: zero, Debits: zero, Net: zero}
+	}
+	return newPeriodDelta(ts.Points[0], ts.Points[len(ts.Points)-1])
+}
+
+func newPeriodDelta(start, end BalancePoint) PeriodDelta {
+	return PeriodDelta{
+		Start:   start.Timestamp,
+		End:     end.Timestamp,
+		Opening: new(big.Int).Set(start.Balance),
+		Closing: new(big.Int).Set(end.Balance),
+		Credits: new(big.Int).Sub(end.CreditBalance, start.CreditBalance),
+		Debits:  new(big.Int).Sub(end.DebitBalance, start.DebitBalance),
+		Net:     new(big.Int).Sub(end.Balance, start.Balance),
+	}
+}
+
+// WriteCSV writes one row per point, with amounts in their exact minor units.
+func (ts *TimeSeries) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"timestamp", "balance_id", "currency", "balance", "credit_balance", "debit_balance"}); err != nil {
+		return err
+	}
+	for _, point := range ts.Points {
+		record := []string{
+			point.Timestamp.Format(time.RFC3339),
+			ts.BalanceID,
+			point.Currency,
+			point.Balance.String(),
+			point.CreditBalance.String(),
+			point.DebitBalance.String(),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON writes the time series as a JSON document.
+func (ts *TimeSeries) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(ts)
+}
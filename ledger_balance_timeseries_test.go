@@ -0,0 +1,129 @@
+package blnkgo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// historyServer serves a balance that is credited 1000 and debited 250 every day
+// from 2024-01-01.
+func historyServer(t *testing.T, calls *int32) *blnkgo.Client {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if !strings.HasPrefix(r.URL.Path, "/balances/bln_1/at") {
+			http.NotFound(w, r)
+			return
+		}
+		ts, err := time.Parse(time.RFC3339, r.URL.Query().Get("timestamp"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		days := int64(ts.Sub(start).Hours() / 24)
+		credit := new(big.Int).Mul(big.NewInt(days), big.NewInt(1000))
+		credit.Add(credit, new(big.Int).Lsh(big.NewInt(1), 70))
+		debit := big.NewInt(days * 250)
+		fmt.Fprintf(w, `{"balance":{"balance_id":"bln_1","currency":"USD","balance":%s,"credit_balance":%s,"debit_balance":%s},"from_source":%t,"timestamp":"%s"}`,
+			new(big.Int).Sub(credit, debit), credit, debit, r.URL.Query().Get("from_source") == "true", ts.Format(time.RFC3339))
+	}))
+	t.Cleanup(server.Close)
+	baseURL, _ := url.Parse(server.URL)
+	return blnkgo.NewClient(baseURL, nil)
+}
+
+func TestLedgerBalanceService_BalanceTimeSeries(t *testing.T) {
+	var calls int32
+	client := historyServer(t, &calls)
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, time.January, 4, 12, 0, 0, 0, time.UTC)
+
+	series, err := client.LedgerBalance.BalanceTimeSeries("bln_1", from, to, 24*time.Hour, blnkgo.WithConcurrency(2), blnkgo.WithFromSource())
+
+	require.NoError(t, err)
+	require.Len(t, series.Points, 5)
+	assert.True(t, series.Points[0].Timestamp.Equal(from))
+	assert.True(t, series.Points[4].Timestamp.Equal(to))
+	assert.True(t, series.Points[1].FromSource)
+	assert.Equal(t, "1180591620717411304424", series.Points[1].CreditBalance.String())
+
+	deltas := series.Deltas()
+	require.Len(t, deltas, 4)
+	assert.Equal(t, big.NewInt(1000), deltas[0].Credits)
+	assert.Equal(t, big.NewInt(250), deltas[0].Debits)
+	assert.Equal(t, big.NewInt(750), deltas[0].Net)
+
+	summary := series.Summary()
+	assert.Equal(t, big.NewInt(3000), summary.Credits)
+	assert.Equal(t, big.NewInt(750), summary.Debits)
+	assert.Equal(t, new(big.Int).Add(summary.Opening, summary.Net), summary.Closing)
+}
+
+func TestLedgerBalanceService_BalanceTimeSeries_Cache(t *testing.T) {
+	var calls int32
+	client := historyServer(t, &calls)
+	cache := blnkgo.NewMemoryHistoricalCache()
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(48 * time.Hour)
+
+	_, err := client.LedgerBalance.BalanceTimeSeries("bln_1", from, to, 24*time.Hour, blnkgo.WithHistoryCache(cache))
+	require.NoError(t, err)
+	_, err = client.LedgerBalance.BalanceTimeSeries("bln_1", from, to.Add(24*time.Hour), 24*time.Hour, blnkgo.WithHistoryCache(cache))
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	cache.Clear()
+	_, err = client.LedgerBalance.BalanceTimeSeries("bln_1", from, to, 24*time.Hour, blnkgo.WithHistoryCache(cache))
+	require.NoError(t, err)
+	assert.Equal(t, int32(7), atomic.LoadInt32(&calls))
+}
+
+func TestLedgerBalanceService_BalanceTimeSeries_Export(t *testing.T) {
+	var calls int32
+	client := historyServer(t, &calls)
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	series, err := client.LedgerBalance.BalanceTimeSeries("bln_1", from, from.Add(24*time.Hour), 24*time.Hour)
+	require.NoError(t, err)
+
+	var csvBuf bytes.Buffer
+	require.NoError(t, series.WriteCSV(&csvBuf))
+	lines := strings.Split(strings.TrimSpace(csvBuf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, "timestamp,balance_id,currency,balance,credit_balance,debit_balance", lines[0])
+	assert.Equal(t, "2024-01-02T00:00:00Z,bln_1,USD,1180591620717411304174,1180591620717411304424,250", lines[2])
+
+	var jsonBuf bytes.Buffer
+	require.NoError(t, series.WriteJSON(&jsonBuf))
+	var decoded blnkgo.TimeSeries
+	require.NoError(t, json.Unmarshal(jsonBuf.Bytes(), &decoded))
+	assert.Equal(t, series.Points[1].Balance, decoded.Points[1].Balance)
+}
+
+func TestLedgerBalanceService_BalanceTimeSeries_InvalidInput(t *testing.T) {
+	_, svc := setupLedgerBalanceService()
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := svc.BalanceTimeSeries("", from, from.Add(time.Hour), time.Minute)
+	assert.Error(t, err)
+	_, err = svc.BalanceTimeSeries("bln_1", from, from.Add(-time.Hour), time.Minute)
+	assert.Error(t, err)
+	_, err = svc.BalanceTimeSeries("bln_1", from, from.Add(time.Hour), 0)
+	assert.Error(t, err)
+	_, err = svc.BalanceTimeSeries("bln_1", from, from.AddDate(1, 0, 0), time.Second)
+	assert.Error(t, err)
+}
@@ -26,3 +26,45 @@ func NewDefaultLogger() *DefaultLogger {
 		logger: log.Default(),
 	}
 }
+
+// LogLevel selects which messages a LevelLogger writes.
+type LogLevel string
+
+const (
+	LogLevelInfo  LogLevel = "info"
+	LogLevelError LogLevel = "error"
+	LogLevelOff   LogLevel = "off"
+)
+
+// Valid reports whether l is a known level.
+func (l LogLevel) Valid() bool {
+	switch l {
+	case LogLevelInfo, LogLevelError, LogLevelOff:
+		return true
+	}
+	return false
+}
+
+// LevelLogger drops the messages of a Logger below a level.
+type LevelLogger struct {
+	Logger Logger
+	Level  LogLevel
+}
+
+// NewLevelLogger returns a DefaultLogger that only writes messages at level or
+// above.
+func NewLevelLogger(level LogLevel) *LevelLogger {
+	return &LevelLogger{Logger: NewDefaultLogger(), Level: level}
+}
+
+func (l *LevelLogger) Info(msg string) {
+	if l.Level == LogLevelInfo {
+		l.Logger.Info(msg)
+	}
+}
+
+func (l *LevelLogger) Error(msg string) {
+	if l.Level == LogLevelInfo || l.Level == LogLevelError {
+		l.Logger.Error(msg)
+	}
+}
@@ -1,11 +1,20 @@
 package blnkgo
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
+	"strings"
 )
 
-type MetadataService service
+type MetadataService struct {
+	client ClientInterface
+	// Schemas validates the metadata written by UpdateTypedMetadata. Nil
+	// means DefaultMetadataSchemas.
+	Schemas *MetadataSchemas
+}
 
 type UpdateMetaDataRequest struct {
 	MetaData map[string]interface{} `json:"meta_data"`
@@ -36,6 +45,215 @@ func (s *MetadataService) UpdateMetadata(entityID string, body UpdateMetaDataReq
 	return response, resp, nil
 }
 
+// EntityKind is the kind of entity that carries metadata, told apart by the
+// prefix of its ID.
+type EntityKind string
+
+const (
+	EntityLedger      EntityKind = "ledger"
+	EntityBalance     EntityKind = "balance"
+	EntityTransaction EntityKind = "transaction"
+	EntityIdentity    EntityKind = "identity"
+)
+
+var entityPrefixes = []struct {
+	prefix   string
+	kind     EntityKind
+	endpoint string
+}{
+	{"ldg_", EntityLedger, "ledgers"},
+	{"bln_", EntityBalance, "balances"},
+	{"txn_", EntityTransaction, "transactions"},
+	{"idt_", EntityIdentity, "identities"},
+}
+
+// EntityKindOf returns the kind of entityID from its prefix: ldg_, bln_, txn_
+// or idt_.
+func EntityKindOf(entityID string) (EntityKind, error) {
+	kind, _, err := entityOf(entityID)
+	return kind, err
+}
+
+func entityOf(entityID string) (EntityKind, string, error) {
+	for _, p := range entityPrefixes {
+		if strings.HasPrefix(entityID, p.prefix) && len(entityID) > len(p.prefix) {
+			return p.kind, p.endpoint, nil
+		}
+	}
+	return "", "", fmt.Errorf("invalid entity ID %q, want a ldg_, bln_, txn_ or idt_ prefix", entityID)
+}
+
+// GetMetadata reads the metadata of a ledger, balance, transaction or
+// identity. Blnk has no metadata read endpoint, so the entity itself is
+// fetched. Keys whose value is null are left out, since that is how
+// DeleteKeys and MergeMetadata remove keys.
+func (s *MetadataService) GetMetadata(entityID string) (map[string]interface{}, *http.Response, error) {
+	_, endpoint, err := entityOf(entityID)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := s.client.NewRequest(fmt.Sprintf("%s/%s", endpoint, entityID), http.MethodGet, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var entity struct {
+		MetaData map[string]interface{} `json:"meta_data"`
+	}
+	resp, err := s.client.CallWithRetry(req, &entity)
+	if err != nil {
+		return nil, resp, err
+	}
+	metaData := make(map[string]interface{}, len(entity.MetaData))
+	for key, value := range entity.MetaData {
+		if value != nil {
+			metaData[key] = value
+		}
+	}
+	return metaData, resp, nil
+}
+
+// DeleteKeys removes keys from the metadata of an entity. Blnk merges metadata
+// updates into the existing metadata and has no way to drop a key, so the keys
+// are set to null: Blnk keeps them with null values, which GetMetadata and
+// MergeMetadata treat as absent but the entity itself still returns.
+func (s *MetadataService) DeleteKeys(entityID string, keys ...string) (*Metadata, *http.Response, error) {
+	if _, err := EntityKindOf(entityID); err != nil {
+		return nil, nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("at least one key is required")
+	}
+	update := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		update[key] = nil
+	}
+	return s.UpdateMetadata(entityID, UpdateMetaDataRequest{MetaData: update})
+}
+
+// MergeStrategy decides what MergeMetadata does when the patch changes a value
+// that is already set.
+type MergeStrategy int
+
+const (
+	// MergeOverwrite lets the patch win.
+	MergeOverwrite MergeStrategy = iota
+	// MergeFailOnConflict fails instead of changing an existing value.
+	MergeFailOnConflict
+)
+
+// MetadataConflictError lists the keys MergeMetadata could not merge, as dotted
+// paths.
+type MetadataConflictError struct {
+	EntityID string
+	Keys     []string
+}
+
+func (e *MetadataConflictError) Error() string {
+	return fmt.Sprintf("metadata of %s conflicts at %s", e.EntityID, strings.Join(e.Keys, ", "))
+}
+
+// MergeMetadata deep merges patch into the current metadata of an entity:
+// nested objects are merged key by key instead of being replaced, and nil
+// values delete keys. A deleted top-level key is stored as null, as with
+// DeleteKeys; a deleted nested key is dropped, since its whole top-level
+// object is sent again. An object that meets a non-object is always a conflict;
+// other changed values are conflicts with MergeFailOnConflict. Only the
+// top-level keys that changed are sent. The read and the write are separate
+// requests, so a concurrent update between them can be overwritten.
+func (s *MetadataService) MergeMetadata(entityID string, patch map[string]interface{}, strategy MergeStrategy) (map[string]interface{}, *http.Response, error) {
+	current, resp, err := s.GetMetadata(entityID)
+	if err != nil {
+		return nil, resp, err
+	}
+	normalized, err := normalizeJSON(patch)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid metadata patch: %w", err)
+	}
+
+	var conflicts []string
+	merged := deepMerge(current, normalized.(map[string]interface{}), strategy, "", &conflicts)
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, nil, &MetadataConflictError{EntityID: entityID, Keys: conflicts}
+	}
+
+	changed := make(map[string]interface{})
+	for key := range normalized.(map[string]interface{}) {
+		value, ok := merged[key]
+		if !ok {
+			if _, existed := current[key]; existed {
+				changed[key] = nil
+			}
+			continue
+		}
+		if !reflect.DeepEqual(current[key], value) {
+			changed[key] = value
+		}
+	}
+	if len(changed) == 0 {
+		return merged, resp, nil
+	}
+	_, resp, err = s.UpdateMetadata(entityID, UpdateMetaDataRequest{MetaData: changed})
+	if err != nil {
+		return nil, resp, err
+	}
+	return merged, resp, nil
+}
+
+func deepMerge(current, patch map[string]interface{}, strategy MergeStrategy, path string, conflicts *[]string) map[string]interface{} {
+	merged := make(map[string]interface{}, len(current)+len(patch))
+	for k, v := range current {
+		merged[k] = v
+	}
+	for key, value := range patch {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		existing, ok := current[key]
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		if !ok || existing == nil {
+			merged[key] = value
+			continue
+		}
+		existingObject, existingIsObject := existing.(map[string]interface{})
+		valueObject, valueIsObject := value.(map[string]interface{})
+		switch {
+		case existingIsObject && valueIsObject:
+			merged[key] = deepMerge(existingObject, valueObject, strategy, keyPath, conflicts)
+		case existingIsObject != valueIsObject:
+			*conflicts = append(*conflicts, keyPath)
+		case !reflect.DeepEqual(existing, value):
+			if strategy == MergeFailOnConflict {
+				*conflicts = append(*conflicts, keyPath)
+				continue
+			}
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// normalizeJSON round-trips v through JSON so it compares equal to decoded
+// responses.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	if _, ok := out.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("metadata must be a JSON object")
+	}
+	return out, nil
+}
+
 func NewMetadataService(client ClientInterface) *MetadataService {
 	return &MetadataService{client: client}
 }
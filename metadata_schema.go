@@ -0,0 +1,393 @@
+package blnkgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Schema is a JSON Schema restricted to the keywords metadata needs: type,
+// properties, required, additionalProperties, items, enum, const, minimum,
+// maximum, minLength, maxLength, pattern, minItems and maxItems. Annotations
+// such as title and description are ignored; any other keyword is rejected
+// when the schema is parsed, so a schema is never silently half-enforced.
+type Schema struct {
+	Types                []string
+	Properties           map[string]*Schema
+	Required             []string
+	AdditionalProperties *Schema
+	NoAdditional         bool
+	Items                *Schema
+	Enum                 []interface{}
+	Const                interface{}
+	HasConst             bool
+	Minimum, Maximum     *float64
+	MinLength, MaxLength *int
+	Pattern              *regexp.Regexp
+	MinItems, MaxItems   *int
+}
+
+var schemaAnnotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true,
+	"description": true, "default": true, "examples": true,
+}
+
+// ParseSchema parses a JSON Schema document.
+func ParseSchema(data []byte) (*Schema, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return parseSchema(raw, "#")
+}
+
+func parseSchema(raw interface{}, at string) (*Schema, error) {
+	object, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema at %s must be an object", at)
+	}
+	schema := &Schema{}
+	for keyword, value := range object {
+		var err error
+		switch keyword {
+		case "type":
+			switch value := value.(type) {
+			case string:
+				schema.Types = []string{value}
+			case []interface{}:
+				for _, t := range value {
+					name, ok := t.(string)
+					if !ok {
+						return nil, fmt.Errorf("schema at %s: type must be a string or a list of strings", at)
+					}
+					schema.Types = append(schema.Types, name)
+				}
+			default:
+				return nil, fmt.Errorf("schema at %s: type must be a string or a list of strings", at)
+			}
+			for _, t := range schema.Types {
+				switch t {
+				case "object", "array", "string", "number", "integer", "boolean", "null":
+				default:
+					return nil, fmt.Errorf("schema at %s: unknown type %q", at, t)
+				}
+			}
+		case "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("schema at %s: properties must be an object", at)
+			}
+			schema.Properties = make(map[string]*Schema, len(properties))
+			for name, property := range properties {
+				if schema.Properties[name], err = parseSchema(property, at+"/properties/"+name); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("schema at %s: required must be a list", at)
+			}
+			for _, name := range list {
+				name, ok := name.(string)
+				if !ok {
+					return nil, fmt.Errorf("schema at %s: required must list strings", at)
+				}
+				schema.Required = append(schema.Required, name)
+			}
+		case "additionalProperties":
+			if allowed, ok := value.(bool); ok {
+				schema.NoAdditional = !allowed
+			} else if schema.AdditionalProperties, err = parseSchema(value, at+"/additionalProperties"); err != nil {
+				return nil, err
+			}
+		case "items":
+			if schema.Items, err = parseSchema(value, at+"/items"); err != nil {
+				return nil, err
+			}
+		case "enum":
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("schema at %s: enum must be a list", at)
+			}
+			schema.Enum = list
+		case "const":
+			schema.Const, schema.HasConst = value, true
+		case "minimum", "maximum":
+			number, ok := value.(float64)
+			if !ok {
+				return nil, fmt.Errorf("schema at %s: %s must be a number", at, keyword)
+			}
+			if keyword == "minimum" {
+				schema.Minimum = &number
+			} else {
+				schema.Maximum = &number
+			}
+		case "minLength", "maxLength", "minItems", "maxItems":
+			number, ok := value.(float64)
+			if !ok || number < 0 || number != math.Trunc(number) {
+				return nil, fmt.Errorf("schema at %s: %s must be a non-negative integer", at, keyword)
+			}
+			n := int(number)
+			switch keyword {
+			case "minLength":
+				schema.MinLength = &n
+			case "maxLength":
+				schema.MaxLength = &n
+			case "minItems":
+				schema.MinItems = &n
+			case "maxItems":
+				schema.MaxItems = &n
+			}
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("schema at %s: pattern must be a string", at)
+			}
+			if schema.Pattern, err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("schema at %s: invalid pattern: %w", at, err)
+			}
+		default:
+			if !schemaAnnotations[keyword] {
+				return nil, fmt.Errorf("schema at %s: unsupported keyword %q", at, keyword)
+			}
+		}
+	}
+	return schema, nil
+}
+
+// Validate checks a decoded JSON value against the schema and returns every
+// violation, each prefixed with the path of the offending value.
+func (s *Schema) Validate(value interface{}) []string {
+	var problems []string
+	s.validate(value, "$", &problems)
+	return problems
+}
+
+func (s *Schema) validate(value interface{}, path string, problems *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(s.Types) > 0 {
+		matched := false
+		for _, t := range s.Types {
+			if jsonType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("must be %s", strings.Join(s.Types, " or "))
+			return
+		}
+	}
+	if s.HasConst && !reflect.DeepEqual(value, s.Const) {
+		fail("must be %v", s.Const)
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if reflect.DeepEqual(value, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %v", s.Enum)
+		}
+	}
+
+	switch value := value.(type) {
+	case float64:
+		if s.Minimum != nil && value < *s.Minimum {
+			fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && value > *s.Maximum {
+			fail("must be at most %v", *s.Maximum)
+		}
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.MinLength != nil && length < *s.MinLength {
+			fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.Pattern != nil && !s.Pattern.MatchString(value) {
+			fail("must match %s", s.Pattern)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, ok := s.Properties[key]
+			switch {
+			case ok:
+				property.validate(value[key], path+"."+key, problems)
+			case s.AdditionalProperties != nil:
+				s.AdditionalProperties.validate(value[key], path+"."+key, problems)
+			case s.NoAdditional:
+				fail("unexpected property %q", key)
+			}
+		}
+	}
+}
+
+func jsonType(value interface{}, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+// SchemaValidationError is returned when metadata does not match the schema
+// registered for its entity kind.
+type SchemaValidationError struct {
+	Kind     EntityKind
+	Problems []string
+}
+
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("%s metadata does not match its schema: %s", e.Kind, strings.Join(e.Problems, "; "))
+}
+
+// MetadataSchemas holds one schema per entity kind.
+type MetadataSchemas struct {
+	mu      sync.RWMutex
+	schemas map[EntityKind]*Schema
+}
+
+func NewMetadataSchemas() *MetadataSchemas {
+	return &MetadataSchemas{schemas: make(map[EntityKind]*Schema)}
+}
+
+// DefaultMetadataSchemas is the registry used by UpdateTypedMetadata when the
+// MetadataService has no Schemas of its own.
+var DefaultMetadataSchemas = NewMetadataSchemas()
+
+// Register parses schema and uses it for the metadata of kind, replacing any
+// schema registered before.
+func (r *MetadataSchemas) Register(kind EntityKind, schema []byte) error {
+	parsed, err := ParseSchema(schema)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schemas[kind] = parsed
+	return nil
+}
+
+// Validate checks metadata against the schema of kind. Kinds without a schema
+// accept anything.
+func (r *MetadataSchemas) Validate(kind EntityKind, metaData map[string]interface{}) error {
+	r.mu.RLock()
+	schema, ok := r.schemas[kind]
+	r.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	normalized, err := normalizeJSON(metaData)
+	if err != nil {
+		return err
+	}
+	if problems := schema.Validate(normalized); len(problems) > 0 {
+		return &SchemaValidationError{Kind: kind, Problems: problems}
+	}
+	return nil
+}
+
+// RegisterMetadataSchema registers schema for kind in DefaultMetadataSchemas.
+func RegisterMetadataSchema(kind EntityKind, schema []byte) error {
+	return DefaultMetadataSchemas.Register(kind, schema)
+}
+
+// UpdateTypedMetadata sets the fields of value, a struct or map that marshals
+// to a JSON object, as metadata keys of an entity. The object is validated
+// against the schema registered for the entity's kind in s.Schemas, or in
+// DefaultMetadataSchemas if it is nil, first. Keys outside value are left
+// alone.
+func UpdateTypedMetadata[T any](s *MetadataService, entityID string, value T) (*Metadata, *http.Response, error) {
+	kind, err := EntityKindOf(entityID)
+	if err != nil {
+		return nil, nil, err
+	}
+	normalized, err := normalizeJSON(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	metaData := normalized.(map[string]interface{})
+	schemas := s.Schemas
+	if schemas == nil {
+		schemas = DefaultMetadataSchemas
+	}
+	if err := schemas.Validate(kind, metaData); err != nil {
+		return nil, nil, err
+	}
+	return s.UpdateMetadata(entityID, UpdateMetaDataRequest{MetaData: metaData})
+}
+
+// GetTypedMetadata reads the metadata of an entity into a T. Keys without a
+// matching field are ignored.
+func GetTypedMetadata[T any](s *MetadataService, entityID string) (*T, *http.Response, error) {
+	metaData, resp, err := s.GetMetadata(entityID)
+	if err != nil {
+		return nil, resp, err
+	}
+	data, err := json.Marshal(metaData)
+	if err != nil {
+		return nil, resp, err
+	}
+	value := new(T)
+	if err := json.Unmarshal(data, value); err != nil {
+		return nil, resp, fmt.Errorf("failed to decode metadata of %s: %w", entityID, err)
+	}
+	return value, resp, nil
+}
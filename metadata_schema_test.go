@@ -0,0 +1,119 @@
+package blnkgo_test
+
+import (
+	"testing"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const walletSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"title": "Wallet metadata",
+	"type": "object",
+	"required": ["tier", "owner"],
+	"additionalProperties": false,
+	"properties": {
+		"tier": {"enum": ["basic", "gold"]},
+		"owner": {"type": "string", "minLength": 1, "pattern": "^usr_"},
+		"limit": {"type": "integer", "minimum": 0, "maximum": 10000},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+	}
+}`
+
+func TestParseSchema(t *testing.T) {
+	schema, err := blnkgo.ParseSchema([]byte(walletSchema))
+	require.NoError(t, err)
+
+	assert.Empty(t, schema.Validate(map[string]interface{}{"tier": "gold", "owner": "usr_1", "tags": []interface{}{"vip"}}))
+	assert.Equal(t, []string{
+		"$: missing required property \"owner\"",
+		"$.limit: must be at most 10000",
+		"$: unexpected property \"nickname\"",
+		"$.tags: must have at most 2 items",
+		"$.tags[1]: must be string",
+		"$.tier: must be one of [basic gold]",
+	}, schema.Validate(map[string]interface{}{
+		"tier":     "platinum",
+		"limit":    float64(20000),
+		"nickname": "x",
+		"tags":     []interface{}{"a", float64(1), "c"},
+	}))
+	assert.Equal(t, []string{"$: must be object"}, schema.Validate("gold"))
+
+	for _, bad := range []string{
+		`[]`,
+		`{"type": "decimal"}`,
+		`{"oneOf": []}`,
+		`{"properties": {"a": {"$ref": "#"}}}`,
+		`{"pattern": "("}`,
+		`{"minLength": -1}`,
+	} {
+		_, err := blnkgo.ParseSchema([]byte(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+type walletMetadata struct {
+	Tier  string   `json:"tier"`
+	Owner string   `json:"owner"`
+	Limit int      `json:"limit,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+func TestUpdateTypedMetadata(t *testing.T) {
+	require.NoError(t, blnkgo.RegisterMetadataSchema(blnkgo.EntityBalance, []byte(walletSchema)))
+	t.Cleanup(func() { blnkgo.RegisterMetadataSchema(blnkgo.EntityBalance, []byte(`{}`)) })
+
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	balance, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: "ldg_1", Currency: "USD"})
+	require.NoError(t, err)
+
+	_, _, err = blnkgo.UpdateTypedMetadata(client.Metadata, balance.BalanceID, walletMetadata{Tier: "gold", Owner: "usr_1", Limit: 500})
+	require.NoError(t, err)
+	wallet, _, err := blnkgo.GetTypedMetadata[walletMetadata](client.Metadata, balance.BalanceID)
+	require.NoError(t, err)
+	assert.Equal(t, walletMetadata{Tier: "gold", Owner: "usr_1", Limit: 500}, *wallet)
+
+	_, _, err = blnkgo.UpdateTypedMetadata(client.Metadata, balance.BalanceID, walletMetadata{Tier: "silver", Owner: "usr_1"})
+	var invalid *blnkgo.SchemaValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, blnkgo.EntityBalance, invalid.Kind)
+	assert.Equal(t, []string{"$.tier: must be one of [basic gold]"}, invalid.Problems)
+	stored, _ := server.Balance(balance.BalanceID)
+	assert.Equal(t, "gold", stored.MetaData["tier"])
+
+	_, _, err = blnkgo.UpdateTypedMetadata(client.Metadata, "acc_1", walletMetadata{Tier: "gold", Owner: "usr_1"})
+	assert.ErrorContains(t, err, "invalid entity ID")
+	_, _, err = blnkgo.UpdateTypedMetadata(client.Metadata, balance.BalanceID, []string{"gold"})
+	assert.ErrorContains(t, err, "JSON object")
+}
+
+func TestUpdateTypedMetadata_ServiceSchemas(t *testing.T) {
+	client := blnktest.NewServer(t).Client()
+	balance, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: "ldg_1", Currency: "USD"})
+	require.NoError(t, err)
+
+	schemas := blnkgo.NewMetadataSchemas()
+	require.NoError(t, schemas.Register(blnkgo.EntityBalance, []byte(walletSchema)))
+	scoped := blnkgo.NewMetadataService(client)
+	scoped.Schemas = schemas
+
+	_, _, err = blnkgo.UpdateTypedMetadata(scoped, balance.BalanceID, walletMetadata{Tier: "silver", Owner: "usr_1"})
+	var invalid *blnkgo.SchemaValidationError
+	assert.ErrorAs(t, err, &invalid)
+
+	// the default registry has no schema for balances
+	_, _, err = blnkgo.UpdateTypedMetadata(client.Metadata, balance.BalanceID, walletMetadata{Tier: "silver", Owner: "usr_1"})
+	assert.NoError(t, err)
+}
+
+func TestMetadataSchemas_UnregisteredKind(t *testing.T) {
+	schemas := blnkgo.NewMetadataSchemas()
+	assert.NoError(t, schemas.Validate(blnkgo.EntityLedger, map[string]interface{}{"anything": true}))
+	require.NoError(t, schemas.Register(blnkgo.EntityLedger, []byte(`{"properties": {"anything": {"type": "string"}}}`)))
+	assert.Error(t, schemas.Validate(blnkgo.EntityLedger, map[string]interface{}{"anything": true}))
+}
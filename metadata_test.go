@@ -3,8 +3,10 @@ package blnkgo_test
 import (
 	"errors"
 	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"testing"
 )
@@ -141,3 +143,101 @@ func TestTransactionService_UpdateMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestMetadataService_GetMergeDelete(t *testing.T) {
+	server := blnktest.NewServer(t)
+	client := server.Client()
+	ledger, _, err := client.Ledger.Create(blnkgo.CreateLedgerRequest{Name: "Fees", MetaData: map[string]interface{}{
+		"owner":   "ops",
+		"limits":  map[string]interface{}{"daily": 100, "monthly": 1000},
+		"retired": false,
+	}})
+	require.NoError(t, err)
+
+	metaData, _, err := client.Metadata.GetMetadata(ledger.LedgerID)
+	require.NoError(t, err)
+	assert.Equal(t, "ops", metaData["owner"])
+
+	merged, _, err := client.Metadata.MergeMetadata(ledger.LedgerID, map[string]interface{}{
+		"limits":  map[string]interface{}{"daily": 200},
+		"retired": nil,
+		"region":  "eu",
+	}, blnkgo.MergeOverwrite)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"daily": float64(200), "monthly": float64(1000)}, merged["limits"])
+	assert.NotContains(t, merged, "retired")
+
+	stored, _, err := client.Metadata.GetMetadata(ledger.LedgerID)
+	require.NoError(t, err)
+	assert.Equal(t, merged, stored)
+
+	_, _, err = client.Metadata.MergeMetadata(ledger.LedgerID, map[string]interface{}{
+		"owner":  "finance",
+		"limits": map[string]interface{}{"daily": 200, "weekly": 700},
+	}, blnkgo.MergeFailOnConflict)
+	var conflict *blnkgo.MetadataConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, []string{"owner"}, conflict.Keys)
+
+	_, _, err = client.Metadata.MergeMetadata(ledger.LedgerID, map[string]interface{}{"owner": map[string]interface{}{"team": "ops"}}, blnkgo.MergeOverwrite)
+	require.ErrorAs(t, err, &conflict, "an object never replaces a scalar")
+
+	_, _, err = client.Metadata.DeleteKeys(ledger.LedgerID, "owner", "region")
+	require.NoError(t, err)
+	stored, _, err = client.Metadata.GetMetadata(ledger.LedgerID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"limits": merged["limits"]}, stored)
+
+	// Blnk keeps deleted keys with null values
+	raw, _, err := client.Ledger.Get(ledger.LedgerID)
+	require.NoError(t, err)
+	assert.Contains(t, raw.MetaData, "owner")
+	assert.Nil(t, raw.MetaData["owner"])
+	assert.Contains(t, raw.MetaData, "retired")
+
+	// a deleted key can be set again
+	merged, _, err = client.Metadata.MergeMetadata(ledger.LedgerID, map[string]interface{}{"owner": "risk"}, blnkgo.MergeFailOnConflict)
+	require.NoError(t, err)
+	assert.Equal(t, "risk", merged["owner"])
+}
+
+func TestMetadataService_Identity(t *testing.T) {
+	client := blnktest.NewServer(t).Client()
+	identity, _, err := client.Identity.Create(blnkgo.Identity{
+		IdentityType: blnkgo.Organization, OrganizationName: "ACME Inc", MetaData: map[string]interface{}{"kyc": "pending"},
+	})
+	require.NoError(t, err)
+
+	merged, _, err := client.Metadata.MergeMetadata(identity.IdentityId, map[string]interface{}{"kyc": "passed", "tier": 2}, blnkgo.MergeOverwrite)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"kyc": "passed", "tier": float64(2)}, merged)
+
+	_, _, err = client.Metadata.DeleteKeys(identity.IdentityId, "tier")
+	require.NoError(t, err)
+	stored, _, err := client.Metadata.GetMetadata(identity.IdentityId)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"kyc": "passed"}, stored)
+}
+
+func TestMetadataService_EntityPrefix(t *testing.T) {
+	mockClient, svc := setupMetdataService()
+
+	for _, id := range []string{"", "ldg_", "entity-123", "acc_1"} {
+		_, _, err := svc.GetMetadata(id)
+		assert.Error(t, err, id)
+		_, _, err = svc.DeleteKeys(id, "key")
+		assert.Error(t, err, id)
+	}
+	_, _, err := svc.DeleteKeys("txn_1")
+	assert.Error(t, err, "no keys")
+	mockClient.AssertNotCalled(t, "NewRequest", mock.Anything, mock.Anything, mock.Anything)
+
+	for id, kind := range map[string]blnkgo.EntityKind{
+		"ldg_1": blnkgo.EntityLedger, "bln_1": blnkgo.EntityBalance,
+		"txn_1": blnkgo.EntityTransaction, "idt_1": blnkgo.EntityIdentity,
+	} {
+		got, err := blnkgo.EntityKindOf(id)
+		require.NoError(t, err)
+		assert.Equal(t, kind, got)
+	}
+}
@@ -0,0 +1,481 @@
+package blnkgo
+
+import (
+	"fmt"
+	"iter"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence yields the occurrences of a recurring schedule.
+type Recurrence interface {
+	// Next returns the first occurrence strictly after the given time, or false
+	// when the recurrence has no more occurrences.
+	Next(after time.Time) (time.Time, bool)
+}
+
+// CronSchedule is a standard 5-field cron expression: minute, hour, day of
+// month, month and day of week. Fields accept *, lists, ranges, steps and
+// month/day names; the @hourly, @daily, @weekly, @monthly and @yearly macros
+// are also accepted. Occurrences are computed in the location of the time
+// passed to Next.
+type CronSchedule struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var cronDayNames = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+// ParseCron parses a 5-field cron expression.
+func ParseCron(expr string) (*CronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+
+	c := &CronSchedule{expr: expr}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("cron expression %q: minute: %w", expr, err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("cron expression %q: hour: %w", expr, err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("cron expression %q: day of month: %w", expr, err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("cron expression %q: month: %w", expr, err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		return nil, fmt.Errorf("cron expression %q: day of week: %w", expr, err)
+	}
+	//7 is an alias for Sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domRestricted = !strings.HasPrefix(fields[2], "*")
+	c.dowRestricted = !strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	value := func(s string) (int, error) {
+		if n, ok := names[strings.ToUpper(s)]; ok {
+			return n, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value %q", s)
+		}
+		if n < min || n > max {
+			return 0, fmt.Errorf("value %d out of range %d-%d", n, min, max)
+		}
+		return n, nil
+	}
+
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		var lo, hi int
+		switch {
+		case rangePart == "*":
+			lo, hi = min, max
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if hi, err = value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			var err error
+			if lo, err = value(rangePart); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if step > 1 {
+				hi = max
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c *CronSchedule) String() string {
+	return c.expr
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	//as in cron, a restricted day of month and day of week match either one
+	if c.domRestricted && c.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Next returns the first minute after the given time matching the expression.
+// It gives up after five years, which only happens for expressions that never
+// match, like 30 February.
+func (c *CronSchedule) Next(after time.Time) (time.Time, bool) {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// RRule is the subset of RFC 5545 recurrence rules that covers billing
+// schedules: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL,
+// BYDAY (without ordinals), BYMONTHDAY (negative values count from the end of
+// the month) and BYMONTH. Occurrences keep the time of day of Start, in its
+// location.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+	// Start is the DTSTART of the rule and its first possible occurrence.
+	Start time.Time
+}
+
+// maxRRulePeriods bounds the search of RRule.Next for rules that never match.
+const maxRRulePeriods = 50000
+
+var rruleDays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// ParseRRule parses a recurrence rule such as "FREQ=MONTHLY;BYMONTHDAY=-1",
+// with or without the "RRULE:" prefix, starting at start.
+func ParseRRule(rule string, start time.Time) (*RRule, error) {
+	r := &RRule{Interval: 1, Start: start}
+	spec := strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if start.IsZero() {
+		return nil, fmt.Errorf("rrule %q: start is required", rule)
+	}
+
+	for _, part := range strings.Split(spec, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("rrule %q: invalid part %q", rule, part)
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		var err error
+		switch key {
+		case "FREQ":
+			switch value {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				r.Freq = value
+			default:
+				err = fmt.Errorf("unsupported FREQ %s", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval <= 0 {
+				err = fmt.Errorf("INTERVAL must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count <= 0 {
+				err = fmt.Errorf("COUNT must be positive")
+			}
+		case "UNTIL":
+			r.Until, err = parseRRuleTime(value)
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := rruleDays[day]
+				if !ok {
+					err = fmt.Errorf("unsupported BYDAY %s", day)
+					break
+				}
+				r.ByDay = append(r.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				n, convErr := strconv.Atoi(day)
+				if convErr != nil || n == 0 || n < -31 || n > 31 {
+					err = fmt.Errorf("invalid BYMONTHDAY %s", day)
+					break
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(value, ",") {
+				n, convErr := strconv.Atoi(month)
+				if convErr != nil || n < 1 || n > 12 {
+					err = fmt.Errorf("invalid BYMONTH %s", month)
+					break
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(n))
+			}
+		default:
+			err = fmt.Errorf("unsupported part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rrule %q: %w", rule, err)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("rrule %q: FREQ is required", rule)
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("rrule %q: COUNT and UNTIL cannot both be set", rule)
+	}
+	return r, nil
+}
+
+func parseRRuleTime(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %s", value)
+}
+
+// Next returns the first occurrence of the rule strictly after the given time.
+func (r *RRule) Next(after time.Time) (time.Time, bool) {
+	for occurrence := range r.All(after) {
+		return occurrence, true
+	}
+	return time.Time{}, false
+}
+
+// All returns the occurrences of the rule strictly after the given time, in
+// order. COUNT is counted from Start, so every call of Next walks the rule from
+// there; All walks it once for any number of occurrences.
+func (r *RRule) All(after time.Time) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		seen := 0
+		for period := 0; period < maxRRulePeriods; period++ {
+			for _, candidate := range r.expand(period) {
+				if candidate.Before(r.Start) {
+					continue
+				}
+				if !r.Until.IsZero() && candidate.After(r.Until) {
+					return
+				}
+				seen++
+				if r.Count > 0 && seen > r.Count {
+					return
+				}
+				if candidate.After(after) && !yield(candidate) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// occurrencesAfter returns the occurrences of recurrence strictly after the
+// given time, through its All method if it has one.
+func occurrencesAfter(recurrence Recurrence, after time.Time) iter.Seq[time.Time] {
+	if all, ok := recurrence.(interface {
+		All(after time.Time) iter.Seq[time.Time]
+	}); ok {
+		return all.All(after)
+	}
+	return func(yield func(time.Time) bool) {
+		for {
+			next, ok := recurrence.Next(after)
+			if !ok || !yield(next) {
+				return
+			}
+			after = next
+		}
+	}
+}
+
+// expand returns the sorted candidate occurrences of the given period, counted
+// in units of FREQ * INTERVAL from Start.
+func (r *RRule) expand(period int) []time.Time {
+	start := r.Start
+	loc := start.Location()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, loc)
+	}
+	step := period * r.Interval
+
+	var candidates []time.Time
+	switch r.Freq {
+	case "DAILY":
+		day := at(start.Year(), start.Month(), start.Day()+step)
+		if r.monthAllowed(day.Month()) && r.monthDayAllowed(day) && r.weekdayAllowed(day.Weekday(), nil) {
+			candidates = append(candidates, day)
+		}
+	case "WEEKLY":
+		offset := (int(start.Weekday()) - int(time.Monday) + 7) % 7
+		weekStart := at(start.Year(), start.Month(), start.Day()-offset+7*step)
+		for i := 0; i < 7; i++ {
+			day := weekStart.AddDate(0, 0, i)
+			if r.monthAllowed(day.Month()) && r.weekdayAllowed(day.Weekday(), []time.Weekday{start.Weekday()}) {
+				candidates = append(candidates, day)
+			}
+		}
+	case "MONTHLY":
+		first := time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, loc)
+		if r.monthAllowed(first.Month()) {
+			for _, day := range r.monthDays(first) {
+				candidates = append(candidates, at(first.Year(), first.Month(), day))
+			}
+		}
+	case "YEARLY":
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{start.Month()}
+		}
+		for _, month := range months {
+			first := time.Date(start.Year()+step, month, 1, 0, 0, 0, 0, loc)
+			for _, day := range r.monthDays(first) {
+				candidates = append(candidates, at(first.Year(), first.Month(), day))
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	return candidates
+}
+
+// monthDays returns the days of the month starting at first that match
+// BYMONTHDAY and BYDAY, or the day of Start when neither is set. Months too
+// short for that day are skipped, as in RFC 5545.
+func (r *RRule) monthDays(first time.Time) []int {
+	last := first.AddDate(0, 1, -1).Day()
+	var days []int
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, day := range r.ByMonthDay {
+			if day < 0 {
+				day = last + day + 1
+			}
+			if day >= 1 && day <= last && r.weekdayAllowed(first.AddDate(0, 0, day-1).Weekday(), nil) {
+				days = append(days, day)
+			}
+		}
+	case len(r.ByDay) > 0:
+		for day := 1; day <= last; day++ {
+			if r.weekdayAllowed(first.AddDate(0, 0, day-1).Weekday(), nil) {
+				days = append(days, day)
+			}
+		}
+	default:
+		if r.Start.Day() <= last {
+			days = append(days, r.Start.Day())
+		}
+	}
+	sort.Ints(days)
+	return days
+}
+
+func (r *RRule) monthAllowed(month time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) monthDayAllowed(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	for _, day := range r.ByMonthDay {
+		if day == t.Day() || last+day+1 == t.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// weekdayAllowed checks BYDAY, falling back to defaults when it is not set; a
+// nil default allows every day.
+func (r *RRule) weekdayAllowed(weekday time.Weekday, defaults []time.Weekday) bool {
+	allowed := r.ByDay
+	if len(allowed) == 0 {
+		if defaults == nil {
+			return true
+		}
+		allowed = defaults
+	}
+	for _, d := range allowed {
+		if d == weekday {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package blnkgo_test
+
+import (
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nextN(t *testing.T, r blnkgo.Recurrence, after time.Time, n int) []string {
+	t.Helper()
+	var out []string
+	for i := 0; i < n; i++ {
+		next, ok := r.Next(after)
+		if !ok {
+			break
+		}
+		out = append(out, next.Format(time.RFC3339))
+		after = next
+	}
+	return out
+}
+
+func TestParseCron(t *testing.T) {
+	start := time.Date(2024, time.January, 30, 10, 15, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr     string
+		expected []string
+	}{
+		{"0 9 * * MON-FRI", []string{"2024-01-31T09:00:00Z", "2024-02-01T09:00:00Z", "2024-02-02T09:00:00Z", "2024-02-05T09:00:00Z"}},
+		{"*/20 10 * * *", []string{"2024-01-30T10:20:00Z", "2024-01-30T10:40:00Z", "2024-01-31T10:00:00Z"}},
+		{"0 0 31 * *", []string{"2024-01-31T00:00:00Z", "2024-03-31T00:00:00Z", "2024-05-31T00:00:00Z"}},
+		{"0 0 1 * 0", []string{"2024-02-01T00:00:00Z", "2024-02-04T00:00:00Z", "2024-02-11T00:00:00Z"}},
+		{"@monthly", []string{"2024-02-01T00:00:00Z", "2024-03-01T00:00:00Z"}},
+		{"30 8 1,15 jan,jul 7", []string{"2024-07-01T08:30:00Z", "2024-07-07T08:30:00Z", "2024-07-14T08:30:00Z", "2024-07-15T08:30:00Z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			cron, err := blnkgo.ParseCron(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, nextN(t, cron, start, len(tt.expected)))
+		})
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * FOO *"} {
+		_, err := blnkgo.ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+
+	never, err := blnkgo.ParseCron("0 0 30 2 *")
+	require.NoError(t, err)
+	_, ok := never.Next(time.Now())
+	assert.False(t, ok)
+}
+
+func TestCronSchedule_Location(t *testing.T) {
+	lagos, err := time.LoadLocation("Africa/Lagos")
+	require.NoError(t, err)
+	cron, err := blnkgo.ParseCron("0 9 * * *")
+	require.NoError(t, err)
+
+	next, ok := cron.Next(time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC).In(lagos))
+
+	require.True(t, ok)
+	assert.Equal(t, "2024-03-02T08:00:00Z", next.UTC().Format(time.RFC3339))
+}
+
+func TestParseRRule(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		rule     string
+		expected []string
+	}{
+		{"FREQ=MONTHLY", []string{"2024-01-31T09:00:00Z", "2024-03-31T09:00:00Z", "2024-05-31T09:00:00Z"}},
+		{"RRULE:FREQ=MONTHLY;BYMONTHDAY=-1", []string{"2024-01-31T09:00:00Z", "2024-02-29T09:00:00Z", "2024-03-31T09:00:00Z"}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", []string{"2024-02-02T09:00:00Z", "2024-02-12T09:00:00Z", "2024-02-16T09:00:00Z"}},
+		{"FREQ=DAILY;COUNT=2", []string{"2024-01-31T09:00:00Z", "2024-02-01T09:00:00Z"}},
+		{"FREQ=DAILY;UNTIL=20240202T090000Z;BYDAY=TH,FR", []string{"2024-02-01T09:00:00Z", "2024-02-02T09:00:00Z"}},
+		{"FREQ=YEARLY;BYMONTH=1,7;BYMONTHDAY=1", []string{"2024-07-01T09:00:00Z", "2025-01-01T09:00:00Z"}},
+		{"FREQ=MONTHLY;BYDAY=SA;BYMONTHDAY=1,2,3,4,5,6,7", []string{"2024-02-03T09:00:00Z", "2024-03-02T09:00:00Z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := blnkgo.ParseRRule(tt.rule, start)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, nextN(t, rule, start.Add(-time.Second), len(tt.expected)))
+		})
+	}
+}
+
+func TestRRule_Exhausted(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC)
+
+	for _, rule := range []string{"FREQ=DAILY;COUNT=2", "FREQ=DAILY;UNTIL=20240201T090000Z"} {
+		r, err := blnkgo.ParseRRule(rule, start)
+		require.NoError(t, err)
+		assert.Len(t, nextN(t, r, start.Add(-time.Second), 10), 2, rule)
+	}
+}
+
+func TestRRule_All(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC)
+	rule, err := blnkgo.ParseRRule("FREQ=DAILY;COUNT=9000", start)
+	require.NoError(t, err)
+
+	var all []time.Time
+	for occurrence := range rule.All(start.AddDate(0, 0, 2)) {
+		all = append(all, occurrence)
+	}
+	require.Len(t, all, 8997)
+	assert.Equal(t, start.AddDate(0, 0, 3), all[0])
+	assert.Equal(t, start.AddDate(0, 0, 8999), all[len(all)-1])
+
+	// RecurringSchedule walks the rule once rather than once per occurrence
+	schedule := blnkgo.RecurringSchedule{ID: "daily", Recurrence: rule}
+	occurrences, err := schedule.Occurrences(start, start.AddDate(30, 0, 0))
+	require.NoError(t, err)
+	assert.Len(t, occurrences, 9000)
+}
+
+func TestParseRRule_Invalid(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for _, rule := range []string{"", "INTERVAL=2", "FREQ=HOURLY", "FREQ=DAILY;COUNT=0", "FREQ=DAILY;BYDAY=1MO", "FREQ=DAILY;COUNT=2;UNTIL=20240101", "FREQ=DAILY;FOO=1"} {
+		_, err := blnkgo.ParseRRule(rule, start)
+		assert.Error(t, err, rule)
+	}
+
+	_, err := blnkgo.ParseRRule("FREQ=DAILY", time.Time{})
+	assert.Error(t, err)
+}
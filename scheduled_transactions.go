@@ -0,0 +1,308 @@
+package blnkgo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ScheduledTransactions lists, cancels and reschedules transactions created with
+// a future ScheduledFor, and posts recurring schedules.
+//
+// Blnk has no endpoint to cancel a queued transaction, so only transactions
+// created inflight can be cancelled: Cancel voids them through the inflight
+// endpoint. ScheduleCancellable and RecurringSchedule.Cancellable create
+// transactions that way. An inflight transaction only holds the funds when it
+// runs, so once it has run it must be committed with Commit, or it is never
+// applied.
+//
+// Whether a Blnk server voids an inflight transaction that is still queued
+// depends on its version. The status is checked right before every void, and
+// a void Blnk refuses, or answers with a status other than VOID, is returned
+// as a *ScheduledVoidError.
+type ScheduledTransactions struct {
+	transactions *TransactionService
+}
+
+// maxScheduleOccurrences bounds the occurrences generated for one window.
+const maxScheduleOccurrences = 10000
+
+// scheduleReferenceLayout formats occurrence times in generated references.
+const scheduleReferenceLayout = "20060102T150405Z"
+
+func NewScheduledTransactions(client ClientInterface) *ScheduledTransactions {
+	return &ScheduledTransactions{transactions: NewTransactionService(client)}
+}
+
+// List returns the queued transactions whose scheduled_for is still in the
+// future, ordered by scheduled_for.
+func (s *ScheduledTransactions) List() ([]Transaction, error) {
+	return s.transactions.FilterAll(FilterParams{
+		Filters: []Filter{
+			{Field: "status", Operator: OpEqual, Value: string(PryTransactionStatusQueued)},
+			{Field: "scheduled_for", Operator: OpGreaterThan, Value: time.Now().UTC().Format(time.RFC3339)},
+		},
+		SortBy:    "scheduled_for",
+		SortOrder: "asc",
+	})
+}
+
+// Schedule creates body to run at the given time. body is created as given, so
+// it can only be cancelled or rescheduled if body.Inflight is set; see
+// ScheduleCancellable.
+func (s *ScheduledTransactions) Schedule(body CreateTransactionRequest, at time.Time) (*Transaction, *http.Response, error) {
+	if !at.After(time.Now()) {
+		return nil, nil, fmt.Errorf("scheduled time %s is not in the future", at.Format(time.RFC3339))
+	}
+	at = at.UTC()
+	body.ScheduledFor = &at
+	return s.transactions.Create(body)
+}
+
+// ScheduleCancellable creates body inflight to run at the given time, so it
+// can be cancelled or rescheduled until it runs. When it has run the funds are
+// only held: call Commit to apply the transaction.
+func (s *ScheduledTransactions) ScheduleCancellable(body CreateTransactionRequest, at time.Time) (*Transaction, *http.Response, error) {
+	body.Inflight = true
+	return s.Schedule(body, at)
+}
+
+// Commit applies a cancellable scheduled transaction that has run.
+func (s *ScheduledTransactions) Commit(transactionID string) (*Transaction, *http.Response, error) {
+	return s.transactions.Update(transactionID, UpdateStatus{Status: InflightStatusCommit})
+}
+
+// Cancel voids a scheduled transaction before it runs. The transaction must be
+// queued, scheduled in the future and created inflight.
+func (s *ScheduledTransactions) Cancel(transactionID string) (*Transaction, *http.Response, error) {
+	transaction, resp, err := s.transactions.Get(transactionID)
+	if err != nil {
+		return nil, resp, err
+	}
+	if err := checkCancellable(transaction); err != nil {
+		return nil, nil, err
+	}
+	return s.void(transactionID)
+}
+
+// Reschedule creates a scheduled transaction again to run at the given time,
+// then cancels the original. The new transaction's reference is derived from
+// the original's and the new time, and its meta_data records the original
+// transaction ID under rescheduled_from. If the original cannot be cancelled
+// the new transaction is voided, so at most one of them runs.
+func (s *ScheduledTransactions) Reschedule(transactionID string, at time.Time) (*Transaction, *http.Response, error) {
+	if !at.After(time.Now()) {
+		return nil, nil, fmt.Errorf("scheduled time %s is not in the future", at.Format(time.RFC3339))
+	}
+	original, resp, err := s.transactions.Get(transactionID)
+	if err != nil {
+		return nil, resp, err
+	}
+	if err := checkCancellable(original); err != nil {
+		return nil, nil, err
+	}
+
+	body := CreateTransactionRequest{ParentTransaction: original.ParentTransaction, Inflight: original.Inflight}
+	body.Status = ""
+	body.Reference = fmt.Sprintf("%s-rescheduled-%s", original.Reference, at.UTC().Format(scheduleReferenceLayout))
+	body.MetaData = copyMetaData(original.MetaData)
+	body.MetaData["rescheduled_from"] = transactionID
+	replacement, resp, err := s.Schedule(body, at)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	// the original may have started running while the replacement was
+	// created, so it is checked again
+	original, resp, err = s.transactions.Get(transactionID)
+	if err == nil {
+		err = checkCancellable(original)
+	}
+	if err == nil {
+		_, resp, err = s.void(transactionID)
+	}
+	if err != nil {
+		if _, _, voidErr := s.void(replacement.TransactionID); voidErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to void replacement %s: %w", replacement.TransactionID, voidErr))
+		}
+		return nil, resp, err
+	}
+	return replacement, resp, nil
+}
+
+// ScheduledVoidError is returned when Blnk does not void a scheduled
+// transaction that was queued when it was checked. The transaction may have
+// started running in between, or the server may not void queued inflight
+// transactions; read it again to see which.
+type ScheduledVoidError struct {
+	TransactionID string
+	// Status is the status Blnk answered with, empty if it refused the void.
+	Status PryTransactionStatus
+	Err    error
+}
+
+func (e *ScheduledVoidError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("blnk did not void scheduled transaction %s: %v", e.TransactionID, e.Err)
+	}
+	return fmt.Sprintf("blnk did not void scheduled transaction %s: it is %s", e.TransactionID, e.Status)
+}
+
+func (e *ScheduledVoidError) Unwrap() error {
+	return e.Err
+}
+
+// void voids a scheduled transaction that checkCancellable accepted.
+func (s *ScheduledTransactions) void(transactionID string) (*Transaction, *http.Response, error) {
+	voided, resp, err := s.transactions.Update(transactionID, UpdateStatus{Status: InflightStatusVoid})
+	if err != nil {
+		return nil, resp, &ScheduledVoidError{TransactionID: transactionID, Err: err}
+	}
+	if voided.Status != PryTransactionStatusVoid {
+		return nil, resp, &ScheduledVoidError{TransactionID: transactionID, Status: voided.Status}
+	}
+	return voided, resp, nil
+}
+
+func checkCancellable(transaction *Transaction) error {
+	if transaction.Status != PryTransactionStatusQueued {
+		return fmt.Errorf("transaction %s is %s, only queued transactions can be cancelled", transaction.TransactionID, transaction.Status)
+	}
+	if transaction.ScheduledFor == nil || !transaction.ScheduledFor.After(time.Now()) {
+		return fmt.Errorf("transaction %s is not scheduled in the future", transaction.TransactionID)
+	}
+	if !transaction.Inflight {
+		return fmt.Errorf("transaction %s was not created inflight and cannot be cancelled", transaction.TransactionID)
+	}
+	return nil
+}
+
+func copyMetaData(metaData map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(metaData)+2)
+	for k, v := range metaData {
+		copied[k] = v
+	}
+	return copied
+}
+
+// RecurringSchedule generates the transactions of a recurring payment from a
+// template. Every occurrence gets the reference "<ID>-<time>", with the time in
+// UTC, so posting the same window twice never creates a transaction twice.
+type RecurringSchedule struct {
+	// ID identifies the schedule in references and meta_data. Defaults to
+	// Template.Reference.
+	ID         string
+	Template   CreateTransactionRequest
+	Recurrence Recurrence
+	// Location is the time zone cron expressions are evaluated in. Defaults to UTC.
+	Location *time.Location
+	// Cancellable creates the occurrences inflight, as ScheduleCancellable
+	// does, so they can be cancelled; each must then be committed after it
+	// runs.
+	Cancellable bool
+}
+
+func (r RecurringSchedule) id() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.Template.Reference
+}
+
+// Reference returns the deterministic reference of the occurrence at the given time.
+func (r RecurringSchedule) Reference(at time.Time) string {
+	return fmt.Sprintf("%s-%s", r.id(), at.UTC().Format(scheduleReferenceLayout))
+}
+
+// Occurrences returns the occurrences in [from, to).
+func (r RecurringSchedule) Occurrences(from, to time.Time) ([]time.Time, error) {
+	if r.Recurrence == nil {
+		return nil, fmt.Errorf("recurrence is required")
+	}
+	loc := r.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var occurrences []time.Time
+	for next := range occurrencesAfter(r.Recurrence, from.In(loc).Add(-time.Nanosecond)) {
+		if !next.Before(to) {
+			break
+		}
+		if len(occurrences) == maxScheduleOccurrences {
+			return nil, fmt.Errorf("more than %d occurrences between %s and %s", maxScheduleOccurrences, from.Format(time.RFC3339), to.Format(time.RFC3339))
+		}
+		occurrences = append(occurrences, next)
+	}
+	return occurrences, nil
+}
+
+// Requests returns one CreateTransactionRequest per occurrence in [from, to),
+// scheduled for the occurrence and with its deterministic reference. The
+// schedule ID and occurrence time are recorded in meta_data. Occurrences are
+// created inflight only if the template or Cancellable asks for it.
+func (r RecurringSchedule) Requests(from, to time.Time) ([]CreateTransactionRequest, error) {
+	if r.id() == "" {
+		return nil, fmt.Errorf("schedule ID or template reference is required")
+	}
+	occurrences, err := r.Occurrences(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make([]CreateTransactionRequest, len(occurrences))
+	for i, occurrence := range occurrences {
+		at := occurrence.UTC()
+		request := r.Template
+		request.Reference = r.Reference(at)
+		request.ScheduledFor = &at
+		request.Inflight = request.Inflight || r.Cancellable
+		request.MetaData = copyMetaData(r.Template.MetaData)
+		request.MetaData["recurring_schedule"] = r.id()
+		request.MetaData["occurrence"] = at.Format(time.RFC3339)
+		requests[i] = request
+	}
+	return requests, nil
+}
+
+// Enqueue creates the occurrences of schedule in [from, to) that have not been
+// created yet, found by reference, and returns the transactions it created.
+// Occurrences in the past are skipped.
+func (s *ScheduledTransactions) Enqueue(schedule RecurringSchedule, from, to time.Time) ([]Transaction, error) {
+	if now := time.Now(); from.Before(now) {
+		from = now
+	}
+	requests, err := schedule.Requests(from, to)
+	if err != nil || len(requests) == 0 {
+		return nil, err
+	}
+
+	references := make([]interface{}, len(requests))
+	for i, request := range requests {
+		references[i] = request.Reference
+	}
+	existing, err := s.transactions.FilterAll(FilterParams{
+		Filters: []Filter{{Field: "reference", Operator: OpIn, Values: references}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	posted := make(map[string]bool, len(existing))
+	for _, transaction := range existing {
+		posted[transaction.Reference] = true
+	}
+
+	var created []Transaction
+	for _, request := range requests {
+		if posted[request.Reference] {
+			continue
+		}
+		transaction, _, err := s.transactions.Create(request)
+		if err != nil {
+			return created, fmt.Errorf("failed to create %s: %w", request.Reference, err)
+		}
+		created = append(created, *transaction)
+	}
+	return created, nil
+}
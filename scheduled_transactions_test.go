@@ -0,0 +1,285 @@
+package blnkgo_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type scheduleServer struct {
+	mu       sync.Mutex
+	filters  []blnkgo.FilterParams
+	voided   []string
+	created  []blnkgo.CreateTransactionRequest
+	existing string
+	// events records creates and inflight updates in the order received
+	events []string
+	// failVoid makes voiding this transaction fail
+	failVoid string
+	// voidStatus is the status answered to a void instead of VOID
+	voidStatus string
+	// runOnCreate makes txn_s1 run as soon as a transaction is created
+	runOnCreate bool
+}
+
+func newScheduleServer(t *testing.T, ss *scheduleServer) *blnkgo.ScheduledTransactions {
+	future := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/transactions/filter", func(w http.ResponseWriter, r *http.Request) {
+		var params blnkgo.FilterParams
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		ss.mu.Lock()
+		ss.filters = append(ss.filters, params)
+		ss.mu.Unlock()
+		if params.Filters[0].Field == "reference" {
+			fmt.Fprint(w, ss.existing)
+			return
+		}
+		fmt.Fprintf(w, `[{"transaction_id":"txn_s1","reference":"rent","status":"QUEUED","inflight":true,"scheduled_for":"%s"}]`, future)
+	})
+	mux.HandleFunc("/transactions/txn_s1", func(w http.ResponseWriter, r *http.Request) {
+		ss.mu.Lock()
+		status := "QUEUED"
+		if ss.runOnCreate && len(ss.created) > 0 {
+			status = "INFLIGHT"
+		}
+		ss.mu.Unlock()
+		fmt.Fprintf(w, `{"transaction_id":"txn_s1","reference":"rent","amount":500,"precision":100,"currency":"USD","source":"bln_a","destination":"bln_b","status":%q,"inflight":true,"scheduled_for":"%s","meta_data":{"tenant":"t1"}}`, status, future)
+	})
+	mux.HandleFunc("/transactions/txn_plain", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"transaction_id":"txn_plain","status":"QUEUED","scheduled_for":"%s"}`, future)
+	})
+	mux.HandleFunc("/transactions/txn_ran", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"transaction_id":"txn_ran","status":"QUEUED","inflight":true,"scheduled_for":"%s"}`, past)
+	})
+	mux.HandleFunc("/transactions/inflight/", func(w http.ResponseWriter, r *http.Request) {
+		var body blnkgo.UpdateStatus
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		id := strings.TrimPrefix(r.URL.Path, "/transactions/inflight/")
+		ss.mu.Lock()
+		defer ss.mu.Unlock()
+		ss.events = append(ss.events, string(body.Status)+" "+id)
+		if body.Status == blnkgo.InflightStatusVoid && id == ss.failVoid {
+			http.Error(w, `{"error":"transaction already processed"}`, http.StatusBadRequest)
+			return
+		}
+		if body.Status == blnkgo.InflightStatusVoid && ss.voidStatus != "" {
+			fmt.Fprintf(w, `{"transaction_id":%q,"status":%q}`, id, ss.voidStatus)
+			return
+		}
+		if body.Status == blnkgo.InflightStatusVoid {
+			ss.voided = append(ss.voided, r.URL.Path)
+			fmt.Fprintf(w, `{"transaction_id":%q,"status":"VOID"}`, id)
+			return
+		}
+		fmt.Fprintf(w, `{"transaction_id":%q,"status":"APPLIED"}`, id)
+	})
+	mux.HandleFunc("/transactions", func(w http.ResponseWriter, r *http.Request) {
+		var body blnkgo.CreateTransactionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		ss.mu.Lock()
+		ss.created = append(ss.created, body)
+		ss.events = append(ss.events, "create "+body.Reference)
+		ss.mu.Unlock()
+		fmt.Fprintf(w, `{"transaction_id":"txn_new","reference":%q,"status":"QUEUED"}`, body.Reference)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	baseURL, _ := url.Parse(server.URL)
+	return blnkgo.NewScheduledTransactions(blnkgo.NewClient(baseURL, nil))
+}
+
+func TestScheduledTransactions_List(t *testing.T) {
+	ss := &scheduleServer{}
+	scheduled := newScheduleServer(t, ss)
+
+	transactions, err := scheduled.List()
+
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	assert.True(t, transactions[0].Inflight)
+	require.NotNil(t, transactions[0].ScheduledFor)
+	require.Len(t, ss.filters, 1)
+	assert.Equal(t, "status", ss.filters[0].Filters[0].Field)
+	assert.Equal(t, "QUEUED", ss.filters[0].Filters[0].Value)
+	assert.Equal(t, "scheduled_for", ss.filters[0].Filters[1].Field)
+	assert.Equal(t, blnkgo.OpGreaterThan, ss.filters[0].Filters[1].Operator)
+}
+
+func TestScheduledTransactions_Schedule(t *testing.T) {
+	ss := &scheduleServer{}
+	scheduled := newScheduleServer(t, ss)
+	body := blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: "rent", Amount: 500, Precision: 100, Currency: "USD", Source: "bln_a", Destination: "bln_b",
+	}}
+	at := time.Now().Add(24 * time.Hour)
+
+	_, _, err := scheduled.Schedule(body, at)
+	require.NoError(t, err)
+	_, _, err = scheduled.ScheduleCancellable(body, at)
+	require.NoError(t, err)
+	_, _, err = scheduled.Schedule(body, time.Now().Add(-time.Minute))
+	assert.Error(t, err)
+
+	require.Len(t, ss.created, 2)
+	assert.False(t, ss.created[0].Inflight, "the caller's Inflight is kept")
+	assert.True(t, ss.created[1].Inflight)
+	assert.True(t, at.UTC().Equal(*ss.created[0].ScheduledFor))
+
+	transaction, _, err := scheduled.Commit("txn_new")
+	require.NoError(t, err)
+	assert.Equal(t, blnkgo.PryTransactionStatusApplied, transaction.Status)
+	assert.Equal(t, "commit txn_new", ss.events[len(ss.events)-1])
+}
+
+func TestScheduledTransactions_Cancel(t *testing.T) {
+	ss := &scheduleServer{}
+	scheduled := newScheduleServer(t, ss)
+
+	transaction, _, err := scheduled.Cancel("txn_s1")
+
+	require.NoError(t, err)
+	assert.Equal(t, blnkgo.PryTransactionStatusVoid, transaction.Status)
+	assert.Equal(t, []string{"/transactions/inflight/txn_s1"}, ss.voided)
+
+	_, _, err = scheduled.Cancel("txn_plain")
+	assert.EqualError(t, err, "transaction txn_plain was not created inflight and cannot be cancelled")
+	_, _, err = scheduled.Cancel("txn_ran")
+	assert.EqualError(t, err, "transaction txn_ran is not scheduled in the future")
+	assert.Len(t, ss.voided, 1)
+}
+
+func TestScheduledTransactions_Reschedule(t *testing.T) {
+	ss := &scheduleServer{}
+	scheduled := newScheduleServer(t, ss)
+	at := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+
+	_, _, err := scheduled.Reschedule("txn_s1", at)
+
+	require.NoError(t, err)
+	reference := "rent-rescheduled-" + at.UTC().Format("20060102T150405Z")
+	assert.Equal(t, []string{"create " + reference, "void txn_s1"}, ss.events, "the replacement exists before the original is voided")
+	require.Len(t, ss.created, 1)
+	created := ss.created[0]
+	assert.Equal(t, reference, created.Reference)
+	assert.True(t, created.Inflight)
+	assert.True(t, at.Equal(*created.ScheduledFor))
+	assert.Equal(t, "txn_s1", created.MetaData["rescheduled_from"])
+	assert.Equal(t, "t1", created.MetaData["tenant"])
+	assert.Empty(t, created.Status)
+
+	_, _, err = scheduled.Reschedule("txn_s1", time.Now().Add(-time.Minute))
+	assert.Error(t, err)
+}
+
+func TestScheduledTransactions_Reschedule_VoidFails(t *testing.T) {
+	ss := &scheduleServer{failVoid: "txn_s1"}
+	scheduled := newScheduleServer(t, ss)
+	at := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+
+	transaction, _, err := scheduled.Reschedule("txn_s1", at)
+
+	assert.ErrorContains(t, err, "transaction already processed")
+	var voidErr *blnkgo.ScheduledVoidError
+	require.ErrorAs(t, err, &voidErr)
+	assert.Equal(t, "txn_s1", voidErr.TransactionID)
+	assert.Nil(t, transaction)
+	reference := "rent-rescheduled-" + at.UTC().Format("20060102T150405Z")
+	assert.Equal(t, []string{"create " + reference, "void txn_s1", "void txn_new"}, ss.events, "the replacement is voided")
+}
+
+func TestScheduledTransactions_Reschedule_OriginalRan(t *testing.T) {
+	ss := &scheduleServer{runOnCreate: true}
+	scheduled := newScheduleServer(t, ss)
+	at := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+
+	_, _, err := scheduled.Reschedule("txn_s1", at)
+
+	assert.EqualError(t, err, "transaction txn_s1 is INFLIGHT, only queued transactions can be cancelled")
+	reference := "rent-rescheduled-" + at.UTC().Format("20060102T150405Z")
+	assert.Equal(t, []string{"create " + reference, "void txn_new"}, ss.events, "the original is not voided once it runs")
+}
+
+func TestScheduledTransactions_Cancel_NotVoided(t *testing.T) {
+	ss := &scheduleServer{voidStatus: "QUEUED"}
+	scheduled := newScheduleServer(t, ss)
+
+	transaction, _, err := scheduled.Cancel("txn_s1")
+
+	assert.Nil(t, transaction)
+	assert.EqualError(t, err, "blnk did not void scheduled transaction txn_s1: it is QUEUED")
+}
+
+func TestRecurringSchedule_Requests(t *testing.T) {
+	cron, err := blnkgo.ParseCron("0 9 1 * *")
+	require.NoError(t, err)
+	schedule := blnkgo.RecurringSchedule{
+		ID: "sub_42",
+		Template: blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+			Amount: 9.99, Precision: 100, Currency: "USD", Source: "bln_customer", Destination: "bln_merchant",
+			MetaData: map[string]interface{}{"plan": "pro"},
+		}},
+		Recurrence: cron,
+	}
+	from := time.Date(2024, time.January, 1, 9, 0, 0, 0, time.UTC)
+	to := time.Date(2024, time.April, 1, 9, 0, 0, 0, time.UTC)
+
+	requests, err := schedule.Requests(from, to)
+
+	require.NoError(t, err)
+	require.Len(t, requests, 3)
+	assert.Equal(t, "sub_42-20240101T090000Z", requests[0].Reference)
+	assert.Equal(t, "sub_42-20240301T090000Z", requests[2].Reference)
+	assert.Equal(t, time.Date(2024, time.February, 1, 9, 0, 0, 0, time.UTC), *requests[1].ScheduledFor)
+	assert.Equal(t, "sub_42", requests[1].MetaData["recurring_schedule"])
+	assert.Equal(t, "pro", requests[1].MetaData["plan"])
+	assert.NotContains(t, schedule.Template.MetaData, "recurring_schedule")
+
+	assert.False(t, requests[1].Inflight)
+
+	schedule.Cancellable = true
+	again, err := schedule.Requests(from, to)
+	require.NoError(t, err)
+	assert.Equal(t, requests[1].Reference, again[1].Reference)
+	assert.True(t, again[1].Inflight)
+
+	_, err = blnkgo.RecurringSchedule{Recurrence: cron}.Requests(from, to)
+	assert.Error(t, err)
+}
+
+func TestScheduledTransactions_Enqueue(t *testing.T) {
+	rule, err := blnkgo.ParseRRule("FREQ=DAILY", time.Now().Add(time.Hour).Truncate(time.Second))
+	require.NoError(t, err)
+	schedule := blnkgo.RecurringSchedule{
+		Template: blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+			Reference: "savings", Amount: 10, Precision: 100, Currency: "USD", Source: "bln_a", Destination: "bln_b",
+		}},
+		Recurrence: rule,
+	}
+	first := schedule.Reference(rule.Start)
+	ss := &scheduleServer{existing: fmt.Sprintf(`[{"transaction_id":"txn_old","reference":%q}]`, first)}
+	scheduled := newScheduleServer(t, ss)
+
+	created, err := scheduled.Enqueue(schedule, time.Now().Add(-24*time.Hour), time.Now().Add(72*time.Hour))
+
+	require.NoError(t, err)
+	assert.Len(t, created, 2)
+	require.Len(t, ss.created, 2)
+	assert.NotEqual(t, first, ss.created[0].Reference)
+	assert.Equal(t, schedule.Reference(rule.Start.AddDate(0, 0, 1)), ss.created[0].Reference)
+	assert.Equal(t, blnkgo.OpIn, ss.filters[0].Filters[0].Operator)
+	assert.Len(t, ss.filters[0].Filters[0].Values, 3)
+}
@@ -67,6 +67,13 @@ type SearchParams struct {
 	PerPage    int    `json:"per_page,omitempty"`
 	GroupBy    string `json:"group_by,omitempty"`
 	GroupLimit int    `json:"group_limit,omitempty"`
+	// FacetBy lists the fields to count values of, returned as FacetCounts
+	// by the typed searches.
+	FacetBy        string `json:"facet_by,omitempty"`
+	MaxFacetValues int    `json:"max_facet_values,omitempty"`
+	// HighlightFields limits highlighting to these fields instead of the
+	// QueryBy fields.
+	HighlightFields string `json:"highlight_fields,omitempty"`
 }
 
 type SearchResponse struct {
@@ -0,0 +1,420 @@
+package blnkgo
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchKind is the Typesense type of a searchable field, which decides the
+// operators and values a filter on it accepts.
+type searchKind int
+
+const (
+	searchString searchKind = iota
+	searchNumber
+	searchBool
+	searchTime
+	// searchObject fields are filtered through their nested keys, such as
+	// meta_data.customer, whose type is not known in advance.
+	searchObject
+)
+
+func (k searchKind) String() string {
+	switch k {
+	case searchString:
+		return "string"
+	case searchNumber:
+		return "number"
+	case searchBool:
+		return "boolean"
+	case searchTime:
+		return "time"
+	}
+	return "object"
+}
+
+// searchFields lists the fields Blnk indexes per resource. Amounts other than
+// transaction amounts are indexed as strings, so they cannot be ranged over.
+var searchFields = map[ResourceType]map[string]searchKind{
+	Ledgers: {
+		"ledger_id":  searchString,
+		"name":       searchString,
+		"created_at": searchTime,
+		"meta_data":  searchObject,
+	},
+	Balances: {
+		"balance_id":              searchString,
+		"indicator":               searchString,
+		"currency":                searchString,
+		"ledger_id":               searchString,
+		"identity_id":             searchString,
+		"balance":                 searchString,
+		"credit_balance":          searchString,
+		"debit_balance":           searchString,
+		"inflight_balance":        searchString,
+		"inflight_credit_balance": searchString,
+		"inflight_debit_balance":  searchString,
+		"precision":               searchNumber,
+		"version":                 searchNumber,
+		"created_at":              searchTime,
+		"inflight_expires_at":     searchTime,
+		"meta_data":               searchObject,
+	},
+	Transactions: {
+		"transaction_id":       searchString,
+		"parent_transaction":   searchString,
+		"source":               searchString,
+		"destination":          searchString,
+		"reference":            searchString,
+		"currency":             searchString,
+		"description":          searchString,
+		"status":               searchString,
+		"hash":                 searchString,
+		"amount_string":        searchString,
+		"precise_amount":       searchString,
+		"amount":               searchNumber,
+		"precision":            searchNumber,
+		"rate":                 searchNumber,
+		"overdraft_limit":      searchNumber,
+		"atomic":               searchBool,
+		"inflight":             searchBool,
+		"allow_overdraft":      searchBool,
+		"skip_queue":           searchBool,
+		"created_at":           searchTime,
+		"scheduled_for":        searchTime,
+		"effective_date":       searchTime,
+		"inflight_expiry_date": searchTime,
+		"meta_data":            searchObject,
+	},
+}
+
+// SearchFields returns the names of the fields of a resource that can be
+// searched, filtered or sorted on, sorted by name.
+func SearchFields(resource ResourceType) []string {
+	fields := make([]string, 0, len(searchFields[resource]))
+	for name := range searchFields[resource] {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// fieldKind looks up a field of resource. Nested keys of object fields are
+// accepted with the kind searchObject.
+func fieldKind(resource ResourceType, field string) (searchKind, error) {
+	fields, ok := searchFields[resource]
+	if !ok {
+		return 0, fmt.Errorf("cannot search %q, want ledgers, balances or transactions", resource)
+	}
+	if kind, ok := fields[field]; ok && kind != searchObject {
+		return kind, nil
+	}
+	if parent, key, nested := strings.Cut(field, "."); nested && key != "" && fields[parent] == searchObject {
+		return searchObject, nil
+	}
+	return 0, fmt.Errorf("%s has no searchable field %q", resource, field)
+}
+
+// SearchFilter is a filter_by expression: a comparison built from SearchField,
+// or a group of filters combined with And or Or. The zero value matches
+// everything. Fields, operators and values are only checked against a
+// resource by Build, which SearchQuery.Params calls before a search is sent.
+type SearchFilter struct {
+	field  string
+	op     string
+	values []interface{}
+
+	join  string
+	group []SearchFilter
+}
+
+// SearchFieldFilter starts a comparison on one field.
+type SearchFieldFilter struct {
+	name string
+}
+
+// SearchField starts a filter on field, e.g. SearchField("status").Eq("APPLIED").
+// Keys of meta_data are addressed as meta_data.key.
+func SearchField(field string) SearchFieldFilter {
+	return SearchFieldFilter{name: field}
+}
+
+func (f SearchFieldFilter) compare(op string, values ...interface{}) SearchFilter {
+	return SearchFilter{field: f.name, op: op, values: values}
+}
+
+// Eq matches the exact value (:=).
+func (f SearchFieldFilter) Eq(value interface{}) SearchFilter { return f.compare(":=", value) }
+
+// NotEq excludes the exact value (:!=).
+func (f SearchFieldFilter) NotEq(value interface{}) SearchFilter { return f.compare(":!=", value) }
+
+// Gt matches values above value (:>). Number and time fields only.
+func (f SearchFieldFilter) Gt(value interface{}) SearchFilter { return f.compare(":>", value) }
+
+// Gte matches values at or above value (:>=). Number and time fields only.
+func (f SearchFieldFilter) Gte(value interface{}) SearchFilter { return f.compare(":>=", value) }
+
+// Lt matches values below value (:<). Number and time fields only.
+func (f SearchFieldFilter) Lt(value interface{}) SearchFilter { return f.compare(":<", value) }
+
+// Lte matches values at or below value (:<=). Number and time fields only.
+func (f SearchFieldFilter) Lte(value interface{}) SearchFilter { return f.compare(":<=", value) }
+
+// Between matches values from low to high, both included ([low..high]).
+// Number and time fields only.
+func (f SearchFieldFilter) Between(low, high interface{}) SearchFilter {
+	return f.compare("..", low, high)
+}
+
+// In matches any of the exact values (:=[a,b]).
+func (f SearchFieldFilter) In(values ...interface{}) SearchFilter {
+	return f.compare(":=[]", values...)
+}
+
+// NotIn excludes all of the values (:!=[a,b]).
+func (f SearchFieldFilter) NotIn(values ...interface{}) SearchFilter {
+	return f.compare(":!=[]", values...)
+}
+
+// Matches matches string fields containing the words of text (:), rather
+// than the exact value.
+func (f SearchFieldFilter) Matches(text string) SearchFilter { return f.compare(":", text) }
+
+// And matches documents matching every filter. Zero filters are skipped.
+func And(filters ...SearchFilter) SearchFilter {
+	return SearchFilter{join: "&&", group: filters}
+}
+
+// Or matches documents matching any of the filters. Zero filters are skipped.
+func Or(filters ...SearchFilter) SearchFilter {
+	return SearchFilter{join: "||", group: filters}
+}
+
+// IsZero reports whether the filter is empty.
+func (f SearchFilter) IsZero() bool {
+	if f.join == "" {
+		return f.field == ""
+	}
+	for _, child := range f.group {
+		if !child.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// Build renders the filter as a filter_by expression after checking its
+// fields, operators and values against resource.
+func (f SearchFilter) Build(resource ResourceType) (string, error) {
+	if f.IsZero() {
+		return "", nil
+	}
+	if f.join != "" {
+		var parts []string
+		for _, child := range f.group {
+			if child.IsZero() {
+				continue
+			}
+			part, err := child.Build(resource)
+			if err != nil {
+				return "", err
+			}
+			if child.join != "" && child.join != f.join {
+				part = "(" + part + ")"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, " "+f.join+" "), nil
+	}
+
+	kind, err := fieldKind(resource, f.field)
+	if err != nil {
+		return "", err
+	}
+	ranged := f.op == ":>" || f.op == ":>=" || f.op == ":<" || f.op == ":<=" || f.op == ".."
+	switch {
+	case ranged && (kind == searchString || kind == searchBool):
+		return "", fmt.Errorf("cannot compare %s field %s by range", kind, f.field)
+	case f.op == ":" && kind != searchString && kind != searchObject:
+		return "", fmt.Errorf("cannot match words in %s field %s", kind, f.field)
+	case len(f.values) == 0:
+		return "", fmt.Errorf("filter on %s needs at least one value", f.field)
+	}
+
+	values := make([]string, len(f.values))
+	for i, value := range f.values {
+		if values[i], err = searchValue(kind, f.field, value); err != nil {
+			return "", err
+		}
+	}
+	switch f.op {
+	case "..":
+		return fmt.Sprintf("%s:[%s..%s]", f.field, values[0], values[1]), nil
+	case ":=[]", ":!=[]":
+		return fmt.Sprintf("%s%s[%s]", f.field, strings.TrimSuffix(f.op, "[]"), strings.Join(values, ",")), nil
+	}
+	return f.field + f.op + values[0], nil
+}
+
+// searchValue formats a filter value for a field of kind. Strings are always
+// wrapped in backticks, with backticks and backslashes inside them escaped, so
+// commas, spaces and operators in values are taken literally. Times are sent
+// as Unix seconds, the way Blnk indexes them.
+func searchValue(kind searchKind, field string, value interface{}) (string, error) {
+	if t, ok := value.(time.Time); ok {
+		if kind != searchTime && kind != searchObject {
+			return "", fmt.Errorf("cannot compare %s field %s with a time", kind, field)
+		}
+		return strconv.FormatInt(t.Unix(), 10), nil
+	}
+	if n, ok := value.(*big.Int); ok && n != nil {
+		if kind == searchString || kind == searchBool {
+			return "", fmt.Errorf("cannot compare %s field %s with %s", kind, field, n)
+		}
+		return n.String(), nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		if kind != searchString && kind != searchObject {
+			return "", fmt.Errorf("cannot compare %s field %s with the string %q", kind, field, v.String())
+		}
+		escaped := strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(v.String())
+		return "`" + escaped + "`", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if kind == searchNumber || kind == searchTime || kind == searchObject {
+			return strconv.FormatInt(v.Int(), 10), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if kind == searchNumber || kind == searchTime || kind == searchObject {
+			return strconv.FormatUint(v.Uint(), 10), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if kind == searchNumber || kind == searchObject {
+			return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+		}
+	case reflect.Bool:
+		if kind == searchBool || kind == searchObject {
+			return strconv.FormatBool(v.Bool()), nil
+		}
+	default:
+		return "", fmt.Errorf("unsupported value %v (%T) for field %s", value, value, field)
+	}
+	return "", fmt.Errorf("cannot compare %s field %s with %v (%T)", kind, field, value, value)
+}
+
+// SearchSort is one sort_by clause.
+type SearchSort struct {
+	Field string
+	Desc  bool
+}
+
+// SortAsc sorts by field, lowest first.
+func SortAsc(field string) SearchSort { return SearchSort{Field: field} }
+
+// SortDesc sorts by field, highest first.
+func SortDesc(field string) SearchSort { return SearchSort{Field: field, Desc: true} }
+
+// buildSort renders sort_by. Typesense sorts on up to three number or time
+// fields, or on the relevance of a match with _text_match.
+func buildSort(resource ResourceType, clauses []SearchSort) (string, error) {
+	if len(clauses) > 3 {
+		return "", fmt.Errorf("at most 3 sort fields are allowed, got %d", len(clauses))
+	}
+	parts := make([]string, len(clauses))
+	for i, clause := range clauses {
+		if clause.Field != "_text_match" {
+			kind, err := fieldKind(resource, clause.Field)
+			if err != nil {
+				return "", err
+			}
+			if kind != searchNumber && kind != searchTime {
+				return "", fmt.Errorf("cannot sort by %s field %s", kind, clause.Field)
+			}
+		}
+		order := "asc"
+		if clause.Desc {
+			order = "desc"
+		}
+		parts[i] = clause.Field + ":" + order
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// SearchQuery is a search whose fields are checked against the resource before
+// it is sent.
+type SearchQuery struct {
+	Resource ResourceType
+	// Q is the text to search for. Defaults to "*", which matches everything.
+	Q       string
+	QueryBy []string
+	Filter  SearchFilter
+	Sort    []SearchSort
+	Page    int
+	PerPage int
+	GroupBy []string
+	// GroupLimit is the number of hits per group.
+	GroupLimit int
+	FacetBy    []string
+	// MaxFacetValues is the number of values counted per facet.
+	MaxFacetValues  int
+	HighlightFields []string
+}
+
+// Params checks the query and renders it as SearchParams.
+func (q SearchQuery) Params() (SearchParams, error) {
+	params := SearchParams{Q: q.Q, Page: q.Page, PerPage: q.PerPage, GroupLimit: q.GroupLimit, MaxFacetValues: q.MaxFacetValues}
+	if params.Q == "" {
+		params.Q = "*"
+	}
+	if _, ok := searchFields[q.Resource]; !ok {
+		return SearchParams{}, fmt.Errorf("cannot search %q, want ledgers, balances or transactions", q.Resource)
+	}
+	for _, field := range q.QueryBy {
+		kind, err := fieldKind(q.Resource, field)
+		if err != nil {
+			return SearchParams{}, err
+		}
+		if kind != searchString && kind != searchObject {
+			return SearchParams{}, fmt.Errorf("cannot query by %s field %s", kind, field)
+		}
+	}
+	params.QueryBy = strings.Join(q.QueryBy, ",")
+	for _, fields := range [][]string{q.GroupBy, q.FacetBy, q.HighlightFields} {
+		for _, field := range fields {
+			if _, err := fieldKind(q.Resource, field); err != nil {
+				return SearchParams{}, err
+			}
+		}
+	}
+	params.GroupBy = strings.Join(q.GroupBy, ",")
+	params.FacetBy = strings.Join(q.FacetBy, ",")
+	params.HighlightFields = strings.Join(q.HighlightFields, ",")
+
+	var err error
+	if params.FilterBy, err = q.Filter.Build(q.Resource); err != nil {
+		return SearchParams{}, err
+	}
+	if params.SortBy, err = buildSort(q.Resource, q.Sort); err != nil {
+		return SearchParams{}, err
+	}
+	return params, nil
+}
+
+// Query checks and sends a SearchQuery. Nothing is sent if the query is
+// invalid.
+func (s *SearchService) Query(query SearchQuery) (*SearchResponse, *http.Response, error) {
+	params, err := query.Params()
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.SearchDocument(params, query.Resource)
+}
@@ -0,0 +1,119 @@
+package blnkgo_test
+
+import (
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchFilter_Build(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		resource blnkgo.ResourceType
+		filter   blnkgo.SearchFilter
+		want     string
+	}{
+		{"zero", blnkgo.Transactions, blnkgo.SearchFilter{}, ""},
+		{"exact", blnkgo.Transactions, blnkgo.SearchField("status").Eq(blnkgo.PryTransactionStatusApplied), "status:=`APPLIED`"},
+		{"escaping", blnkgo.Transactions, blnkgo.SearchField("reference").Eq("a`b,c\\d"), "reference:=`a\\`b,c\\\\d`"},
+		{"range", blnkgo.Transactions, blnkgo.SearchField("amount").Between(10, 99.5), "amount:[10..99.5]"},
+		{"time", blnkgo.Ledgers, blnkgo.SearchField("created_at").Gte(since), "created_at:>=1704067200"},
+		{"list", blnkgo.Balances, blnkgo.SearchField("currency").NotIn("USD", "EUR"), "currency:!=[`USD`,`EUR`]"},
+		{"big int", blnkgo.Transactions, blnkgo.SearchField("amount").Lt(big.NewInt(500)), "amount:<500"},
+		{"metadata", blnkgo.Balances, blnkgo.SearchField("meta_data.tier").Matches("gold"), "meta_data.tier:`gold`"},
+		{
+			"grouping",
+			blnkgo.Transactions,
+			blnkgo.And(
+				blnkgo.SearchField("inflight").Eq(false),
+				blnkgo.Or(blnkgo.SearchField("source").Eq("@world"), blnkgo.SearchField("destination").In("@a", "@b")),
+				blnkgo.SearchFilter{},
+				blnkgo.And(blnkgo.SearchField("currency").Eq("USD")),
+			),
+			"inflight:=false && (source:=`@world` || destination:=[`@a`,`@b`]) && currency:=`USD`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.filter.Build(tt.resource)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSearchFilter_BuildErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource blnkgo.ResourceType
+		filter   blnkgo.SearchFilter
+		err      string
+	}{
+		{"unknown field", blnkgo.Ledgers, blnkgo.SearchField("status").Eq("APPLIED"), `ledgers has no searchable field "status"`},
+		{"unknown resource", "identities", blnkgo.SearchField("name").Eq("x"), `cannot search "identities"`},
+		{"string range", blnkgo.Balances, blnkgo.SearchField("balance").Gt(100), "cannot compare string field balance by range"},
+		{"bool range", blnkgo.Transactions, blnkgo.SearchField("atomic").Between(false, true), "cannot compare boolean field atomic by range"},
+		{"words in number", blnkgo.Transactions, blnkgo.SearchField("amount").Matches("10"), "cannot match words in number field amount"},
+		{"string for number", blnkgo.Transactions, blnkgo.SearchField("amount").Eq("10"), `cannot compare number field amount with the string "10"`},
+		{"time for string", blnkgo.Transactions, blnkgo.SearchField("status").Eq(time.Now()), "cannot compare string field status with a time"},
+		{"no values", blnkgo.Transactions, blnkgo.SearchField("status").In(), "needs at least one value"},
+		{"nested", blnkgo.Transactions, blnkgo.Or(blnkgo.SearchField("status").Eq("APPLIED"), blnkgo.SearchField("nope").Eq(1)), `no searchable field "nope"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.filter.Build(tt.resource)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestSearchQuery_Params(t *testing.T) {
+	params, err := blnkgo.SearchQuery{
+		Resource: blnkgo.Transactions,
+		QueryBy:  []string{"reference", "description"},
+		Filter:   blnkgo.SearchField("status").Eq("APPLIED"),
+		Sort:     []blnkgo.SearchSort{blnkgo.SortDesc("created_at"), blnkgo.SortAsc("_text_match")},
+		PerPage:  50,
+	}.Params()
+	require.NoError(t, err)
+	assert.Equal(t, blnkgo.SearchParams{
+		Q:        "*",
+		QueryBy:  "reference,description",
+		FilterBy: "status:=`APPLIED`",
+		SortBy:   "created_at:desc,_text_match:asc",
+		PerPage:  50,
+	}, params)
+
+	for _, query := range []blnkgo.SearchQuery{
+		{Resource: blnkgo.Transactions, Sort: []blnkgo.SearchSort{blnkgo.SortAsc("status")}},
+		{Resource: blnkgo.Transactions, Sort: []blnkgo.SearchSort{blnkgo.SortAsc("amount"), blnkgo.SortAsc("rate"), blnkgo.SortAsc("precision"), blnkgo.SortAsc("created_at")}},
+		{Resource: blnkgo.Transactions, QueryBy: []string{"amount"}},
+		{Resource: blnkgo.Ledgers, GroupBy: []string{"currency"}},
+		{Resource: "accounts"},
+	} {
+		_, err := query.Params()
+		assert.Error(t, err, "%+v", query)
+	}
+}
+
+func TestSearchService_Query(t *testing.T) {
+	mockClient, svc := setupSearchService()
+
+	_, _, err := svc.Query(blnkgo.SearchQuery{Resource: blnkgo.Balances, Filter: blnkgo.SearchField("balance").Gt(0)})
+	assert.Error(t, err)
+	mockClient.AssertNotCalled(t, "NewRequest", mock.Anything, mock.Anything, mock.Anything)
+
+	expected := blnkgo.SearchParams{Q: "*", FilterBy: "currency:=`USD`"}
+	mockClient.On("NewRequest", "search/balances", http.MethodPost, expected).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil)
+	_, _, err = svc.Query(blnkgo.SearchQuery{Resource: blnkgo.Balances, Filter: blnkgo.SearchField("currency").Eq("USD")})
+	require.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
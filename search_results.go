@@ -0,0 +1,310 @@
+package blnkgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+)
+
+// BalanceDocument is a balance as indexed for search.
+type BalanceDocument struct {
+	BalanceID             string        `json:"balance_id"`
+	Indicator             string        `json:"indicator,omitempty"`
+	Currency              string        `json:"currency"`
+	LedgerID              string        `json:"ledger_id"`
+	IdentityID            string        `json:"identity_id,omitempty"`
+	Balance               string        `json:"balance"`
+	CreditBalance         string        `json:"credit_balance"`
+	DebitBalance          string        `json:"debit_balance"`
+	InflightBalance       string        `json:"inflight_balance,omitempty"`
+	InflightCreditBalance string        `json:"inflight_credit_balance,omitempty"`
+	InflightDebitBalance  string        `json:"inflight_debit_balance,omitempty"`
+	Precision             int           `json:"precision,omitempty"`
+	Version               int64         `json:"version,omitempty"`
+	CreatedAt             FlexibleTime  `json:"created_at"`
+	InflightExpiresAt     *FlexibleTime `json:"inflight_expires_at,omitempty"`
+	MetaData              interface{}   `json:"meta_data,omitempty"` // Can be string, map, or other types
+}
+
+// TransactionDocument is a transaction as indexed for search.
+type TransactionDocument struct {
+	TransactionID      string        `json:"transaction_id"`
+	ParentTransaction  string        `json:"parent_transaction,omitempty"`
+	Amount             float64       `json:"amount"`
+	AmountString       string        `json:"amount_string,omitempty"`
+	PreciseAmount      string        `json:"precise_amount,omitempty"`
+	Precision          int           `json:"precision,omitempty"`
+	Rate               float64       `json:"rate,omitempty"`
+	Currency           string        `json:"currency"`
+	Source             string        `json:"source"`
+	Destination        string        `json:"destination"`
+	Sources            interface{}   `json:"sources,omitempty"`
+	Destinations       interface{}   `json:"destinations,omitempty"`
+	Reference          string        `json:"reference"`
+	Description        string        `json:"description,omitempty"`
+	Status             string        `json:"status"`
+	Hash               string        `json:"hash,omitempty"`
+	Atomic             bool          `json:"atomic,omitempty"`
+	Inflight           bool          `json:"inflight,omitempty"`
+	AllowOverdraft     bool          `json:"allow_overdraft,omitempty"`
+	OverdraftLimit     float64       `json:"overdraft_limit,omitempty"`
+	SkipQueue          bool          `json:"skip_queue,omitempty"`
+	CreatedAt          FlexibleTime  `json:"created_at"`
+	ScheduledFor       *FlexibleTime `json:"scheduled_for,omitempty"`
+	EffectiveDate      *FlexibleTime `json:"effective_date,omitempty"`
+	InflightExpiryDate *FlexibleTime `json:"inflight_expiry_date,omitempty"`
+	MetaData           interface{}   `json:"meta_data,omitempty"` // Can be string, map, or other types
+}
+
+// LedgerDocument is a ledger as indexed for search.
+type LedgerDocument struct {
+	LedgerID  string       `json:"ledger_id"`
+	Name      string       `json:"name"`
+	CreatedAt FlexibleTime `json:"created_at"`
+	MetaData  interface{}  `json:"meta_data,omitempty"` // Can be string, map, or other types
+}
+
+// Highlight marks where the query matched a field. Snippet is an excerpt of
+// the field with the matches wrapped in <mark> tags; array fields have
+// Snippets instead.
+type Highlight struct {
+	Field         string        `json:"field"`
+	Snippet       string        `json:"snippet,omitempty"`
+	Snippets      []string      `json:"snippets,omitempty"`
+	Value         string        `json:"value,omitempty"`
+	MatchedTokens []interface{} `json:"matched_tokens,omitempty"`
+}
+
+// HitInfo is what a search says about a hit besides its document.
+type HitInfo struct {
+	Highlights []Highlight `json:"highlights,omitempty"`
+	// TextMatch is the relevance score of the hit; higher is better.
+	TextMatch int64 `json:"text_match,omitempty"`
+}
+
+type BalanceHit struct {
+	Document BalanceDocument `json:"document"`
+	HitInfo
+}
+
+type TransactionHit struct {
+	Document TransactionDocument `json:"document"`
+	HitInfo
+}
+
+type LedgerHit struct {
+	Document LedgerDocument `json:"document"`
+	HitInfo
+}
+
+// FacetCount counts the hits per value of a FacetBy field. Stats is only set
+// for number fields.
+type FacetCount struct {
+	FieldName string       `json:"field_name"`
+	Counts    []FacetValue `json:"counts"`
+	Stats     *FacetStats  `json:"stats,omitempty"`
+}
+
+type FacetValue struct {
+	Value       string `json:"value"`
+	Count       int    `json:"count"`
+	Highlighted string `json:"highlighted,omitempty"`
+}
+
+type FacetStats struct {
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max"`
+	Sum         float64 `json:"sum"`
+	Avg         float64 `json:"avg"`
+	TotalValues int     `json:"total_values"`
+}
+
+// SearchResults is a search response with hits of type H, one of BalanceHit,
+// TransactionHit or LedgerHit.
+type SearchResults[H any] struct {
+	Found         int              `json:"found"`
+	OutOf         int              `json:"out_of"`
+	Page          int              `json:"page"`
+	RequestParams SearchParams     `json:"request_params"`
+	SearchTimeMs  int              `json:"search_time_ms"`
+	Hits          []H              `json:"hits,omitempty"`
+	GroupedHits   []SearchGroup[H] `json:"grouped_hits,omitempty"`
+	FacetCounts   []FacetCount     `json:"facet_counts,omitempty"`
+}
+
+type SearchGroup[H any] struct {
+	GroupKey []string `json:"group_key,omitempty"`
+	Found    int      `json:"found,omitempty"`
+	Hits     []H      `json:"hits"`
+}
+
+// Facet returns the counts of field, or nil if it was not faceted.
+func (r *SearchResults[H]) Facet(field string) *FacetCount {
+	for i := range r.FacetCounts {
+		if r.FacetCounts[i].FieldName == field {
+			return &r.FacetCounts[i]
+		}
+	}
+	return nil
+}
+
+func search[H any](s *SearchService, params SearchParams, resource ResourceType) (*SearchResults[H], *http.Response, error) {
+	req, err := s.client.NewRequest(fmt.Sprintf("search/%s", resource), http.MethodPost, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := new(SearchResults[H])
+	resp, err := s.client.CallWithRetry(req, results)
+	if err != nil {
+		return nil, resp, err
+	}
+	return results, resp, nil
+}
+
+func (s *SearchService) SearchBalances(params SearchParams) (*SearchResults[BalanceHit], *http.Response, error) {
+	return search[BalanceHit](s, params, Balances)
+}
+
+func (s *SearchService) SearchTransactions(params SearchParams) (*SearchResults[TransactionHit], *http.Response, error) {
+	return search[TransactionHit](s, params, Transactions)
+}
+
+func (s *SearchService) SearchLedgers(params SearchParams) (*SearchResults[LedgerHit], *http.Response, error) {
+	return search[LedgerHit](s, params, Ledgers)
+}
+
+// searchPageSize is the page size used when iterating over search results
+// and the caller did not set PerPage. Typesense allows at most 250.
+const searchPageSize = 100
+
+// searchAll pages through every hit of a search, starting at params.Page.
+// Hits are yielded as each page arrives, so only one page is held at a time.
+func searchAll[H any](s *SearchService, params SearchParams, resource ResourceType) iter.Seq2[H, error] {
+	return func(yield func(H, error) bool) {
+		params := params
+		var zero H
+		if params.GroupBy != "" {
+			yield(zero, fmt.Errorf("cannot iterate over grouped results"))
+			return
+		}
+		if params.PerPage <= 0 {
+			params.PerPage = searchPageSize
+		}
+		if params.Page <= 0 {
+			params.Page = 1
+		}
+		for {
+			results, _, err := search[H](s, params, resource)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, hit := range results.Hits {
+				if !yield(hit, nil) {
+					return
+				}
+			}
+			if len(results.Hits) < params.PerPage || params.Page*params.PerPage >= results.Found {
+				return
+			}
+			params.Page++
+		}
+	}
+}
+
+// AllBalances iterates over every balance matching params, fetching pages as
+// needed. Iteration stops at the first error, which is yielded with a zero hit.
+func (s *SearchService) AllBalances(params SearchParams) iter.Seq2[BalanceHit, error] {
+	return searchAll[BalanceHit](s, params, Balances)
+}
+
+// AllTransactions iterates over every transaction matching params, like
+// AllBalances.
+func (s *SearchService) AllTransactions(params SearchParams) iter.Seq2[TransactionHit, error] {
+	return searchAll[TransactionHit](s, params, Transactions)
+}
+
+// AllLedgers iterates over every ledger matching params, like AllBalances.
+func (s *SearchService) AllLedgers(params SearchParams) iter.Seq2[LedgerHit, error] {
+	return searchAll[LedgerHit](s, params, Ledgers)
+}
+
+// MultiSearch is one search of a MultiSearchRequest.
+type MultiSearch struct {
+	Resource ResourceType `json:"collection"`
+	SearchParams
+}
+
+type MultiSearchRequest struct {
+	Searches []MultiSearch `json:"searches"`
+}
+
+// MultiSearchResult is the result of one search of a MultiSearchRequest. The
+// field matching Resource is set, unless the search failed, in which case Err
+// says why. One search failing does not fail the others.
+type MultiSearchResult struct {
+	Resource     ResourceType
+	Balances     *SearchResults[BalanceHit]
+	Transactions *SearchResults[TransactionHit]
+	Ledgers      *SearchResults[LedgerHit]
+	Err          error
+}
+
+// MultiSearch runs several searches, of any resources, in one request. The
+// results are in the order of the searches.
+func (s *SearchService) MultiSearch(body MultiSearchRequest) ([]MultiSearchResult, *http.Response, error) {
+	if len(body.Searches) == 0 {
+		return nil, nil, fmt.Errorf("at least one search is required")
+	}
+	for _, m := range body.Searches {
+		if _, ok := searchFields[m.Resource]; !ok {
+			return nil, nil, fmt.Errorf("cannot search %q, want ledgers, balances or transactions", m.Resource)
+		}
+	}
+	req, err := s.client.NewRequest("multi-search", http.MethodPost, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	var response struct {
+		Results []json.RawMessage `json:"results"`
+	}
+	resp, err := s.client.CallWithRetry(req, &response)
+	if err != nil {
+		return nil, resp, err
+	}
+	if len(response.Results) != len(body.Searches) {
+		return nil, resp, fmt.Errorf("got %d search results for %d searches", len(response.Results), len(body.Searches))
+	}
+
+	results := make([]MultiSearchResult, len(body.Searches))
+	for i, raw := range response.Results {
+		result := MultiSearchResult{Resource: body.Searches[i].Resource}
+		var failure struct {
+			Code  int    `json:"code"`
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(raw, &failure) == nil && failure.Error != "" {
+			result.Err = fmt.Errorf("search %d of %s failed: %s", i, result.Resource, failure.Error)
+			results[i] = result
+			continue
+		}
+		switch result.Resource {
+		case Balances:
+			result.Balances = new(SearchResults[BalanceHit])
+			err = json.Unmarshal(raw, result.Balances)
+		case Transactions:
+			result.Transactions = new(SearchResults[TransactionHit])
+			err = json.Unmarshal(raw, result.Transactions)
+		case Ledgers:
+			result.Ledgers = new(SearchResults[LedgerHit])
+			err = json.Unmarshal(raw, result.Ledgers)
+		}
+		if err != nil {
+			result.Balances, result.Transactions, result.Ledgers = nil, nil, nil
+			result.Err = fmt.Errorf("failed to decode search %d of %s: %w", i, result.Resource, err)
+		}
+		results[i] = result
+	}
+	return results, resp, nil
+}
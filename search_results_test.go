@@ -0,0 +1,149 @@
+package blnkgo_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// searchServer serves Typesense-style responses over total transactions and
+// records the params of each search.
+func searchServer(t *testing.T, total int) (*blnkgo.Client, *[]blnkgo.SearchParams) {
+	var received []blnkgo.SearchParams
+	hits := func(params blnkgo.SearchParams) []map[string]interface{} {
+		if params.Page <= 0 {
+			params.Page = 1
+		}
+		var page []map[string]interface{}
+		for i := (params.Page - 1) * params.PerPage; i < params.Page*params.PerPage && i < total; i++ {
+			page = append(page, map[string]interface{}{
+				"document":   map[string]interface{}{"transaction_id": fmt.Sprintf("txn_%d", i), "amount": 10.5, "currency": "USD", "created_at": 1704067200, "scheduled_for": nil},
+				"highlights": []map[string]interface{}{{"field": "reference", "snippet": "<mark>ref</mark>-1", "matched_tokens": []string{"ref"}}},
+				"text_match": 578730123365187705,
+			})
+		}
+		return page
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /search/transactions", func(w http.ResponseWriter, r *http.Request) {
+		var params blnkgo.SearchParams
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		received = append(received, params)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"found": total, "page": params.Page, "hits": hits(params),
+			"facet_counts": []map[string]interface{}{{
+				"field_name": "currency",
+				"counts":     []map[string]interface{}{{"value": "USD", "count": total}},
+			}},
+		})
+	})
+	mux.HandleFunc("POST /multi-search", func(w http.ResponseWriter, r *http.Request) {
+		var body blnkgo.MultiSearchRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		var results []interface{}
+		for _, search := range body.Searches {
+			switch search.Resource {
+			case blnkgo.Transactions:
+				results = append(results, map[string]interface{}{"found": total, "hits": hits(blnkgo.SearchParams{Page: 1, PerPage: 1})})
+			case blnkgo.Ledgers:
+				results = append(results, map[string]interface{}{"found": 1, "hits": []interface{}{map[string]interface{}{"document": map[string]interface{}{"ledger_id": "ldg_1", "name": "Fees", "created_at": "2024-01-01T00:00:00Z"}}}})
+			default:
+				results = append(results, map[string]interface{}{"code": 404, "error": "Could not find a field named `x` in the schema."})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	baseURL, _ := url.Parse(server.URL)
+	return blnkgo.NewClient(baseURL, nil), &received
+}
+
+func TestSearchService_SearchTransactions(t *testing.T) {
+	client, received := searchServer(t, 3)
+
+	params, err := blnkgo.SearchQuery{Resource: blnkgo.Transactions, Q: "ref", QueryBy: []string{"reference"}, FacetBy: []string{"currency"}, PerPage: 10}.Params()
+	require.NoError(t, err)
+	results, _, err := client.Search.SearchTransactions(params)
+	require.NoError(t, err)
+	assert.Equal(t, "currency", (*received)[0].FacetBy)
+
+	require.Len(t, results.Hits, 3)
+	hit := results.Hits[0]
+	assert.Equal(t, "txn_0", hit.Document.TransactionID)
+	assert.Equal(t, 10.5, hit.Document.Amount)
+	assert.Equal(t, int64(1704067200), hit.Document.CreatedAt.Unix())
+	assert.Nil(t, hit.Document.ScheduledFor)
+	assert.Equal(t, int64(578730123365187705), hit.TextMatch)
+	require.Len(t, hit.Highlights, 1)
+	assert.Equal(t, "<mark>ref</mark>-1", hit.Highlights[0].Snippet)
+
+	facet := results.Facet("currency")
+	require.NotNil(t, facet)
+	assert.Equal(t, []blnkgo.FacetValue{{Value: "USD", Count: 3}}, facet.Counts)
+	assert.Nil(t, results.Facet("status"))
+}
+
+func TestSearchService_AllTransactions(t *testing.T) {
+	client, received := searchServer(t, 5)
+
+	var ids []string
+	for hit, err := range client.Search.AllTransactions(blnkgo.SearchParams{Q: "*", PerPage: 2}) {
+		require.NoError(t, err)
+		ids = append(ids, hit.Document.TransactionID)
+	}
+	assert.Equal(t, []string{"txn_0", "txn_1", "txn_2", "txn_3", "txn_4"}, ids)
+	require.Len(t, *received, 3)
+	assert.Equal(t, 3, (*received)[2].Page)
+
+	*received = nil
+	for hit, err := range client.Search.AllTransactions(blnkgo.SearchParams{Q: "*", PerPage: 2}) {
+		require.NoError(t, err)
+		if hit.Document.TransactionID == "txn_1" {
+			break
+		}
+	}
+	assert.Len(t, *received, 1, "breaking early stops paging")
+
+	for _, err := range client.Search.AllTransactions(blnkgo.SearchParams{Q: "*", GroupBy: "currency"}) {
+		assert.ErrorContains(t, err, "grouped")
+	}
+	for _, err := range client.Search.AllBalances(blnkgo.SearchParams{Q: "*"}) {
+		assert.Error(t, err, "the server has no balance search")
+	}
+}
+
+func TestSearchService_MultiSearch(t *testing.T) {
+	client, _ := searchServer(t, 4)
+
+	results, _, err := client.Search.MultiSearch(blnkgo.MultiSearchRequest{Searches: []blnkgo.MultiSearch{
+		{Resource: blnkgo.Transactions, SearchParams: blnkgo.SearchParams{Q: "*"}},
+		{Resource: blnkgo.Ledgers, SearchParams: blnkgo.SearchParams{Q: "Fees", QueryBy: "name"}},
+		{Resource: blnkgo.Balances, SearchParams: blnkgo.SearchParams{Q: "*", FilterBy: "x:=1"}},
+	}})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, 4, results[0].Transactions.Found)
+	assert.Equal(t, "txn_0", results[0].Transactions.Hits[0].Document.TransactionID)
+	assert.Nil(t, results[0].Balances)
+
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, "Fees", results[1].Ledgers.Hits[0].Document.Name)
+
+	assert.ErrorContains(t, results[2].Err, "Could not find a field")
+	assert.Nil(t, results[2].Balances)
+
+	_, _, err = client.Search.MultiSearch(blnkgo.MultiSearchRequest{})
+	assert.Error(t, err)
+	_, _, err = client.Search.MultiSearch(blnkgo.MultiSearchRequest{Searches: []blnkgo.MultiSearch{{Resource: "identities"}}})
+	assert.Error(t, err)
+}
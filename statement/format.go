@@ -0,0 +1,121 @@
+package statement
+
+import (
+	"math/big"
+	"strings"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// Locale describes how amounts are written in a given locale.
+type Locale struct {
+	DecimalSeparator string
+	GroupSeparator   string
+	// SymbolAfter places the currency symbol after the amount, e.g. "1.234,56 €".
+	SymbolAfter bool
+	// SymbolSpace separates the currency symbol from the amount with a space.
+	SymbolSpace bool
+	// SecondaryGroupSize is the size of the digit groups left of the first
+	// group of three, e.g. 2 for Indian grouping (12,34,567). Zero means 3.
+	SecondaryGroupSize int
+}
+
+// Locales holds the built-in locales, keyed by BCP 47 tag. Add entries to
+// support other locales.
+var Locales = map[string]Locale{
+	"en-US": {DecimalSeparator: ".", GroupSeparator: ","},
+	"en-GB": {DecimalSeparator: ".", GroupSeparator: ","},
+	"en-NG": {DecimalSeparator: ".", GroupSeparator: ","},
+	"en-KE": {DecimalSeparator: ".", GroupSeparator: ","},
+	"en-IN": {DecimalSeparator: ".", GroupSeparator: ",", SecondaryGroupSize: 2},
+	"de-DE": {DecimalSeparator: ",", GroupSeparator: ".", SymbolAfter: true, SymbolSpace: true},
+	"es-ES": {DecimalSeparator: ",", GroupSeparator: ".", SymbolAfter: true, SymbolSpace: true},
+	"fr-FR": {DecimalSeparator: ",", GroupSeparator: " ", SymbolAfter: true, SymbolSpace: true},
+	"pt-BR": {DecimalSeparator: ",", GroupSeparator: ".", SymbolSpace: true},
+	"ja-JP": {DecimalSeparator: ".", GroupSeparator: ","},
+}
+
+// CurrencySymbols maps ISO 4217 codes to their symbol. Currencies that are not
+// listed are written with their code.
+var CurrencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"NGN": "₦",
+	"JPY": "¥",
+	"BRL": "R$",
+	"INR": "₹",
+	"KES": "KSh",
+	"GHS": "GH₵",
+	"ZAR": "R",
+	"CAD": "CA$",
+	"AUD": "A$",
+}
+
+// Formatter writes amounts in minor units as localized currency strings.
+type Formatter struct {
+	Locale Locale
+}
+
+// NewFormatter returns a formatter for the given locale tag, falling back to
+// en-US for unknown tags.
+func NewFormatter(tag string) Formatter {
+	locale, ok := Locales[tag]
+	if !ok {
+		locale = Locales["en-US"]
+	}
+	return Formatter{Locale: locale}
+}
+
+// Number formats amount as a grouped decimal number without currency symbol,
+// with the number of decimals given by precision.
+func (f Formatter) Number(amount *big.Int, precision int64) string {
+	plain := blnkgo.FormatPreciseAmount(amount, precision)
+	negative := strings.HasPrefix(plain, "-")
+	plain = strings.TrimPrefix(plain, "-")
+
+	integer, fraction, hasFraction := strings.Cut(plain, ".")
+	secondary := f.Locale.SecondaryGroupSize
+	if secondary <= 0 {
+		secondary = 3
+	}
+	var grouped strings.Builder
+	for i, digit := range integer {
+		// digits from this one to the decimal point
+		left := len(integer) - i
+		if i > 0 && left >= 3 && (left-3)%secondary == 0 {
+			grouped.WriteString(f.Locale.GroupSeparator)
+		}
+		grouped.WriteRune(digit)
+	}
+
+	result := grouped.String()
+	if hasFraction {
+		result += f.Locale.DecimalSeparator + fraction
+	}
+	if negative {
+		result = "-" + result
+	}
+	return result
+}
+
+// Money formats amount with the symbol of currency.
+func (f Formatter) Money(amount *big.Int, precision int64, currency string) string {
+	number := f.Number(amount, precision)
+	symbol, ok := CurrencySymbols[currency]
+	if !ok {
+		symbol = currency
+	}
+
+	space := ""
+	if f.Locale.SymbolSpace || !ok {
+		space = " "
+	}
+	if f.Locale.SymbolAfter {
+		return number + space + symbol
+	}
+	if strings.HasPrefix(number, "-") {
+		return "-" + symbol + space + strings.TrimPrefix(number, "-")
+	}
+	return symbol + space + number
+}
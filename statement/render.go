@@ -0,0 +1,143 @@
+package statement
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	htmltemplate "html/template"
+	"io"
+	"math/big"
+	texttemplate "text/template"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// RenderOptions controls how a statement is rendered. Template replaces the
+// built-in template of RenderHTML and RenderText; it can use the functions
+// money, number and date.
+type RenderOptions struct {
+	Locale   string
+	Template string
+}
+
+const defaultTextTemplate = `STATEMENT OF ACCOUNT
+{{with .Header}}{{if .Name}}{{.Name}}
+{{end}}{{range .Address}}{{.}}
+{{end}}Balance: {{.BalanceID}} ({{.Currency}})
+{{end}}Period: {{date .From}} - {{date .To}}
+
+Opening balance: {{money .OpeningBalance}}
+{{range .Lines}}
+{{date .Date}}  {{.Reference}}  {{.Description}}
+    credit {{money .Credit}}  debit {{money .Debit}}  balance {{money .RunningBalance}}
+{{end}}
+Total credits:   {{money .TotalCredits}}
+Total debits:    {{money .TotalDebits}}
+Closing balance: {{money .ClosingBalance}}
+`
+
+const defaultHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Statement {{.Header.BalanceID}}</title></head>
+<body>
+<h1>Statement of account</h1>
+<section class="header">
+{{with .Header}}{{if .Name}}<p class="name">{{.Name}}</p>{{end}}
+{{range .Address}}<p class="address">{{.}}</p>{{end}}
+<p>Balance {{.BalanceID}} ({{.Currency}})</p>{{end}}
+<p>Period {{date .From}} - {{date .To}}</p>
+</section>
+<table>
+<thead><tr><th>Date</th><th>Reference</th><th>Description</th><th>Credit</th><th>Debit</th><th>Balance</th></tr></thead>
+<tbody>
+<tr class="opening"><td>{{date .From}}</td><td></td><td>Opening balance</td><td></td><td></td><td>{{money .OpeningBalance}}</td></tr>
+{{range .Lines}}<tr><td>{{date .Date}}</td><td>{{.Reference}}</td><td>{{.Description}}</td><td>{{money .Credit}}</td><td>{{money .Debit}}</td><td>{{money .RunningBalance}}</td></tr>
+{{end}}<tr class="closing"><td>{{date .To}}</td><td></td><td>Closing balance</td><td>{{money .TotalCredits}}</td><td>{{money .TotalDebits}}</td><td>{{money .ClosingBalance}}</td></tr>
+</tbody>
+</table>
+</body>
+</html>
+`
+
+func (s *Statement) templateFuncs(locale string) map[string]interface{} {
+	formatter := NewFormatter(locale)
+	return map[string]interface{}{
+		"money": func(amount *big.Int) string {
+			return formatter.Money(amount, s.Precision, s.Header.Currency)
+		},
+		"number": func(amount *big.Int) string {
+			return formatter.Number(amount, s.Precision)
+		},
+		"date": func(t time.Time) string {
+			return t.Format("2006-01-02")
+		},
+	}
+}
+
+// RenderText writes the statement as plain text.
+func (s *Statement) RenderText(w io.Writer, opts RenderOptions) error {
+	source := opts.Template
+	if source == "" {
+		source = defaultTextTemplate
+	}
+	tmpl, err := texttemplate.New("statement").Funcs(s.templateFuncs(opts.Locale)).Parse(source)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, s)
+}
+
+// RenderHTML writes the statement as an HTML document. Values are escaped by
+// html/template.
+func (s *Statement) RenderHTML(w io.Writer, opts RenderOptions) error {
+	source := opts.Template
+	if source == "" {
+		source = defaultHTMLTemplate
+	}
+	tmpl, err := htmltemplate.New("statement").Funcs(s.templateFuncs(opts.Locale)).Parse(source)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, s)
+}
+
+// WriteCSV writes one row per line, starting with the opening balance and
+// ending with the closing balance. Amounts are plain decimals so the file can be
+// imported into spreadsheets regardless of locale.
+func (s *Statement) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	amount := func(v *big.Int) string {
+		return blnkgo.FormatPreciseAmount(v, s.Precision)
+	}
+
+	rows := [][]string{
+		{"date", "transaction_id", "reference", "description", "counterparty", "credit", "debit", "balance", "currency"},
+		{s.From.Format(time.RFC3339), "", "", "Opening balance", "", "", "", amount(s.OpeningBalance), s.Header.Currency},
+	}
+	for _, line := range s.Lines {
+		rows = append(rows, []string{
+			line.Date.Format(time.RFC3339),
+			line.TransactionID,
+			line.Reference,
+			line.Description,
+			line.Counterparty,
+			amount(line.Credit),
+			amount(line.Debit),
+			amount(line.RunningBalance),
+			s.Header.Currency,
+		})
+	}
+	rows = append(rows, []string{s.To.Format(time.RFC3339), "", "", "Closing balance", "", amount(s.TotalCredits), amount(s.TotalDebits), amount(s.ClosingBalance), s.Header.Currency})
+
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// WriteJSON writes the statement as JSON, with amounts in minor units.
+func (s *Statement) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
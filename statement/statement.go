@@ -0,0 +1,263 @@
+// Package statement builds customer-facing account statements for a Blnk
+// balance: the opening balance, every transaction in the period with a running
+// balance and a closing balance checked against the server.
+package statement
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+)
+
+// Header identifies the account holder and the balance a statement is for.
+type Header struct {
+	IdentityID string   `json:"identity_id,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Email      string   `json:"email,omitempty"`
+	Address    []string `json:"address,omitempty"`
+	BalanceID  string   `json:"balance_id"`
+	LedgerID   string   `json:"ledger_id,omitempty"`
+	Currency   string   `json:"currency"`
+}
+
+// Line is a single transaction on a statement. Exactly one of Credit and Debit
+// is non-zero, except for transfers from a balance to itself.
+//
+// Date is when the transaction was recorded, which is what places it in a
+// period and orders the running balance, as it is for the opening and closing
+// balances. EffectiveDate is the value date of a backdated transaction.
+type Line struct {
+	TransactionID  string     `json:"transaction_id"`
+	Reference      string     `json:"reference"`
+	Description    string     `json:"description"`
+	Date           time.Time  `json:"date"`
+	EffectiveDate  *time.Time `json:"effective_date,omitempty"`
+	Counterparty   string     `json:"counterparty"`
+	Credit         *big.Int   `json:"credit"`
+	Debit          *big.Int   `json:"debit"`
+	RunningBalance *big.Int   `json:"running_balance"`
+}
+
+// Statement is the statement of a balance between From (inclusive) and To
+// (exclusive). Amounts are in minor units of Precision.
+type Statement struct {
+	Header         Header    `json:"header"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	Precision      int64     `json:"precision"`
+	OpeningBalance *big.Int  `json:"opening_balance"`
+	Lines          []Line    `json:"lines"`
+	TotalCredits   *big.Int  `json:"total_credits"`
+	TotalDebits    *big.Int  `json:"total_debits"`
+	ClosingBalance *big.Int  `json:"closing_balance"`
+	// ServerClosingBalance is the balance reported by the server at To. It
+	// equals ClosingBalance when the statement reconciles.
+	ServerClosingBalance *big.Int  `json:"server_closing_balance"`
+	Reconciled           bool      `json:"reconciled"`
+	GeneratedAt          time.Time `json:"generated_at"`
+}
+
+// ReconciliationError is returned with the statement when the computed closing
+// balance does not match the balance reported by the server.
+type ReconciliationError struct {
+	Computed *big.Int
+	Server   *big.Int
+}
+
+func (e *ReconciliationError) Error() string {
+	return fmt.Sprintf("statement does not reconcile: computed closing balance %s, server closing balance %s", e.Computed, e.Server)
+}
+
+// Generator fetches the data a statement needs from Blnk.
+type Generator struct {
+	balances     *blnkgo.LedgerBalanceService
+	transactions *blnkgo.TransactionService
+	identities   *blnkgo.IdentityService
+}
+
+func NewGenerator(client blnkgo.ClientInterface) *Generator {
+	return &Generator{
+		balances:     blnkgo.NewLedgerBalanceService(client),
+		transactions: blnkgo.NewTransactionService(client),
+		identities:   blnkgo.NewIdentityService(client),
+	}
+}
+
+// Generate builds the statement of balanceID for [from, to). When the computed
+// closing balance does not match the server, the statement is returned together
+// with a *ReconciliationError.
+func (g *Generator) Generate(balanceID string, from, to time.Time) (*Statement, error) {
+	if balanceID == "" {
+		return nil, fmt.Errorf("invalid: balanceID is required")
+	}
+	if from.IsZero() || !from.Before(to) {
+		return nil, fmt.Errorf("invalid: from must be before to")
+	}
+
+	balance, _, err := g.balances.Get(balanceID)
+	if err != nil {
+		return nil, fmt.Errorf("fetching balance: %w", err)
+	}
+
+	header := Header{
+		IdentityID: balance.IdentityID,
+		BalanceID:  balance.BalanceID,
+		LedgerID:   balance.LedgerID,
+		Currency:   balance.Currency,
+	}
+	if balance.IdentityID != "" {
+		identity, _, err := g.identities.Get(balance.IdentityID)
+		if err != nil {
+			return nil, fmt.Errorf("fetching identity: %w", err)
+		}
+		header = withIdentity(header, identity)
+	}
+
+	opening, _, err := g.balances.GetHistorical(balanceID, from, false)
+	if err != nil {
+		return nil, fmt.Errorf("fetching opening balance: %w", err)
+	}
+	closing, _, err := g.balances.GetHistorical(balanceID, to, false)
+	if err != nil {
+		return nil, fmt.Errorf("fetching closing balance: %w", err)
+	}
+
+	transactions, err := g.periodTransactions(balanceID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	precision := int64(balance.Precision)
+	if precision <= 0 && len(transactions) > 0 {
+		precision = transactions[0].Precision
+	}
+	if precision <= 0 {
+		precision = 100
+	}
+
+	statement := &Statement{
+		Header:               header,
+		From:                 from,
+		To:                   to,
+		Precision:            precision,
+		OpeningBalance:       new(big.Int),
+		TotalCredits:         new(big.Int),
+		TotalDebits:          new(big.Int),
+		ServerClosingBalance: new(big.Int),
+		Lines:                make([]Line, 0, len(transactions)),
+		GeneratedAt:          time.Now().UTC(),
+	}
+
+	if opening.Balance.Balance != nil {
+		statement.OpeningBalance.Set(opening.Balance.Balance)
+	}
+	if closing.Balance.Balance != nil {
+		statement.ServerClosingBalance.Set(closing.Balance.Balance)
+	}
+
+	running := new(big.Int).Set(statement.OpeningBalance)
+	for _, transaction := range transactions {
+		amount := blnkgo.RescalePreciseAmount(transaction.PreciseValue(), transaction.Precision, precision)
+		line := Line{
+			TransactionID: transaction.TransactionID,
+			Reference:     transaction.Reference,
+			Description:   transaction.Description,
+			Date:          transaction.CreatedAt,
+			EffectiveDate: transaction.EffectiveDate,
+			Credit:        new(big.Int),
+			Debit:         new(big.Int),
+		}
+		if transaction.Destination == balanceID {
+			line.Credit.Set(amount)
+			line.Counterparty = transaction.Source
+		}
+		if transaction.Source == balanceID {
+			line.Debit.Set(amount)
+			line.Counterparty = transaction.Destination
+		}
+		running.Add(running, line.Credit).Sub(running, line.Debit)
+		line.RunningBalance = new(big.Int).Set(running)
+
+		statement.TotalCredits.Add(statement.TotalCredits, line.Credit)
+		statement.TotalDebits.Add(statement.TotalDebits, line.Debit)
+		statement.Lines = append(statement.Lines, line)
+	}
+
+	statement.ClosingBalance = running
+	statement.Reconciled = running.Cmp(statement.ServerClosingBalance) == 0
+	if !statement.Reconciled {
+		return statement, &ReconciliationError{Computed: new(big.Int).Set(running), Server: statement.ServerClosingBalance}
+	}
+	return statement, nil
+}
+
+// periodTransactions returns the applied transactions of the balance recorded
+// in the period, oldest first. Historical balances move when a transaction is
+// recorded, so filtering on created_at keeps opening balance plus movements
+// equal to the closing balance, backdated transactions included.
+func (g *Generator) periodTransactions(balanceID string, from, to time.Time) ([]blnkgo.Transaction, error) {
+	var transactions []blnkgo.Transaction
+	seen := make(map[string]bool)
+	for _, field := range []string{"source", "destination"} {
+		found, err := g.transactions.FilterAll(blnkgo.FilterParams{
+			Filters: []blnkgo.Filter{
+				{Field: field, Operator: blnkgo.OpEqual, Value: balanceID},
+				{Field: "status", Operator: blnkgo.OpEqual, Value: string(blnkgo.PryTransactionStatusApplied)},
+				{Field: "created_at", Operator: blnkgo.OpGreaterThanOrEqual, Value: from.Format(time.RFC3339)},
+				{Field: "created_at", Operator: blnkgo.OpLessThan, Value: to.Format(time.RFC3339)},
+			},
+			SortBy:    "created_at",
+			SortOrder: "asc",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fetching transactions: %w", err)
+		}
+		for _, transaction := range found {
+			if !seen[transaction.TransactionID] {
+				seen[transaction.TransactionID] = true
+				transactions = append(transactions, transaction)
+			}
+		}
+	}
+
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].CreatedAt.Before(transactions[j].CreatedAt)
+	})
+	return transactions, nil
+}
+
+func withIdentity(header Header, identity *blnkgo.IdentityResponse) Header {
+	if identity.IdentityType == blnkgo.Organization {
+		header.Name = identity.OrganizationName
+	} else {
+		header.Name = joinNonEmpty(" ", identity.FirstName, identity.OtherNames, identity.LastName)
+	}
+	header.Email = identity.EmailAddress
+	for _, line := range []string{
+		identity.Street,
+		joinNonEmpty(", ", identity.City, identity.State),
+		joinNonEmpty(" ", identity.PostCode, identity.Country),
+	} {
+		if line != "" {
+			header.Address = append(header.Address, line)
+		}
+	}
+	return header
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	joined := ""
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if joined != "" {
+			joined += sep
+		}
+		joined += part
+	}
+	return joined
+}
@@ -0,0 +1,193 @@
+package statement_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
+	"github.com/blnkfinance/blnk-go/statement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	periodStart = time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd   = time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func newTestServer(t *testing.T, closing int64) *blnkgo.Client {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/balances/bln_1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"balance_id":"bln_1","ledger_id":"ldg_1","identity_id":"idt_1","currency":"EUR","precision":100,"balance":0}`)
+	})
+	mux.HandleFunc("/balances/bln_1/at", func(w http.ResponseWriter, r *http.Request) {
+		balance := int64(10000)
+		if r.URL.Query().Get("timestamp") == periodEnd.Format(time.RFC3339) {
+			balance = closing
+		}
+		fmt.Fprintf(w, `{"balance":{"balance_id":"bln_1","currency":"EUR","balance":%d,"credit_balance":0,"debit_balance":0},"timestamp":"%s"}`,
+			balance, r.URL.Query().Get("timestamp"))
+	})
+	mux.HandleFunc("/identities/idt_1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"identity_id":"idt_1","identity_type":"individual","first_name":"Ada","last_name":"Obi","street":"1 Marina Road","city":"Lagos","country":"NG"}`)
+	})
+	mux.HandleFunc("/transactions/filter", func(w http.ResponseWriter, r *http.Request) {
+		var params blnkgo.FilterParams
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		switch params.Filters[0].Field {
+		case "destination":
+			fmt.Fprint(w, `[
+				{"transaction_id":"txn_1","reference":"salary-march","description":"Salary","amount":2500.5,"precision":100,"currency":"EUR","source":"@payroll","destination":"bln_1","created_at":"2024-03-01T09:00:00Z"},
+				{"transaction_id":"txn_3","reference":"refund","description":"Refund","precise_amount":1999,"amount":19.99,"precision":100,"currency":"EUR","source":"@shop","destination":"bln_1","created_at":"2024-03-20T09:00:00Z"}
+			]`)
+		case "source":
+			fmt.Fprint(w, `[
+				{"transaction_id":"txn_2","reference":"rent-march","description":"Rent <March>","amount":1200,"precision":100,"currency":"EUR","source":"bln_1","destination":"@landlord","created_at":"2024-03-05T09:00:00Z"}
+			]`)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	baseURL, _ := url.Parse(server.URL)
+	return blnkgo.NewClient(baseURL, nil)
+}
+
+func TestGenerator_Generate(t *testing.T) {
+	client := newTestServer(t, 142049)
+
+	stmt, err := statement.NewGenerator(client).Generate("bln_1", periodStart, periodEnd)
+
+	require.NoError(t, err)
+	assert.True(t, stmt.Reconciled)
+	assert.Equal(t, "Ada Obi", stmt.Header.Name)
+	assert.Equal(t, []string{"1 Marina Road", "Lagos", "NG"}, stmt.Header.Address)
+	assert.Equal(t, int64(100), stmt.Precision)
+	require.Len(t, stmt.Lines, 3)
+
+	assert.Equal(t, "txn_1", stmt.Lines[0].TransactionID)
+	assert.Equal(t, big.NewInt(250050), stmt.Lines[0].Credit)
+	assert.Equal(t, big.NewInt(260050), stmt.Lines[0].RunningBalance)
+	assert.Equal(t, "txn_2", stmt.Lines[1].TransactionID)
+	assert.Equal(t, big.NewInt(120000), stmt.Lines[1].Debit)
+	assert.Equal(t, "@landlord", stmt.Lines[1].Counterparty)
+	assert.Equal(t, big.NewInt(142049), stmt.Lines[2].RunningBalance)
+
+	assert.Equal(t, big.NewInt(252049), stmt.TotalCredits)
+	assert.Equal(t, big.NewInt(120000), stmt.TotalDebits)
+	assert.Equal(t, big.NewInt(142049), stmt.ClosingBalance)
+}
+
+func TestGenerator_Generate_Backdated(t *testing.T) {
+	server := blnktest.NewServer(t)
+	now := periodStart.Add(24 * time.Hour)
+	server.SetClock(func() time.Time { return now })
+	client := server.Client()
+	ledger, _, err := client.Ledger.Create(blnkgo.CreateLedgerRequest{Name: "Wallets"})
+	require.NoError(t, err)
+	balance, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: ledger.LedgerID, Currency: "EUR"})
+	require.NoError(t, err)
+
+	create := func(reference string, amount float64, effective *time.Time) {
+		_, _, err := client.Transaction.Create(blnkgo.CreateTransactionRequest{
+			ParentTransaction: blnkgo.ParentTransaction{
+				Reference: reference, Amount: amount, Precision: 100, Currency: "EUR",
+				Source: "@world", Destination: balance.BalanceID, EffectiveDate: effective,
+			},
+		})
+		require.NoError(t, err)
+	}
+	create("opening", 100, nil)
+	now = periodStart.Add(10 * 24 * time.Hour)
+	// recorded in March, backdated to February
+	february := periodStart.Add(-5 * 24 * time.Hour)
+	create("backdated", 25, &february)
+	now = periodEnd.Add(time.Hour)
+	create("april", 7, nil)
+
+	stmt, err := statement.NewGenerator(client).Generate(balance.BalanceID, periodStart.Add(2*24*time.Hour), periodEnd)
+	require.NoError(t, err)
+	assert.True(t, stmt.Reconciled)
+	require.Len(t, stmt.Lines, 1)
+	assert.Equal(t, "backdated", stmt.Lines[0].Reference)
+	require.NotNil(t, stmt.Lines[0].EffectiveDate)
+	assert.True(t, stmt.Lines[0].EffectiveDate.Equal(february))
+	assert.Equal(t, big.NewInt(10000), stmt.OpeningBalance)
+	assert.Equal(t, big.NewInt(12500), stmt.ClosingBalance)
+}
+
+func TestGenerator_Generate_NotReconciled(t *testing.T) {
+	client := newTestServer(t, 142000)
+
+	stmt, err := statement.NewGenerator(client).Generate("bln_1", periodStart, periodEnd)
+
+	var reconErr *statement.ReconciliationError
+	require.True(t, errors.As(err, &reconErr))
+	assert.Equal(t, big.NewInt(142049), reconErr.Computed)
+	assert.Equal(t, big.NewInt(142000), reconErr.Server)
+	require.NotNil(t, stmt)
+	assert.False(t, stmt.Reconciled)
+}
+
+func TestGenerator_Generate_InvalidInput(t *testing.T) {
+	generator := statement.NewGenerator(newTestServer(t, 0))
+
+	_, err := generator.Generate("", periodStart, periodEnd)
+	assert.Error(t, err)
+	_, err = generator.Generate("bln_1", periodEnd, periodStart)
+	assert.Error(t, err)
+}
+
+func TestStatement_Render(t *testing.T) {
+	stmt, err := statement.NewGenerator(newTestServer(t, 142049)).Generate("bln_1", periodStart, periodEnd)
+	require.NoError(t, err)
+
+	var text bytes.Buffer
+	require.NoError(t, stmt.RenderText(&text, statement.RenderOptions{Locale: "de-DE"}))
+	assert.Contains(t, text.String(), "Opening balance: 100,00 €")
+	assert.Contains(t, text.String(), "Closing balance: 1.420,49 €")
+
+	var html bytes.Buffer
+	require.NoError(t, stmt.RenderHTML(&html, statement.RenderOptions{Locale: "en-GB"}))
+	assert.Contains(t, html.String(), "Rent &lt;March&gt;")
+	assert.Contains(t, html.String(), "€2,600.50")
+
+	var custom bytes.Buffer
+	require.NoError(t, stmt.RenderText(&custom, statement.RenderOptions{Template: `{{.Header.Name}}: {{number .ClosingBalance}}`}))
+	assert.Equal(t, "Ada Obi: 1,420.49", custom.String())
+
+	var csvBuf bytes.Buffer
+	require.NoError(t, stmt.WriteCSV(&csvBuf))
+	rows, err := csv.NewReader(&csvBuf).ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, rows, 6)
+	assert.Equal(t, []string{"2024-03-05T09:00:00Z", "txn_2", "rent-march", "Rent <March>", "@landlord", "0.00", "1200.00", "1400.50", "EUR"}, rows[3])
+
+	var jsonBuf bytes.Buffer
+	require.NoError(t, stmt.WriteJSON(&jsonBuf))
+	assert.True(t, strings.Contains(jsonBuf.String(), `"closing_balance": 142049`))
+}
+
+func TestFormatter_Money(t *testing.T) {
+	amount := big.NewInt(-123456789)
+
+	assert.Equal(t, "-$1,234,567.89", statement.NewFormatter("en-US").Money(amount, 100, "USD"))
+	assert.Equal(t, "-1 234 567,89 €", statement.NewFormatter("fr-FR").Money(amount, 100, "EUR"))
+	assert.Equal(t, "R$ 1.234.567,89", statement.NewFormatter("pt-BR").Money(big.NewInt(123456789), 100, "BRL"))
+	assert.Equal(t, "CHF 1,234,567.89", statement.NewFormatter("unknown").Money(big.NewInt(123456789), 100, "CHF"))
+	assert.Equal(t, "¥1,234", statement.NewFormatter("ja-JP").Money(big.NewInt(1234), 1, "JPY"))
+	assert.Equal(t, "-₹12,34,567.89", statement.NewFormatter("en-IN").Money(amount, 100, "INR"))
+	assert.Equal(t, "₹1,00,00,000", statement.NewFormatter("en-IN").Money(big.NewInt(10000000), 1, "INR"))
+	assert.Equal(t, "₹999", statement.NewFormatter("en-IN").Money(big.NewInt(999), 1, "INR"))
+}
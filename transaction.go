@@ -43,8 +43,12 @@ type CreateTransactionRequest struct {
 
 type Transaction struct {
 	ParentTransaction
-	CreatedAt     time.Time `json:"created_at"`
-	TransactionID string    `json:"transaction_id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	TransactionID       string     `json:"transaction_id"`
+	ParentTransactionID string     `json:"parent_transaction,omitempty"`
+	Hash                string     `json:"hash,omitempty"`
+	Inflight            bool       `json:"inflight,omitempty"`
+	ScheduledFor        *time.Time `json:"scheduled_for,omitempty"`
 }
 
 type UpdateStatus struct {
@@ -93,7 +97,12 @@ func (s *TransactionService) Update(transactionID string, body UpdateStatus) (*T
 	return transaction, resp, nil
 }
 
+// Refund refunds the full amount of a transaction. Use RefundWithOptions for
+// partial refunds.
 func (s *TransactionService) Refund(transactionID string) (*Transaction, *http.Response, error) {
+	if transactionID == "" {
+		return nil, nil, fmt.Errorf("transactionID is required")
+	}
 	u := fmt.Sprintf("refund-transaction/%s", transactionID)
 	req, err := s.client.NewRequest(u, http.MethodPost, nil)
 	if err != nil {
@@ -144,6 +153,12 @@ func (s *TransactionService) Filter(params FilterParams) (*FilterResponse, *http
 	return &filterResponse, resp, nil
 }
 
+// FilterAll pages through every transaction matching params. params.Limit is used as
+// the page size.
+func (s *TransactionService) FilterAll(params FilterParams) ([]Transaction, error) {
+	return filterAll[Transaction](s.Filter, params)
+}
+
 func NewTransactionService(client ClientInterface) *TransactionService {
 	return &TransactionService{client: client}
 }
@@ -0,0 +1,349 @@
+package blnkgo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// ChainRecord is the part of a transaction needed to verify its hash and
+// lineage. It can be built from a Transaction, a SearchDocument or read from an
+// offline export with ReadChainRecords.
+type ChainRecord struct {
+	TransactionID     string    `json:"transaction_id"`
+	ParentTransaction string    `json:"parent_transaction,omitempty"`
+	Hash              string    `json:"hash"`
+	Reference         string    `json:"reference"`
+	Currency          string    `json:"currency"`
+	Source            string    `json:"source"`
+	Destination       string    `json:"destination"`
+	Amount            float64   `json:"amount"`
+	PreciseAmount     *big.Int  `json:"precise_amount,omitempty"`
+	Precision         int64     `json:"precision"`
+	Rate              float64   `json:"rate,omitempty"`
+	Status            string    `json:"status"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// ChainRecordFromTransaction builds a ChainRecord from a Transaction.
+func ChainRecordFromTransaction(t Transaction) ChainRecord {
+	return ChainRecord{
+		TransactionID:     t.TransactionID,
+		ParentTransaction: t.ParentTransactionID,
+		Hash:              t.Hash,
+		Reference:         t.Reference,
+		Currency:          t.Currency,
+		Source:            t.Source,
+		Destination:       t.Destination,
+		Amount:            t.Amount,
+		PreciseAmount:     t.PreciseAmount,
+		Precision:         t.Precision,
+		Rate:              t.Rate,
+		Status:            string(t.Status),
+		CreatedAt:         t.CreatedAt,
+	}
+}
+
+// ChainRecordFromSearchDocument builds a ChainRecord from a search hit.
+func ChainRecordFromSearchDocument(d SearchDocument) ChainRecord {
+	record := ChainRecord{
+		TransactionID:     d.TransactionID,
+		ParentTransaction: d.ParentTransaction,
+		Hash:              d.Hash,
+		Reference:         d.Reference,
+		Currency:          d.Currency,
+		Source:            d.Source,
+		Destination:       d.Destination,
+		Amount:            d.Amount,
+		Precision:         int64(d.Precision),
+		Rate:              d.Rate,
+		Status:            d.Status,
+		CreatedAt:         d.CreatedAt.Time,
+	}
+	if preciseAmount, ok := new(big.Int).SetString(d.PreciseAmount, 10); ok {
+		record.PreciseAmount = preciseAmount
+	}
+	return record
+}
+
+// preciseValue returns the amount in minor units.
+func (r ChainRecord) preciseValue() *big.Int {
+	return ParentTransaction{Amount: r.Amount, Precision: r.Precision, PreciseAmount: r.PreciseAmount}.PreciseValue()
+}
+
+// TransactionHashFunc computes the hash the server stores for a transaction.
+type TransactionHashFunc func(record ChainRecord) string
+
+// BlnkTransactionHash mirrors the server's Transaction.HashTxn: the SHA-256 of
+// the amount (formatted with %f), reference, currency, source and destination.
+func BlnkTransactionHash(record ChainRecord) string {
+	data := fmt.Sprintf("%f%s%s%s%s", record.Amount, record.Reference, record.Currency, record.Source, record.Destination)
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+type ChainIssueKind string
+
+const (
+	ChainIssueMissingHash        ChainIssueKind = "missing_hash"
+	ChainIssueHashMismatch       ChainIssueKind = "hash_mismatch"
+	ChainIssueDuplicate          ChainIssueKind = "duplicate_transaction"
+	ChainIssueMissingParent      ChainIssueKind = "missing_parent"
+	ChainIssueCycle              ChainIssueKind = "lineage_cycle"
+	ChainIssueCurrencyMismatch   ChainIssueKind = "currency_mismatch"
+	ChainIssueChildrenExceedRoot ChainIssueKind = "children_exceed_parent"
+)
+
+// ChainIssue is a single integrity problem found by a ChainVerifier.
+type ChainIssue struct {
+	Kind          ChainIssueKind `json:"kind"`
+	TransactionID string         `json:"transaction_id"`
+	Expected      string         `json:"expected,omitempty"`
+	Actual        string         `json:"actual,omitempty"`
+	Message       string         `json:"message"`
+}
+
+// ChainReport is the result of verifying a set of transactions.
+type ChainReport struct {
+	VerifiedAt time.Time    `json:"verified_at"`
+	Checked    int          `json:"checked"`
+	Issues     []ChainIssue `json:"issues"`
+}
+
+// Valid reports whether no issue was found.
+func (r *ChainReport) Valid() bool {
+	return len(r.Issues) == 0
+}
+
+// ChainVerifier recomputes transaction hashes and checks parent/child links
+// (refunds, inflight commits and voids, split legs) for tamper evidence.
+type ChainVerifier struct {
+	// Hash computes the expected hash of a record. Defaults to BlnkTransactionHash.
+	Hash TransactionHashFunc
+	// AllowMissingParents skips missing_parent issues, for exports that only
+	// cover part of the history.
+	AllowMissingParents bool
+}
+
+func NewChainVerifier() *ChainVerifier {
+	return &ChainVerifier{Hash: BlnkTransactionHash}
+}
+
+// Verify checks every record and returns all issues found.
+func (v *ChainVerifier) Verify(records []ChainRecord) *ChainReport {
+	hashFunc := v.Hash
+	if hashFunc == nil {
+		hashFunc = BlnkTransactionHash
+	}
+	report := &ChainReport{VerifiedAt: time.Now().UTC(), Checked: len(records), Issues: []ChainIssue{}}
+	addIssue := func(issue ChainIssue) {
+		report.Issues = append(report.Issues, issue)
+	}
+
+	byID := make(map[string]ChainRecord, len(records))
+	children := make(map[string][]ChainRecord)
+	for _, record := range records {
+		if _, ok := byID[record.TransactionID]; ok {
+			addIssue(ChainIssue{Kind: ChainIssueDuplicate, TransactionID: record.TransactionID, Message: "transaction appears more than once"})
+			continue
+		}
+		byID[record.TransactionID] = record
+
+		switch expected := hashFunc(record); {
+		case record.Hash == "":
+			addIssue(ChainIssue{Kind: ChainIssueMissingHash, TransactionID: record.TransactionID, Expected: expected, Message: "transaction has no stored hash"})
+		case record.Hash != expected:
+			addIssue(ChainIssue{Kind: ChainIssueHashMismatch, TransactionID: record.TransactionID, Expected: expected, Actual: record.Hash, Message: "stored hash does not match the recomputed hash"})
+		}
+
+		if record.ParentTransaction != "" {
+			children[record.ParentTransaction] = append(children[record.ParentTransaction], record)
+		}
+	}
+
+	linked := make(map[string]bool)
+	for _, record := range records {
+		if record.ParentTransaction == "" || linked[record.TransactionID] {
+			continue
+		}
+		linked[record.TransactionID] = true
+		parent, ok := byID[record.ParentTransaction]
+		if !ok {
+			if !v.AllowMissingParents {
+				addIssue(ChainIssue{Kind: ChainIssueMissingParent, TransactionID: record.TransactionID, Expected: record.ParentTransaction, Message: "parent transaction is not in the export"})
+			}
+			continue
+		}
+		if parent.Rate == 0 && record.Rate == 0 && parent.Currency != record.Currency {
+			addIssue(ChainIssue{Kind: ChainIssueCurrencyMismatch, TransactionID: record.TransactionID, Expected: parent.Currency, Actual: record.Currency, Message: "child currency differs from its parent"})
+		}
+		if hasCycle(record, byID) {
+			addIssue(ChainIssue{Kind: ChainIssueCycle, TransactionID: record.TransactionID, Message: "parent links form a cycle"})
+		}
+	}
+
+	parentIDs := make([]string, 0, len(children))
+	for parentID := range children {
+		parentIDs = append(parentIDs, parentID)
+	}
+	sort.Strings(parentIDs)
+	for _, parentID := range parentIDs {
+		parent, ok := byID[parentID]
+		if !ok || parent.Rate != 0 {
+			continue
+		}
+		// refunds reverse the parent, every other child (commits, voids,
+		// split legs) draws down from it; neither group may exceed the parent
+		refunds, drawdowns := new(big.Int), new(big.Int)
+		for _, child := range children[parentID] {
+			amount := RescalePreciseAmount(child.preciseValue(), child.Precision, parent.Precision)
+			if child.Source == parent.Destination && child.Destination == parent.Source && parent.Source != "" {
+				refunds.Add(refunds, amount)
+			} else {
+				drawdowns.Add(drawdowns, amount)
+			}
+		}
+		limit := parent.preciseValue()
+		for _, total := range []*big.Int{refunds, drawdowns} {
+			if total.Cmp(limit) > 0 {
+				addIssue(ChainIssue{
+					Kind:          ChainIssueChildrenExceedRoot,
+					TransactionID: parentID,
+					Expected:      limit.String(),
+					Actual:        total.String(),
+					Message:       "child transactions exceed the parent amount",
+				})
+			}
+		}
+	}
+
+	return report
+}
+
+func hasCycle(record ChainRecord, byID map[string]ChainRecord) bool {
+	visited := map[string]bool{record.TransactionID: true}
+	current := record
+	for current.ParentTransaction != "" {
+		if visited[current.ParentTransaction] {
+			return true
+		}
+		visited[current.ParentTransaction] = true
+		parent, ok := byID[current.ParentTransaction]
+		if !ok {
+			return false
+		}
+		current = parent
+	}
+	return false
+}
+
+// chainRecordJSON accepts both transaction and search document exports, where
+// created_at may be RFC3339 or a Unix timestamp and precise_amount a number or
+// a string.
+type chainRecordJSON struct {
+	TransactionID     string          `json:"transaction_id"`
+	ParentTransaction string          `json:"parent_transaction"`
+	Hash              string          `json:"hash"`
+	Reference         string          `json:"reference"`
+	Currency          string          `json:"currency"`
+	Source            string          `json:"source"`
+	Destination       string          `json:"destination"`
+	Amount            float64         `json:"amount"`
+	PreciseAmount     json.RawMessage `json:"precise_amount"`
+	Precision         int64           `json:"precision"`
+	Rate              float64         `json:"rate"`
+	Status            string          `json:"status"`
+	CreatedAt         *FlexibleTime   `json:"created_at"`
+}
+
+func (c chainRecordJSON) record() (ChainRecord, error) {
+	record := ChainRecord{
+		TransactionID:     c.TransactionID,
+		ParentTransaction: c.ParentTransaction,
+		Hash:              c.Hash,
+		Reference:         c.Reference,
+		Currency:          c.Currency,
+		Source:            c.Source,
+		Destination:       c.Destination,
+		Amount:            c.Amount,
+		Precision:         c.Precision,
+		Rate:              c.Rate,
+		Status:            c.Status,
+	}
+	if c.CreatedAt != nil {
+		record.CreatedAt = c.CreatedAt.Time
+	}
+	if raw := bytes.Trim(c.PreciseAmount, `"`); len(raw) > 0 && string(raw) != "null" {
+		preciseAmount, ok := new(big.Int).SetString(string(raw), 10)
+		if !ok {
+			return record, fmt.Errorf("transaction %s: invalid precise_amount %s", c.TransactionID, c.PreciseAmount)
+		}
+		record.PreciseAmount = preciseAmount
+	}
+	return record, nil
+}
+
+// ReadChainRecords reads an offline export of transactions, either a JSON array
+// or newline-delimited JSON objects.
+func ReadChainRecords(r io.Reader) ([]ChainRecord, error) {
+	reader := bufio.NewReader(r)
+	first, err := peekNonSpace(reader)
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(reader)
+	var raws []chainRecordJSON
+	if first == '[' {
+		if err := decoder.Decode(&raws); err != nil {
+			return nil, fmt.Errorf("failed to decode export: %w", err)
+		}
+	} else {
+		for {
+			var raw chainRecordJSON
+			if err := decoder.Decode(&raw); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to decode export line %d: %w", len(raws)+1, err)
+			}
+			raws = append(raws, raw)
+		}
+	}
+
+	records := make([]ChainRecord, 0, len(raws))
+	for _, raw := range raws {
+		record, err := raw.record()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			if _, err := reader.ReadByte(); err != nil {
+				return 0, err
+			}
+		default:
+			return b[0], nil
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package blnkgo_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func chainRecord(id, parent, source, destination string, amount float64) blnkgo.ChainRecord {
+	record := blnkgo.ChainRecord{
+		TransactionID:     id,
+		ParentTransaction: parent,
+		Reference:         "ref-" + id,
+		Currency:          "USD",
+		Source:            source,
+		Destination:       destination,
+		Amount:            amount,
+		Precision:         100,
+		Status:            "APPLIED",
+	}
+	record.Hash = blnkgo.BlnkTransactionHash(record)
+	return record
+}
+
+func issueKinds(report *blnkgo.ChainReport) []blnkgo.ChainIssueKind {
+	kinds := make([]blnkgo.ChainIssueKind, len(report.Issues))
+	for i, issue := range report.Issues {
+		kinds[i] = issue.Kind
+	}
+	return kinds
+}
+
+func TestBlnkTransactionHash(t *testing.T) {
+	record := blnkgo.ChainRecord{Amount: 10.5, Reference: "ref", Currency: "USD", Source: "bln_a", Destination: "bln_b"}
+	sum := sha256.Sum256([]byte("10.500000refUSDbln_abln_b"))
+
+	assert.Equal(t, hex.EncodeToString(sum[:]), blnkgo.BlnkTransactionHash(record))
+}
+
+func TestChainVerifier_Verify_Valid(t *testing.T) {
+	records := []blnkgo.ChainRecord{
+		chainRecord("txn_inflight", "", "bln_a", "bln_b", 100),
+		chainRecord("txn_commit_1", "txn_inflight", "bln_a", "bln_b", 60),
+		chainRecord("txn_commit_2", "txn_inflight", "bln_a", "bln_b", 40),
+		chainRecord("txn_refund", "txn_inflight", "bln_b", "bln_a", 25),
+	}
+
+	report := blnkgo.NewChainVerifier().Verify(records)
+
+	assert.True(t, report.Valid())
+	assert.Equal(t, 4, report.Checked)
+}
+
+func TestChainVerifier_Verify_HashIssues(t *testing.T) {
+	tampered := chainRecord("txn_tampered", "", "bln_a", "bln_b", 100)
+	tampered.Amount = 1000
+	unhashed := chainRecord("txn_unhashed", "", "bln_a", "bln_b", 10)
+	unhashed.Hash = ""
+
+	report := blnkgo.NewChainVerifier().Verify([]blnkgo.ChainRecord{tampered, unhashed, unhashed})
+
+	assert.False(t, report.Valid())
+	assert.Equal(t, []blnkgo.ChainIssueKind{
+		blnkgo.ChainIssueHashMismatch,
+		blnkgo.ChainIssueMissingHash,
+		blnkgo.ChainIssueDuplicate,
+	}, issueKinds(report))
+	assert.Equal(t, "txn_tampered", report.Issues[0].TransactionID)
+	assert.Equal(t, tampered.Hash, report.Issues[0].Actual)
+	assert.Equal(t, blnkgo.BlnkTransactionHash(tampered), report.Issues[0].Expected)
+}
+
+func TestChainVerifier_Verify_Lineage(t *testing.T) {
+	parent := chainRecord("txn_parent", "", "bln_a", "bln_b", 50)
+	eur := chainRecord("txn_eur", "txn_parent", "bln_a", "bln_b", 10)
+	eur.Currency = "EUR"
+	eur.Hash = blnkgo.BlnkTransactionHash(eur)
+	records := []blnkgo.ChainRecord{
+		parent,
+		chainRecord("txn_refund_1", "txn_parent", "bln_b", "bln_a", 30),
+		chainRecord("txn_refund_2", "txn_parent", "bln_b", "bln_a", 30),
+		eur,
+		chainRecord("txn_orphan", "txn_missing", "bln_a", "bln_b", 5),
+		chainRecord("txn_x", "txn_y", "bln_a", "bln_b", 1),
+		chainRecord("txn_y", "txn_x", "bln_a", "bln_b", 1),
+	}
+
+	report := blnkgo.NewChainVerifier().Verify(records)
+
+	assert.ElementsMatch(t, []blnkgo.ChainIssueKind{
+		blnkgo.ChainIssueCurrencyMismatch,
+		blnkgo.ChainIssueMissingParent,
+		blnkgo.ChainIssueCycle,
+		blnkgo.ChainIssueCycle,
+		blnkgo.ChainIssueChildrenExceedRoot,
+	}, issueKinds(report))
+	for _, issue := range report.Issues {
+		if issue.Kind == blnkgo.ChainIssueChildrenExceedRoot {
+			assert.Equal(t, "txn_parent", issue.TransactionID)
+			assert.Equal(t, "5000", issue.Expected)
+			assert.Equal(t, "6000", issue.Actual)
+		}
+	}
+
+	verifier := blnkgo.NewChainVerifier()
+	verifier.AllowMissingParents = true
+	assert.NotContains(t, issueKinds(verifier.Verify(records)), blnkgo.ChainIssueMissingParent)
+}
+
+func TestChainVerifier_Verify_CustomHash(t *testing.T) {
+	record := chainRecord("txn_1", "", "bln_a", "bln_b", 1)
+	record.Hash = "custom"
+
+	verifier := &blnkgo.ChainVerifier{Hash: func(blnkgo.ChainRecord) string { return "custom" }}
+
+	assert.True(t, verifier.Verify([]blnkgo.ChainRecord{record}).Valid())
+}
+
+func TestReadChainRecords(t *testing.T) {
+	array := `[
+		{"transaction_id":"txn_1","hash":"h1","amount":10.5,"precise_amount":1050,"precision":100,"currency":"USD","created_at":"2024-01-02T03:04:05Z"},
+		{"transaction_id":"txn_2","parent_transaction":"txn_1","hash":"h2","amount":1,"precise_amount":"100000000000000000000000","precision":100,"created_at":null}
+	]`
+	records, err := blnkgo.ReadChainRecords(strings.NewReader(array))
+
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, big.NewInt(1050), records[0].PreciseAmount)
+	assert.Equal(t, 2024, records[0].CreatedAt.Year())
+	assert.Equal(t, "txn_1", records[1].ParentTransaction)
+	assert.Equal(t, "100000000000000000000000", records[1].PreciseAmount.String())
+
+	ndjson := "{\"transaction_id\":\"txn_1\",\"created_at\":1704164645}\n\n{\"transaction_id\":\"txn_2\"}\n"
+	records, err = blnkgo.ReadChainRecords(strings.NewReader(ndjson))
+
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, int64(1704164645), records[0].CreatedAt.Unix())
+	assert.Nil(t, records[1].PreciseAmount)
+
+	_, err = blnkgo.ReadChainRecords(strings.NewReader(`{"transaction_id":"txn_1","precise_amount":"abc"}`))
+	assert.Error(t, err)
+}
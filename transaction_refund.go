@@ -0,0 +1,162 @@
+package blnkgo
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+)
+
+// RefundRequest describes a partial or full refund. Leave Amount and
+// PreciseAmount unset to refund whatever has not been refunded yet.
+type RefundRequest struct {
+	// Amount in major units, converted with the original transaction's precision.
+	Amount float64 `json:"amount,omitempty"`
+	// PreciseAmount in minor units; takes precedence over Amount.
+	PreciseAmount *big.Int `json:"precise_amount,omitempty"`
+	// Reason is recorded in the refund's meta_data as refund_reason.
+	Reason   string                 `json:"-"`
+	MetaData map[string]interface{} `json:"meta_data,omitempty"`
+}
+
+// RefundLimitError is returned by RefundWithOptions when a refund would take the
+// total refunded above the original amount. Amounts are in minor units of the
+// original transaction.
+type RefundLimitError struct {
+	TransactionID string
+	Original      *big.Int
+	Refunded      *big.Int
+	Requested     *big.Int
+}
+
+func (e *RefundLimitError) Error() string {
+	remaining := new(big.Int).Sub(e.Original, e.Refunded)
+	return fmt.Sprintf("refund of %s exceeds the %s left to refund on transaction %s (original %s, refunded %s)",
+		e.Requested, remaining, e.TransactionID, e.Original, e.Refunded)
+}
+
+// RefundWithOptions refunds part or all of a transaction. The original is
+// fetched first and the refund is checked against it and against the refunds
+// already recorded for it, so the total refunded can never exceed the original
+// amount.
+//
+// The check is done client-side: two concurrent refunds of the same
+// transaction can still both pass it.
+func (s *TransactionService) RefundWithOptions(transactionID string, body RefundRequest) (*Transaction, *http.Response, error) {
+	if transactionID == "" {
+		return nil, nil, fmt.Errorf("transactionID is required")
+	}
+	if body.Amount < 0 || (body.PreciseAmount != nil && body.PreciseAmount.Sign() < 0) {
+		return nil, nil, fmt.Errorf("refund amount must not be negative")
+	}
+
+	original, resp, err := s.Get(transactionID)
+	if err != nil {
+		return nil, resp, err
+	}
+	if original.Status != PryTransactionStatusApplied && original.Status != PryTransactionStatusCommit {
+		return nil, nil, fmt.Errorf("transaction %s cannot be refunded in status %s", transactionID, original.Status)
+	}
+
+	refunds, err := s.refunds(original)
+	if err != nil {
+		return nil, nil, err
+	}
+	refunded := sumRefunds(original, refunds)
+	total := original.PreciseValue()
+	remaining := new(big.Int).Sub(total, refunded)
+
+	requested := body.PreciseAmount
+	switch {
+	case requested != nil && requested.Sign() > 0:
+	case body.Amount > 0:
+		requested = ToPreciseAmount(body.Amount, original.Precision)
+	default:
+		requested = remaining
+	}
+	if requested.Sign() <= 0 || requested.Cmp(remaining) > 0 {
+		return nil, nil, &RefundLimitError{TransactionID: transactionID, Original: total, Refunded: refunded, Requested: requested}
+	}
+
+	precision := original.Precision
+	if precision <= 0 {
+		precision = 1
+	}
+	amount, _ := new(big.Rat).SetFrac(requested, big.NewInt(precision)).Float64()
+
+	metaData := make(map[string]interface{}, len(body.MetaData)+1)
+	for k, v := range body.MetaData {
+		metaData[k] = v
+	}
+	if body.Reason != "" {
+		metaData["refund_reason"] = body.Reason
+	}
+	payload := RefundRequest{Amount: amount, PreciseAmount: requested, MetaData: metaData}
+
+	u := fmt.Sprintf("refund-transaction/%s", transactionID)
+	req, err := s.client.NewRequest(u, http.MethodPost, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transaction := new(Transaction)
+	resp, err = s.client.CallWithRetry(req, transaction)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return transaction, resp, nil
+}
+
+// Refunds returns the refunds recorded against a transaction: its children
+// (by parent_transaction) that move funds back from its destination to its
+// source.
+func (s *TransactionService) Refunds(transactionID string) ([]Transaction, error) {
+	original, _, err := s.Get(transactionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.refunds(original)
+}
+
+// RefundedAmount returns the total refunded so far on a transaction, in minor
+// units of the original. Voided, rejected and expired refunds are not counted.
+func (s *TransactionService) RefundedAmount(transactionID string) (*big.Int, error) {
+	original, _, err := s.Get(transactionID)
+	if err != nil {
+		return nil, err
+	}
+	refunds, err := s.refunds(original)
+	if err != nil {
+		return nil, err
+	}
+	return sumRefunds(original, refunds), nil
+}
+
+func (s *TransactionService) refunds(original *Transaction) ([]Transaction, error) {
+	children, err := s.FilterAll(FilterParams{
+		Filters: []Filter{{Field: "parent_transaction", Operator: OpEqual, Value: original.TransactionID}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refunds := make([]Transaction, 0, len(children))
+	for _, child := range children {
+		if child.Source == original.Destination && child.Destination == original.Source {
+			refunds = append(refunds, child)
+		}
+	}
+	return refunds, nil
+}
+
+func sumRefunds(original *Transaction, refunds []Transaction) *big.Int {
+	total := new(big.Int)
+	for _, refund := range refunds {
+		switch refund.Status {
+		case PryTransactionStatusVoid, PryTransactionStatusRejected, PryTransactionStatusExpired:
+			continue
+		}
+		total.Add(total, RescalePreciseAmount(refund.PreciseValue(), refund.Precision, original.Precision))
+	}
+	return total
+}
@@ -0,0 +1,154 @@
+package blnkgo_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type refundServer struct {
+	status   string
+	children string
+	refunds  []map[string]interface{}
+}
+
+func newRefundServer(t *testing.T, rs *refundServer) *blnkgo.TransactionService {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/transactions/txn_1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"transaction_id":"txn_1","amount":100,"precise_amount":10000,"precision":100,"currency":"USD","source":"bln_customer","destination":"bln_merchant","status":"%s"}`, rs.status)
+	})
+	mux.HandleFunc("/transactions/filter", func(w http.ResponseWriter, r *http.Request) {
+		var params blnkgo.FilterParams
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		assert.Equal(t, "parent_transaction", params.Filters[0].Field)
+		assert.Equal(t, "txn_1", params.Filters[0].Value)
+		fmt.Fprint(w, rs.children)
+	})
+	mux.HandleFunc("/refund-transaction/txn_1", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		rs.refunds = append(rs.refunds, body)
+		fmt.Fprint(w, `{"transaction_id":"txn_refund","parent_transaction":"txn_1","source":"bln_merchant","destination":"bln_customer","status":"QUEUED"}`)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	baseURL, _ := url.Parse(server.URL)
+	return blnkgo.NewClient(baseURL, nil).Transaction
+}
+
+const existingChildren = `[
+	{"transaction_id":"txn_r1","parent_transaction":"txn_1","precise_amount":2500,"precision":100,"source":"bln_merchant","destination":"bln_customer","status":"APPLIED"},
+	{"transaction_id":"txn_r2","parent_transaction":"txn_1","precise_amount":5000,"precision":100,"source":"bln_merchant","destination":"bln_customer","status":"VOID"},
+	{"transaction_id":"txn_c1","parent_transaction":"txn_1","precise_amount":1000,"precision":100,"source":"bln_customer","destination":"bln_merchant","status":"APPLIED"}
+]`
+
+func TestTransactionService_RefundWithOptions_Partial(t *testing.T) {
+	rs := &refundServer{status: "APPLIED", children: existingChildren}
+	svc := newRefundServer(t, rs)
+
+	refund, _, err := svc.RefundWithOptions("txn_1", blnkgo.RefundRequest{
+		Amount:   40.5,
+		Reason:   "damaged item",
+		MetaData: map[string]interface{}{"ticket": "T-1"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "txn_refund", refund.TransactionID)
+	require.Len(t, rs.refunds, 1)
+	assert.Equal(t, float64(4050), rs.refunds[0]["precise_amount"])
+	assert.Equal(t, 40.5, rs.refunds[0]["amount"])
+	assert.Equal(t, map[string]interface{}{"ticket": "T-1", "refund_reason": "damaged item"}, rs.refunds[0]["meta_data"])
+}
+
+func TestTransactionService_RefundWithOptions_Remaining(t *testing.T) {
+	rs := &refundServer{status: "APPLIED", children: existingChildren}
+	svc := newRefundServer(t, rs)
+
+	_, _, err := svc.RefundWithOptions("txn_1", blnkgo.RefundRequest{})
+
+	require.NoError(t, err)
+	require.Len(t, rs.refunds, 1)
+	assert.Equal(t, float64(7500), rs.refunds[0]["precise_amount"])
+}
+
+func TestTransactionService_RefundWithOptions_ExceedsOriginal(t *testing.T) {
+	rs := &refundServer{status: "APPLIED", children: existingChildren}
+	svc := newRefundServer(t, rs)
+
+	_, _, err := svc.RefundWithOptions("txn_1", blnkgo.RefundRequest{PreciseAmount: big.NewInt(7501)})
+
+	var limitErr *blnkgo.RefundLimitError
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, big.NewInt(10000), limitErr.Original)
+	assert.Equal(t, big.NewInt(2500), limitErr.Refunded)
+	assert.Equal(t, big.NewInt(7501), limitErr.Requested)
+	assert.Empty(t, rs.refunds)
+}
+
+func TestTransactionService_RefundWithOptions_Invalid(t *testing.T) {
+	rs := &refundServer{status: "INFLIGHT", children: `[]`}
+	svc := newRefundServer(t, rs)
+
+	_, _, err := svc.RefundWithOptions("", blnkgo.RefundRequest{})
+	assert.EqualError(t, err, "transactionID is required")
+
+	_, _, err = svc.RefundWithOptions("txn_1", blnkgo.RefundRequest{Amount: -1})
+	assert.Error(t, err)
+
+	_, _, err = svc.RefundWithOptions("txn_1", blnkgo.RefundRequest{})
+	assert.EqualError(t, err, "transaction txn_1 cannot be refunded in status INFLIGHT")
+	assert.Empty(t, rs.refunds)
+}
+
+func TestTransactionService_RefundedAmount(t *testing.T) {
+	svc := newRefundServer(t, &refundServer{status: "APPLIED", children: existingChildren})
+
+	refunded, err := svc.RefundedAmount("txn_1")
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(2500), refunded)
+
+	refunds, err := svc.Refunds("txn_1")
+	require.NoError(t, err)
+	require.Len(t, refunds, 2)
+	assert.Equal(t, "txn_r1", refunds[0].TransactionID)
+}
+
+func TestTransactionService_RefundedAmount_RepeatedRecord(t *testing.T) {
+	// a refund sharing a created_at with others can be returned on two pages
+	children := `[
+	{"transaction_id":"txn_r1","parent_transaction":"txn_1","precise_amount":2500,"precision":100,"source":"bln_merchant","destination":"bln_customer","status":"APPLIED"},
+	{"transaction_id":"txn_r1","parent_transaction":"txn_1","precise_amount":2500,"precision":100,"source":"bln_merchant","destination":"bln_customer","status":"APPLIED"}
+]`
+	rs := &refundServer{status: "APPLIED", children: children}
+	svc := newRefundServer(t, rs)
+
+	refunded, err := svc.RefundedAmount("txn_1")
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(2500), refunded)
+
+	_, _, err = svc.RefundWithOptions("txn_1", blnkgo.RefundRequest{})
+	require.NoError(t, err)
+	require.Len(t, rs.refunds, 1)
+	assert.Equal(t, float64(7500), rs.refunds[0]["precise_amount"])
+}
+
+func TestRefundTransaction_EmptyID(t *testing.T) {
+	mockClient, svc := setupTransactionService()
+
+	transaction, resp, err := svc.Refund("")
+
+	assert.EqualError(t, err, "transactionID is required")
+	assert.Nil(t, transaction)
+	assert.Nil(t, resp)
+	mockClient.AssertNotCalled(t, "NewRequest")
+}
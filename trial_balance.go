@@ -0,0 +1,292 @@
+package blnkgo
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type TrialBalanceIssueKind string
+
+const (
+	// IssueCurrencyImbalance means total credits and debits of a currency do not
+	// net to zero across the whole system.
+	IssueCurrencyImbalance TrialBalanceIssueKind = "currency_imbalance"
+	// IssueBalanceMismatch means a balance's Balance is not CreditBalance - DebitBalance.
+	IssueBalanceMismatch TrialBalanceIssueKind = "balance_mismatch"
+	// IssueInflightOutstanding means a balance still has inflight amounts.
+	IssueInflightOutstanding TrialBalanceIssueKind = "inflight_outstanding"
+	// IssueQueuedOutstanding means a balance still has queued amounts.
+	IssueQueuedOutstanding TrialBalanceIssueKind = "queued_outstanding"
+)
+
+// TrialBalanceIssue is a discrepancy found while building a trial balance.
+type TrialBalanceIssue struct {
+	Kind      TrialBalanceIssueKind `json:"kind"`
+	Currency  string                `json:"currency"`
+	LedgerID  string                `json:"ledger_id,omitempty"`
+	BalanceID string                `json:"balance_id,omitempty"`
+	Amount    *big.Int              `json:"amount"`
+	Message   string                `json:"message"`
+}
+
+// TrialBalanceTotals sums the balances of one currency, either across the
+// whole scope of the report or within a single ledger.
+type TrialBalanceTotals struct {
+	LedgerID       string   `json:"ledger_id,omitempty"`
+	Currency       string   `json:"currency"`
+	Precision      int64    `json:"precision"`
+	BalanceCount   int      `json:"balance_count"`
+	CreditBalance  *big.Int `json:"credit_balance"`
+	DebitBalance   *big.Int `json:"debit_balance"`
+	Balance        *big.Int `json:"balance"`
+	InflightCredit *big.Int `json:"inflight_credit_balance"`
+	InflightDebit  *big.Int `json:"inflight_debit_balance"`
+	QueuedCredit   *big.Int `json:"queued_credit_balance"`
+	QueuedDebit    *big.Int `json:"queued_debit_balance"`
+}
+
+// Difference is CreditBalance - DebitBalance, which must be zero for a currency
+// across the whole system.
+func (t *TrialBalanceTotals) Difference() *big.Int {
+	return new(big.Int).Sub(t.CreditBalance, t.DebitBalance)
+}
+
+// TrialBalanceReport is the result of TrialBalance.
+type TrialBalanceReport struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	// LedgerIDs is the scope of the report; empty means every ledger.
+	LedgerIDs    []string              `json:"ledger_ids"`
+	BalanceCount int                   `json:"balance_count"`
+	Currencies   []*TrialBalanceTotals `json:"currencies"`
+	Ledgers      []*TrialBalanceTotals `json:"ledgers"`
+	Issues       []TrialBalanceIssue   `json:"issues"`
+}
+
+// Balanced reports whether no issue was found.
+func (r *TrialBalanceReport) Balanced() bool {
+	return len(r.Issues) == 0
+}
+
+// TrialBalance pages through every balance of the given ledgers (or of all
+// ledgers when none are given), oldest first, and sums them per currency and
+// per ledger.
+//
+// Credits and debits only have to net to zero across the whole system, since
+// the counterpart of a transfer (often an @world balance in the general ledger)
+// may live in another ledger. Currency imbalances are therefore only reported
+// when no ledger IDs are given.
+func (s *LedgerBalanceService) TrialBalance(ledgerIDs ...string) (*TrialBalanceReport, error) {
+	params := FilterParams{}
+	if len(ledgerIDs) > 0 {
+		values := make([]interface{}, len(ledgerIDs))
+		for i, id := range ledgerIDs {
+			values[i] = id
+		}
+		params.Filters = []Filter{{Field: "ledger_id", Operator: OpIn, Values: values}}
+	}
+
+	report := &TrialBalanceReport{
+		GeneratedAt: time.Now().UTC(),
+		LedgerIDs:   ledgerIDs,
+		Issues:      []TrialBalanceIssue{},
+	}
+	currencies := make(map[string]*TrialBalanceTotals)
+	ledgers := make(map[string]*TrialBalanceTotals)
+
+	err := filterEach(s.Filter, params, func(page []LedgerBalance) error {
+		for _, balance := range page {
+			report.BalanceCount++
+			addToTotals(totalsFor(currencies, "", balance), balance)
+			addToTotals(totalsFor(ledgers, balance.LedgerID, balance), balance)
+			report.Issues = append(report.Issues, balanceIssues(balance)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, totals := range currencies {
+		report.Currencies = append(report.Currencies, totals)
+		if difference := totals.Difference(); len(ledgerIDs) == 0 && difference.Sign() != 0 {
+			report.Issues = append(report.Issues, TrialBalanceIssue{
+				Kind:     IssueCurrencyImbalance,
+				Currency: totals.Currency,
+				Amount:   difference,
+				Message:  fmt.Sprintf("credits and debits differ by %s", FormatPreciseAmount(difference, totals.Precision)),
+			})
+		}
+	}
+	for _, totals := range ledgers {
+		report.Ledgers = append(report.Ledgers, totals)
+	}
+
+	sort.Slice(report.Currencies, func(i, j int) bool {
+		return report.Currencies[i].Currency < report.Currencies[j].Currency
+	})
+	sort.Slice(report.Ledgers, func(i, j int) bool {
+		if report.Ledgers[i].LedgerID != report.Ledgers[j].LedgerID {
+			return report.Ledgers[i].LedgerID < report.Ledgers[j].LedgerID
+		}
+		return report.Ledgers[i].Currency < report.Ledgers[j].Currency
+	})
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return report.Issues[i].Kind < report.Issues[j].Kind
+	})
+
+	return report, nil
+}
+
+func totalsFor(totals map[string]*TrialBalanceTotals, ledgerID string, balance LedgerBalance) *TrialBalanceTotals {
+	key := ledgerID + "|" + balance.Currency
+	t, ok := totals[key]
+	if !ok {
+		t = &TrialBalanceTotals{
+			LedgerID:       ledgerID,
+			Currency:       balance.Currency,
+			CreditBalance:  new(big.Int),
+			DebitBalance:   new(big.Int),
+			Balance:        new(big.Int),
+			InflightCredit: new(big.Int),
+			InflightDebit:  new(big.Int),
+			QueuedCredit:   new(big.Int),
+			QueuedDebit:    new(big.Int),
+		}
+		totals[key] = t
+	}
+	return t
+}
+
+// addToTotals adds balance to t. Balances of one currency can have different
+// precisions, so the totals are kept at the highest precision seen and every
+// balance is rescaled to it.
+func addToTotals(t *TrialBalanceTotals, balance LedgerBalance) {
+	t.BalanceCount++
+	precision := int64(balance.Precision)
+	if precision <= 0 {
+		precision = t.Precision
+	}
+	sums := []*big.Int{t.CreditBalance, t.DebitBalance, t.Balance, t.InflightCredit, t.InflightDebit, t.QueuedCredit, t.QueuedDebit}
+	if precision > t.Precision {
+		if t.Precision > 0 {
+			for _, sum := range sums {
+				sum.Set(RescalePreciseAmount(sum, t.Precision, precision))
+			}
+		}
+		t.Precision = precision
+	}
+	values := []*big.Int{balance.CreditBalance, balance.DebitBalance, balance.Balance,
+		balance.InflightCreditBalance, balance.InflightDebitBalance, balance.QueuedCreditBalance, balance.QueuedDebitBalance}
+	for i, v := range values {
+		if v != nil {
+			sums[i].Add(sums[i], RescalePreciseAmount(v, precision, t.Precision))
+		}
+	}
+}
+
+func addIfSet(total, v *big.Int) {
+	if v != nil {
+		total.Add(total, v)
+	}
+}
+
+func isNonZero(v *big.Int) bool {
+	return v != nil && v.Sign() != 0
+}
+
+func balanceIssues(balance LedgerBalance) []TrialBalanceIssue {
+	var issues []TrialBalanceIssue
+	issue := func(kind TrialBalanceIssueKind, amount *big.Int, message string) {
+		issues = append(issues, TrialBalanceIssue{
+			Kind:      kind,
+			Currency:  balance.Currency,
+			LedgerID:  balance.LedgerID,
+			BalanceID: balance.BalanceID,
+			Amount:    amount,
+			Message:   message,
+		})
+	}
+
+	expected := new(big.Int)
+	addIfSet(expected, balance.CreditBalance)
+	if balance.DebitBalance != nil {
+		expected.Sub(expected, balance.DebitBalance)
+	}
+	actual := ValueOrZero(balance.Balance)
+	if actual.Cmp(expected) != 0 {
+		issue(IssueBalanceMismatch, new(big.Int).Sub(actual, expected),
+			fmt.Sprintf("balance %s is not credit_balance - debit_balance (%s)", actual, expected))
+	}
+
+	if isNonZero(balance.InflightBalance) || isNonZero(balance.InflightCreditBalance) || isNonZero(balance.InflightDebitBalance) {
+		inflight := new(big.Int)
+		addIfSet(inflight, balance.InflightCreditBalance)
+		addIfSet(inflight, balance.InflightDebitBalance)
+		issue(IssueInflightOutstanding, inflight, "inflight amounts have not been committed or voided")
+	}
+
+	if isNonZero(balance.QueuedCreditBalance) || isNonZero(balance.QueuedDebitBalance) {
+		queued := new(big.Int)
+		addIfSet(queued, balance.QueuedCreditBalance)
+		addIfSet(queued, balance.QueuedDebitBalance)
+		issue(IssueQueuedOutstanding, queued, "queued amounts have not been applied")
+	}
+
+	return issues
+}
+
+// WriteText writes the report as a plain-text document for auditors to review
+// and sign off.
+func (r *TrialBalanceReport) WriteText(w io.Writer) error {
+	scope := "all ledgers"
+	if len(r.LedgerIDs) > 0 {
+		scope = strings.Join(r.LedgerIDs, ", ")
+	}
+	amount := func(t *TrialBalanceTotals, v *big.Int) string {
+		return FormatPreciseAmount(v, t.Precision)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "TRIAL BALANCE\n")
+	fmt.Fprintf(tw, "Generated at:\t%s\t\n", r.GeneratedAt.Format(time.RFC3339))
+	fmt.Fprintf(tw, "Scope:\t%s\t\n", scope)
+	fmt.Fprintf(tw, "Balances:\t%d\t\n\n", r.BalanceCount)
+
+	fmt.Fprintf(tw, "Currency\tCredits\tDebits\tDifference\tInflight\tQueued\t\n")
+	for _, t := range r.Currencies {
+		inflight := new(big.Int).Add(t.InflightCredit, t.InflightDebit)
+		queued := new(big.Int).Add(t.QueuedCredit, t.QueuedDebit)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t\n", t.Currency, amount(t, t.CreditBalance), amount(t, t.DebitBalance),
+			amount(t, t.Difference()), amount(t, inflight), amount(t, queued))
+	}
+
+	fmt.Fprintf(tw, "\nLedger\tCurrency\tBalances\tCredits\tDebits\tNet\t\n")
+	for _, t := range r.Ledgers {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t\n", t.LedgerID, t.Currency, t.BalanceCount,
+			amount(t, t.CreditBalance), amount(t, t.DebitBalance), amount(t, t.Difference()))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if r.Balanced() {
+		fmt.Fprintf(w, "\nResult: BALANCED, no discrepancies found\n")
+	} else {
+		fmt.Fprintf(w, "\nResult: %d DISCREPANCIES FOUND\n", len(r.Issues))
+		for _, issue := range r.Issues {
+			location := issue.Currency
+			if issue.BalanceID != "" {
+				location = fmt.Sprintf("%s %s/%s", issue.Currency, issue.LedgerID, issue.BalanceID)
+			}
+			fmt.Fprintf(w, "  [%s] %s: %s\n", issue.Kind, location, issue.Message)
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\nPrepared by: ____________________  Date: __________\nReviewed by: ____________________  Date: __________\n")
+	return err
+}
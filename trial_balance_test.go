@@ -0,0 +1,193 @@
+package blnkgo_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"testing"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func ledgerBalance(id, ledgerID, currency string, credit, debit int64) blnkgo.LedgerBalance {
+	return blnkgo.LedgerBalance{
+		BalanceID:     id,
+		LedgerID:      ledgerID,
+		Currency:      currency,
+		Precision:     100,
+		CreditBalance: big.NewInt(credit),
+		DebitBalance:  big.NewInt(debit),
+		Balance:       big.NewInt(credit - debit),
+	}
+}
+
+func TestLedgerBalanceService_TrialBalance_Balanced(t *testing.T) {
+	mockClient, svc := setupLedgerBalanceService()
+
+	mockClient.On("NewRequest", "balances/filter", http.MethodPost, blnkgo.FilterParams{Filters: []blnkgo.Filter{}, Limit: 100, SortBy: "created_at", SortOrder: "asc"}).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		resp := args.Get(1).(*blnkgo.FilterResponse)
+		resp.Data = []blnkgo.LedgerBalance{
+			ledgerBalance("bln_world", "ldg_general", "USD", 0, 150000),
+			ledgerBalance("bln_1", "ldg_customers", "USD", 100000, 0),
+			ledgerBalance("bln_2", "ldg_customers", "USD", 60000, 10000),
+			ledgerBalance("bln_eur", "ldg_customers", "EUR", 0, 0),
+		}
+	})
+
+	report, err := svc.TrialBalance()
+
+	require.NoError(t, err)
+	assert.True(t, report.Balanced())
+	assert.Equal(t, 4, report.BalanceCount)
+	require.Len(t, report.Currencies, 2)
+	assert.Equal(t, "EUR", report.Currencies[0].Currency)
+	assert.Equal(t, "USD", report.Currencies[1].Currency)
+	assert.Equal(t, big.NewInt(160000), report.Currencies[1].CreditBalance)
+	assert.Equal(t, 0, report.Currencies[1].Difference().Sign())
+	require.Len(t, report.Ledgers, 3)
+	assert.Equal(t, "ldg_customers", report.Ledgers[1].LedgerID)
+	assert.Equal(t, 2, report.Ledgers[1].BalanceCount)
+	assert.Equal(t, big.NewInt(150000), report.Ledgers[1].Difference())
+
+	var text bytes.Buffer
+	require.NoError(t, report.WriteText(&text))
+	assert.Contains(t, text.String(), "BALANCED")
+	assert.Contains(t, text.String(), "1600.00")
+	assert.Contains(t, text.String(), "Reviewed by:")
+}
+
+func TestLedgerBalanceService_TrialBalance_Discrepancies(t *testing.T) {
+	mockClient, svc := setupLedgerBalanceService()
+
+	mismatched := ledgerBalance("bln_bad", "ldg_customers", "USD", 5000, 0)
+	mismatched.Balance = big.NewInt(4000)
+	pending := ledgerBalance("bln_pending", "ldg_customers", "USD", 0, 0)
+	pending.InflightDebitBalance = big.NewInt(700)
+	pending.QueuedCreditBalance = big.NewInt(300)
+
+	mockClient.On("NewRequest", "balances/filter", http.MethodPost, blnkgo.FilterParams{Filters: []blnkgo.Filter{}, Limit: 100, SortBy: "created_at", SortOrder: "asc"}).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		resp := args.Get(1).(*blnkgo.FilterResponse)
+		resp.Data = []blnkgo.LedgerBalance{mismatched, pending}
+	})
+
+	report, err := svc.TrialBalance()
+
+	require.NoError(t, err)
+	assert.False(t, report.Balanced())
+	kinds := make([]blnkgo.TrialBalanceIssueKind, len(report.Issues))
+	for i, issue := range report.Issues {
+		kinds[i] = issue.Kind
+	}
+	assert.ElementsMatch(t, []blnkgo.TrialBalanceIssueKind{
+		blnkgo.IssueBalanceMismatch,
+		blnkgo.IssueCurrencyImbalance,
+		blnkgo.IssueInflightOutstanding,
+		blnkgo.IssueQueuedOutstanding,
+	}, kinds)
+
+	var text bytes.Buffer
+	require.NoError(t, report.WriteText(&text))
+	assert.Contains(t, text.String(), "4 DISCREPANCIES FOUND")
+}
+
+func TestLedgerBalanceService_TrialBalance_ScopedToLedgers(t *testing.T) {
+	mockClient, svc := setupLedgerBalanceService()
+
+	params := blnkgo.FilterParams{
+		Filters:   []blnkgo.Filter{{Field: "ledger_id", Operator: blnkgo.OpIn, Values: []interface{}{"ldg_customers"}}},
+		Limit:     100,
+		SortBy:    "created_at",
+		SortOrder: "asc",
+	}
+	mockClient.On("NewRequest", "balances/filter", http.MethodPost, params).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		resp := args.Get(1).(*blnkgo.FilterResponse)
+		resp.Data = []blnkgo.LedgerBalance{ledgerBalance("bln_1", "ldg_customers", "USD", 100000, 0)}
+	})
+
+	report, err := svc.TrialBalance("ldg_customers")
+
+	require.NoError(t, err)
+	assert.True(t, report.Balanced())
+	assert.Equal(t, big.NewInt(100000), report.Currencies[0].Difference())
+	mockClient.AssertExpectations(t)
+}
+
+func TestLedgerBalanceService_TrialBalance_Paged(t *testing.T) {
+	mockClient, svc := setupLedgerBalanceService()
+
+	first := make([]blnkgo.LedgerBalance, 100)
+	for i := range first {
+		first[i] = ledgerBalance(fmt.Sprintf("bln_%03d", i), "ldg_customers", "USD", 100, 0)
+	}
+	params := blnkgo.FilterParams{Filters: []blnkgo.Filter{}, Limit: 100, SortBy: "created_at", SortOrder: "asc"}
+	firstReq := filterRequest("balances/filter?offset=0")
+	mockClient.On("NewRequest", "balances/filter", http.MethodPost, params).Return(firstReq, nil)
+	mockClient.On("CallWithRetry", firstReq, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*blnkgo.FilterResponse).Data = first
+	})
+	params.Offset = 100
+	secondReq := filterRequest("balances/filter?offset=100")
+	mockClient.On("NewRequest", "balances/filter", http.MethodPost, params).Return(secondReq, nil)
+	mockClient.On("CallWithRetry", secondReq, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		// the last balance of the first page came back again
+		args.Get(1).(*blnkgo.FilterResponse).Data = []blnkgo.LedgerBalance{
+			first[99],
+			ledgerBalance("bln_100", "ldg_customers", "USD", 100, 0),
+		}
+	})
+
+	report, err := svc.TrialBalance()
+
+	require.NoError(t, err)
+	assert.Equal(t, 101, report.BalanceCount)
+	assert.Equal(t, big.NewInt(10100), report.Currencies[0].CreditBalance)
+	mockClient.AssertExpectations(t)
+}
+
+func TestLedgerBalanceService_TrialBalance_MixedPrecision(t *testing.T) {
+	mockClient, svc := setupLedgerBalanceService()
+
+	fine := ledgerBalance("bln_fine", "ldg_customers", "USD", 5, 0)
+	fine.Precision = 1000
+	mockClient.On("NewRequest", "balances/filter", http.MethodPost, mock.Anything).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusOK}, nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*blnkgo.FilterResponse).Data = []blnkgo.LedgerBalance{
+			ledgerBalance("bln_world", "ldg_general", "USD", 0, 1005),
+			ledgerBalance("bln_1", "ldg_customers", "USD", 1000, 0),
+			fine,
+		}
+	})
+
+	report, err := svc.TrialBalance()
+
+	require.NoError(t, err)
+	// 10.05 of debits at precision 100 against 10.00 + 0.005 of credits
+	require.Len(t, report.Currencies, 1)
+	usd := report.Currencies[0]
+	assert.Equal(t, int64(1000), usd.Precision)
+	assert.Equal(t, big.NewInt(10005), usd.CreditBalance)
+	assert.Equal(t, big.NewInt(10050), usd.DebitBalance)
+	require.Len(t, report.Issues, 1)
+	assert.Equal(t, "credits and debits differ by -0.045", report.Issues[0].Message)
+	assert.Equal(t, big.NewInt(10005), report.Ledgers[0].CreditBalance)
+}
+
+func TestLedgerBalanceService_TrialBalance_FilterError(t *testing.T) {
+	mockClient, svc := setupLedgerBalanceService()
+
+	mockClient.On("NewRequest", "balances/filter", http.MethodPost, mock.Anything).Return(&http.Request{}, nil)
+	mockClient.On("CallWithRetry", mock.Anything, mock.Anything).Return(&http.Response{StatusCode: http.StatusInternalServerError}, errors.New("server error"))
+
+	report, err := svc.TrialBalance()
+
+	assert.Error(t, err)
+	assert.Nil(t, report)
+}
@@ -1,31 +1,303 @@
 package blnkgo
 
-import "errors"
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FieldError describes a single identity field that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors holds every FieldError found while validating an identity,
+// so callers can report all problems at once instead of the first one.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, fieldErr := range v {
+		messages[i] = fieldErr.Error()
+	}
+	return "validation error: " + strings.Join(messages, "; ")
+}
+
+// Fields returns the names of the invalid fields, without duplicates.
+func (v ValidationErrors) Fields() []string {
+	seen := make(map[string]bool)
+	var fields []string
+	for _, fieldErr := range v {
+		if !seen[fieldErr.Field] {
+			seen[fieldErr.Field] = true
+			fields = append(fields, fieldErr.Field)
+		}
+	}
+	return fields
+}
+
+// IdentityRule checks one aspect of an identity and returns the problems found.
+// Rules should ignore empty optional fields; RequiredFieldsRule reports those.
+type IdentityRule func(identity Identity) []FieldError
+
+// IdentityValidator runs a set of rules against identities. Rules registered
+// for a category only run for identities with that Category, rules registered
+// with an empty category run for every identity.
+type IdentityValidator struct {
+	mu    sync.RWMutex
+	rules map[string][]IdentityRule
+}
+
+func NewIdentityValidator(rules ...IdentityRule) *IdentityValidator {
+	v := &IdentityValidator{rules: make(map[string][]IdentityRule)}
+	v.Register("", rules...)
+	return v
+}
+
+func (v *IdentityValidator) Register(category string, rules ...IdentityRule) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.rules[category] = append(v.rules[category], rules...)
+}
+
+// Validate returns nil or a ValidationErrors with every rule failure.
+func (v *IdentityValidator) Validate(identity Identity) error {
+	v.mu.RLock()
+	rules := append([]IdentityRule{}, v.rules[""]...)
+	if identity.Category != "" {
+		rules = append(rules, v.rules[identity.Category]...)
+	}
+	v.mu.RUnlock()
+
+	var errs ValidationErrors
+	for _, rule := range rules {
+		errs = append(errs, rule(identity)...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// DefaultIdentityRules returns the rules used by ValidateCreateIdentity unless
+// more are registered: the identity type and the fields it requires.
+func DefaultIdentityRules() []IdentityRule {
+	return []IdentityRule{RequiredFieldsRule()}
+}
+
+// FormatIdentityRules returns the format checks: email, E.164 phone numbers,
+// ISO 3166 country and nationality codes, gender, a minimum age of 18 and post
+// codes of major countries. They are opt-in, since they reject identities
+// Blnk itself accepts:
+//
+//	RegisterIdentityRule("", FormatIdentityRules()...)
+func FormatIdentityRules() []IdentityRule {
+	return []IdentityRule{
+		EmailRule(),
+		E164PhoneRule(),
+		CountryRule(),
+		NationalityRule(),
+		GenderRule("male", "female", "other"),
+		MinimumAgeRule(18),
+		PostCodeRule(),
+	}
+}
+
+// StrictIdentityRules returns DefaultIdentityRules and FormatIdentityRules,
+// for use with NewIdentityValidator.
+func StrictIdentityRules() []IdentityRule {
+	return append(DefaultIdentityRules(), FormatIdentityRules()...)
+}
+
+// DefaultIdentityValidator is used by ValidateCreateIdentity and
+// IdentityService.Create. It runs DefaultIdentityRules; use
+// RegisterIdentityRule to extend it.
+var DefaultIdentityValidator = NewIdentityValidator(DefaultIdentityRules()...)
+
+// RegisterIdentityRule adds rules to DefaultIdentityValidator for identities
+// of the given category, or for all identities when category is empty.
+func RegisterIdentityRule(category string, rules ...IdentityRule) {
+	DefaultIdentityValidator.Register(category, rules...)
+}
 
 // validate fields in Idenity based on the type of identity selected
 func ValidateCreateIdentity(identity Identity) error {
-	if identity.IdentityType == Individual {
-		if identity.FirstName == "" {
-			return errors.New("FirstName is required for Individual")
+	return DefaultIdentityValidator.Validate(identity)
+}
+
+// RequiredFieldsRule checks the identity type and the fields it requires.
+func RequiredFieldsRule() IdentityRule {
+	return func(identity Identity) []FieldError {
+		var errs []FieldError
+		required := func(field, name, identityType string, missing bool) {
+			if missing {
+				errs = append(errs, FieldError{
+					Field:   field,
+					Rule:    "required",
+					Message: fmt.Sprintf("%s is required for %s", name, identityType),
+				})
+			}
+		}
+
+		switch identity.IdentityType {
+		case Individual:
+			required("first_name", "FirstName", "Individual", identity.FirstName == "")
+			required("last_name", "LastName", "Individual", identity.LastName == "")
+			required("dob", "DateOfBirth", "Individual", identity.DOB == nil)
+			required("gender", "gender", "Individual", identity.Gender == "")
+			required("nationality", "nationality", "Individual", identity.Nationality == "")
+		case Organization:
+			required("organization_name", "organizationName", "Organization", identity.OrganizationName == "")
+		default:
+			errs = append(errs, FieldError{Field: "identity_type", Rule: "required", Message: "invalid IdentityType"})
+		}
+		return errs
+	}
+}
+
+// EmailRule checks that the email address is a single valid address.
+func EmailRule() IdentityRule {
+	return func(identity Identity) []FieldError {
+		if identity.EmailAddress == "" {
+			return nil
 		}
-		if identity.LastName == "" {
-			return errors.New("LastName is required for Individual")
+		addr, err := mail.ParseAddress(identity.EmailAddress)
+		if err != nil || addr.Address != identity.EmailAddress || !strings.Contains(addr.Address[strings.LastIndex(addr.Address, "@"):], ".") {
+			return []FieldError{{Field: "email_address", Rule: "email", Message: "must be a valid email address"}}
+		}
+		return nil
+	}
+}
+
+var e164Regex = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+
+// E164PhoneRule checks that the phone number is in E.164 format, e.g. +2348012345678.
+func E164PhoneRule() IdentityRule {
+	return func(identity Identity) []FieldError {
+		if identity.PhoneNumber == "" || e164Regex.MatchString(identity.PhoneNumber) {
+			return nil
 		}
-		if identity.DOB == nil {
-			return errors.New("DateOfBirth is required for Individual")
+		return []FieldError{{Field: "phone_number", Rule: "e164", Message: "must be an E.164 phone number such as +14155552671"}}
+	}
+}
+
+// normalizeCountry returns the ISO 3166-1 alpha-2 code for an alpha-2 or
+// alpha-3 code, or false when the code is unknown.
+func normalizeCountry(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := isoCountries[code]; ok {
+		return code, true
+	}
+	alpha2, ok := isoCountryAlpha3[code]
+	return alpha2, ok
+}
+
+// CountryRule checks that Country is an ISO 3166-1 alpha-2 or alpha-3 code.
+func CountryRule() IdentityRule {
+	return func(identity Identity) []FieldError {
+		if identity.Country == "" {
+			return nil
 		}
-		if identity.Gender == "" {
-			return errors.New("gender is required for Individual")
+		if _, ok := normalizeCountry(identity.Country); !ok {
+			return []FieldError{{Field: "country", Rule: "iso3166", Message: "must be an ISO 3166-1 country code"}}
 		}
+		return nil
+	}
+}
+
+// NationalityRule checks that Nationality is an ISO 3166-1 alpha-2 or alpha-3 code.
+func NationalityRule() IdentityRule {
+	return func(identity Identity) []FieldError {
 		if identity.Nationality == "" {
-			return errors.New("nationality is required for Individual")
+			return nil
 		}
-	} else if identity.IdentityType == Organization {
-		if identity.OrganizationName == "" {
-			return errors.New("organizationName is required for Organization")
+		if _, ok := normalizeCountry(identity.Nationality); !ok {
+			return []FieldError{{Field: "nationality", Rule: "iso3166", Message: "must be an ISO 3166-1 country code"}}
 		}
-	} else {
-		return errors.New("invalid IdentityType")
+		return nil
+	}
+}
+
+// GenderRule checks Gender against the allowed values, ignoring case.
+func GenderRule(allowed ...string) IdentityRule {
+	values := make(map[string]bool, len(allowed))
+	for _, value := range allowed {
+		values[strings.ToLower(value)] = true
+	}
+	return func(identity Identity) []FieldError {
+		if identity.Gender == "" || values[strings.ToLower(identity.Gender)] {
+			return nil
+		}
+		sorted := append([]string{}, allowed...)
+		sort.Strings(sorted)
+		return []FieldError{{Field: "gender", Rule: "gender", Message: "must be one of " + strings.Join(sorted, ", ")}}
+	}
+}
+
+// MinimumAgeRule checks that an individual's date of birth is not in the future
+// and that they are at least years old.
+func MinimumAgeRule(years int) IdentityRule {
+	return func(identity Identity) []FieldError {
+		if identity.IdentityType != Individual || identity.DOB == nil {
+			return nil
+		}
+		now := time.Now()
+		if identity.DOB.After(now) {
+			return []FieldError{{Field: "dob", Rule: "dob", Message: "can not be in the future"}}
+		}
+		if identity.DOB.AddDate(years, 0, 0).After(now) {
+			return []FieldError{{Field: "dob", Rule: "minimum_age", Message: fmt.Sprintf("must be at least %d years old", years)}}
+		}
+		return nil
+	}
+}
+
+// postCodeFormats holds the post code format of major countries, keyed by
+// ISO 3166-1 alpha-2 code. Countries not listed are not checked.
+var postCodeFormats = map[string]*regexp.Regexp{
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+	"CA": regexp.MustCompile(`(?i)^[ABCEGHJ-NPRSTVXY]\d[ABCEGHJ-NPRSTV-Z] ?\d[ABCEGHJ-NPRSTV-Z]\d$`),
+	"CN": regexp.MustCompile(`^\d{6}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`(?i)^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"IN": regexp.MustCompile(`^[1-9]\d{5}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"KE": regexp.MustCompile(`^\d{5}$`),
+	"MX": regexp.MustCompile(`^\d{5}$`),
+	"NG": regexp.MustCompile(`^\d{6}$`),
+	"NL": regexp.MustCompile(`(?i)^\d{4} ?[A-Z]{2}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"ZA": regexp.MustCompile(`^\d{4}$`),
+}
+
+// PostCodeRule checks PostCode against the format of the identity's Country.
+func PostCodeRule() IdentityRule {
+	return func(identity Identity) []FieldError {
+		if identity.PostCode == "" {
+			return nil
+		}
+		country, ok := normalizeCountry(identity.Country)
+		if !ok {
+			return nil
+		}
+		format, ok := postCodeFormats[country]
+		if !ok || format.MatchString(strings.TrimSpace(identity.PostCode)) {
+			return nil
+		}
+		return []FieldError{{Field: "post_code", Rule: "post_code", Message: fmt.Sprintf("is not a valid post code for %s", country)}}
 	}
-	return nil
 }
@@ -0,0 +1,136 @@
+package blnkgo_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func validIndividual() blnkgo.Identity {
+	dob := time.Date(1990, time.May, 17, 0, 0, 0, 0, time.UTC)
+	return blnkgo.Identity{
+		IdentityType: blnkgo.Individual,
+		FirstName:    "Ada",
+		LastName:     "Obi",
+		Gender:       "Female",
+		DOB:          &dob,
+		EmailAddress: "ada.obi@example.com",
+		PhoneNumber:  "+2348012345678",
+		Nationality:  "NG",
+		Category:     "customer",
+		Country:      "NGA",
+		PostCode:     "100001",
+	}
+}
+
+var strictValidator = blnkgo.NewIdentityValidator(blnkgo.StrictIdentityRules()...)
+
+func TestValidateCreateIdentity_Valid(t *testing.T) {
+	assert.NoError(t, blnkgo.ValidateCreateIdentity(validIndividual()))
+	assert.NoError(t, strictValidator.Validate(validIndividual()))
+
+	organization := blnkgo.Identity{
+		IdentityType:     blnkgo.Organization,
+		OrganizationName: "ACME Inc",
+		Country:          "GB",
+		PostCode:         "SW1A 1AA",
+	}
+	assert.NoError(t, blnkgo.ValidateCreateIdentity(organization))
+	assert.NoError(t, strictValidator.Validate(organization))
+}
+
+func TestValidateCreateIdentity_DefaultIsLenient(t *testing.T) {
+	identity := validIndividual()
+	identity.PhoneNumber = "1234567890"
+	identity.Nationality = "Nigerian"
+	identity.Gender = "Non-binary"
+	dob := time.Now().AddDate(-10, 0, 0)
+	identity.DOB = &dob
+
+	assert.NoError(t, blnkgo.ValidateCreateIdentity(identity))
+	assert.Error(t, strictValidator.Validate(identity))
+
+	identity.FirstName = ""
+	err := blnkgo.ValidateCreateIdentity(identity)
+	var validationErrs blnkgo.ValidationErrors
+	assert.True(t, errors.As(err, &validationErrs))
+	assert.Equal(t, []string{"first_name"}, validationErrs.Fields())
+}
+
+func TestValidateCreateIdentity_ReportsAllErrors(t *testing.T) {
+	future := time.Now().AddDate(1, 0, 0)
+	identity := validIndividual()
+	identity.EmailAddress = "ada.obi@"
+	identity.PhoneNumber = "08012345678"
+	identity.DOB = &future
+	identity.Country = "Nigeria"
+	identity.Nationality = "XX"
+	identity.Gender = "unknown"
+	identity.LastName = ""
+
+	err := strictValidator.Validate(identity)
+
+	var validationErrs blnkgo.ValidationErrors
+	assert.True(t, errors.As(err, &validationErrs))
+	assert.ElementsMatch(t, []string{
+		"last_name", "email_address", "phone_number", "country", "nationality", "gender", "dob",
+	}, validationErrs.Fields())
+	assert.Contains(t, err.Error(), "LastName is required for Individual")
+}
+
+func TestValidateCreateIdentity_Rules(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*blnkgo.Identity)
+		field  string
+	}{
+		{name: "invalid identity type", modify: func(i *blnkgo.Identity) { i.IdentityType = "robot" }, field: "identity_type"},
+		{name: "email with display name", modify: func(i *blnkgo.Identity) { i.EmailAddress = "Ada <ada@example.com>" }, field: "email_address"},
+		{name: "email without tld", modify: func(i *blnkgo.Identity) { i.EmailAddress = "ada@localhost" }, field: "email_address"},
+		{name: "phone too long", modify: func(i *blnkgo.Identity) { i.PhoneNumber = "+1234567890123456" }, field: "phone_number"},
+		{name: "underage", modify: func(i *blnkgo.Identity) {
+			dob := time.Now().AddDate(-10, 0, 0)
+			i.DOB = &dob
+		}, field: "dob"},
+		{name: "us post code", modify: func(i *blnkgo.Identity) { i.Country, i.PostCode = "US", "ABCDE" }, field: "post_code"},
+		{name: "nigerian post code", modify: func(i *blnkgo.Identity) { i.PostCode = "1000" }, field: "post_code"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity := validIndividual()
+			tt.modify(&identity)
+
+			err := strictValidator.Validate(identity)
+
+			var validationErrs blnkgo.ValidationErrors
+			assert.True(t, errors.As(err, &validationErrs))
+			assert.Contains(t, validationErrs.Fields(), tt.field)
+		})
+	}
+}
+
+func TestIdentityValidator_CategoryRules(t *testing.T) {
+	validator := blnkgo.NewIdentityValidator(blnkgo.StrictIdentityRules()...)
+	validator.Register("merchant", func(identity blnkgo.Identity) []blnkgo.FieldError {
+		if identity.Street == "" {
+			return []blnkgo.FieldError{{Field: "street", Rule: "required", Message: "street is required for merchants"}}
+		}
+		return nil
+	})
+
+	customer := validIndividual()
+	assert.NoError(t, validator.Validate(customer))
+
+	merchant := validIndividual()
+	merchant.Category = "merchant"
+	err := validator.Validate(merchant)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "street is required for merchants")
+
+	merchant.Street = "1 Marina Road"
+	assert.NoError(t, validator.Validate(merchant))
+}
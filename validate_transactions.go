@@ -34,15 +34,27 @@ func ValidateCreateTransacation(t CreateTransactionRequest) error {
 		return errors.New(sb.String())
 	}
 
-	if len(t.Sources) > 0 && (t.PreciseAmount == nil || t.PreciseAmount.Cmp(big.NewInt(0)) == 0) {
-		err := validateSources(t.Sources, t.Amount, &sb)
+	precise := t.PreciseAmount != nil && t.PreciseAmount.Sign() != 0
+
+	if len(t.Sources) > 0 {
+		var err error
+		if precise {
+			err = validatePreciseSources(t.Sources, t.PreciseAmount, t.Precision, &sb)
+		} else {
+			err = validateSources(t.Sources, t.Amount, &sb)
+		}
 		if err != nil {
 			return err
 		}
 	}
 
-	if len(t.Destinations) > 0 && (t.PreciseAmount == nil || t.PreciseAmount.Cmp(big.NewInt(0)) == 0) {
-		err := validateSources(t.Destinations, t.Amount, &sb)
+	if len(t.Destinations) > 0 {
+		var err error
+		if precise {
+			err = validatePreciseSources(t.Destinations, t.PreciseAmount, t.Precision, &sb)
+		} else {
+			err = validateSources(t.Destinations, t.Amount, &sb)
+		}
 		if err != nil {
 			return err
 		}
@@ -51,6 +63,71 @@ func ValidateCreateTransacation(t CreateTransactionRequest) error {
 	return nil
 }
 
+// validatePreciseSources is validateSources for a transaction given in minor
+// units: fixed distributions are in major units like Amount, so they are
+// scaled by precision, and the sums are exact.
+func validatePreciseSources(sources []Source, amount *big.Int, precision int64, sb *strings.Builder) error {
+	if precision <= 0 {
+		precision = 1
+	}
+	total := new(big.Rat)
+	hasLeft := false
+	for _, source := range sources {
+		distribution := source.Distribution
+		if !distribution.IsValid() {
+			sb.WriteString("invalid distribution: " + string(distribution))
+			return errors.New(sb.String())
+		}
+
+		switch {
+		case distribution.IsPercentage():
+			percentage, ok := new(big.Rat).SetString(string(distribution[:len(distribution)-1]))
+			if !ok || percentage.Sign() < 0 {
+				sb.WriteString("invalid distribution in source: " + source.Identifier)
+				return errors.New(sb.String())
+			}
+			share := new(big.Rat).Mul(percentage, new(big.Rat).SetInt(amount))
+			total.Add(total, share.Quo(share, big.NewRat(100, 1)))
+
+		case distribution.IsNumber():
+			number, ok := new(big.Rat).SetString(string(distribution))
+			if !ok || number.Sign() < 0 {
+				sb.WriteString("invalid distribution in source: " + source.Identifier)
+				return errors.New(sb.String())
+			}
+			total.Add(total, number.Mul(number, big.NewRat(precision, 1)))
+
+		case distribution.IsLeft():
+			if hasLeft {
+				sb.WriteString("you cannot use left distribution more than once")
+				return errors.New(sb.String())
+			}
+			hasLeft = true
+
+		default:
+			sb.WriteString("unknown distribution type in source: " + source.Identifier)
+			return errors.New(sb.String())
+		}
+	}
+
+	switch total.Cmp(new(big.Rat).SetInt(amount)) {
+	case 1:
+		if hasLeft {
+			sb.WriteString("total amount of sources exceeds the amount")
+		} else {
+			sb.WriteString("total amount of sources must be equal to the amount")
+		}
+		return errors.New(sb.String())
+	case -1:
+		if !hasLeft {
+			sb.WriteString("total amount of sources must be equal to the amount")
+			return errors.New(sb.String())
+		}
+	}
+
+	return nil
+}
+
 func validateSources(sources []Source, amount float64, sb *strings.Builder) error {
 	//total amount of sources  must be equal to the amount
 	total := 0.0
@@ -0,0 +1,27 @@
+package blnkgo_test
+
+import (
+	"math/big"
+	"testing"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCreateTransacation_PreciseDistributions(t *testing.T) {
+	request := func(distributions ...blnkgo.Distribution) blnkgo.CreateTransactionRequest {
+		var sources []blnkgo.Source
+		for _, d := range distributions {
+			sources = append(sources, blnkgo.Source{Identifier: "@s", Distribution: d})
+		}
+		return blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+			PreciseAmount: big.NewInt(1001), Precision: 100, Currency: "USD", Sources: sources, Destination: "@d",
+		}}
+	}
+
+	assert.NoError(t, blnkgo.ValidateCreateTransacation(request("50%", "5.005")))
+	assert.NoError(t, blnkgo.ValidateCreateTransacation(request("10", "left")))
+	assert.ErrorContains(t, blnkgo.ValidateCreateTransacation(request("50%", "5")), "must be equal to the amount")
+	assert.ErrorContains(t, blnkgo.ValidateCreateTransacation(request("10.02", "left")), "exceeds the amount")
+	assert.ErrorContains(t, blnkgo.ValidateCreateTransacation(request("left", "left")), "left distribution more than once")
+}
@@ -0,0 +1,385 @@
+package blnkgo
+
+import (
+	"iter"
+	"net/http"
+	"time"
+)
+
+// WatchOptions configures TransactionService.Watch and
+// LedgerBalanceService.Watch.
+type WatchOptions struct {
+	// Filters restricts the records watched.
+	Filters []Filter
+	// PageSize is the number of records fetched per request. Defaults to 100.
+	PageSize int
+	// PollInterval is the wait between polls. Defaults to 1s.
+	PollInterval time.Duration
+	// MaxPollInterval caps the wait, which doubles after every poll that
+	// finds nothing or fails. Defaults to 30s.
+	MaxPollInterval time.Duration
+	// Lookback is how far before the cursor each poll reads again, to catch
+	// records committed out of created_at order. Records already emitted in
+	// that window are remembered in the cursor and skipped. Defaults to 5s.
+	Lookback time.Duration
+	// StopWhenIdle ends the watch at the first poll that finds nothing, for
+	// jobs that catch up and exit.
+	StopWhenIdle bool
+	// Stop ends the watch when it is closed, also in the middle of a wait,
+	// for consumers that are not inside the loop to break out of it.
+	Stop <-chan struct{}
+}
+
+func (o WatchOptions) withDefaults() WatchOptions {
+	if o.PageSize <= 0 {
+		o.PageSize = filterPageSize
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	if o.MaxPollInterval < o.PollInterval {
+		o.MaxPollInterval = max(30*time.Second, o.PollInterval)
+	}
+	if o.Lookback <= 0 {
+		o.Lookback = 5 * time.Second
+	}
+	return o
+}
+
+// WatchCursor is the position of a watch: the newest created_at emitted, and
+// the records emitted within the lookback window before it so they are not
+// emitted again. It marshals to JSON for storage.
+type WatchCursor struct {
+	Since time.Time   `json:"since"`
+	Seen  []WatchSeen `json:"seen,omitempty"`
+}
+
+// WatchSeen is a record remembered by a WatchCursor.
+type WatchSeen struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TransactionEvent is a transaction emitted by TransactionService.Watch,
+// with the cursor to resume after it.
+type TransactionEvent struct {
+	Transaction Transaction
+	Cursor      WatchCursor
+}
+
+// BalanceWatchCursor is the position of a LedgerBalanceService.Watch, which
+// follows both new balances and the transactions that change balances.
+type BalanceWatchCursor struct {
+	Balances     WatchCursor `json:"balances"`
+	Transactions WatchCursor `json:"transactions"`
+}
+
+// BalanceEvent is a balance emitted by LedgerBalanceService.Watch, with the
+// cursor to resume after it.
+type BalanceEvent struct {
+	Balance LedgerBalance
+	Cursor  BalanceWatchCursor
+}
+
+// Watch polls for transactions matching options.Filters, in created_at
+// order, starting after from or at the oldest transaction if from is nil.
+// Pass &WatchCursor{Since: time.Now()} to see only new transactions. Status
+// changes such as inflight commits are recorded by Blnk as new transactions,
+// so they are emitted too.
+//
+// Delivery is at least once: store the cursor of an event after handling it
+// and pass it back to resume, and events after it are emitted again. Errors
+// are yielded with a zero event and polling continues after the backoff;
+// break out of the loop or close options.Stop to stop.
+func (s *TransactionService) Watch(from *WatchCursor, options WatchOptions) iter.Seq2[TransactionEvent, error] {
+	return func(yield func(TransactionEvent, error) bool) {
+		options := options.withDefaults()
+		feed := newWatchFeed(s.Filter, options, from, func(t *Transaction) (string, time.Time) {
+			return t.TransactionID, t.CreatedAt
+		})
+		watchLoop(options, func() (int, bool, error) {
+			return feed.poll(func(records []Transaction, cursors []WatchCursor) bool {
+				for i := range records {
+					if !yield(TransactionEvent{Transaction: records[i], Cursor: cursors[i]}, nil) {
+						return false
+					}
+				}
+				return true
+			})
+		}, func(err error) bool {
+			return yield(TransactionEvent{}, err)
+		})
+	}
+}
+
+// Watch polls for new balances and for balances changed by transactions,
+// emitting the current state of each balance matching options.Filters.
+// Balances carry no update time, so changes are found through the
+// transactions recorded since the cursor: every balance a transaction moves
+// is fetched and emitted, once per poll however many transactions moved it.
+// Resuming and errors work as in TransactionService.Watch.
+func (s *LedgerBalanceService) Watch(from *BalanceWatchCursor, options WatchOptions) iter.Seq2[BalanceEvent, error] {
+	return func(yield func(BalanceEvent, error) bool) {
+		options := options.withDefaults()
+		var cursor BalanceWatchCursor
+		if from != nil {
+			cursor = *from
+		}
+		balances := newWatchFeed(s.Filter, options, &cursor.Balances, func(b *LedgerBalance) (string, time.Time) {
+			return b.BalanceID, b.CreatedAt
+		})
+		// the balance filters do not apply to transactions, only to the
+		// balances they move
+		transactionOptions := options
+		transactionOptions.Filters = nil
+		transactions := newWatchFeed(NewTransactionService(s.client).Filter, transactionOptions, &cursor.Transactions, func(t *Transaction) (string, time.Time) {
+			return t.TransactionID, t.CreatedAt
+		})
+
+		watchLoop(options, func() (int, bool, error) {
+			// emitted holds the versions emitted during this poll
+			emitted := make(map[string]int64)
+			emit := func(balance LedgerBalance) bool {
+				if version, ok := emitted[balance.BalanceID]; ok && version >= balance.Version {
+					return true
+				}
+				emitted[balance.BalanceID] = balance.Version
+				return yield(BalanceEvent{Balance: balance, Cursor: cursor}, nil)
+			}
+
+			created, stopped, err := balances.poll(func(records []LedgerBalance, cursors []WatchCursor) bool {
+				for i := range records {
+					cursor.Balances = cursors[i]
+					if !emit(records[i]) {
+						return false
+					}
+				}
+				return true
+			})
+			if stopped || err != nil {
+				return created, stopped, err
+			}
+
+			changed, stopped, err := transactions.poll(func(records []Transaction, cursors []WatchCursor) bool {
+				var ids []interface{}
+				moved := make(map[string]bool)
+				for _, t := range records {
+					for _, id := range []string{t.Source, t.Destination} {
+						if id != "" && !moved[id] {
+							moved[id] = true
+							ids = append(ids, id)
+						}
+					}
+				}
+				if len(ids) == 0 {
+					return true
+				}
+				var page []LedgerBalance
+				err := filterEach(s.Filter, FilterParams{
+					Filters: append([]Filter{{Field: "balance_id", Operator: OpIn, Values: ids}}, options.Filters...),
+					Limit:   options.PageSize,
+				}, func(balances []LedgerBalance) error {
+					page = append(page, balances...)
+					return nil
+				})
+				if err != nil {
+					// the transaction cursor has not moved, so the page is
+					// read again at the next poll
+					transactions.fail(err)
+					return false
+				}
+				// the cursor only moves past the transactions with the last
+				// balance they moved, so every balance is emitted at least
+				// once after a restart
+				for i, balance := range page {
+					if i == len(page)-1 {
+						cursor.Transactions = cursors[len(cursors)-1]
+					}
+					if !emit(balance) {
+						return false
+					}
+				}
+				return true
+			})
+			return created + changed, stopped, err
+		}, func(err error) bool {
+			return yield(BalanceEvent{}, err)
+		})
+	}
+}
+
+// watchLoop calls poll until it reports that the consumer stopped or
+// options.Stop is closed, waiting options.PollInterval between polls and
+// backing off while polls find nothing or fail. Errors are passed to
+// yieldErr, which reports whether to go on.
+func watchLoop(options WatchOptions, poll func() (found int, stopped bool, err error), yieldErr func(error) bool) {
+	wait := options.PollInterval
+	for {
+		select {
+		case <-options.Stop:
+			return
+		default:
+		}
+		found, stopped, err := poll()
+		switch {
+		case stopped:
+			return
+		case err != nil:
+			if !yieldErr(err) {
+				return
+			}
+		case found > 0:
+			wait = options.PollInterval
+			if !watchSleep(options.Stop, wait) {
+				return
+			}
+			continue
+		case options.StopWhenIdle:
+			return
+		}
+		if !watchSleep(options.Stop, wait) {
+			return
+		}
+		wait = min(2*wait, options.MaxPollInterval)
+	}
+}
+
+// watchSleep waits for d, reporting false if stop was closed first. A nil
+// stop never closes.
+func watchSleep(stop <-chan struct{}, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// watchFeed reads the records created since its cursor, in created_at order,
+// skipping those it has already returned.
+type watchFeed[T any] struct {
+	filter   func(FilterParams) (*FilterResponse, *http.Response, error)
+	filters  []Filter
+	pageSize int
+	lookback time.Duration
+	key      func(*T) (id string, createdAt time.Time)
+
+	since time.Time
+	// seen only grows by appending, or is replaced by prune, so the cursors
+	// handed out can share it
+	seen []WatchSeen
+	ids  map[string]bool
+	err  error
+}
+
+func newWatchFeed[T any](filter func(FilterParams) (*FilterResponse, *http.Response, error), options WatchOptions, from *WatchCursor, key func(*T) (string, time.Time)) *watchFeed[T] {
+	f := &watchFeed[T]{
+		filter:   filter,
+		filters:  options.Filters,
+		pageSize: options.PageSize,
+		lookback: options.Lookback,
+		key:      key,
+	}
+	if from != nil {
+		f.since = from.Since
+		f.seen = from.Seen[:len(from.Seen):len(from.Seen)]
+	}
+	return f
+}
+
+// fail makes the running poll return err and read the current page again
+// next time.
+func (f *watchFeed[T]) fail(err error) {
+	f.err = err
+}
+
+// prune forgets the records created before the lookback window of the
+// cursor, which no poll reads again. It copies what is left, since the cursors
+// handed out share the old slice. Records are mostly seen in created_at
+// order, so while since advances poll only prunes once the oldest one has
+// left the window.
+func (f *watchFeed[T]) prune() {
+	window := f.since.Add(-f.lookback)
+	seen := make([]WatchSeen, 0, len(f.seen))
+	for _, s := range f.seen {
+		if s.CreatedAt.Before(window) {
+			delete(f.ids, s.ID)
+		} else {
+			seen = append(seen, s)
+		}
+	}
+	f.seen = seen
+}
+
+// poll reads every page of records created since the cursor, less the
+// lookback, and passes the records not returned before to fn along with the
+// cursor after each. It returns the number of such records and whether fn
+// returned false.
+func (f *watchFeed[T]) poll(fn func(records []T, cursors []WatchCursor) bool) (int, bool, error) {
+	window := f.since.Add(-f.lookback)
+	f.ids = make(map[string]bool, len(f.seen))
+	for _, s := range f.seen {
+		f.ids[s.ID] = true
+	}
+	f.prune()
+	f.err = nil
+
+	params := FilterParams{
+		Filters:   append([]Filter{}, f.filters...),
+		Limit:     f.pageSize,
+		SortBy:    "created_at",
+		SortOrder: "asc",
+	}
+	if !f.since.IsZero() {
+		params.Filters = append(params.Filters, Filter{Field: "created_at", Operator: OpGreaterThanOrEqual, Value: window.Format(time.RFC3339Nano)})
+	}
+
+	found := 0
+	for {
+		response, _, err := f.filter(params)
+		if err != nil {
+			return found, false, err
+		}
+		var page []T
+		if err := response.DecodeData(&page); err != nil {
+			return found, false, err
+		}
+
+		since, seen := f.since, f.seen[:len(f.seen):len(f.seen)]
+		var records []T
+		var cursors []WatchCursor
+		for i := range page {
+			id, createdAt := f.key(&page[i])
+			if f.ids[id] {
+				continue
+			}
+			f.ids[id] = true
+			f.seen = append(f.seen, WatchSeen{ID: id, CreatedAt: createdAt})
+			if createdAt.After(f.since) {
+				f.since = createdAt
+				if f.seen[0].CreatedAt.Before(f.since.Add(-f.lookback)) {
+					f.prune()
+				}
+			}
+			records = append(records, page[i])
+			cursors = append(cursors, WatchCursor{Since: f.since, Seen: f.seen[:len(f.seen):len(f.seen)]})
+		}
+		if len(records) > 0 {
+			found += len(records)
+			if !fn(records, cursors) {
+				if f.err != nil {
+					// forget the page so the next poll reads it again
+					f.since, f.seen = since, seen
+					return found - len(records), false, f.err
+				}
+				return found, true, nil
+			}
+		}
+		if len(page) < params.Limit {
+			return found, false, nil
+		}
+		params.Offset += params.Limit
+	}
+}
@@ -0,0 +1,211 @@
+package blnkgo_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	blnkgo "github.com/blnkfinance/blnk-go"
+	"github.com/blnkfinance/blnk-go/blnktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// watchClock is a clock for the fake server that tests move by hand.
+type watchClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *watchClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *watchClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func newWatchServer(t *testing.T) (*blnktest.Server, *blnkgo.Client, *watchClock) {
+	server := blnktest.NewServer(t)
+	clock := &watchClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	server.SetClock(clock.Now)
+	return server, server.Client(), clock
+}
+
+func createWatched(t *testing.T, client *blnkgo.Client, reference, source, destination string) {
+	t.Helper()
+	_, _, err := client.Transaction.Create(blnkgo.CreateTransactionRequest{ParentTransaction: blnkgo.ParentTransaction{
+		Reference: reference, Amount: 10, Precision: 100, Currency: "USD", Source: source, Destination: destination,
+	}})
+	require.NoError(t, err)
+}
+
+var watchOptions = blnkgo.WatchOptions{PageSize: 2, PollInterval: time.Millisecond, StopWhenIdle: true}
+
+func watchTransactions(t *testing.T, client *blnkgo.Client, from *blnkgo.WatchCursor) ([]string, []blnkgo.WatchCursor) {
+	t.Helper()
+	var references []string
+	var cursors []blnkgo.WatchCursor
+	for event, err := range client.Transaction.Watch(from, watchOptions) {
+		require.NoError(t, err)
+		references = append(references, event.Transaction.Reference)
+		cursors = append(cursors, event.Cursor)
+	}
+	return references, cursors
+}
+
+func TestTransactionService_Watch(t *testing.T) {
+	_, client, clock := newWatchServer(t)
+	for i := 1; i <= 3; i++ {
+		createWatched(t, client, fmt.Sprintf("w-%d", i), "@world", "@wallet")
+	}
+
+	references, cursors := watchTransactions(t, client, nil)
+	assert.Equal(t, []string{"w-1", "w-2", "w-3"}, references)
+
+	// resume after w-2 from a stored cursor; the transactions sharing its
+	// created_at are remembered and not emitted again
+	stored, err := json.Marshal(cursors[1])
+	require.NoError(t, err)
+	var from blnkgo.WatchCursor
+	require.NoError(t, json.Unmarshal(stored, &from))
+
+	clock.Set(clock.Now().Add(time.Second))
+	createWatched(t, client, "w-4", "@world", "@wallet")
+	// committed late with an earlier created_at, inside the lookback window
+	clock.Set(clock.Now().Add(-500 * time.Millisecond))
+	createWatched(t, client, "w-5", "@world", "@wallet")
+
+	references, cursors = watchTransactions(t, client, &from)
+	assert.Equal(t, []string{"w-3", "w-5", "w-4"}, references)
+
+	last := cursors[len(cursors)-1]
+	references, _ = watchTransactions(t, client, &last)
+	assert.Empty(t, references)
+}
+
+func TestTransactionService_WatchForgetsOutsideLookback(t *testing.T) {
+	_, client, clock := newWatchServer(t)
+	for i := 1; i <= 5; i++ {
+		createWatched(t, client, fmt.Sprintf("w-%d", i), "@world", "@wallet")
+		clock.Set(clock.Now().Add(10 * time.Second))
+	}
+
+	// a single poll pages through everything; the cursor only remembers the
+	// records within the lookback window of its since
+	references, cursors := watchTransactions(t, client, nil)
+	assert.Equal(t, []string{"w-1", "w-2", "w-3", "w-4", "w-5"}, references)
+	for i, cursor := range cursors {
+		assert.Len(t, cursor.Seen, 1, references[i])
+	}
+}
+
+func TestTransactionService_WatchErrors(t *testing.T) {
+	server, client, _ := newWatchServer(t)
+	createWatched(t, client, "w-1", "@world", "@wallet")
+	server.FailNext(http.MethodPost, "/transactions/filter", http.StatusBadRequest)
+
+	var errs int
+	var references []string
+	for event, err := range client.Transaction.Watch(nil, watchOptions) {
+		if err != nil {
+			errs++
+			continue
+		}
+		references = append(references, event.Transaction.Reference)
+	}
+	assert.Equal(t, 1, errs)
+	assert.Equal(t, []string{"w-1"}, references)
+
+	// breaking out of the loop stops the watch
+	options := watchOptions
+	options.StopWhenIdle = false
+	for event, err := range client.Transaction.Watch(nil, options) {
+		require.NoError(t, err)
+		assert.Equal(t, "w-1", event.Transaction.Reference)
+		break
+	}
+}
+
+func TestTransactionService_WatchStop(t *testing.T) {
+	_, client, _ := newWatchServer(t)
+	createWatched(t, client, "w-1", "@world", "@wallet")
+
+	stop := make(chan struct{})
+	options := watchOptions
+	options.StopWhenIdle = false
+	options.PollInterval, options.MaxPollInterval = time.Hour, time.Hour
+	options.Stop = stop
+	done := make(chan []string)
+	go func() {
+		var references []string
+		for event, err := range client.Transaction.Watch(nil, options) {
+			assert.NoError(t, err)
+			references = append(references, event.Transaction.Reference)
+		}
+		done <- references
+	}()
+
+	// the watch is waiting out the hour-long poll interval when stopped
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	select {
+	case references := <-done:
+		assert.Equal(t, []string{"w-1"}, references)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watch did not stop")
+	}
+}
+
+func TestLedgerBalanceService_Watch(t *testing.T) {
+	server, client, clock := newWatchServer(t)
+	ledger, _, err := client.Ledger.Create(blnkgo.CreateLedgerRequest{Name: "Wallets"})
+	require.NoError(t, err)
+	other, _, err := client.Ledger.Create(blnkgo.CreateLedgerRequest{Name: "Other"})
+	require.NoError(t, err)
+	alice, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: ledger.LedgerID, Currency: "USD"})
+	require.NoError(t, err)
+	bob, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: ledger.LedgerID, Currency: "USD"})
+	require.NoError(t, err)
+	outsider, _, err := client.LedgerBalance.Create(blnkgo.CreateLedgerBalanceRequest{LedgerID: other.LedgerID, Currency: "USD"})
+	require.NoError(t, err)
+
+	options := watchOptions
+	options.Filters = []blnkgo.Filter{{Field: "ledger_id", Operator: blnkgo.OpEqual, Value: ledger.LedgerID}}
+	watch := func(from *blnkgo.BalanceWatchCursor) ([]string, *blnkgo.BalanceWatchCursor) {
+		var seen []string
+		var cursor *blnkgo.BalanceWatchCursor
+		for event, err := range client.LedgerBalance.Watch(from, options) {
+			require.NoError(t, err)
+			seen = append(seen, fmt.Sprintf("%s@%d", event.Balance.BalanceID, event.Balance.Version))
+			cursor = &event.Cursor
+		}
+		return seen, cursor
+	}
+
+	seen, cursor := watch(nil)
+	assert.Equal(t, []string{alice.BalanceID + "@0", bob.BalanceID + "@0"}, seen)
+
+	clock.Set(clock.Now().Add(time.Minute))
+	createWatched(t, client, "b-1", "@world", alice.BalanceID)
+	createWatched(t, client, "b-2", alice.BalanceID, bob.BalanceID)
+	createWatched(t, client, "b-3", "@world", outsider.BalanceID)
+
+	aliceNow, _ := server.Balance(alice.BalanceID)
+	bobNow, _ := server.Balance(bob.BalanceID)
+	seen, cursor = watch(cursor)
+	assert.ElementsMatch(t, []string{
+		fmt.Sprintf("%s@%d", alice.BalanceID, aliceNow.Version),
+		fmt.Sprintf("%s@%d", bob.BalanceID, bobNow.Version),
+	}, seen, "each balance once, at its current version")
+
+	seen, _ = watch(cursor)
+	assert.Empty(t, seen)
+}